@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	config "salesTracker/internal/config"
+)
+
+// ====================================================================
+// COMMANDS - Подкоманды бинарника
+// ====================================================================
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) int
+}
+
+// commands — подкоманды в порядке справки; без подкоманды выполняется serve
+var commands = []command{
+	{"serve", "run the HTTP API (default)", runServe},
+	{"migrate", "apply, roll back or list schema migrations", runMigrate},
+	{"seed", "load reproducible test data into an empty database", runSeed},
+	{"report", "print the sales report for a period", runReport},
+	{"import", "import historical orders from CSV or JSONL", runImport},
+	{"export", "stream a table as NDJSON, CSV or Parquet", runExport},
+	{"check-config", "load and validate the configuration", runCheckConfig},
+}
+
+// dispatch - выполнить подкоманду из args (без имени бинарника), вернуть код выхода
+func dispatch(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "help", "-h", "-help", "--help":
+			printUsage(os.Stdout)
+			return 0
+		}
+	}
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return runServe(args)
+	}
+
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(args[1:])
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+	printUsage(os.Stderr)
+	return 2
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: salesTracker <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-14s %s\n", c.name, c.usage)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "run 'salesTracker <command> -h' for the flags of a command")
+}
+
+// ====================================================================
+// CONFIG FLAGS - Общие флаги конфигурации
+// ====================================================================
+
+// configFlags - флаги конфигурации, общие для подкоманд, которым нужна конфигурация
+type configFlags struct {
+	sources config.Sources
+}
+
+func addConfigFlags(fs *flag.FlagSet) *configFlags {
+	var cf configFlags
+	fs.StringVar(&cf.sources.File, "config", "", "YAML config file (default: <config-dir>/<ENV>.yaml if it exists)")
+	fs.StringVar(&cf.sources.Dir, "config-dir", config.DefaultDir, "directory of <ENV>.yaml config files")
+	fs.StringVar(&cf.sources.EnvFile, "env-file", "", "file with environment variables (default: "+config.DefaultEnvFile+" if it exists)")
+	fs.Func("set", "override a config key, repeatable: -set server.port=8081", func(value string) error {
+		cf.sources.Overrides = append(cf.sources.Overrides, value)
+		return nil
+	})
+	return &cf
+}
+
+// load - конфигурация по флагам; ошибка печатается с именем подкоманды
+func (cf *configFlags) load(name string) (*config.Config, bool) {
+	cfg, err := config.Load(cf.sources)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return nil, false
+	}
+	return cfg, true
+}
+
+// setupLogging - уровень и формат журнала; log.Printf пишет через тот же обработчик
+func setupLogging(cfg config.Log) {
+	var level slog.Level
+	level.UnmarshalText([]byte(cfg.Level))
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler = slog.NewTextHandler(os.Stderr, opts)
+	if cfg.Format == "json" {
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	}
+	slog.SetDefault(slog.New(handler))
+}
+
+// ====================================================================
+// SERVE, CHECK-CONFIG
+// ====================================================================
+
+// runServe - подкоманда serve: HTTP API на порту SERVER_PORT
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	cf := addConfigFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	cfg, ok := cf.load("serve")
+	if !ok {
+		return 1
+	}
+	setupLogging(cfg.Log)
+
+	// SIGTERM при деплое: дождаться начатых запросов, остановить фоновые задачи, закрыть базу
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	storage, err := NewSalesService(ctx, cfg)
+	if err != nil {
+		log.Printf("serve: %v", err)
+		return 1
+	}
+	defer storage.DB.Close()
+	app, err := NewApp(cfg, storage)
+	if err != nil {
+		log.Printf("serve: %v", err)
+		return 1
+	}
+	workers := app.RunWorkers(ctx, cfg)
+
+	code := 0
+	if err := Run(ctx, cfg.Server, app); err != nil {
+		log.Printf("serve: %v", err)
+		code = 1
+	}
+
+	// сервер мог завершиться и без сигнала, например, если порт занят
+	stop()
+	if !waitTimeout(workers, cfg.Server.ShutdownTimeout) {
+		log.Printf("serve: background workers did not stop within %s", cfg.Server.ShutdownTimeout)
+		code = 1
+	}
+
+	log.Printf("stopped")
+	return code
+}
+
+// waitTimeout - дождаться wg не дольше timeout; false - не дождались
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// runCheckConfig - подкоманда check-config: загрузить и проверить конфигурацию,
+// с -ping - еще и подключиться к базе, с -dump - вывести ее без секретов
+func runCheckConfig(args []string) int {
+	fs := flag.NewFlagSet("check-config", flag.ContinueOnError)
+	cf := addConfigFlags(fs)
+	ping := fs.Bool("ping", false, "also connect to the database")
+	dump := fs.Bool("dump", false, "print the effective config as YAML with secrets redacted")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	cfg, ok := cf.load("check-config")
+	if !ok {
+		return 1
+	}
+
+	if *ping {
+		storage, err := NewSalesService(context.Background(), cfg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "check-config: database:", err)
+			return 1
+		}
+		storage.DB.Close()
+	}
+
+	if *dump {
+		if err := cfg.Dump(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "check-config:", err)
+			return 1
+		}
+		return 0
+	}
+
+	fmt.Printf("config OK: env %s, port %s, database %s@%s:%s/%s\n",
+		cfg.Env, cfg.Server.Port, cfg.Database.User, cfg.Database.Host, cfg.Database.Port, cfg.Database.Name)
+	return 0
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// capture — вывод fn в stdout и stderr
+func capture(t *testing.T, fn func()) (stdout, stderr string) {
+	t.Helper()
+
+	read := func(target **os.File) (restore func() string) {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		saved := *target
+		*target = w
+		out := make(chan string)
+		go func() {
+			data, _ := io.ReadAll(r)
+			out <- string(data)
+		}()
+		return func() string {
+			*target = saved
+			w.Close()
+			return <-out
+		}
+	}
+
+	restoreOut := read(&os.Stdout)
+	restoreErr := read(&os.Stderr)
+	fn()
+	return restoreOut(), restoreErr()
+}
+
+// validConfig — флаги конфигурации, с которыми check-config проходит без базы
+func validConfig(t *testing.T) []string {
+	return []string{
+		"-config-dir", t.TempDir(), "-env-file", os.DevNull,
+		"-set", "database.driver=postgres", "-set", "database.host=db.internal", "-set", "database.port=5432",
+		"-set", "database.user=sales", "-set", "database.name=sales", "-set", "auth.jwt_secret=s3cret-value",
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{name: "help", args: []string{"help"}, wantCode: 0, wantStdout: "check-config"},
+		{name: "unknown command", args: []string{"frobnicate"}, wantCode: 2, wantStderr: `unknown command "frobnicate"`},
+		{name: "serve extra argument", args: []string{"serve", "now"}, wantCode: 2},
+		{name: "serve unknown flag", args: []string{"-port", "8081"}, wantCode: 2},
+
+		// ошибки аргументов отклоняются до подключения к базе
+		{name: "migrate without command", args: []string{"migrate"}, wantCode: 2},
+		{name: "migrate unknown command", args: []string{"migrate", "sideways"}, wantCode: 2},
+		{name: "migrate zero steps", args: []string{"migrate", "down", "-steps", "0"}, wantCode: 2},
+		{name: "seed bad date", args: []string{"seed", "-from", "2024/01/01"}, wantCode: 2, wantStderr: "invalid -from"},
+		{name: "report without period", args: []string{"report", "-start", "2024-01-01"}, wantCode: 2},
+		{name: "report unknown format", args: []string{"report", "-start", "2024-01-01", "-end", "2024-01-31", "-format", "xml"},
+			wantCode: 2, wantStderr: `unknown format "xml"`},
+		{name: "report unknown zone", args: []string{"report", "-start", "2024-01-01", "-end", "2024-01-31", "-tz", "Mars/Base"},
+			wantCode: 2, wantStderr: "invalid -tz"},
+		{name: "report bad date", args: []string{"report", "-start", "01.01.2024", "-end", "2024-01-31"},
+			wantCode: 2, wantStderr: "invalid -start"},
+		{name: "import without file", args: []string{"import"}, wantCode: 2},
+		{name: "import unknown extension", args: []string{"import", "orders.xlsx"}, wantCode: 2, wantStderr: "cannot tell the format"},
+		{name: "import unknown format", args: []string{"import", "-format", "xml", "orders.csv"}, wantCode: 2, wantStderr: `unknown format "xml"`},
+		{name: "import dry run resume", args: []string{"import", "-dry-run", "-resume", "3", "orders.csv"},
+			wantCode: 2, wantStderr: "-dry-run cannot be combined with -resume"},
+		{name: "export unknown entity", args: []string{"export", "payments"}, wantCode: 2, wantStderr: `unknown entity "payments"`},
+		{name: "export unknown format", args: []string{"export", "-format", "xml", "orders"}, wantCode: 2},
+		{name: "export bad since", args: []string{"export", "-updated-since", "yesterday", "orders"},
+			wantCode: 2, wantStderr: "invalid -updated-since"},
+
+		{name: "check-config invalid", args: []string{"check-config", "-config-dir", t.TempDir(), "-env-file", os.DevNull},
+			wantCode: 1, wantStderr: "database.host: required"},
+		{name: "check-config", args: append([]string{"check-config"}, validConfig(t)...),
+			wantCode: 0, wantStdout: "database sales@db.internal:5432/sales"},
+		{name: "check-config override", args: append([]string{"check-config"}, append(validConfig(t), "-set", "server.port=9090")...),
+			wantCode: 0, wantStdout: "port 9090"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var code int
+			stdout, stderr := capture(t, func() { code = dispatch(tt.args) })
+
+			if code != tt.wantCode {
+				t.Fatalf("dispatch(%q) = %d, want %d\nstdout: %s\nstderr: %s", tt.args, code, tt.wantCode, stdout, stderr)
+			}
+			if !strings.Contains(stdout, tt.wantStdout) {
+				t.Errorf("stdout has no %q:\n%s", tt.wantStdout, stdout)
+			}
+			if !strings.Contains(stderr, tt.wantStderr) {
+				t.Errorf("stderr has no %q:\n%s", tt.wantStderr, stderr)
+			}
+		})
+	}
+}
+
+func TestCheckConfigDumpRedacts(t *testing.T) {
+	var code int
+	stdout, stderr := capture(t, func() { code = dispatch(append([]string{"check-config", "-dump"}, validConfig(t)...)) })
+
+	if code != 0 {
+		t.Fatalf("check-config -dump = %d: %s", code, stderr)
+	}
+	if strings.Contains(stdout, "s3cret-value") || !strings.Contains(stdout, "db.internal") {
+		t.Errorf("dump must show the config without secrets:\n%s", stdout)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"salesTracker/internal/importer"
+)
+
+// runImport - подкоманда import: загрузка исторических заказов из файла
+//
+//	salesTracker import [-dry-run] [-resume JOB_ID] [-errors errors.csv] orders.csv
+func runImport(args []string) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	cf := addConfigFlags(fs)
+	format := fs.String("format", "", "file format: csv or jsonl (default: by file extension)")
+	dryRun := fs.Bool("dry-run", false, "check the file and roll back all writes")
+	batchSize := fs.Int("batch-size", importer.DefaultBatchSize, "orders per transaction")
+	resume := fs.Int("resume", 0, "continue the interrupted import job with this id")
+	errorsPath := fs.String("errors", "", "write the row-level error report as CSV to this file (default: stderr)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: salesTracker import [flags] FILE|-")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	path := fs.Arg(0)
+	opts := importer.Options{
+		Source:      path,
+		Format:      *format,
+		DryRun:      *dryRun,
+		BatchSize:   *batchSize,
+		ResumeJobID: *resume,
+	}
+	if opts.Format == "" {
+		opts.Format = formatByExtension(path)
+	}
+	switch opts.Format {
+	case importer.FormatCSV, importer.FormatJSONL:
+	case "":
+		fmt.Fprintf(os.Stderr, "import: cannot tell the format of %s, use -format csv or jsonl\n", path)
+		return 2
+	default:
+		fmt.Fprintf(os.Stderr, "import: unknown format %q, use csv or jsonl\n", opts.Format)
+		return 2
+	}
+	if opts.DryRun && opts.ResumeJobID != 0 {
+		fmt.Fprintln(os.Stderr, "import: -dry-run cannot be combined with -resume")
+		return 2
+	}
+
+	in := io.Reader(os.Stdin)
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "import:", err)
+			return 1
+		}
+		defer file.Close()
+		in = file
+	}
+
+	// по Ctrl+C загрузка останавливается между пачками и может быть продолжена
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg, ok := cf.load("import")
+	if !ok {
+		return 1
+	}
+	storage, err := NewSalesService(ctx, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "import:", err)
+		return 1
+	}
+	defer storage.DB.Close()
+
+	report, err := importer.New(storage, nil).Run(ctx, in, opts)
+	if report != nil {
+		printImportReport(report)
+		if writeErr := writeImportErrors(*errorsPath, report.Errors); writeErr != nil {
+			fmt.Fprintln(os.Stderr, "import: write error report:", writeErr)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "import:", err)
+		if report != nil && report.JobID != 0 {
+			fmt.Fprintf(os.Stderr, "resume with: salesTracker import -resume %d %s\n", report.JobID, path)
+		}
+		return 1
+	}
+	if report.Failed > 0 {
+		return 3
+	}
+	return 0
+}
+
+// formatByExtension - csv для .csv, jsonl для .jsonl и .ndjson
+func formatByExtension(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return importer.FormatCSV
+	case ".jsonl", ".ndjson":
+		return importer.FormatJSONL
+	default:
+		return ""
+	}
+}
+
+func printImportReport(report *importer.Report) {
+	mode := "import"
+	if report.DryRun {
+		mode = "dry run"
+	}
+	if report.JobID != 0 {
+		mode += " job " + strconv.Itoa(report.JobID)
+	}
+	if report.ResumedAfter > 0 {
+		mode += fmt.Sprintf(" (resumed after line %d)", report.ResumedAfter)
+	}
+
+	fmt.Printf("%s: %d orders read, %d imported, %d skipped as already imported, %d failed\n",
+		mode, report.Orders, report.Imported, report.Skipped, report.Failed)
+}
+
+// writeImportErrors - отчет об ошибках заказов в CSV: line,order_ref,error
+func writeImportErrors(path string, rows []importer.RowError) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	out := io.Writer(os.Stderr)
+	if path != "" {
+		file, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	w := csv.NewWriter(out)
+	w.Write([]string{"line", "order_ref", "error"})
+	for _, row := range rows {
+		w.Write([]string{strconv.Itoa(row.Line), row.OrderRef, row.Error})
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -1,6 +1,12 @@
 package main
 
+import (
+	"os"
+	// база часовых поясов встроена в бинарник: аналитика принимает tz=Europe/Moscow
+	// и в контейнерах без /usr/share/zoneinfo
+	_ "time/tzdata"
+)
+
 func main() {
-	storage := NewSalesService()
-	Run(":8080", storage)
+	os.Exit(dispatch(os.Args[1:]))
 }
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	config "salesTracker/internal/config"
+	"salesTracker/internal/migrate"
+	"salesTracker/internal/seed"
+	"salesTracker/migrations"
+)
+
+// runMigrate - подкоманда migrate: версии схемы базы
+//
+//	salesTracker migrate up
+//	salesTracker migrate down [-steps 1]
+//	salesTracker migrate status
+func runMigrate(args []string) int {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	cf := addConfigFlags(fs)
+	steps := fs.Int("steps", 1, "migrations to roll back (down only)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: salesTracker migrate up|down|status [flags]")
+		fs.PrintDefaults()
+	}
+	if len(args) == 0 {
+		fs.Usage()
+		return 2
+	}
+	command := args[0]
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	// опечатка в команде не должна ждать подключения к базе
+	if fs.NArg() != 0 || *steps <= 0 || (command != "up" && command != "down" && command != "status") {
+		fs.Usage()
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	migrator, closeDB, err := newMigrator(ctx, cf)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "migrate:", err)
+		return 1
+	}
+	defer closeDB()
+
+	switch command {
+	case "up":
+		done, err := migrator.Up(ctx)
+		for _, m := range done {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "migrate:", err)
+			return 1
+		}
+		if len(done) == 0 {
+			fmt.Println("schema is up to date")
+		}
+	case "down":
+		done, err := migrator.Down(ctx, *steps)
+		for _, m := range done {
+			fmt.Printf("rolled back %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "migrate:", err)
+			return 1
+		}
+		if len(done) == 0 {
+			fmt.Println("no applied migrations")
+		}
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Local().Format(time.DateTime)
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, applied)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "migrate:", err)
+			return 1
+		}
+	default:
+		fs.Usage()
+		return 2
+	}
+
+	return 0
+}
+
+// runSeed - подкоманда seed: воспроизводимые тестовые данные в пустую базу после migrate up
+//
+//	salesTracker seed [-seed 1] [-customers 200] [-orders 5000] [-from 2024-01-01] [-to 2025-01-31]
+func runSeed(args []string) int {
+	defaults := seed.DefaultOptions()
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	cf := addConfigFlags(fs)
+	seedValue := fs.Uint64("seed", defaults.Seed, "random seed: the same seed produces the same data")
+	customers := fs.Int("customers", defaults.Customers, "customers to generate")
+	orders := fs.Int("orders", defaults.Orders, "orders to generate")
+	from := fs.String("from", defaults.From.Format(time.DateOnly), "first order date")
+	to := fs.String("to", defaults.To.Format(time.DateOnly), "last order date")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: salesTracker seed [flags]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	opts := seed.Options{Seed: *seedValue, Customers: *customers, Orders: *orders}
+	var err error
+	if opts.From, err = time.Parse(time.DateOnly, *from); err != nil {
+		fmt.Fprintln(os.Stderr, "seed: invalid -from, use YYYY-MM-DD")
+		return 2
+	}
+	if opts.To, err = time.Parse(time.DateOnly, *to); err != nil {
+		fmt.Fprintln(os.Stderr, "seed: invalid -to, use YYYY-MM-DD")
+		return 2
+	}
+
+	migrator, closeDB, err := newMigrator(context.Background(), cf)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "seed:", err)
+		return 1
+	}
+	defer closeDB()
+
+	var summary *seed.Summary
+	err = migrator.Seed(context.Background(), func(ctx context.Context, tx *sql.Tx) (err error) {
+		summary, err = seed.Fill(ctx, tx, migrations.Catalog, opts)
+		return err
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "seed:", err)
+		if errors.Is(err, migrate.ErrNotEmpty) {
+			fmt.Fprintln(os.Stderr, "seed data is only loaded into an empty database")
+		}
+		return 1
+	}
+
+	fmt.Printf("seed %d: %d categories, %d products, %d customers, %d orders, %d order items\n",
+		opts.Seed, summary.Categories, summary.Products, summary.Customers, summary.Orders, summary.Items)
+	return 0
+}
+
+func newMigrator(ctx context.Context, cf *configFlags) (*migrate.Migrator, func() error, error) {
+	cfg, err := config.Load(cf.sources)
+	if err != nil {
+		return nil, nil, err
+	}
+	storage, err := NewSalesService(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	migrator, err := migrate.New(storage.DB, migrations.FS)
+	if err != nil {
+		storage.DB.Close()
+		return nil, nil, err
+	}
+	return migrator, storage.DB.Close, nil
+}
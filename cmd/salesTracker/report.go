@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"salesTracker/internal/storage/postgresql"
+)
+
+// runReport - подкоманда report: отчет по продажам за период, как
+// GET /api/v1/analytics/sales-report
+//
+//	salesTracker report -start 2024-01-01 -end 2024-01-31 [-format csv] [-tz Europe/Moscow] [-currency USD]
+func runReport(args []string) int {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	cf := addConfigFlags(fs)
+	startFlag := fs.String("start", "", "first day of the period, YYYY-MM-DD (required)")
+	endFlag := fs.String("end", "", "last day of the period, YYYY-MM-DD (required)")
+	format := fs.String("format", "json", "output format: json (full report) or csv (daily stats)")
+	tz := fs.String("tz", "UTC", "IANA time zone of the period days")
+	currency := fs.String("currency", "", "convert amounts to this currency (default: no conversion)")
+	output := fs.String("o", "", "write to this file (default: stdout)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: salesTracker report -start YYYY-MM-DD -end YYYY-MM-DD [flags]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 0 || *startFlag == "" || *endFlag == "" {
+		fs.Usage()
+		return 2
+	}
+	if *format != "json" && *format != "csv" {
+		fmt.Fprintf(os.Stderr, "report: unknown format %q, use json or csv\n", *format)
+		return 2
+	}
+
+	loc, err := time.LoadLocation(*tz)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "report: invalid -tz %q, use an IANA time zone name\n", *tz)
+		return 2
+	}
+	start, err := time.ParseInLocation(time.DateOnly, *startFlag, loc)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "report: invalid -start, use YYYY-MM-DD")
+		return 2
+	}
+	end, err := time.ParseInLocation(time.DateOnly, *endFlag, loc)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "report: invalid -end, use YYYY-MM-DD")
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg, ok := cf.load("report")
+	if !ok {
+		return 1
+	}
+	storage, err := NewSalesService(ctx, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "report:", err)
+		return 1
+	}
+	defer storage.DB.Close()
+
+	report, err := storage.GenerateSalesReport(ctx, start, end, strings.ToUpper(*currency))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "report:", err)
+		return 1
+	}
+
+	// отчет пишется только после того, как он полностью сформирован: при
+	// ошибке в -output не остается ни пустого, ни недописанного файла
+	if *output == "" {
+		err = writeReport(os.Stdout, *format, report)
+	} else {
+		err = writeReportFile(*output, *format, report)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "report:", err)
+		return 1
+	}
+
+	return 0
+}
+
+func writeReport(out io.Writer, format string, report *postgresql.SalesReport) error {
+	if format == "csv" {
+		return writeReportCSV(out, report)
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// writeReportFile — записать отчет в path; при ошибке записи или закрытия
+// файл удаляется
+func writeReportFile(path, format string, report *postgresql.SalesReport) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	err = writeReport(file, format, report)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+// writeReportCSV - продажи по дням: date,order_count,total_amount
+func writeReportCSV(out io.Writer, report *postgresql.SalesReport) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{"date", "order_count", "total_amount"})
+	for _, day := range report.DailyStats {
+		w.Write([]string{day.Date, strconv.Itoa(day.OrderCount), strconv.FormatFloat(day.TotalAmount, 'f', 2, 64)})
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -1,59 +1,303 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"database/sql"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"salesTracker/internal/audit"
+	"salesTracker/internal/auth"
 	config "salesTracker/internal/config"
 	"salesTracker/internal/handlers"
 	"salesTracker/internal/handlers/analytics"
+	"salesTracker/internal/handlers/apikeys"
+	"salesTracker/internal/handlers/auditlog"
+	"salesTracker/internal/handlers/exports"
+	"salesTracker/internal/handlers/imports"
+	"salesTracker/internal/handlers/promotions"
+	"salesTracker/internal/handlers/rates"
+	"salesTracker/internal/handlers/returns"
+	"salesTracker/internal/handlers/schedules"
+	"salesTracker/internal/health"
+	"salesTracker/internal/idempotency"
+	"salesTracker/internal/mergepatch"
+	"salesTracker/internal/metrics"
+	"salesTracker/internal/migrate"
+	"salesTracker/internal/scheduler"
+	"salesTracker/internal/storage/cache"
 	postgresql "salesTracker/internal/storage/postgresql"
+	"salesTracker/migrations"
+	"sync"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/joho/godotenv"
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
-func MustOpenDataBaseConnection(connStr, connDriver string) *sql.DB {
-	db, err := sql.Open(connDriver, connStr)
+const (
+	// connectAttemptTimeout - одна попытка подключения к базе при запуске
+	connectAttemptTimeout = 5 * time.Second
+	// maxConnectDelay - предел экспоненциальной паузы между попытками
+	maxConnectDelay = 5 * time.Second
+)
+
+// OpenDatabase - пул соединений с базой. Первое подключение повторяется
+// с растущей паузой, пока база не ответит или не истечет cfg.ConnectTimeout:
+// сервис, запущенный вместе с базой, дожидается ее готовности.
+func OpenDatabase(ctx context.Context, cfg config.Database) (*sql.DB, error) {
+	const op = "OpenDatabase"
+
+	db, err := sql.Open(cfg.Driver, cfg.DSN())
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 
-	return db
+	if err := connect(ctx, db, cfg.ConnectTimeout); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %s@%s:%s/%s: %w", op, cfg.User, cfg.Host, cfg.Port, cfg.Name, err)
+	}
+
+	return db, nil
 }
 
-func NewSalesService() *postgresql.Storage {
-	const op = "NewSalesService"
+// connect - ping с повторами до timeout. Отказ самого сервера (неверный
+// пароль, нет базы) не повторяется: ожидание его не исправит.
+func connect(ctx context.Context, db *sql.DB, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	delay := 250 * time.Millisecond
 
-	err := godotenv.Load(".env")
-	if err != nil {
-		panic(err)
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, connectAttemptTimeout)
+		err := db.PingContext(attemptCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code != "57P03" {
+			return err
+		}
+		wait := min(delay, time.Until(deadline))
+		if wait <= 0 {
+			return fmt.Errorf("database is unavailable after %d attempt(s): %w", attempt, err)
+		}
+
+		log.Printf("database is unavailable (attempt %d), retrying in %s: %v", attempt, wait.Round(time.Millisecond), err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		delay = min(delay*2, maxConnectDelay)
 	}
+}
 
-	cfg, err := config.MustLoad()
+// NewSalesService - хранилище поверх OpenDatabase с таймаутами запросов из cfg
+func NewSalesService(ctx context.Context, cfg *config.Config) (*postgresql.Storage, error) {
+	db, err := OpenDatabase(ctx, cfg.Database)
 	if err != nil {
-		panic(fmt.Errorf("%s: %w", op, err))
+		return nil, err
 	}
 
 	return &postgresql.Storage{
-		DB: MustOpenDataBaseConnection(cfg.Database.DSN(), cfg.Database.Driver),
+		DB:               db,
+		QueryTimeout:     cfg.Database.QueryTimeout,
+		AnalyticsTimeout: cfg.Database.AnalyticsTimeout,
+	}, nil
+}
+
+// App - зависимости, общие для всех роутов приложения
+type App struct {
+	Storage     *postgresql.Storage
+	Scheduler   *scheduler.Scheduler
+	Analytics   analytics.Storage
+	Invalidator handlers.AnalyticsInvalidator
+	Auth        *auth.Authenticator
+	Policy      *auth.Policy
+	Idempotency *idempotency.Keeper
+	Migrator    *migrate.Migrator
+	Workers     *health.Workers
+	Metrics     *prometheus.Registry
+	HTTPMetrics *metrics.HTTP
+	// BusinessMetrics - показатели продаж, отдаются только с правом metrics:read
+	BusinessMetrics *prometheus.Registry
+	// HealthTimeout - общий таймаут проверок /health/ready
+	HealthTimeout time.Duration
+}
+
+// NewApp - собирает зависимости приложения по конфигурации
+func NewApp(cfg *config.Config, storage *postgresql.Storage) (*App, error) {
+	const op = "NewApp"
+
+	app := &App{
+		Storage:     storage,
+		Scheduler:   scheduler.New(storage, cfg.Scheduler),
+		Analytics:   storage,
+		Invalidator: handlers.NopInvalidator{},
+		Idempotency: idempotency.New(storage, cfg.Idempotency, "text/csv", imports.ContentTypeJSONL),
+		Workers:     health.NewWorkers(),
+
+		HealthTimeout: cfg.Health.Timeout,
+	}
+
+	migrator, err := migrate.New(storage.DB, migrations.FS)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	app.Migrator = migrator
+
+	// Метрики: запросы HTTP, операции хранилища, пул соединений; продажи
+	// в отдельном реестре
+	app.Metrics = metrics.NewRegistry()
+	app.HTTPMetrics = metrics.NewHTTP(app.Metrics)
+	storage.Observe = metrics.NewQueries(app.Metrics).Observe
+	app.Metrics.MustRegister(metrics.DBStats(storage.DB))
+	app.BusinessMetrics = prometheus.NewRegistry()
+	storage.OrderCreated = metrics.NewBusiness(app.BusinessMetrics, storage).OrderCreated
+
+	if cfg.Cache.Enabled {
+		cached := cache.NewAnalytics(storage, cfg.Cache.Size, cfg.Cache.TTL)
+		app.Analytics = cached
+		app.Invalidator = cached
 	}
+
+	if cfg.Auth.Enabled {
+		authenticator, err := auth.New(cfg.Auth, storage)
+		if err != nil {
+			return nil, fmt.Errorf("%s: auth: %w", op, err)
+		}
+		app.Auth = authenticator
+	}
+
+	policy, err := auth.LoadPolicy(cfg.Auth.PolicyFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	app.Policy = policy
+
+	return app, nil
+}
+
+// RunWorkers - фоновые задачи приложения до отмены ctx; возвращает
+// WaitGroup, которая освобождается, когда задачи остановились
+func (app *App) RunWorkers(ctx context.Context, cfg *config.Config) *sync.WaitGroup {
+	var wg sync.WaitGroup
+
+	if cfg.Scheduler.Enabled {
+		wg.Go(func() { app.Workers.Run("scheduler", func() { app.Scheduler.Run(ctx) }) })
+	} else {
+		app.Workers.Disable("scheduler")
+	}
+	wg.Go(func() { app.Workers.Run("idempotency_purge", func() { app.Idempotency.Run(ctx) }) })
+	if interval := cfg.Database.RollupInterval; interval > 0 {
+		wg.Go(func() { app.Workers.Run("rollup_drain", func() { drainRollup(ctx, app.Storage, interval) }) })
+	} else {
+		app.Workers.Disable("rollup_drain")
+	}
+
+	return &wg
+}
+
+// drainRollup - фоновый пересчет дней дневной сводки, отмеченных триггерами
+func drainRollup(ctx context.Context, storage *postgresql.Storage, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := storage.DrainDailyRollup(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("rollup: drain: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// readinessChecks - проверки /health/ready: база отвечает, схема на версии
+// бинарника, фоновые задачи работают
+func (app *App) readinessChecks() []health.Check {
+	database := health.Check{Name: "database", Run: func(ctx context.Context) (map[string]any, error) {
+		stats := app.Storage.DB.Stats()
+		details := map[string]any{"open_connections": stats.OpenConnections, "in_use": stats.InUse, "idle": stats.Idle}
+		return details, app.Storage.DB.PingContext(ctx)
+	}}
+
+	schema := health.Check{Name: "migrations", Run: func(ctx context.Context) (map[string]any, error) {
+		current, latest, err := app.Migrator.Version(ctx)
+		details := map[string]any{"current": current, "latest": latest}
+		switch {
+		case err != nil:
+			return details, err
+		case current < latest:
+			return details, fmt.Errorf("schema is at version %d, %d expected: run migrate up", current, latest)
+		case current > latest:
+			return details, migrate.ErrUnknownVersion
+		}
+		return details, nil
+	}}
+
+	return []health.Check{database, schema, app.Workers.Check()}
+}
+
+// require - middleware проверки прав роли на ресурс; без аутентификации не действует
+func (app *App) require(resource string) func(http.Handler) http.Handler {
+	return app.Policy.Require(resource)
+}
+
+// requireAction - проверка права на конкретное действие независимо от метода
+func (app *App) requireAction(resource, action string) func(http.Handler) http.Handler {
+	return app.Policy.RequireAction(resource, action)
 }
 
 // setupRoutes - настраивает все роуты приложения
-func setupRoutes(r *chi.Mux, storage *postgresql.Storage) {
+func setupRoutes(r *chi.Mux, app *App) {
+	r.Group(func(r chi.Router) {
+		// Все роуты, кроме /health, требуют аутентификации
+		if app.Auth != nil {
+			r.Use(app.Auth.Middleware)
+		}
+
+		setupAPIRoutes(r, app)
+		setupAnalyticsRoutes(r, app)
+	})
+}
+
+// setupAPIRoutes - CRUD и служебные роуты /api/v1
+func setupAPIRoutes(r chi.Router, app *App) {
+	storage := app.Storage
+	invalidator := app.Invalidator
+
 	// ====================================================================
 	// API v1 - Основные CRUD операции
 	// ====================================================================
 	r.Route("/api/v1", func(r chi.Router) {
+		// Повторы POST с тем же Idempotency-Key получают сохраненный ответ
+		r.Use(app.Idempotency.Middleware)
+		// Автор изменений, которые журналирует хранилище
+		r.Use(audit.Actor)
+
 		// CATEGORIES - Категории товаров
 		r.Route("/categories", func(r chi.Router) {
+			r.Use(app.require("categories"))
 			r.Post("/", handlers.CreateCategory(storage))
 			r.Get("/", handlers.ListCategories(storage))
 			r.Route("/{id}", func(r chi.Router) {
 				r.Get("/", handlers.GetCategory(storage))
 				r.Put("/", handlers.UpdateCategory(storage))
+				r.Patch("/", handlers.PatchCategory(storage))
 				r.Delete("/", handlers.DeleteCategory(storage))
 				// Товары категории
 				r.Get("/products", handlers.ListProductsByCategory(storage))
@@ -62,87 +306,226 @@ func setupRoutes(r *chi.Mux, storage *postgresql.Storage) {
 
 		// PRODUCTS - Товары
 		r.Route("/products", func(r chi.Router) {
+			r.Use(app.require("products"))
 			r.Post("/", handlers.CreateProduct(storage))
 			r.Get("/", handlers.ListProducts(storage))
 			r.Route("/{id}", func(r chi.Router) {
 				r.Get("/", handlers.GetProduct(storage))
-				r.Put("/", handlers.UpdateProduct(storage))
+				r.Put("/", handlers.UpdateProduct(storage, invalidator))
+				r.Patch("/", handlers.PatchProduct(storage, invalidator))
 				r.Delete("/", handlers.DeleteProduct(storage))
+				r.With(app.requireAction("products", auth.ActionDelete)).Post("/restore", handlers.RestoreProduct(storage))
 			})
 		})
 
+		// Пакетная запись: строки создаются и обновляются, поэтому нужны оба права
+		r.With(app.requireAction("products", auth.ActionCreate), app.requireAction("products", auth.ActionUpdate)).
+			Post("/products:batch", handlers.SaveProductsBatch(storage, invalidator))
+
 		// CUSTOMERS - Покупатели
 		r.Route("/customers", func(r chi.Router) {
+			r.Use(app.require("customers"))
 			r.Post("/", handlers.CreateCustomer(storage))
 			r.Get("/", handlers.ListCustomers(storage))
 			r.Route("/{id}", func(r chi.Router) {
 				r.Get("/", handlers.GetCustomer(storage))
-				r.Put("/", handlers.UpdateCustomer(storage))
+				r.Put("/", handlers.UpdateCustomer(storage, invalidator))
+				r.Patch("/", handlers.PatchCustomer(storage, invalidator))
 				r.Delete("/", handlers.DeleteCustomer(storage))
+				r.With(app.requireAction("customers", auth.ActionDelete)).Post("/restore", handlers.RestoreCustomer(storage))
 				// Заказы покупателя
 				r.Get("/orders", handlers.ListOrdersByCustomer(storage))
 			})
 		})
 
+		r.With(app.requireAction("customers", auth.ActionCreate), app.requireAction("customers", auth.ActionUpdate)).
+			Post("/customers:batch", handlers.SaveCustomersBatch(storage, invalidator))
+
 		// ORDERS - Заказы
 		r.Route("/orders", func(r chi.Router) {
-			r.Post("/", handlers.CreateOrder(storage))
+			r.Use(app.require("orders"))
+			r.Post("/", handlers.CreateOrder(storage, invalidator))
 			r.Get("/", handlers.ListOrders(storage))
 			r.Route("/{id}", func(r chi.Router) {
 				r.Get("/", handlers.GetOrder(storage))
-				r.Put("/", handlers.UpdateOrder(storage))
-				r.Delete("/", handlers.DeleteOrder(storage))
+				r.Put("/", handlers.UpdateOrder(storage, invalidator))
+				r.Patch("/", handlers.PatchOrder(storage, invalidator))
+				r.Delete("/", handlers.DeleteOrder(storage, invalidator))
+				r.With(app.requireAction("orders", auth.ActionDelete)).Post("/restore", handlers.RestoreOrder(storage, invalidator))
 				// Позиции заказа
 				r.Get("/items", handlers.ListOrderItems(storage))
+				// Возвраты по заказу
+				r.With(app.require("returns")).Post("/returns", returns.CreateReturn(storage, invalidator))
+				r.With(app.require("returns")).Get("/returns", returns.ListReturns(storage))
 			})
 		})
 
 		// ORDER ITEMS - Позиции в заказах
 		r.Route("/order-items", func(r chi.Router) {
-			r.Post("/", handlers.CreateOrderItem(storage))
+			r.Use(app.require("order-items"))
+			r.Post("/", handlers.CreateOrderItem(storage, invalidator))
 			r.Route("/{id}", func(r chi.Router) {
 				r.Get("/", handlers.GetOrderItem(storage))
-				r.Put("/", handlers.UpdateOrderItem(storage))
-				r.Delete("/", handlers.DeleteOrderItem(storage))
+				r.Put("/", handlers.UpdateOrderItem(storage, invalidator))
+				r.Patch("/", handlers.PatchOrderItem(storage, invalidator))
+				r.Delete("/", handlers.DeleteOrderItem(storage, invalidator))
+			})
+		})
+
+		// EXCHANGE RATES - Курсы валют
+		r.Route("/exchange-rates", func(r chi.Router) {
+			r.Use(app.require("exchange-rates"))
+			r.Post("/", rates.UpsertRates(storage, invalidator))
+			r.Get("/", rates.ListRates(storage))
+			r.Post("/import", rates.ImportRatesCSV(storage, invalidator))
+		})
+
+		// IMPORTS - Загрузка исторических заказов
+		r.Route("/imports", func(r chi.Router) {
+			r.Use(app.require("imports"))
+			r.Post("/orders", imports.ImportOrders(storage, invalidator))
+			r.Get("/{id}", imports.GetImportJob(storage))
+		})
+
+		// EXPORT - Потоковая выгрузка для хранилища данных
+		r.With(app.require("export")).Get("/export/{entity}", exports.ExportEntity(storage))
+
+		// PROMOTIONS - Промоакции и купоны
+		r.Route("/promotions", func(r chi.Router) {
+			r.Use(app.require("promotions"))
+			r.Post("/", promotions.CreatePromotion(storage))
+			r.Get("/", promotions.ListPromotions(storage))
+			r.Route("/{id}", func(r chi.Router) {
+				r.Get("/", promotions.GetPromotion(storage))
+				r.Put("/", promotions.UpdatePromotion(storage))
+				r.Patch("/", promotions.PatchPromotion(storage))
+				r.Delete("/", promotions.DeletePromotion(storage))
+			})
+		})
+
+		// API KEYS - Ключи доступа
+		r.Route("/api-keys", func(r chi.Router) {
+			r.Use(app.require("api-keys"))
+			r.Post("/", apikeys.CreateAPIKey(storage, app.Policy))
+			r.Get("/", apikeys.ListAPIKeys(storage))
+			r.Delete("/{id}", apikeys.RevokeAPIKey(storage))
+		})
+		r.Get("/auth/whoami", apikeys.WhoAmI())
+
+		// METRICS - Показатели продаж для Prometheus
+		r.With(app.require("metrics")).Get("/metrics/business", metrics.Handler(app.BusinessMetrics).ServeHTTP)
+
+		// AUDIT - Журнал изменений
+		r.With(app.require("audit")).Get("/audit", auditlog.ListAuditEntries(storage))
+
+		// REPORT SCHEDULES - Расписания регулярных отчетов
+		r.Route("/report-schedules", func(r chi.Router) {
+			r.Use(app.require("report-schedules"))
+			r.Post("/", schedules.CreateSchedule(storage))
+			r.Get("/", schedules.ListSchedules(storage))
+			r.Route("/{id}", func(r chi.Router) {
+				r.Get("/", schedules.GetSchedule(storage))
+				r.Put("/", schedules.UpdateSchedule(storage))
+				r.Patch("/", schedules.PatchSchedule(storage))
+				r.Delete("/", schedules.DeleteSchedule(storage))
+				r.Post("/run", schedules.RunSchedule(storage, app.Scheduler))
 			})
 		})
 	})
+}
 
+// setupAnalyticsRoutes - аналитические отчеты /analytics
+func setupAnalyticsRoutes(r chi.Router, app *App) {
 	// ====================================================================
 	// ANALYTICS - Аналитика
 	// ====================================================================
 	r.Route("/analytics", func(r chi.Router) {
-		r.Get("/revenue", analytics.TotalRevenueByPeriod(storage))
-		r.Get("/daily-orders", analytics.OrdersPerDay(storage))
-		r.Get("/average-check", analytics.AverageCheckByPeriod(storage))
-		r.Get("/orders-median", analytics.OrdersMedian(storage))
-		r.Get("/customer-median", analytics.CustomerSpendingMedian(storage))
-		r.Get("/orders-percentile", analytics.OrdersPercentile(storage))
-		r.Get("/customer-percentile", analytics.CustomerSpendingPercentile(storage))
-		r.Get("/sales-report", analytics.GenerateSalesReport(storage))
+		r.Use(app.require("analytics"))
+		r.Get("/revenue", analytics.TotalRevenueByPeriod(app.Analytics))
+		r.Get("/daily-orders", analytics.OrdersPerDay(app.Analytics))
+		r.Get("/average-check", analytics.AverageCheckByPeriod(app.Analytics))
+		r.Get("/orders-median", analytics.OrdersMedian(app.Analytics))
+		r.Get("/customer-median", analytics.CustomerSpendingMedian(app.Analytics))
+		r.Get("/orders-percentile", analytics.OrdersPercentile(app.Analytics))
+		r.Get("/customer-percentile", analytics.CustomerSpendingPercentile(app.Analytics))
+		r.Get("/sales-report", analytics.GenerateSalesReport(app.Analytics))
+		r.Get("/return-rate", analytics.ReturnRateByProduct(app.Analytics))
+		r.Get("/promotions", analytics.PromotionsReport(app.Analytics))
 	})
 }
 
-// Run запускает HTTP сервер с всеми роутами
-func Run(server string, storage *postgresql.Storage) {
+// NewRouter - роутер API со всеми middleware
+func NewRouter(app *App) http.Handler {
 	r := chi.NewRouter()
 
 	// Middleware
+	r.Use(middleware.RequestID)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.AllowContentType("application/json"))
+	r.Use(app.HTTPMetrics.Middleware)
+	r.Use(middleware.AllowContentType("application/json", "text/csv", mergepatch.ContentType, imports.ContentTypeJSONL))
 	r.Use(middleware.SetHeader("Content-Type", "application/json"))
 
-	// Health check
+	// Health check: /health оставлен для старых проверок, /health/live -
+	// процесс жив, /health/ready - база, схема и фоновые задачи в порядке
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	})
+	r.Get("/health/live", health.Live())
+	r.Get("/health/ready", health.Ready(app.HealthTimeout, app.readinessChecks()...))
+
+	// Служебные метрики Prometheus, как и health, без аутентификации;
+	// показатели продаж — /api/v1/metrics/business
+	r.Get("/metrics", metrics.Handler(app.Metrics).ServeHTTP)
 
 	// Настраиваем роуты
-	setupRoutes(r, storage)
+	setupRoutes(r, app)
 
-	if err := http.ListenAndServe(server, r); err != nil {
-		panic(err)
+	return r
+}
+
+// Run - HTTP-сервер до отмены ctx. После отмены новые соединения не
+// принимаются, а начатые запросы получают cfg.ShutdownTimeout на завершение;
+// не успевшие (долгие выгрузки) обрываются.
+func Run(ctx context.Context, cfg config.Server, app *App) error {
+	const op = "Run"
+
+	server := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           NewRouter(app),
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		if cfg.TLSCertFile != "" {
+			server.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+			log.Printf("listening on %s (TLS)", server.Addr)
+			errc <- server.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			log.Printf("listening on %s", server.Addr)
+			errc <- server.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-errc:
+		return fmt.Errorf("%s: %w", op, err)
+	case <-ctx.Done():
 	}
+
+	log.Printf("shutting down, waiting up to %s for in-flight requests", cfg.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		server.Close()
+		return fmt.Errorf("%s: requests still running after %s were cut off: %w", op, cfg.ShutdownTimeout, err)
+	}
+
+	return nil
 }
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"salesTracker/internal/config"
+	"salesTracker/internal/health"
+	"salesTracker/internal/storage/postgresql"
+
+	"github.com/lib/pq"
+)
+
+// testStorage — хранилище без подключения: sql.Open не обращается к базе
+func testStorage(t *testing.T) *postgresql.Storage {
+	t.Helper()
+
+	db, err := sql.Open("postgres", "host=127.0.0.1 port=1 sslmode=disable connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return &postgresql.Storage{DB: db}
+}
+
+func testConfig() *config.Config {
+	cfg := config.Default()
+	cfg.Auth.JWTSecret = "secret"
+	cfg.Cache.Enabled = false
+	return &cfg
+}
+
+func TestNewApp(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	policy := write("policy.json", `{"viewer": ["products:read"]}`)
+	badPolicy := write("bad-policy.json", `{"viewer": ["products"]}`)
+	badKey := write("jwt.pem", "not a key")
+
+	tests := []struct {
+		name    string
+		modify  func(cfg *config.Config)
+		wantErr string
+	}{
+		{name: "defaults", modify: func(cfg *config.Config) {}},
+		{name: "policy file", modify: func(cfg *config.Config) { cfg.Auth.PolicyFile = policy }},
+		{name: "auth disabled", modify: func(cfg *config.Config) { cfg.Auth.Enabled, cfg.Auth.JWTSecret = false, "" }},
+
+		{name: "missing policy file", modify: func(cfg *config.Config) { cfg.Auth.PolicyFile = filepath.Join(dir, "none.json") },
+			wantErr: "read policy"},
+		{name: "invalid permission", modify: func(cfg *config.Config) { cfg.Auth.PolicyFile = badPolicy },
+			wantErr: "invalid permission"},
+		{name: "unreadable public key", modify: func(cfg *config.Config) { cfg.Auth.JWTSecret, cfg.Auth.JWTPublicKeyFile = "", badKey },
+			wantErr: "NewApp: auth"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			tt.modify(cfg)
+
+			app, err := NewApp(cfg, testStorage(t))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("NewApp() error = %v", err)
+				}
+				if app.Policy == nil || app.Migrator == nil {
+					t.Errorf("NewApp() app is incomplete: %+v", app)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("NewApp() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunStopsOnCancel(t *testing.T) {
+	cfg := testConfig()
+	app, err := NewApp(cfg, testStorage(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := cfg.Server
+	server.Port = "0"
+	server.ShutdownTimeout = time.Second
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- Run(ctx, server, app) }()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() did not return after cancel")
+	}
+}
+
+func TestRunPortInUse(t *testing.T) {
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	cfg := testConfig()
+	app, err := NewApp(cfg, testStorage(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := cfg.Server
+	_, server.Port, _ = net.SplitHostPort(ln.Addr().String())
+
+	// сервер не стартовал: Run возвращает ошибку, не дожидаясь отмены
+	if err := Run(context.Background(), server, app); err == nil {
+		t.Fatal("Run() error = nil on a busy port")
+	}
+}
+
+func TestWaitTimeout(t *testing.T) {
+	var done sync.WaitGroup
+	if !waitTimeout(&done, time.Second) {
+		t.Error("waitTimeout() = false for a finished group")
+	}
+
+	var stuck sync.WaitGroup
+	stuck.Add(1)
+	defer stuck.Done()
+	if waitTimeout(&stuck, 10*time.Millisecond) {
+		t.Error("waitTimeout() = true for a running group")
+	}
+}
+
+// bearer — токен HS256 для testConfig с ролью role
+func bearer(t *testing.T, role string) string {
+	t.Helper()
+
+	segment := func(v any) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := segment(map[string]string{"alg": "HS256", "typ": "JWT"}) + "." +
+		segment(map[string]any{"sub": "test", "role": role, "exp": time.Now().Add(time.Hour).Unix()})
+
+	mac := hmac.New(sha256.New, []byte(testConfig().Auth.JWTSecret))
+	mac.Write([]byte(signed))
+	return "Bearer " + signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestMetricsRoutes(t *testing.T) {
+	app, err := NewApp(testConfig(), testStorage(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := NewRouter(app)
+	// хранилище сообщает о созданных заказах в счетчик показателей продаж
+	app.Storage.OrderCreated("completed", "RUB")
+
+	get := func(path, authorization string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if authorization != "" {
+			r.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	// служебные метрики открыты, показателей продаж в них нет
+	w := get("/metrics", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("/metrics: status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if body := w.Body.String(); strings.Contains(body, "salestracker_revenue") || strings.Contains(body, "salestracker_orders_created") {
+		t.Errorf("/metrics exposes business metrics:\n%s", body)
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+	}{
+		{name: "anonymous", wantStatus: http.StatusUnauthorized},
+		{name: "clerk", authorization: bearer(t, "clerk"), wantStatus: http.StatusForbidden},
+		{name: "analyst", authorization: bearer(t, "analyst"), wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get("/api/v1/metrics/business", tt.authorization)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			want := `salestracker_orders_created_total{currency="RUB",status="completed"} 1`
+			if w.Code == http.StatusOK && !strings.Contains(w.Body.String(), want) {
+				t.Errorf("business metrics have no %q:\n%s", want, w.Body)
+			}
+		})
+	}
+}
+
+func TestRestoreRoutes(t *testing.T) {
+	app, err := NewApp(testConfig(), testStorage(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := NewRouter(app)
+
+	// восстановление возвращает запись в отчеты, поэтому требует права на удаление;
+	// неверный id проверяется после прав, до обращения к базе
+	tests := []struct {
+		path       string
+		role       string
+		wantStatus int
+	}{
+		{path: "/api/v1/customers/x/restore", role: "clerk", wantStatus: http.StatusForbidden},
+		{path: "/api/v1/customers/x/restore", role: "manager", wantStatus: http.StatusBadRequest},
+		{path: "/api/v1/products/x/restore", role: "analyst", wantStatus: http.StatusForbidden},
+		{path: "/api/v1/products/x/restore", role: "manager", wantStatus: http.StatusBadRequest},
+		{path: "/api/v1/orders/x/restore", role: "clerk", wantStatus: http.StatusForbidden},
+		{path: "/api/v1/orders/x/restore", role: "admin", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.role+" "+tt.path, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			r.Header.Set("Authorization", bearer(t, tt.role))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+}
+
+func TestDrainRollupStopsOnCancel(t *testing.T) {
+	storage := testStorage(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		// база недоступна: ошибки пересчета пишутся в журнал, цикл продолжается
+		drainRollup(ctx, storage, 10*time.Millisecond)
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("drainRollup() did not return after cancel")
+	}
+}
+
+// flakyDriver — база, подключение к которой завершается очередной ошибкой
+// из flakyErrors[name], пока они не кончатся
+type flakyDriver struct{}
+
+type flakyConn struct{}
+
+var (
+	flakyMu       sync.Mutex
+	flakyErrors   = map[string][]error{}
+	flakyAttempts = map[string]int{}
+)
+
+func init() {
+	sql.Register("flaky", flakyDriver{})
+}
+
+func (flakyDriver) Open(name string) (driver.Conn, error) {
+	flakyMu.Lock()
+	defer flakyMu.Unlock()
+
+	flakyAttempts[name]++
+	if errs := flakyErrors[name]; len(errs) > 0 {
+		flakyErrors[name] = errs[1:]
+		return nil, errs[0]
+	}
+	return flakyConn{}, nil
+}
+
+func (flakyConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("flaky: not supported") }
+func (flakyConn) Close() error                        { return nil }
+func (flakyConn) Begin() (driver.Tx, error)           { return nil, errors.New("flaky: not supported") }
+
+func TestConnectRetries(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	starting := &pq.Error{Code: "57P03", Message: "the database system is starting up"}
+
+	tests := []struct {
+		name         string
+		errs         []error
+		timeout      time.Duration
+		cancel       bool
+		wantErr      string
+		wantAttempts int
+	}{
+		// база поднимается: сначала не слушает порт, потом отвечает "starting up"
+		{name: "database comes up", errs: []error{refused, starting}, timeout: 10 * time.Second, wantAttempts: 3},
+		// неверный пароль ожиданием не исправить
+		{name: "server refuses", errs: []error{&pq.Error{Code: "28P01", Message: "password authentication failed"}},
+			timeout: 10 * time.Second, wantErr: "password authentication failed", wantAttempts: 1},
+		{name: "gives up at timeout", errs: []error{refused, refused, refused, refused, refused}, timeout: 300 * time.Millisecond,
+			wantErr: "database is unavailable after 3 attempt(s)", wantAttempts: 3},
+		{name: "stops on cancel", errs: []error{refused, refused, refused}, timeout: 10 * time.Second, cancel: true,
+			wantErr: context.Canceled.Error(), wantAttempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flakyMu.Lock()
+			flakyErrors[tt.name] = tt.errs
+			flakyMu.Unlock()
+
+			db, err := sql.Open("flaky", tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				time.AfterFunc(50*time.Millisecond, cancel)
+			}
+
+			start := time.Now()
+			err = connect(ctx, db, tt.timeout)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("connect() error = %v, want %q", err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("connect() took %v", elapsed)
+			}
+
+			flakyMu.Lock()
+			defer flakyMu.Unlock()
+			if got := flakyAttempts[tt.name]; got != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestHealthRoutes(t *testing.T) {
+	app, err := NewApp(testConfig(), testStorage(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.Workers.Disable("scheduler")
+	router := NewRouter(app)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	// база недоступна: процесс жив, но запросы принимать не готов
+	if w := get("/health/live"); w.Code != http.StatusOK {
+		t.Errorf("/health/live: status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	w := get("/health/ready")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("/health/ready: status = %d, want %d: %s", w.Code, http.StatusServiceUnavailable, w.Body)
+	}
+	var report health.Report
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"database": health.StatusFail, "migrations": health.StatusFail, "workers": health.StatusOK} {
+		if got := report.Checks[name]; got.Status != want {
+			t.Errorf("check %s = %+v, want %s", name, got, want)
+		}
+	}
+	if latest := report.Checks["migrations"].Details["latest"]; latest == nil || latest.(float64) < 1 {
+		t.Errorf("migrations details = %v, want the binary's latest version", report.Checks["migrations"].Details)
+	}
+}
@@ -0,0 +1,34 @@
+package audit
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"salesTracker/internal/auth"
+	"salesTracker/internal/storage/postgresql"
+)
+
+// ====================================================================
+// AUDIT - Журналирование изменений сущностей
+// ====================================================================
+
+// Журнал пишет хранилище: каждая операция, которая меняет записи, добавляет
+// записи журнала в своей транзакции. Здесь — только автор изменений.
+
+// Actor — передает хранилищу автора и ID запроса: изменения, сделанные
+// при обработке запроса, журналируются от имени аутентифицированного субъекта,
+// без аутентификации — от имени "anonymous"
+func Actor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := postgresql.WithAuditActor(r.Context(), actor(r), middleware.GetReqID(r.Context()))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func actor(r *http.Request) string {
+	if principal := auth.PrincipalFrom(r.Context()); principal != nil {
+		return principal.Subject
+	}
+	return "anonymous"
+}
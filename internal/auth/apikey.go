@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// ====================================================================
+// API KEYS - Выпуск и проверка API-ключей
+// ====================================================================
+
+// Ключ имеет вид st_<prefix>_<secret>: prefix хранится открыто и служит для
+// поиска записи, в базе лежит только SHA-256 всего ключа.
+const apiKeyScheme = "st_"
+
+var ErrMalformedAPIKey = errors.New("malformed api key")
+
+// GenerateAPIKey — новый ключ; plaintext показывается клиенту один раз
+func GenerateAPIKey() (plaintext, prefix, hash string, err error) {
+	prefixBytes := make([]byte, 6)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return "", "", "", err
+	}
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", "", "", err
+	}
+
+	prefix = hex.EncodeToString(prefixBytes)
+	plaintext = apiKeyScheme + prefix + "_" + base64.RawURLEncoding.EncodeToString(secretBytes)
+	return plaintext, prefix, HashAPIKey(plaintext), nil
+}
+
+// HashAPIKey — SHA-256 ключа в hex. Ключ случайный и длинный, поэтому
+// медленное хэширование (bcrypt и т.п.) не требуется.
+func HashAPIKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsAPIKey — похоже ли значение на API-ключ, а не на JWT
+func IsAPIKey(value string) bool {
+	return strings.HasPrefix(value, apiKeyScheme)
+}
+
+// APIKeyPrefix — открытая часть ключа
+func APIKeyPrefix(plaintext string) (string, error) {
+	rest, ok := strings.CutPrefix(plaintext, apiKeyScheme)
+	if !ok {
+		return "", ErrMalformedAPIKey
+	}
+	prefix, secret, ok := strings.Cut(rest, "_")
+	if !ok || prefix == "" || secret == "" {
+		return "", ErrMalformedAPIKey
+	}
+	return prefix, nil
+}
+
+// MatchAPIKey — сравнить ключ с сохраненным хэшем за постоянное время
+func MatchAPIKey(plaintext, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashAPIKey(plaintext)), []byte(hash)) == 1
+}
@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/render"
+
+	"salesTracker/internal/config"
+	"salesTracker/internal/storage"
+	"salesTracker/internal/storage/postgresql"
+)
+
+// ====================================================================
+// PRINCIPAL - Аутентифицированный клиент запроса
+// ====================================================================
+
+// Способы аутентификации
+const (
+	MethodJWT    = "jwt"
+	MethodAPIKey = "api_key"
+)
+
+// Principal — кто выполняет запрос
+type Principal struct {
+	Subject  string   `json:"subject"`
+	Method   string   `json:"method"`
+	Roles    []string `json:"roles,omitempty"`
+	APIKeyID int      `json:"api_key_id,omitempty"`
+}
+
+type principalKey struct{}
+
+// WithPrincipal — контекст с аутентифицированным клиентом
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom — клиент из контекста запроса; nil, если аутентификация выключена
+func PrincipalFrom(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// ====================================================================
+// AUTHENTICATOR - Проверка учетных данных запроса
+// ====================================================================
+
+var ErrUnauthenticated = errors.New("missing credentials")
+
+// KeyStore — хранилище API-ключей
+type KeyStore interface {
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (*postgresql.APIKey, error)
+	TouchAPIKey(ctx context.Context, id int, at time.Time) error
+}
+
+// Authenticator — принимает "Authorization: Bearer <JWT или API-ключ>"
+// или заголовок "X-API-Key: <API-ключ>"
+type Authenticator struct {
+	jwt  *JWTVerifier
+	keys KeyStore
+}
+
+func New(cfg config.Auth, keys KeyStore) (*Authenticator, error) {
+	verifier, err := NewJWTVerifier(cfg.JWTSecret, cfg.JWTPublicKeyFile, cfg.JWTIssuer, cfg.JWTAudience, cfg.JWTLeeway)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Authenticator{jwt: verifier, keys: keys}, nil
+}
+
+// Authenticate — определить клиента по заголовкам запроса
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	now := time.Now()
+
+	credential := r.Header.Get("X-API-Key")
+	if credential == "" {
+		scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			return nil, ErrUnauthenticated
+		}
+		credential = strings.TrimSpace(token)
+	}
+	if credential == "" {
+		return nil, ErrUnauthenticated
+	}
+
+	if IsAPIKey(credential) {
+		return a.authenticateAPIKey(r.Context(), credential, now)
+	}
+
+	claims, err := a.jwt.Verify(credential, now)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := claims.Roles
+	if len(roles) == 0 && claims.Role != "" {
+		roles = []string{claims.Role}
+	}
+
+	return &Principal{Subject: claims.Subject, Method: MethodJWT, Roles: roles}, nil
+}
+
+func (a *Authenticator) authenticateAPIKey(ctx context.Context, plaintext string, now time.Time) (*Principal, error) {
+	prefix, err := APIKeyPrefix(plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := a.keys.GetAPIKeyByPrefix(ctx, prefix)
+	if errors.Is(err, storage.ErrAPIKeyNotFound) {
+		return nil, fmt.Errorf("%w: unknown api key", ErrInvalidToken)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !MatchAPIKey(plaintext, key.KeyHash) {
+		return nil, fmt.Errorf("%w: unknown api key", ErrInvalidToken)
+	}
+	if !key.Usable(now) {
+		return nil, fmt.Errorf("%w: api key revoked or expired", ErrInvalidToken)
+	}
+
+	if err := a.keys.TouchAPIKey(ctx, key.KeyID, now); err != nil {
+		log.Printf("auth: %v", err)
+	}
+
+	return &Principal{Subject: "api-key:" + key.Name, Method: MethodAPIKey, Roles: []string{key.Role}, APIKeyID: key.KeyID}, nil
+}
+
+// Middleware — пропускает только аутентифицированные запросы и кладет
+// Principal в контекст; остальным отвечает 401
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, err := a.Authenticate(r)
+		if err != nil {
+			unauthorized(w, r, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+	})
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrUnauthenticated):
+		w.Header().Set("WWW-Authenticate", `Bearer realm="sales-tracker"`)
+	case errors.Is(err, ErrInvalidToken), errors.Is(err, ErrMalformedToken), errors.Is(err, ErrMalformedAPIKey):
+		w.Header().Set("WWW-Authenticate", `Bearer realm="sales-tracker", error="invalid_token"`)
+	default:
+		// хранилище ключей недоступно — это не ошибка клиента
+		log.Printf("auth: %v", err)
+		render.Status(r, http.StatusServiceUnavailable)
+		render.JSON(w, r, map[string]string{"error": "authentication unavailable"})
+		return
+	}
+
+	render.Status(r, http.StatusUnauthorized)
+	render.JSON(w, r, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,74 @@
+package authfile
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ====================================================================
+// AUTH FILES - Файлы политики прав и открытого ключа JWT
+// ====================================================================
+
+// Файлы читает и auth при запуске, и проверка конфигурации, поэтому
+// разбор вынесен в пакет без зависимостей от auth и config.
+
+// ReadPolicy — права ролей из JSON-файла вида {"clerk": ["orders:create"]};
+// каждое право имеет вид "<ресурс>:<действие>"
+func ReadPolicy(path string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read policy: %w", err)
+	}
+
+	var roles map[string][]string
+	if err := json.Unmarshal(data, &roles); err != nil {
+		return nil, fmt.Errorf("parse policy %s: %w", path, err)
+	}
+
+	for role, permissions := range roles {
+		for _, permission := range permissions {
+			if resource, action, ok := strings.Cut(permission, ":"); !ok || resource == "" || action == "" {
+				return nil, fmt.Errorf("policy %s: role %s: invalid permission %q, use <resource>:<action>", path, role, permission)
+			}
+		}
+	}
+
+	return roles, nil
+}
+
+// ReadRSAPublicKey — открытый ключ RS256 из PEM-файла
+func ReadRSAPublicKey(path string) (*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read jwt public key: %w", err)
+	}
+	return ParseRSAPublicKey(data)
+}
+
+// ParseRSAPublicKey — PEM "PUBLIC KEY" (PKIX) или "RSA PUBLIC KEY" (PKCS #1)
+func ParseRSAPublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("jwt public key: no PEM block found")
+	}
+
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("jwt public key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("jwt public key: not an RSA key")
+	}
+	return rsaKey, nil
+}
@@ -0,0 +1,214 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testSecret = "test-secret"
+
+// signToken — токен с заголовком alg; key — секрет HS256 ([]byte)
+// или закрытый ключ RS256 (*rsa.PrivateKey), nil — без подписи
+func signToken(t *testing.T, alg string, claims map[string]any, key any) string {
+	t.Helper()
+
+	segment := func(v any) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := segment(map[string]string{"alg": alg, "typ": "JWT"}) + "." + segment(claims)
+
+	var signature []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signed))
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// writePublicKey — PEM-файл открытого ключа в формате blockType
+func writePublicKey(t *testing.T, key *rsa.PublicKey, blockType string) string {
+	t.Helper()
+
+	var der []byte
+	if blockType == "RSA PUBLIC KEY" {
+		der = x509.MarshalPKCS1PublicKey(key)
+	} else {
+		var err error
+		if der, err = x509.MarshalPKIXPublicKey(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "jwt.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestJWTVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	claims := func(overrides map[string]any) map[string]any {
+		c := map[string]any{
+			"sub":  "pos-terminal-7",
+			"iss":  "https://auth.example.com",
+			"aud":  "sales-tracker",
+			"exp":  now.Add(time.Hour).Unix(),
+			"iat":  now.Add(-time.Minute).Unix(),
+			"role": "cashier",
+		}
+		for k, v := range overrides {
+			if v == nil {
+				delete(c, k)
+				continue
+			}
+			c[k] = v
+		}
+		return c
+	}
+
+	hsOnly, err := NewJWTVerifier(testSecret, "", "https://auth.example.com", "sales-tracker", 30*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsOnly, err := NewJWTVerifier("", writePublicKey(t, &rsaKey.PublicKey, "PUBLIC KEY"), "", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsPKCS1, err := NewJWTVerifier("", writePublicKey(t, &rsaKey.PublicKey, "RSA PUBLIC KEY"), "", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		verifier *JWTVerifier
+		token    string
+		wantSub  string
+		wantErr  error
+	}{
+		{name: "HS256 valid", verifier: hsOnly,
+			token: signToken(t, "HS256", claims(nil), []byte(testSecret)), wantSub: "pos-terminal-7"},
+		{name: "HS256 audience array", verifier: hsOnly,
+			token:   signToken(t, "HS256", claims(map[string]any{"aud": []string{"other", "sales-tracker"}}), []byte(testSecret)),
+			wantSub: "pos-terminal-7"},
+		{name: "HS256 expired within leeway", verifier: hsOnly,
+			token:   signToken(t, "HS256", claims(map[string]any{"exp": now.Add(-20 * time.Second).Unix()}), []byte(testSecret)),
+			wantSub: "pos-terminal-7"},
+		{name: "RS256 PKIX key", verifier: rsOnly,
+			token: signToken(t, "RS256", claims(nil), rsaKey), wantSub: "pos-terminal-7"},
+		{name: "RS256 PKCS1 key", verifier: rsPKCS1,
+			token: signToken(t, "RS256", claims(nil), rsaKey), wantSub: "pos-terminal-7"},
+
+		{name: "HS256 wrong secret", verifier: hsOnly,
+			token: signToken(t, "HS256", claims(nil), []byte("other-secret")), wantErr: ErrInvalidToken},
+		{name: "RS256 wrong key", verifier: rsOnly,
+			token: signToken(t, "RS256", claims(nil), otherKey), wantErr: ErrInvalidToken},
+		{name: "alg none", verifier: hsOnly,
+			token: signToken(t, "none", claims(nil), nil), wantErr: ErrInvalidToken},
+		{name: "RS256 token without RSA key", verifier: hsOnly,
+			token: signToken(t, "RS256", claims(nil), rsaKey), wantErr: ErrInvalidToken},
+		{name: "HS256 token without secret", verifier: rsOnly,
+			token: signToken(t, "HS256", claims(nil), []byte(testSecret)), wantErr: ErrInvalidToken},
+		{name: "expired", verifier: hsOnly,
+			token:   signToken(t, "HS256", claims(map[string]any{"exp": now.Add(-time.Minute).Unix()}), []byte(testSecret)),
+			wantErr: ErrInvalidToken},
+		{name: "not valid yet", verifier: hsOnly,
+			token:   signToken(t, "HS256", claims(map[string]any{"nbf": now.Add(time.Minute).Unix()}), []byte(testSecret)),
+			wantErr: ErrInvalidToken},
+		{name: "missing exp", verifier: hsOnly,
+			token: signToken(t, "HS256", claims(map[string]any{"exp": nil}), []byte(testSecret)), wantErr: ErrInvalidToken},
+		{name: "missing sub", verifier: hsOnly,
+			token: signToken(t, "HS256", claims(map[string]any{"sub": nil}), []byte(testSecret)), wantErr: ErrInvalidToken},
+		{name: "wrong issuer", verifier: hsOnly,
+			token:   signToken(t, "HS256", claims(map[string]any{"iss": "https://evil.example.com"}), []byte(testSecret)),
+			wantErr: ErrInvalidToken},
+		{name: "wrong audience", verifier: hsOnly,
+			token:   signToken(t, "HS256", claims(map[string]any{"aud": "billing"}), []byte(testSecret)),
+			wantErr: ErrInvalidToken},
+
+		{name: "two segments", verifier: hsOnly, token: "abc.def", wantErr: ErrMalformedToken},
+		{name: "header not base64", verifier: hsOnly, token: "!!!.e30.c2ln", wantErr: ErrMalformedToken},
+		{name: "signature not base64", verifier: hsOnly,
+			token: signToken(t, "HS256", claims(nil), []byte(testSecret)) + "!", wantErr: ErrMalformedToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.verifier.Verify(tt.token, now)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if got.Subject != tt.wantSub {
+				t.Errorf("Verify() sub = %q, want %q", got.Subject, tt.wantSub)
+			}
+		})
+	}
+}
+
+func TestNewJWTVerifier(t *testing.T) {
+	notPEM := filepath.Join(t.TempDir(), "key.txt")
+	if err := os.WriteFile(notPEM, []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		secret        string
+		publicKeyFile string
+		wantErr       bool
+	}{
+		{name: "secret", secret: testSecret},
+		{name: "no key", wantErr: true},
+		{name: "missing key file", publicKeyFile: filepath.Join(t.TempDir(), "missing.pem"), wantErr: true},
+		{name: "not PEM", publicKeyFile: notPEM, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewJWTVerifier(tt.secret, tt.publicKeyFile, "", "", 0)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewJWTVerifier() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"salesTracker/internal/auth/authfile"
+)
+
+// ====================================================================
+// RBAC - Роли и права доступа к роутам
+// ====================================================================
+
+// Роли по умолчанию
+const (
+	RoleAdmin   = "admin"
+	RoleManager = "manager"
+	RoleAnalyst = "analyst"
+	RoleClerk   = "clerk"
+)
+
+// Действия над ресурсом; определяются HTTP-методом запроса
+const (
+	ActionRead   = "read"
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// defaultPolicy — права ролей, если AUTH_POLICY_FILE не задан.
+// Право имеет вид "<ресурс>:<действие>", "*" подходит под любой ресурс или действие.
+var defaultPolicy = map[string][]string{
+	RoleAdmin: {"*:*"},
+	RoleManager: {
+		"categories:*", "products:*", "customers:*", "orders:*", "order-items:*", "returns:*",
+		"promotions:*", "exchange-rates:*", "report-schedules:*", "imports:*", "export:read", "analytics:read", "audit:read",
+		"metrics:read",
+	},
+	RoleAnalyst: {
+		"analytics:read", "report-schedules:*", "export:read", "metrics:read",
+		"categories:read", "products:read", "customers:read", "orders:read", "order-items:read",
+		"returns:read", "promotions:read", "exchange-rates:read",
+	},
+	RoleClerk: {
+		"categories:read", "products:read", "promotions:read",
+		"customers:read", "customers:create", "customers:update",
+		"orders:read", "orders:create", "orders:update",
+		"order-items:*", "returns:read", "returns:create",
+	},
+}
+
+// Policy — права ролей. Загружается из JSON-файла вида
+// {"clerk": ["orders:create", "orders:read"], "auditor": ["*:read"]}
+type Policy struct {
+	roles map[string][]string
+}
+
+// DefaultPolicy — встроенные роли admin, manager, analyst и clerk
+func DefaultPolicy() *Policy {
+	return &Policy{roles: defaultPolicy}
+}
+
+// LoadPolicy — политика из файла; пустой путь — политика по умолчанию
+func LoadPolicy(path string) (*Policy, error) {
+	if path == "" {
+		return DefaultPolicy(), nil
+	}
+
+	roles, err := authfile.ReadPolicy(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Policy{roles: roles}, nil
+}
+
+// HasRole — описана ли роль в политике
+func (p *Policy) HasRole(role string) bool {
+	_, ok := p.roles[role]
+	return ok
+}
+
+// Roles — имена ролей политики
+func (p *Policy) Roles() []string {
+	roles := make([]string, 0, len(p.roles))
+	for role := range p.roles {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// Allowed — разрешено ли хотя бы одной из ролей действие над ресурсом
+func (p *Policy) Allowed(roles []string, resource, action string) bool {
+	for _, role := range roles {
+		for _, permission := range p.roles[role] {
+			res, act, _ := strings.Cut(permission, ":")
+			if (res == "*" || res == resource) && (act == "*" || act == action) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Require — middleware группы роутов ресурса: действие определяется методом
+// запроса, при отсутствии права клиент получает 403 в формате RFC 7807.
+// Без Principal в контексте (аутентификация выключена) проверка не выполняется.
+func (p *Policy) Require(resource string) func(http.Handler) http.Handler {
+	return p.require(resource, "")
+}
+
+// RequireAction — как Require, но с явным действием для роутов, смысл
+// которых не выводится из метода (POST /{id}/restore отменяет удаление)
+func (p *Policy) RequireAction(resource, action string) func(http.Handler) http.Handler {
+	return p.require(resource, action)
+}
+
+func (p *Policy) require(resource, action string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := PrincipalFrom(r.Context())
+			if principal == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			action := action
+			if action == "" {
+				action = methodAction(r.Method)
+			}
+			if !p.Allowed(principal.Roles, resource, action) {
+				forbidden(w, r, fmt.Sprintf("%s is not allowed to %s %s", describe(principal), action, resource))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Problem — ответ об ошибке в формате RFC 7807
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
+
+func forbidden(w http.ResponseWriter, r *http.Request, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(http.StatusForbidden),
+		Status:   http.StatusForbidden,
+		Detail:   detail,
+		Instance: r.URL.Path,
+	})
+}
+
+func methodAction(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return ActionRead
+	case http.MethodPost:
+		return ActionCreate
+	case http.MethodDelete:
+		return ActionDelete
+	default:
+		return ActionUpdate
+	}
+}
+
+func describe(p *Principal) string {
+	if len(p.Roles) == 0 {
+		return p.Subject + " (no roles)"
+	}
+	return p.Subject + " (" + strings.Join(p.Roles, ", ") + ")"
+}
@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDefaultPolicyAllowed(t *testing.T) {
+	p := DefaultPolicy()
+
+	tests := []struct {
+		roles    []string
+		resource string
+		action   string
+		want     bool
+	}{
+		{roles: []string{RoleAdmin}, resource: "api-keys", action: ActionDelete, want: true},
+		{roles: []string{RoleAdmin}, resource: "anything-new", action: "restore", want: true},
+
+		{roles: []string{RoleManager}, resource: "products", action: ActionDelete, want: true},
+		{roles: []string{RoleManager}, resource: "audit", action: ActionRead, want: true},
+		{roles: []string{RoleManager}, resource: "api-keys", action: ActionCreate, want: false},
+		{roles: []string{RoleManager}, resource: "audit", action: ActionDelete, want: false},
+
+		// аналитик читает отчеты, но ничего не меняет
+		{roles: []string{RoleAnalyst}, resource: "analytics", action: ActionRead, want: true},
+		{roles: []string{RoleAnalyst}, resource: "metrics", action: ActionRead, want: true},
+		{roles: []string{RoleAnalyst}, resource: "orders", action: ActionRead, want: true},
+		{roles: []string{RoleAnalyst}, resource: "orders", action: ActionCreate, want: false},
+		{roles: []string{RoleAnalyst}, resource: "audit", action: ActionRead, want: false},
+
+		// кассир создает заказы, но не удаляет покупателей
+		{roles: []string{RoleClerk}, resource: "orders", action: ActionCreate, want: true},
+		{roles: []string{RoleClerk}, resource: "order-items", action: ActionDelete, want: true},
+		{roles: []string{RoleClerk}, resource: "customers", action: ActionUpdate, want: true},
+		{roles: []string{RoleClerk}, resource: "customers", action: ActionDelete, want: false},
+		{roles: []string{RoleClerk}, resource: "orders", action: ActionDelete, want: false},
+		{roles: []string{RoleClerk}, resource: "analytics", action: ActionRead, want: false},
+
+		// права нескольких ролей складываются
+		{roles: []string{RoleClerk, RoleAnalyst}, resource: "analytics", action: ActionRead, want: true},
+		{roles: []string{"auditor"}, resource: "orders", action: ActionRead, want: false},
+		{roles: nil, resource: "orders", action: ActionRead, want: false},
+	}
+
+	for _, tt := range tests {
+		name := strings.Join(tt.roles, "+") + " " + tt.action + " " + tt.resource
+		t.Run(name, func(t *testing.T) {
+			if got := p.Allowed(tt.roles, tt.resource, tt.action); got != tt.want {
+				t.Errorf("Allowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequire(t *testing.T) {
+	p := DefaultPolicy()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+
+	tests := []struct {
+		name       string
+		middleware func(http.Handler) http.Handler
+		method     string
+		principal  *Principal
+		wantStatus int
+	}{
+		{name: "read by method", middleware: p.Require("analytics"), method: http.MethodGet,
+			principal: &Principal{Subject: "ann", Roles: []string{RoleAnalyst}}, wantStatus: http.StatusNoContent},
+		{name: "create by method", middleware: p.Require("analytics"), method: http.MethodPost,
+			principal: &Principal{Subject: "ann", Roles: []string{RoleAnalyst}}, wantStatus: http.StatusForbidden},
+		{name: "patch is update", middleware: p.Require("customers"), method: http.MethodPatch,
+			principal: &Principal{Subject: "bob", Roles: []string{RoleClerk}}, wantStatus: http.StatusNoContent},
+		{name: "delete", middleware: p.Require("customers"), method: http.MethodDelete,
+			principal: &Principal{Subject: "bob", Roles: []string{RoleClerk}}, wantStatus: http.StatusForbidden},
+		// POST /{id}/restore проверяется как удаление, а не создание
+		{name: "explicit action", middleware: p.RequireAction("orders", ActionDelete), method: http.MethodPost,
+			principal: &Principal{Subject: "bob", Roles: []string{RoleClerk}}, wantStatus: http.StatusForbidden},
+		{name: "explicit action allowed", middleware: p.RequireAction("orders", ActionDelete), method: http.MethodPost,
+			principal: &Principal{Subject: "max", Roles: []string{RoleManager}}, wantStatus: http.StatusNoContent},
+		{name: "no roles", middleware: p.Require("orders"), method: http.MethodGet,
+			principal: &Principal{Subject: "key"}, wantStatus: http.StatusForbidden},
+		// аутентификация выключена: клиента в контексте нет
+		{name: "no principal", middleware: p.Require("api-keys"), method: http.MethodDelete,
+			wantStatus: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/api/v1/resource/1", nil)
+			if tt.principal != nil {
+				r = r.WithContext(WithPrincipal(r.Context(), tt.principal))
+			}
+			w := httptest.NewRecorder()
+			tt.middleware(ok).ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if w.Code != http.StatusForbidden {
+				return
+			}
+
+			if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Errorf("Content-Type = %q, want application/problem+json", ct)
+			}
+			var problem Problem
+			if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+				t.Fatal(err)
+			}
+			if problem.Status != http.StatusForbidden || problem.Instance != "/api/v1/resource/1" ||
+				!strings.Contains(problem.Detail, tt.principal.Subject) {
+				t.Errorf("problem = %+v", problem)
+			}
+		})
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	p, err := LoadPolicy("")
+	if err != nil {
+		t.Fatalf("LoadPolicy(\"\") error = %v", err)
+	}
+	if got := p.Roles(); strings.Join(got, ",") != "admin,analyst,clerk,manager" {
+		t.Errorf("default roles = %v", got)
+	}
+
+	// политика из файла заменяет встроенные роли целиком
+	p, err = LoadPolicy(write("policy.json", `{"auditor": ["*:read"], "cashier": ["orders:create"]}`))
+	if err != nil {
+		t.Fatalf("LoadPolicy() error = %v", err)
+	}
+	if p.HasRole(RoleAdmin) || !p.HasRole("auditor") {
+		t.Errorf("roles = %v, want only the file's roles", p.Roles())
+	}
+	if !p.Allowed([]string{"auditor"}, "audit", ActionRead) || p.Allowed([]string{"auditor"}, "audit", ActionDelete) {
+		t.Error("auditor: *:read must allow reading any resource and nothing else")
+	}
+	if !p.Allowed([]string{"cashier"}, "orders", ActionCreate) || p.Allowed([]string{"cashier"}, "orders", ActionRead) {
+		t.Error("cashier: orders:create must allow exactly that")
+	}
+
+	for name, content := range map[string]string{
+		"no-action.json":  `{"clerk": ["orders"]}`,
+		"empty-part.json": `{"clerk": [":read"]}`,
+		"broken.json":     `{"clerk": [`,
+	} {
+		if _, err := LoadPolicy(write(name, content)); err == nil {
+			t.Errorf("LoadPolicy(%s) error = nil", name)
+		}
+	}
+	if _, err := LoadPolicy(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("LoadPolicy(missing) error = nil")
+	}
+}
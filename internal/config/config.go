@@ -1,37 +1,328 @@
 package config
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
+// DefaultEnvFile — .env, который читается, если он есть, а путь не задан
+const DefaultEnvFile = ".env"
+
+// DefaultDir — каталог файлов конфигурации <ENV>.yaml
+const DefaultDir = "config"
+
+// Config — конфигурация сервиса. Значения накладываются слоями:
+// Default, файл YAML, переменные окружения, флаги -set.
 type Config struct {
-	Env        string   `env:"ENV" env-default:"local"`
-	Database   Database `env-prefix:"DB_"`
-	ServerPort string   `env:"SERVER_PORT" env-default:"8080"`
+	Env         string      `yaml:"env" env:"ENV"`
+	Server      Server      `yaml:"server" env-prefix:"SERVER_"`
+	Database    Database    `yaml:"database" env-prefix:"DB_"`
+	Log         Log         `yaml:"log" env-prefix:"LOG_"`
+	Scheduler   Scheduler   `yaml:"scheduler" env-prefix:"SCHEDULER_"`
+	Cache       Cache       `yaml:"cache" env-prefix:"CACHE_"`
+	Auth        Auth        `yaml:"auth" env-prefix:"AUTH_"`
+	Idempotency Idempotency `yaml:"idempotency" env-prefix:"IDEMPOTENCY_"`
+	Health      Health      `yaml:"health" env-prefix:"HEALTH_"`
+}
+
+// Server - HTTP-сервер. Нулевой таймаут - без ограничения. ShutdownTimeout -
+// сколько ждать начатые запросы при остановке. TLS включается, когда заданы
+// сертификат и ключ.
+type Server struct {
+	Port              string        `yaml:"port" env:"PORT"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"READ_TIMEOUT"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"READ_HEADER_TIMEOUT"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"WRITE_TIMEOUT"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"IDLE_TIMEOUT"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	TLSCertFile       string        `yaml:"tls_cert_file" env:"TLS_CERT_FILE"`
+	TLSKeyFile        string        `yaml:"tls_key_file" env:"TLS_KEY_FILE"`
 }
 
+// Database - подключение к PostgreSQL и пул соединений. ConnectTimeout -
+// сколько повторять первое подключение при запуске (0 - одна попытка).
+// QueryTimeout ограничивает одну операцию CRUD, AnalyticsTimeout - отчеты
+// и массовую запись (пакеты, импорт, курсы валют); 0 - без ограничения.
+// RollupInterval - как часто фоном пересчитывать дни дневной сводки из
+// очереди; 0 - только перед чтением отчетов.
 type Database struct {
-	Driver   string `env:"DRIVER" env-required:"true"`
-	Host     string `env:"HOST" env-required:"true"`
-	Port     string `env:"PORT" env-required:"true"`
-	User     string `env:"USER" env-required:"true"`
-	Password string `env:"PASSWORD" env-required:"true"`
-	Name     string `env:"NAME" env-required:"true"`
+	Driver           string        `yaml:"driver" env:"DRIVER"`
+	Host             string        `yaml:"host" env:"HOST"`
+	Port             string        `yaml:"port" env:"PORT"`
+	User             string        `yaml:"user" env:"USER"`
+	Password         string        `yaml:"password" env:"PASSWORD"`
+	Name             string        `yaml:"name" env:"NAME"`
+	SSLMode          string        `yaml:"sslmode" env:"SSLMODE"`
+	SSLRootCert      string        `yaml:"sslrootcert" env:"SSLROOTCERT"`
+	MaxOpenConns     int           `yaml:"max_open_conns" env:"MAX_OPEN_CONNS"`
+	MaxIdleConns     int           `yaml:"max_idle_conns" env:"MAX_IDLE_CONNS"`
+	ConnMaxLifetime  time.Duration `yaml:"conn_max_lifetime" env:"CONN_MAX_LIFETIME"`
+	ConnMaxIdleTime  time.Duration `yaml:"conn_max_idle_time" env:"CONN_MAX_IDLE_TIME"`
+	ConnectTimeout   time.Duration `yaml:"connect_timeout" env:"CONNECT_TIMEOUT"`
+	QueryTimeout     time.Duration `yaml:"query_timeout" env:"QUERY_TIMEOUT"`
+	AnalyticsTimeout time.Duration `yaml:"analytics_timeout" env:"ANALYTICS_TIMEOUT"`
+	RollupInterval   time.Duration `yaml:"rollup_interval" env:"ROLLUP_INTERVAL"`
+}
+
+// Log - журнал сервиса: уровень debug|info|warn|error, формат text|json
+type Log struct {
+	Level  string `yaml:"level" env:"LEVEL"`
+	Format string `yaml:"format" env:"FORMAT"`
+}
+
+// Scheduler - настройки планировщика регулярных отчетов
+type Scheduler struct {
+	Enabled   bool          `yaml:"enabled" env:"ENABLED"`
+	Interval  time.Duration `yaml:"interval" env:"INTERVAL"`
+	ReportDir string        `yaml:"report_dir" env:"REPORT_DIR"`
+	SMTP      SMTP          `yaml:"smtp" env-prefix:"SMTP_"`
+}
+
+// SMTP - параметры почтового сервера для доставки отчетов
+type SMTP struct {
+	Host     string `yaml:"host" env:"HOST"`
+	Port     string `yaml:"port" env:"PORT"`
+	User     string `yaml:"user" env:"USER"`
+	Password string `yaml:"password" env:"PASSWORD"`
+	From     string `yaml:"from" env:"FROM"`
+	// Timeout ограничивает всю отправку письма: соединение и обмен командами
+	Timeout time.Duration `yaml:"timeout" env:"TIMEOUT"`
+}
+
+// Cache - настройки кэша аналитики
+type Cache struct {
+	Enabled bool          `yaml:"enabled" env:"ENABLED"`
+	Size    int           `yaml:"size" env:"SIZE"`
+	TTL     time.Duration `yaml:"ttl" env:"TTL"`
+}
+
+// Auth - аутентификация API: JWT (HS256 по секрету или RS256 по открытому ключу)
+// и API-ключи. Первый API-ключ выпускается по JWT, поэтому при включенной
+// аутентификации нужен секрет или открытый ключ. PolicyFile — JSON с правами
+// ролей, по умолчанию используются встроенные роли admin/manager/analyst/clerk.
+type Auth struct {
+	Enabled          bool          `yaml:"enabled" env:"ENABLED"`
+	JWTSecret        string        `yaml:"jwt_secret" env:"JWT_SECRET"`
+	JWTPublicKeyFile string        `yaml:"jwt_public_key_file" env:"JWT_PUBLIC_KEY_FILE"`
+	JWTIssuer        string        `yaml:"jwt_issuer" env:"JWT_ISSUER"`
+	JWTAudience      string        `yaml:"jwt_audience" env:"JWT_AUDIENCE"`
+	JWTLeeway        time.Duration `yaml:"jwt_leeway" env:"JWT_LEEWAY"`
+	PolicyFile       string        `yaml:"policy_file" env:"POLICY_FILE"`
+}
+
+// Idempotency - хранение ответов на POST с заголовком Idempotency-Key:
+// повтор с тем же ключом в течение TTL получает сохраненный ответ
+type Idempotency struct {
+	TTL           time.Duration `yaml:"ttl" env:"TTL"`
+	PurgeInterval time.Duration `yaml:"purge_interval" env:"PURGE_INTERVAL"`
+}
+
+// Health - проверка готовности /health/ready: Timeout ограничивает все
+// проверки вместе
+type Health struct {
+	Timeout time.Duration `yaml:"timeout" env:"TIMEOUT"`
+}
+
+// Default - значения, которые действуют, пока их не задал ни один слой
+func Default() Config {
+	return Config{
+		Env: "local",
+		Server: Server{
+			Port:              "8080",
+			ReadTimeout:       30 * time.Second,
+			ReadHeaderTimeout: 10 * time.Second,
+			WriteTimeout:      60 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		Database: Database{
+			SSLMode:          "disable",
+			MaxOpenConns:     25,
+			MaxIdleConns:     10,
+			ConnMaxLifetime:  30 * time.Minute,
+			ConnMaxIdleTime:  5 * time.Minute,
+			ConnectTimeout:   30 * time.Second,
+			QueryTimeout:     5 * time.Second,
+			AnalyticsTimeout: 2 * time.Minute,
+			RollupInterval:   30 * time.Second,
+		},
+		Log: Log{Level: "info", Format: "text"},
+		Scheduler: Scheduler{
+			Enabled:   true,
+			Interval:  30 * time.Second,
+			ReportDir: "./reports",
+			SMTP:      SMTP{Host: "localhost", Port: "1025", From: "sales-tracker@localhost", Timeout: 30 * time.Second},
+		},
+		Cache:       Cache{Enabled: true, Size: 1024, TTL: 5 * time.Minute},
+		Auth:        Auth{Enabled: true, JWTLeeway: 30 * time.Second},
+		Idempotency: Idempotency{TTL: 24 * time.Hour, PurgeInterval: time.Hour},
+		Health:      Health{Timeout: 2 * time.Second},
+	}
 }
 
 func (d Database) DSN() string {
-	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		d.Host, d.Port, d.User, d.Password, d.Name)
+	params := []string{
+		"host=" + dsnValue(d.Host),
+		"port=" + dsnValue(d.Port),
+		"user=" + dsnValue(d.User),
+		"password=" + dsnValue(d.Password),
+		"dbname=" + dsnValue(d.Name),
+		"sslmode=" + dsnValue(d.SSLMode),
+	}
+	if d.SSLRootCert != "" {
+		params = append(params, "sslrootcert="+dsnValue(d.SSLRootCert))
+	}
+	return strings.Join(params, " ")
+}
+
+// dsnValue - значение в кавычках, чтобы пароль с пробелами или кавычками не ломал строку
+func dsnValue(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// ====================================================================
+// LOAD - Загрузка конфигурации по слоям
+// ====================================================================
+
+// Sources - откуда загружать конфигурацию
+type Sources struct {
+	// File - файл YAML; пустой - <Dir>/<ENV>.yaml, если такой файл есть
+	File string
+	Dir  string
+	// EnvFile дополняет окружение переменными, которых в нем нет;
+	// пустой путь - DefaultEnvFile, если он есть
+	EnvFile string
+	// Overrides - значения из флагов: "database.host=db", ключ - путь по именам YAML
+	Overrides []string
 }
 
-func MustLoad() (*Config, error) {
-	var cfg Config
+// Load - конфигурация по слоям: Default, файл, окружение, Overrides.
+// Ошибка слоя не прерывает загрузку: остальные слои и проверка все равно
+// выполняются, и ошибка объединяет (errors.Join) все найденные проблемы.
+func Load(src Sources) (*Config, error) {
+	const op = "config.Load"
+
+	var errs []error
+
+	envFile := src.EnvFile
+	if envFile == "" {
+		if _, err := os.Stat(DefaultEnvFile); err == nil {
+			envFile = DefaultEnvFile
+		}
+	}
+	if envFile != "" {
+		if err := godotenv.Load(envFile); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				errs = append(errs, fmt.Errorf("%s: env file %s does not exist", op, envFile))
+			} else {
+				errs = append(errs, fmt.Errorf("%s: %s: %w", op, envFile, err))
+			}
+		}
+	}
+
+	cfg := Default()
+
+	file, required := src.File, true
+	if file == "" {
+		env := os.Getenv("ENV")
+		if env == "" {
+			env = cfg.Env
+		}
+		dir := src.Dir
+		if dir == "" {
+			dir = DefaultDir
+		}
+		// файл окружения необязателен: конфигурация может целиком задаваться окружением
+		file, required = filepath.Join(dir, env+".yaml"), false
+	}
+	if err := readFile(file, required, &cfg); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %w", op, err))
+	}
 
 	if err := cleanenv.ReadEnv(&cfg); err != nil {
-		return nil, err
+		errs = append(errs, fmt.Errorf("%s: %w", op, err))
+	}
+
+	for _, override := range src.Overrides {
+		if err := cfg.Set(override); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", op, err))
+		}
 	}
 
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
 	return &cfg, nil
 }
+
+func readFile(path string, required bool, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// Set - задать значение по пути из имен YAML: "server.port=8081"
+func (c *Config) Set(assignment string) error {
+	key, value, ok := strings.Cut(assignment, "=")
+	if !ok {
+		return fmt.Errorf("override %q: use key=value, for example server.port=8081", assignment)
+	}
+
+	field := reflect.ValueOf(c).Elem()
+	for _, name := range strings.Split(key, ".") {
+		if field.Kind() != reflect.Struct {
+			return fmt.Errorf("override %q: %s is not a section", assignment, name)
+		}
+		next, found := reflect.Value{}, false
+		for i := 0; i < field.NumField(); i++ {
+			if field.Type().Field(i).Tag.Get("yaml") == name {
+				next, found = field.Field(i), true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("override %q: unknown key %s", assignment, key)
+		}
+		field = next
+	}
+	if field.Kind() == reflect.Struct {
+		return fmt.Errorf("override %q: %s is a section, set its keys", assignment, key)
+	}
+
+	// значение разбирается как YAML: длительности, числа и bool - как в файле
+	if field.Kind() == reflect.String {
+		field.SetString(value)
+		return nil
+	}
+	if err := yaml.Unmarshal([]byte(value), field.Addr().Interface()); err != nil {
+		return fmt.Errorf("override %q: %w", assignment, err)
+	}
+	return nil
+}
@@ -0,0 +1,358 @@
+package config
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// baseYAML — минимальный файл, с которым конфигурация проходит проверку
+const baseYAML = `
+database:
+  driver: postgres
+  host: localhost
+  port: "5432"
+  user: app
+  name: sales
+auth:
+  jwt_secret: secret
+`
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		env       map[string]string
+		overrides []string
+		check     func(t *testing.T, cfg *Config)
+		// wantErr — подстроки, которые должны быть в ошибке
+		wantErr []string
+	}{
+		{
+			name: "file over defaults",
+			file: baseYAML + "server:\n  port: \"9000\"\n",
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Server.Port != "9000" {
+					t.Errorf("server.port = %q, want 9000", cfg.Server.Port)
+				}
+				// не заданное в файле остается по умолчанию
+				if cfg.Server.ReadTimeout != 30*time.Second {
+					t.Errorf("server.read_timeout = %s, want 30s", cfg.Server.ReadTimeout)
+				}
+				if cfg.Database.Host != "localhost" {
+					t.Errorf("database.host = %q, want localhost", cfg.Database.Host)
+				}
+			},
+		},
+		{
+			name: "env over file",
+			file: baseYAML,
+			env:  map[string]string{"DB_HOST": "db", "CACHE_TTL": "1m", "SCHEDULER_SMTP_PORT": "2525"},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Database.Host != "db" {
+					t.Errorf("database.host = %q, want db", cfg.Database.Host)
+				}
+				if cfg.Cache.TTL != time.Minute {
+					t.Errorf("cache.ttl = %s, want 1m", cfg.Cache.TTL)
+				}
+				if cfg.Scheduler.SMTP.Port != "2525" {
+					t.Errorf("scheduler.smtp.port = %q, want 2525", cfg.Scheduler.SMTP.Port)
+				}
+			},
+		},
+		{
+			name:      "overrides over env",
+			file:      baseYAML,
+			env:       map[string]string{"DB_HOST": "db"},
+			overrides: []string{"database.host=flag", "cache.enabled=false", "database.max_open_conns=50"},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Database.Host != "flag" {
+					t.Errorf("database.host = %q, want flag", cfg.Database.Host)
+				}
+				if cfg.Cache.Enabled {
+					t.Error("cache.enabled = true, want false")
+				}
+				if cfg.Database.MaxOpenConns != 50 {
+					t.Errorf("database.max_open_conns = %d, want 50", cfg.Database.MaxOpenConns)
+				}
+			},
+		},
+		{
+			name:    "unknown key in file",
+			file:    baseYAML + "databse:\n  host: typo\n",
+			wantErr: []string{"config.Load", "databse"},
+		},
+		{
+			name:    "invalid env value",
+			file:    baseYAML,
+			env:     map[string]string{"SERVER_READ_TIMEOUT": "soon"},
+			wantErr: []string{"config.Load"},
+		},
+		{
+			name:      "all layers reported together",
+			file:      baseYAML,
+			overrides: []string{"server.prot=1", "log.level=verbose"},
+			wantErr:   []string{"unknown key server.prot", `log.level: "verbose"`},
+		},
+		{
+			name:    "validation problems",
+			file:    "database:\n  driver: postgres\n",
+			wantErr: []string{"database.host: required", "database.user: required", "auth: enabled authentication needs"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			file := writeFile(t, t.TempDir(), "test.yaml", tt.file)
+
+			cfg, err := Load(Sources{File: file, Overrides: tt.overrides})
+			if len(tt.wantErr) > 0 {
+				if err == nil {
+					t.Fatalf("Load() error = nil, want %q", tt.wantErr)
+				}
+				for _, want := range tt.wantErr {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("Load() error = %v, want it to contain %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestLoadFileByEnv(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "staging.yaml", baseYAML+"log:\n  format: json\n")
+
+	tests := []struct {
+		name       string
+		env        string
+		file       string
+		wantFormat string
+		wantErr    bool
+	}{
+		{name: "file of ENV", env: "staging", wantFormat: "json"},
+		// файл окружения необязателен, но без него не хватает базы
+		{name: "no file for ENV", env: "production", wantErr: true},
+		{name: "explicit file must exist", env: "staging", file: filepath.Join(dir, "missing.yaml"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ENV", tt.env)
+
+			cfg, err := Load(Sources{File: tt.file, Dir: dir})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && cfg.Log.Format != tt.wantFormat {
+				t.Errorf("log.format = %q, want %q", cfg.Log.Format, tt.wantFormat)
+			}
+		})
+	}
+}
+
+func TestLoadErrorsJoined(t *testing.T) {
+	file := writeFile(t, t.TempDir(), "test.yaml", "database:\n  driver: postgres\n")
+
+	_, err := Load(Sources{
+		File:      file,
+		EnvFile:   filepath.Join(t.TempDir(), "missing.env"),
+		Overrides: []string{"server.port"},
+	})
+	if err == nil {
+		t.Fatal("Load() error = nil")
+	}
+
+	// ошибки всех слоев и проверки приходят вместе
+	for _, want := range []string{"env file", "use key=value", "database.host: required"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Load() error = %v, want it to contain %q", err, want)
+		}
+	}
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Load() error = %v, want *ValidationError inside", err)
+	}
+}
+
+func TestConfigSet(t *testing.T) {
+	tests := []struct {
+		name       string
+		assignment string
+		check      func(cfg Config) bool
+		wantErr    bool
+	}{
+		{name: "string", assignment: "server.port=8081",
+			check: func(cfg Config) bool { return cfg.Server.Port == "8081" }},
+		{name: "string keeps equals sign", assignment: "database.password=a=b",
+			check: func(cfg Config) bool { return cfg.Database.Password == "a=b" }},
+		{name: "duration", assignment: "cache.ttl=90s",
+			check: func(cfg Config) bool { return cfg.Cache.TTL == 90*time.Second }},
+		{name: "int", assignment: "cache.size=16",
+			check: func(cfg Config) bool { return cfg.Cache.Size == 16 }},
+		{name: "bool", assignment: "scheduler.enabled=false",
+			check: func(cfg Config) bool { return !cfg.Scheduler.Enabled }},
+		{name: "nested section", assignment: "scheduler.smtp.host=mail",
+			check: func(cfg Config) bool { return cfg.Scheduler.SMTP.Host == "mail" }},
+
+		{name: "no equals sign", assignment: "server.port", wantErr: true},
+		{name: "unknown key", assignment: "server.host=x", wantErr: true},
+		{name: "section", assignment: "server=x", wantErr: true},
+		{name: "key under scalar", assignment: "server.port.value=1", wantErr: true},
+		{name: "bad duration", assignment: "cache.ttl=soon", wantErr: true},
+		{name: "bad int", assignment: "cache.size=many", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			err := cfg.Set(tt.assignment)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Set(%q) error = %v, wantErr %v", tt.assignment, err, tt.wantErr)
+			}
+			if err == nil && !tt.check(cfg) {
+				t.Errorf("Set(%q) did not apply the value", tt.assignment)
+			}
+		})
+	}
+}
+
+// publicKeyPEM — открытый ключ RSA в PEM для проверки auth.jwt_public_key_file
+func publicKeyPEM(t *testing.T) string {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	publicKey := writeFile(t, dir, "jwt.pem", publicKeyPEM(t))
+	badKey := writeFile(t, dir, "bad.pem", "not a key")
+	policy := writeFile(t, dir, "policy.json", `{"auditor": ["*:read"]}`)
+	badPolicy := writeFile(t, dir, "bad-policy.json", `{"auditor": ["read"]}`)
+	missing := filepath.Join(dir, "missing")
+
+	valid := func() Config {
+		cfg := Default()
+		cfg.Database.Driver = "postgres"
+		cfg.Database.Host = "localhost"
+		cfg.Database.Port = "5432"
+		cfg.Database.User = "app"
+		cfg.Database.Name = "sales"
+		cfg.Auth.JWTSecret = "secret"
+		return cfg
+	}
+
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+		// want — ожидаемые проблемы, пусто — конфигурация верна
+		want []string
+	}{
+		{name: "valid", modify: func(cfg *Config) {}},
+		{name: "auth by public key", modify: func(cfg *Config) {
+			cfg.Auth.JWTSecret, cfg.Auth.JWTPublicKeyFile = "", publicKey
+		}},
+		{name: "policy file", modify: func(cfg *Config) { cfg.Auth.PolicyFile = policy }},
+		{name: "key not read with auth disabled", modify: func(cfg *Config) {
+			cfg.Auth.Enabled, cfg.Auth.JWTPublicKeyFile = false, badKey
+		}},
+		{name: "auth disabled without key", modify: func(cfg *Config) {
+			cfg.Auth.Enabled, cfg.Auth.JWTSecret = false, ""
+		}},
+		{name: "disabled sections not checked", modify: func(cfg *Config) {
+			cfg.Cache.Enabled, cfg.Cache.Size = false, 0
+			cfg.Scheduler.Enabled, cfg.Scheduler.Interval = false, 0
+		}},
+
+		{name: "port not a number", modify: func(cfg *Config) { cfg.Server.Port = "http" },
+			want: []string{`server.port: "http" is not a port number`}},
+		{name: "port out of range", modify: func(cfg *Config) { cfg.Database.Port = "70000" },
+			want: []string{`database.port: "70000" is not a port number`}},
+		{name: "only TLS cert", modify: func(cfg *Config) { cfg.Server.TLSCertFile = "cert.pem" },
+			want: []string{"server.tls_cert_file, server.tls_key_file: set both to enable TLS"}},
+		{name: "unknown sslmode", modify: func(cfg *Config) { cfg.Database.SSLMode = "on" },
+			want: []string{`database.sslmode: "on", use one of disable, allow, prefer, require, verify-ca, verify-full`}},
+		{name: "idle over open conns", modify: func(cfg *Config) { cfg.Database.MaxOpenConns, cfg.Database.MaxIdleConns = 5, 10 },
+			want: []string{"database.max_idle_conns: must not exceed database.max_open_conns"}},
+		{name: "negative timeout", modify: func(cfg *Config) { cfg.Database.QueryTimeout = -time.Second },
+			want: []string{"database.query_timeout: must not be negative"}},
+		{name: "zero cache size", modify: func(cfg *Config) { cfg.Cache.Size = 0 },
+			want: []string{"cache.size: must be positive"}},
+		{name: "auth without key", modify: func(cfg *Config) { cfg.Auth.JWTSecret = "" },
+			want: []string{"auth: enabled authentication needs auth.jwt_secret or auth.jwt_public_key_file"}},
+		{name: "missing public key", modify: func(cfg *Config) { cfg.Auth.JWTPublicKeyFile = missing + ".pem" },
+			want: []string{"auth.jwt_public_key_file: read jwt public key: open " + missing + ".pem: no such file or directory"}},
+		{name: "malformed public key", modify: func(cfg *Config) { cfg.Auth.JWTPublicKeyFile = badKey },
+			want: []string{"auth.jwt_public_key_file: jwt public key: no PEM block found"}},
+		{name: "missing policy file", modify: func(cfg *Config) { cfg.Auth.PolicyFile = missing + ".json" },
+			want: []string{"auth.policy_file: read policy: open " + missing + ".json: no such file or directory"}},
+		{name: "invalid policy", modify: func(cfg *Config) { cfg.Auth.PolicyFile = badPolicy },
+			want: []string{"auth.policy_file: policy " + badPolicy + `: role auditor: invalid permission "read", use <resource>:<action>`}},
+		{name: "all problems at once", modify: func(cfg *Config) {
+			cfg.Log.Level, cfg.Log.Format = "trace", "xml"
+			cfg.Health.Timeout = 0
+		}, want: []string{
+			`log.level: "trace", use one of debug, info, warn, error`,
+			`log.format: "xml", use one of text, json`,
+			"health.timeout: must be positive",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.modify(&cfg)
+
+			err := cfg.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("Validate() error = %v, want *ValidationError", err)
+			}
+			if strings.Join(verr.Problems, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Validate() problems = %q, want %q", verr.Problems, tt.want)
+			}
+		})
+	}
+}
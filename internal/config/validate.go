@@ -0,0 +1,178 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"salesTracker/internal/auth/authfile"
+)
+
+// ====================================================================
+// VALIDATE - Проверка и вывод конфигурации
+// ====================================================================
+
+// redacted — замена секретов при выводе конфигурации
+const redacted = "******"
+
+var (
+	sslModes   = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+	logLevels  = []string{"debug", "info", "warn", "error"}
+	logFormats = []string{"text", "json"}
+)
+
+// ValidationError — все проблемы конфигурации сразу, а не только первая
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// Validate — проверить значения после наложения всех слоев
+func (c *Config) Validate() error {
+	var v validator
+
+	v.port("server.port", c.Server.Port)
+	v.nonNegative("server.read_timeout", c.Server.ReadTimeout)
+	v.nonNegative("server.read_header_timeout", c.Server.ReadHeaderTimeout)
+	v.nonNegative("server.write_timeout", c.Server.WriteTimeout)
+	v.nonNegative("server.idle_timeout", c.Server.IdleTimeout)
+	v.positive("server.shutdown_timeout", c.Server.ShutdownTimeout)
+	if (c.Server.TLSCertFile == "") != (c.Server.TLSKeyFile == "") {
+		v.add("server.tls_cert_file, server.tls_key_file: set both to enable TLS")
+	}
+
+	v.required("database.driver", c.Database.Driver)
+	v.required("database.host", c.Database.Host)
+	v.port("database.port", c.Database.Port)
+	v.required("database.user", c.Database.User)
+	v.required("database.name", c.Database.Name)
+	v.oneOf("database.sslmode", c.Database.SSLMode, sslModes)
+	if c.Database.MaxOpenConns < 0 {
+		v.add("database.max_open_conns: must not be negative (0 - unlimited)")
+	}
+	if c.Database.MaxIdleConns < 0 {
+		v.add("database.max_idle_conns: must not be negative")
+	}
+	if c.Database.MaxOpenConns > 0 && c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		v.add("database.max_idle_conns: must not exceed database.max_open_conns")
+	}
+	v.nonNegative("database.conn_max_lifetime", c.Database.ConnMaxLifetime)
+	v.nonNegative("database.conn_max_idle_time", c.Database.ConnMaxIdleTime)
+	v.nonNegative("database.connect_timeout", c.Database.ConnectTimeout)
+	v.nonNegative("database.query_timeout", c.Database.QueryTimeout)
+	v.nonNegative("database.analytics_timeout", c.Database.AnalyticsTimeout)
+	v.nonNegative("database.rollup_interval", c.Database.RollupInterval)
+
+	v.oneOf("log.level", c.Log.Level, logLevels)
+	v.oneOf("log.format", c.Log.Format, logFormats)
+
+	if c.Scheduler.Enabled {
+		v.positive("scheduler.interval", c.Scheduler.Interval)
+		v.required("scheduler.report_dir", c.Scheduler.ReportDir)
+		v.port("scheduler.smtp.port", c.Scheduler.SMTP.Port)
+		v.positive("scheduler.smtp.timeout", c.Scheduler.SMTP.Timeout)
+	}
+
+	if c.Cache.Enabled {
+		if c.Cache.Size <= 0 {
+			v.add("cache.size: must be positive")
+		}
+		v.positive("cache.ttl", c.Cache.TTL)
+	}
+
+	if c.Auth.Enabled && c.Auth.JWTSecret == "" && c.Auth.JWTPublicKeyFile == "" {
+		v.add("auth: enabled authentication needs auth.jwt_secret or auth.jwt_public_key_file")
+	}
+	// файлы читаются так же, как при запуске: ошибка в них видна до деплоя
+	if c.Auth.Enabled && c.Auth.JWTPublicKeyFile != "" {
+		if _, err := authfile.ReadRSAPublicKey(c.Auth.JWTPublicKeyFile); err != nil {
+			v.add("auth.jwt_public_key_file: " + err.Error())
+		}
+	}
+	if c.Auth.PolicyFile != "" {
+		if _, err := authfile.ReadPolicy(c.Auth.PolicyFile); err != nil {
+			v.add("auth.policy_file: " + err.Error())
+		}
+	}
+	v.nonNegative("auth.jwt_leeway", c.Auth.JWTLeeway)
+
+	v.positive("idempotency.ttl", c.Idempotency.TTL)
+	v.positive("idempotency.purge_interval", c.Idempotency.PurgeInterval)
+
+	v.positive("health.timeout", c.Health.Timeout)
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+// Redacted — копия для вывода: секреты заменены, пустые остаются пустыми
+func (c Config) Redacted() Config {
+	for _, secret := range []*string{&c.Database.Password, &c.Auth.JWTSecret, &c.Scheduler.SMTP.Password} {
+		if *secret != "" {
+			*secret = redacted
+		}
+	}
+	return c
+}
+
+// Dump — действующая конфигурация в YAML без секретов
+func (c Config) Dump(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c.Redacted()); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) add(problem string) {
+	v.problems = append(v.problems, problem)
+}
+
+func (v *validator) required(key, value string) {
+	if value == "" {
+		v.add(key + ": required")
+	}
+}
+
+func (v *validator) port(key, value string) {
+	if value == "" {
+		v.add(key + ": required")
+		return
+	}
+	if n, err := strconv.Atoi(value); err != nil || n <= 0 || n > 65535 {
+		v.add(fmt.Sprintf("%s: %q is not a port number", key, value))
+	}
+}
+
+func (v *validator) oneOf(key, value string, allowed []string) {
+	if !slices.Contains(allowed, value) {
+		v.add(fmt.Sprintf("%s: %q, use one of %s", key, value, strings.Join(allowed, ", ")))
+	}
+}
+
+func (v *validator) positive(key string, d time.Duration) {
+	if d <= 0 {
+		v.add(key + ": must be positive")
+	}
+}
+
+func (v *validator) nonNegative(key string, d time.Duration) {
+	if d < 0 {
+		v.add(key + ": must not be negative")
+	}
+}
@@ -0,0 +1,78 @@
+package etag
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ====================================================================
+// ETAG - Условные запросы по версии записи
+// ====================================================================
+
+var (
+	// ErrPreconditionRequired — изменяющий запрос пришел без If-Match (428)
+	ErrPreconditionRequired = errors.New("If-Match header is required, use the ETag from GET")
+	// ErrPreconditionFailed — If-Match не совпал с текущей версией (412)
+	ErrPreconditionFailed = errors.New("resource has been modified, reload it and retry")
+)
+
+// Format — ETag версии записи: версия 3 дает "3"
+func Format(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// Set — добавить ETag версии в заголовки ответа
+func Set(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", Format(version))
+}
+
+// NotModified — для GET: ставит ETag и, если If-None-Match совпал с версией,
+// отвечает 304 без тела. true означает, что ответ уже записан.
+func NotModified(w http.ResponseWriter, r *http.Request, version int) bool {
+	Set(w, version)
+
+	header := r.Header.Get("If-None-Match")
+	if header == "" || !match(header, version, true) {
+		return false
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// CheckIfMatch — разрешает ли If-Match изменить запись версии version.
+// "*" подходит под любую версию; слабые ETag (W/"3") для If-Match не годятся.
+func CheckIfMatch(r *http.Request, version int) error {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return ErrPreconditionRequired
+	}
+	if !match(header, version, false) {
+		return ErrPreconditionFailed
+	}
+	return nil
+}
+
+// match — есть ли в списке ETag заголовка тег версии; weak разрешает
+// слабое сравнение (If-None-Match)
+func match(header string, version int, weak bool) bool {
+	want := Format(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if strings.HasPrefix(tag, "W/") {
+			if !weak {
+				continue
+			}
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package etag
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		version int
+		want    string
+	}{
+		{version: 1, want: `"1"`},
+		{version: 42, want: `"42"`},
+	}
+
+	for _, tt := range tests {
+		if got := Format(tt.version); got != tt.want {
+			t.Errorf("Format(%d) = %s, want %s", tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestCheckIfMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		version int
+		wantErr error
+	}{
+		{name: "same version", header: `"3"`, version: 3},
+		{name: "wildcard", header: "*", version: 3},
+		{name: "one of list", header: `"1", "3"`, version: 3},
+		{name: "list without spaces", header: `"1","3"`, version: 3},
+
+		{name: "missing header", header: "", version: 3, wantErr: ErrPreconditionRequired},
+		{name: "other version", header: `"2"`, version: 3, wantErr: ErrPreconditionFailed},
+		{name: "weak tag rejected", header: `W/"3"`, version: 3, wantErr: ErrPreconditionFailed},
+		{name: "unquoted", header: "3", version: 3, wantErr: ErrPreconditionFailed},
+		{name: "prefix of version", header: `"3"`, version: 31, wantErr: ErrPreconditionFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/api/v1/products/1", nil)
+			if tt.header != "" {
+				r.Header.Set("If-Match", tt.header)
+			}
+
+			if err := CheckIfMatch(r, tt.version); !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckIfMatch(%s) error = %v, want %v", tt.header, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	tests := []struct {
+		name       string
+		header     string
+		version    int
+		want       bool
+		wantStatus int
+	}{
+		{name: "no header", header: "", version: 3, want: false, wantStatus: http.StatusOK},
+		{name: "same version", header: `"3"`, version: 3, want: true, wantStatus: http.StatusNotModified},
+		{name: "weak tag accepted", header: `W/"3"`, version: 3, want: true, wantStatus: http.StatusNotModified},
+		{name: "wildcard", header: "*", version: 3, want: true, wantStatus: http.StatusNotModified},
+		{name: "one of list", header: `"1", W/"3"`, version: 3, want: true, wantStatus: http.StatusNotModified},
+		{name: "other version", header: `"2"`, version: 3, want: false, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/products/1", nil)
+			if tt.header != "" {
+				r.Header.Set("If-None-Match", tt.header)
+			}
+			w := httptest.NewRecorder()
+
+			if got := NotModified(w, r, tt.version); got != tt.want {
+				t.Errorf("NotModified() = %v, want %v", got, tt.want)
+			}
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("ETag"); got != Format(tt.version) {
+				t.Errorf("ETag = %s, want %s", got, Format(tt.version))
+			}
+		})
+	}
+}
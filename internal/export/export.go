@@ -0,0 +1,203 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"salesTracker/internal/storage/postgresql"
+)
+
+// ====================================================================
+// EXPORT - Запись выгрузки в NDJSON, CSV и Parquet
+// ====================================================================
+
+// Форматы выгрузки
+const (
+	FormatNDJSON  = "ndjson"
+	FormatCSV     = "csv"
+	FormatParquet = "parquet"
+)
+
+// ErrUnsupportedFormat — формат выгрузки не поддерживается
+var ErrUnsupportedFormat = errors.New("unsupported export format")
+
+// Rows — источник строк выгрузки, io.EOF — строки закончились
+type Rows interface {
+	Next() ([]any, error)
+}
+
+// ContentType — тип содержимого для формата
+func ContentType(format string) (string, error) {
+	switch format {
+	case FormatNDJSON:
+		return "application/x-ndjson", nil
+	case FormatCSV:
+		return "text/csv; charset=utf-8", nil
+	case FormatParquet:
+		return "application/vnd.apache.parquet", nil
+	default:
+		return "", fmt.Errorf("%w %q, use %s, %s or %s", ErrUnsupportedFormat, format, FormatNDJSON, FormatCSV, FormatParquet)
+	}
+}
+
+// Write — записать строки в w по одной, не накапливая их в памяти
+// (Parquet — колоночный формат, он держит в памяти одну группу строк);
+// возвращает число записанных строк
+func Write(w io.Writer, format string, columns []postgresql.ExportColumn, rows Rows) (int, error) {
+	if _, err := ContentType(format); err != nil {
+		return 0, err
+	}
+
+	buf := bufio.NewWriterSize(w, 64*1024)
+	var enc encoder
+	switch format {
+	case FormatCSV:
+		enc = newCSVEncoder(buf, columns)
+	case FormatParquet:
+		var err error
+		if enc, err = newParquetEncoder(buf, columns); err != nil {
+			return 0, err
+		}
+	default:
+		enc = newNDJSONEncoder(buf, columns)
+	}
+
+	written := 0
+	for {
+		values, err := rows.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return written, err
+		}
+		if err := enc.encode(values); err != nil {
+			return written, err
+		}
+		written++
+	}
+
+	if err := enc.flush(); err != nil {
+		return written, err
+	}
+	return written, buf.Flush()
+}
+
+// ====================================================================
+// ENCODERS
+// ====================================================================
+
+type encoder interface {
+	encode(values []any) error
+	flush() error
+}
+
+// ndjsonEncoder — объект JSON на строку, поля в порядке колонок
+type ndjsonEncoder struct {
+	w       *bufio.Writer
+	columns []postgresql.ExportColumn
+	// keys — `"name":` для каждой колонки
+	keys [][]byte
+	line []byte
+}
+
+func newNDJSONEncoder(w *bufio.Writer, columns []postgresql.ExportColumn) *ndjsonEncoder {
+	e := &ndjsonEncoder{w: w, columns: columns, keys: make([][]byte, len(columns))}
+	for i, column := range columns {
+		name, _ := json.Marshal(column.Name)
+		e.keys[i] = append(name, ':')
+	}
+	return e
+}
+
+func (e *ndjsonEncoder) encode(values []any) error {
+	e.line = append(e.line[:0], '{')
+	for i, column := range e.columns {
+		if i > 0 {
+			e.line = append(e.line, ',')
+		}
+		e.line = append(e.line, e.keys[i]...)
+
+		value, err := jsonValue(values[i], column.Type == postgresql.ExportNumeric)
+		if err != nil {
+			return fmt.Errorf("column %s: %w", column.Name, err)
+		}
+		e.line = append(e.line, value...)
+	}
+	e.line = append(e.line, '}', '\n')
+
+	_, err := e.w.Write(e.line)
+	return err
+}
+
+func (e *ndjsonEncoder) flush() error { return nil }
+
+func jsonValue(value any, numeric bool) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return []byte("null"), nil
+	case []byte:
+		if numeric {
+			return v, nil
+		}
+		return json.Marshal(string(v))
+	case time.Time:
+		return json.Marshal(v.UTC().Format(time.RFC3339Nano))
+	default:
+		return json.Marshal(v)
+	}
+}
+
+// csvEncoder — строка заголовка с именами колонок, NULL — пустое поле
+type csvEncoder struct {
+	w      *csv.Writer
+	record []string
+}
+
+func newCSVEncoder(w io.Writer, columns []postgresql.ExportColumn) *csvEncoder {
+	e := &csvEncoder{w: csv.NewWriter(w), record: make([]string, len(columns))}
+	for i, column := range columns {
+		e.record[i] = column.Name
+	}
+	e.w.Write(e.record)
+	return e
+}
+
+func (e *csvEncoder) encode(values []any) error {
+	for i, value := range values {
+		e.record[i] = csvValue(value)
+	}
+	return e.w.Write(e.record)
+}
+
+func (e *csvEncoder) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func csvValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,439 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"salesTracker/internal/storage/postgresql"
+)
+
+// ====================================================================
+// PARQUET - Запись выгрузки в Apache Parquet
+// ====================================================================
+
+// Минимальная запись формата без сторонних библиотек: все колонки OPTIONAL,
+// одна страница данных (DataPage v1) на колонку в группе строк, значения
+// в кодировке PLAIN без сжатия, уровни определения — RLE. Метаданные
+// пишутся протоколом Thrift compact, как требует спецификация.
+//
+// Типы колонок: ExportInteger — INT64, ExportNumeric — INT64 DECIMAL(18, Scale),
+// ExportTimestamp — INT64 TIMESTAMP_MICROS (UTC), ExportText — BYTE_ARRAY UTF8.
+
+// parquetMagic — начало и конец файла
+const parquetMagic = "PAR1"
+
+// parquetRowGroupRows — строк в группе: группа целиком держится в памяти
+const parquetRowGroupRows = 64 * 1024
+
+// parquetDecimalPrecision — наибольшая точность DECIMAL в INT64
+const parquetDecimalPrecision = 18
+
+// Значения перечислений из parquet.thrift
+const (
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	parquetOptional = 1
+
+	parquetUTF8            = 0
+	parquetDecimal         = 5
+	parquetTimestampMicros = 10
+
+	parquetPlain = 0
+	parquetRLE   = 3
+
+	parquetUncompressed = 0
+	parquetDataPage     = 0
+)
+
+// parquetEncoder — копит группу строк по колонкам и пишет ее целиком;
+// метаданные групп пишутся в конец файла в flush
+type parquetEncoder struct {
+	w       io.Writer
+	offset  int64
+	columns []*parquetColumn
+	rows    int
+	total   int64
+	groups  []parquetRowGroup
+}
+
+// parquetColumn — значения колонки текущей группы строк
+type parquetColumn struct {
+	postgresql.ExportColumn
+	physical int32
+	// defined — уровень определения строки: false — NULL
+	defined []bool
+	values  bytes.Buffer
+}
+
+// parquetRowGroup — записанная группа строк
+type parquetRowGroup struct {
+	chunks []parquetChunk
+	size   int64
+	rows   int
+}
+
+// parquetChunk — записанная колонка группы строк
+type parquetChunk struct {
+	offset int64
+	size   int64
+}
+
+func newParquetEncoder(w io.Writer, columns []postgresql.ExportColumn) (*parquetEncoder, error) {
+	e := &parquetEncoder{w: w}
+	for _, column := range columns {
+		c := &parquetColumn{ExportColumn: column, physical: parquetInt64}
+		switch column.Type {
+		case postgresql.ExportText:
+			c.physical = parquetByteArray
+		case postgresql.ExportInteger, postgresql.ExportNumeric, postgresql.ExportTimestamp:
+		default:
+			return nil, fmt.Errorf("%w: parquet: column %s has unknown type %d", ErrUnsupportedFormat, column.Name, column.Type)
+		}
+		e.columns = append(e.columns, c)
+	}
+
+	if err := e.write([]byte(parquetMagic)); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *parquetEncoder) encode(values []any) error {
+	for i, c := range e.columns {
+		if err := c.append(values[i]); err != nil {
+			return fmt.Errorf("column %s: %w", c.Name, err)
+		}
+	}
+
+	e.rows++
+	if e.rows == parquetRowGroupRows {
+		return e.writeRowGroup()
+	}
+	return nil
+}
+
+func (e *parquetEncoder) flush() error {
+	if e.rows > 0 {
+		if err := e.writeRowGroup(); err != nil {
+			return err
+		}
+	}
+
+	footer := e.footer()
+	if err := e.write(footer); err != nil {
+		return err
+	}
+	if err := e.write(binary.LittleEndian.AppendUint32(nil, uint32(len(footer)))); err != nil {
+		return err
+	}
+	return e.write([]byte(parquetMagic))
+}
+
+// writeRowGroup — записать накопленные колонки страницей данных каждую
+func (e *parquetEncoder) writeRowGroup() error {
+	group := parquetRowGroup{chunks: make([]parquetChunk, len(e.columns)), rows: e.rows}
+	for i, c := range e.columns {
+		page := c.page()
+		header := pageHeader(len(c.defined), len(page))
+
+		group.chunks[i] = parquetChunk{offset: e.offset, size: int64(len(header) + len(page))}
+		group.size += group.chunks[i].size
+		if err := e.write(header); err != nil {
+			return err
+		}
+		if err := e.write(page); err != nil {
+			return err
+		}
+		c.reset()
+	}
+
+	e.groups = append(e.groups, group)
+	e.total += int64(e.rows)
+	e.rows = 0
+	return nil
+}
+
+func (e *parquetEncoder) write(p []byte) error {
+	n, err := e.w.Write(p)
+	e.offset += int64(n)
+	return err
+}
+
+// footer — FileMetaData: схема и расположение колонок каждой группы строк
+func (e *parquetEncoder) footer() []byte {
+	var t thriftCompact
+
+	t.i32(1, 1)
+	t.list(2, thriftStruct, len(e.columns)+1)
+	t.beginElem()
+	t.binary(4, "schema")
+	t.i32(5, int32(len(e.columns)))
+	t.endStruct()
+	for _, c := range e.columns {
+		t.beginElem()
+		t.i32(1, c.physical)
+		t.i32(3, parquetOptional)
+		t.binary(4, c.Name)
+		switch c.Type {
+		case postgresql.ExportText:
+			t.i32(6, parquetUTF8)
+		case postgresql.ExportNumeric:
+			t.i32(6, parquetDecimal)
+			t.i32(7, int32(c.Scale))
+			t.i32(8, parquetDecimalPrecision)
+		case postgresql.ExportTimestamp:
+			t.i32(6, parquetTimestampMicros)
+		}
+		t.endStruct()
+	}
+	t.i64(3, e.total)
+
+	t.list(4, thriftStruct, len(e.groups))
+	for _, group := range e.groups {
+		t.beginElem()
+		t.list(1, thriftStruct, len(group.chunks))
+		for i, chunk := range group.chunks {
+			c := e.columns[i]
+			t.beginElem()
+			t.i64(2, chunk.offset)
+			t.beginStruct(3)
+			t.i32(1, c.physical)
+			t.list(2, thriftI32, 2)
+			t.elemI32(parquetPlain)
+			t.elemI32(parquetRLE)
+			t.list(3, thriftBinary, 1)
+			t.elemBinary(c.Name)
+			t.i32(4, parquetUncompressed)
+			t.i64(5, int64(group.rows))
+			t.i64(6, chunk.size)
+			t.i64(7, chunk.size)
+			t.i64(9, chunk.offset)
+			t.endStruct()
+			t.endStruct()
+		}
+		t.i64(2, group.size)
+		t.i64(3, int64(group.rows))
+		t.endStruct()
+	}
+	t.binary(6, "salesTracker")
+	t.endStruct()
+
+	return t.buf
+}
+
+// ====================================================================
+// COLUMNS
+// ====================================================================
+
+// append — добавить значение строки в PLAIN-кодировке
+func (c *parquetColumn) append(value any) error {
+	if value == nil {
+		c.defined = append(c.defined, false)
+		return nil
+	}
+
+	var (
+		v   int64
+		err error
+	)
+	switch c.Type {
+	case postgresql.ExportText:
+		var s []byte
+		switch t := value.(type) {
+		case string:
+			s = []byte(t)
+		case []byte:
+			s = t
+		default:
+			return fmt.Errorf("unexpected %T for text", value)
+		}
+		c.values.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(s))))
+		c.values.Write(s)
+		c.defined = append(c.defined, true)
+		return nil
+	case postgresql.ExportInteger:
+		var ok bool
+		if v, ok = value.(int64); !ok {
+			return fmt.Errorf("unexpected %T for integer", value)
+		}
+	case postgresql.ExportNumeric:
+		text, ok := value.([]byte)
+		if !ok {
+			return fmt.Errorf("unexpected %T for numeric", value)
+		}
+		if v, err = decimalValue(string(text), c.Scale); err != nil {
+			return err
+		}
+	case postgresql.ExportTimestamp:
+		t, ok := value.(time.Time)
+		if !ok {
+			return fmt.Errorf("unexpected %T for timestamp", value)
+		}
+		v = t.UnixMicro()
+	}
+
+	c.values.Write(binary.LittleEndian.AppendUint64(nil, uint64(v)))
+	c.defined = append(c.defined, true)
+	return nil
+}
+
+// page — тело страницы данных: длина и уровни определения, затем значения
+func (c *parquetColumn) page() []byte {
+	levels := rleLevels(c.defined)
+	page := make([]byte, 0, 4+len(levels)+c.values.Len())
+	page = binary.LittleEndian.AppendUint32(page, uint32(len(levels)))
+	page = append(page, levels...)
+	return append(page, c.values.Bytes()...)
+}
+
+func (c *parquetColumn) reset() {
+	c.defined = c.defined[:0]
+	c.values.Reset()
+}
+
+// pageHeader — PageHeader страницы данных без сжатия
+func pageHeader(values, size int) []byte {
+	var t thriftCompact
+	t.i32(1, parquetDataPage)
+	t.i32(2, int32(size))
+	t.i32(3, int32(size))
+	t.beginStruct(5)
+	t.i32(1, int32(values))
+	t.i32(2, parquetPlain)
+	t.i32(3, parquetRLE)
+	t.i32(4, parquetRLE)
+	t.endStruct()
+	t.endStruct()
+	return t.buf
+}
+
+// rleLevels — уровни определения гибридом RLE/bit-packing с шириной 1 бит,
+// только RLE-серии: заголовок (длина << 1) и значение одним байтом
+func rleLevels(defined []bool) []byte {
+	var out []byte
+	for i := 0; i < len(defined); {
+		j := i
+		for j < len(defined) && defined[j] == defined[i] {
+			j++
+		}
+		out = binary.AppendUvarint(out, uint64(j-i)<<1)
+		if defined[i] {
+			out = append(out, 1)
+		} else {
+			out = append(out, 0)
+		}
+		i = j
+	}
+	return out
+}
+
+// decimalValue — NUMERIC в тексте ("-12.3") как целое число единиц
+// последнего знака при scale знаках после запятой (-1230 при scale 2)
+func decimalValue(text string, scale int) (int64, error) {
+	digits, fraction, _ := strings.Cut(text, ".")
+	if len(fraction) > scale {
+		return 0, fmt.Errorf("numeric %q has more than %d decimal places", text, scale)
+	}
+	for len(fraction) < scale {
+		fraction += "0"
+	}
+
+	v, err := strconv.ParseInt(digits+fraction, 10, 64)
+	if err != nil || len(strings.TrimLeft(digits, "+-"))+scale > parquetDecimalPrecision {
+		return 0, fmt.Errorf("numeric %q does not fit DECIMAL(%d, %d)", text, parquetDecimalPrecision, scale)
+	}
+	return v, nil
+}
+
+// ====================================================================
+// THRIFT - Протокол Thrift compact для метаданных
+// ====================================================================
+
+// Типы полей протокола compact
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftCompact — запись структур протоколом compact: поле кодируется
+// разницей с номером предыдущего поля той же структуры
+type thriftCompact struct {
+	buf   []byte
+	last  int16
+	stack []int16
+}
+
+func (t *thriftCompact) field(id int16, typ byte) {
+	if delta := id - t.last; delta > 0 && delta <= 15 {
+		t.buf = append(t.buf, byte(delta)<<4|typ)
+	} else {
+		t.buf = append(t.buf, typ)
+		t.buf = binary.AppendVarint(t.buf, int64(id))
+	}
+	t.last = id
+}
+
+func (t *thriftCompact) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.elemI32(v)
+}
+
+func (t *thriftCompact) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.buf = binary.AppendVarint(t.buf, v)
+}
+
+func (t *thriftCompact) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.elemBinary(s)
+}
+
+// list — заголовок списка; элементы пишутся elemI32, elemBinary
+// или beginElem ... endStruct
+func (t *thriftCompact) list(id int16, elem byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf = append(t.buf, byte(n)<<4|elem)
+		return
+	}
+	t.buf = append(t.buf, 0xf0|elem)
+	t.buf = binary.AppendUvarint(t.buf, uint64(n))
+}
+
+func (t *thriftCompact) elemI32(v int32) {
+	t.buf = binary.AppendVarint(t.buf, int64(v))
+}
+
+func (t *thriftCompact) elemBinary(s string) {
+	t.buf = binary.AppendUvarint(t.buf, uint64(len(s)))
+	t.buf = append(t.buf, s...)
+}
+
+// beginStruct — вложенная структура в поле id
+func (t *thriftCompact) beginStruct(id int16) {
+	t.field(id, thriftStruct)
+	t.beginElem()
+}
+
+// beginElem — структура-элемент списка
+func (t *thriftCompact) beginElem() {
+	t.stack = append(t.stack, t.last)
+	t.last = 0
+}
+
+// endStruct — конец структуры; для внешней структуры стек пуст
+func (t *thriftCompact) endStruct() {
+	t.buf = append(t.buf, 0)
+	if n := len(t.stack); n > 0 {
+		t.last, t.stack = t.stack[n-1], t.stack[:n-1]
+	}
+}
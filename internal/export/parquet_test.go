@@ -0,0 +1,561 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"salesTracker/internal/storage/postgresql"
+)
+
+// ====================================================================
+// READER - Независимое чтение Parquet для проверки записи
+// ====================================================================
+
+// Чтение написано по спецификации формата, а не по коду записи: метаданные
+// разбираются общим декодером Thrift compact в поля по номерам, уровни
+// определения — полным гибридом RLE/bit-packing.
+
+// thriftStructValue — структура Thrift: значения полей по номерам
+type thriftStructValue map[int16]any
+
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) byte() (byte, error) {
+	if r.pos >= len(r.data) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b, nil
+}
+
+func (r *thriftReader) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		return 0, errors.New("thrift: bad varint")
+	}
+	r.pos += n
+	return v, nil
+}
+
+// zigzag — целые i16, i32, i64 в compact записаны zigzag-varint
+func (r *thriftReader) zigzag() (int64, error) {
+	u, err := r.uvarint()
+	return int64(u>>1) ^ -int64(u&1), err
+}
+
+func (r *thriftReader) readStruct() (thriftStructValue, error) {
+	s := thriftStructValue{}
+	var last int16
+	for {
+		b, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		if b == 0 {
+			return s, nil
+		}
+
+		typ, delta := b&0x0f, int16(b>>4)
+		id := last + delta
+		if delta == 0 {
+			v, err := r.zigzag()
+			if err != nil {
+				return nil, err
+			}
+			id = int16(v)
+		}
+		last = id
+
+		switch typ {
+		case 1, 2: // bool в поле структуры: значение — сам тип
+			s[id] = typ == 1
+		default:
+			if s[id], err = r.value(typ); err != nil {
+				return nil, fmt.Errorf("field %d: %w", id, err)
+			}
+		}
+	}
+}
+
+func (r *thriftReader) value(typ byte) (any, error) {
+	switch typ {
+	case 1, 2, 3: // bool элемента списка и byte
+		b, err := r.byte()
+		return int64(b), err
+	case 4, 5, 6:
+		return r.zigzag()
+	case 7:
+		if r.pos+8 > len(r.data) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		v := math.Float64frombits(binary.LittleEndian.Uint64(r.data[r.pos:]))
+		r.pos += 8
+		return v, nil
+	case 8:
+		n, err := r.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		if r.pos+int(n) > len(r.data) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		s := string(r.data[r.pos : r.pos+int(n)])
+		r.pos += int(n)
+		return s, nil
+	case 9, 10:
+		b, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		size, elem := uint64(b>>4), b&0x0f
+		if size == 15 {
+			if size, err = r.uvarint(); err != nil {
+				return nil, err
+			}
+		}
+		list := make([]any, size)
+		for i := range list {
+			if list[i], err = r.value(elem); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	case 12:
+		return r.readStruct()
+	default:
+		return nil, fmt.Errorf("thrift: unsupported type %d", typ)
+	}
+}
+
+// Поля структур parquet.thrift, которые проверяет тест
+func (s thriftStructValue) int(id int16) int64  { v, _ := s[id].(int64); return v }
+func (s thriftStructValue) str(id int16) string { v, _ := s[id].(string); return v }
+func (s thriftStructValue) list(id int16) []any { v, _ := s[id].([]any); return v }
+func (s thriftStructValue) sub(id int16) thriftStructValue {
+	v, _ := s[id].(thriftStructValue)
+	return v
+}
+
+// parquetFile — прочитанный файл: метаданные и строки, значения NULL — nil,
+// INT64 — int64, BYTE_ARRAY — string
+type parquetFile struct {
+	meta   thriftStructValue
+	schema []thriftStructValue
+	groups []int
+	rows   [][]any
+}
+
+func readParquet(data []byte) (*parquetFile, error) {
+	if len(data) < 12 || string(data[:4]) != "PAR1" || string(data[len(data)-4:]) != "PAR1" {
+		return nil, errors.New("no PAR1 magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	if footerLen > len(data)-12 {
+		return nil, errors.New("footer length out of range")
+	}
+	footer := &thriftReader{data: data[len(data)-8-footerLen : len(data)-8]}
+	meta, err := footer.readStruct()
+	if err != nil {
+		return nil, fmt.Errorf("footer: %w", err)
+	}
+	if footer.pos != footerLen {
+		return nil, fmt.Errorf("footer: %d trailing bytes", footerLen-footer.pos)
+	}
+
+	f := &parquetFile{meta: meta}
+	for _, e := range meta.list(2) {
+		f.schema = append(f.schema, e.(thriftStructValue))
+	}
+	if len(f.schema) == 0 || int(f.schema[0].int(5)) != len(f.schema)-1 {
+		return nil, errors.New("schema root does not list its columns")
+	}
+	columns := f.schema[1:]
+
+	for g, rg := range meta.list(4) {
+		group := rg.(thriftStructValue)
+		rows := int(group.int(3))
+		chunks := group.list(1)
+		if len(chunks) != len(columns) {
+			return nil, fmt.Errorf("row group %d: %d chunks for %d columns", g, len(chunks), len(columns))
+		}
+
+		values := make([][]any, len(columns))
+		var groupSize int64
+		for i, c := range chunks {
+			cmeta := c.(thriftStructValue).sub(3)
+			if path := cmeta.list(3); len(path) != 1 || path[0] != columns[i].str(4) {
+				return nil, fmt.Errorf("row group %d: chunk %d path %v", g, i, path)
+			}
+			if cmeta.int(4) != 0 {
+				return nil, fmt.Errorf("row group %d: chunk %d is compressed", g, i)
+			}
+			if int(cmeta.int(5)) != rows {
+				return nil, fmt.Errorf("row group %d: chunk %d has %d values for %d rows", g, i, cmeta.int(5), rows)
+			}
+
+			offset, size := int(cmeta.int(9)), int(cmeta.int(7))
+			if offset+size > len(data)-8-footerLen {
+				return nil, fmt.Errorf("row group %d: chunk %d out of range", g, i)
+			}
+			if values[i], err = readChunk(data[offset:offset+size], columns[i].int(1), rows); err != nil {
+				return nil, fmt.Errorf("row group %d: column %s: %w", g, columns[i].str(4), err)
+			}
+			groupSize += int64(size)
+		}
+		if group.int(2) != groupSize {
+			return nil, fmt.Errorf("row group %d: total_byte_size %d, chunks %d", g, group.int(2), groupSize)
+		}
+
+		for r := range rows {
+			row := make([]any, len(columns))
+			for i := range columns {
+				row[i] = values[i][r]
+			}
+			f.rows = append(f.rows, row)
+		}
+		f.groups = append(f.groups, rows)
+	}
+
+	if int(meta.int(3)) != len(f.rows) {
+		return nil, fmt.Errorf("num_rows %d, read %d", meta.int(3), len(f.rows))
+	}
+	return f, nil
+}
+
+// readChunk — колонка из одной страницы данных v1 без сжатия
+func readChunk(chunk []byte, physical int64, rows int) ([]any, error) {
+	r := &thriftReader{data: chunk}
+	header, err := r.readStruct()
+	if err != nil {
+		return nil, fmt.Errorf("page header: %w", err)
+	}
+	if header.int(1) != 0 {
+		return nil, fmt.Errorf("page type %d, want DATA_PAGE", header.int(1))
+	}
+	body := chunk[r.pos:]
+	if int(header.int(3)) != len(body) || header.int(2) != header.int(3) {
+		return nil, fmt.Errorf("page size %d/%d, chunk has %d bytes", header.int(2), header.int(3), len(body))
+	}
+	dp := header.sub(5)
+	if int(dp.int(1)) != rows || dp.int(2) != 0 || dp.int(3) != 3 {
+		return nil, fmt.Errorf("data page header %v", dp)
+	}
+
+	levelsLen := int(binary.LittleEndian.Uint32(body))
+	levels, err := rleHybrid(body[4:4+levelsLen], 1, rows)
+	if err != nil {
+		return nil, fmt.Errorf("definition levels: %w", err)
+	}
+
+	values := body[4+levelsLen:]
+	out := make([]any, rows)
+	for i, level := range levels {
+		if level == 0 {
+			continue
+		}
+		switch physical {
+		case 2: // INT64
+			if len(values) < 8 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			out[i] = int64(binary.LittleEndian.Uint64(values))
+			values = values[8:]
+		case 6: // BYTE_ARRAY
+			if len(values) < 4 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			n := int(binary.LittleEndian.Uint32(values))
+			if len(values) < 4+n {
+				return nil, io.ErrUnexpectedEOF
+			}
+			out[i] = string(values[4 : 4+n])
+			values = values[4+n:]
+		default:
+			return nil, fmt.Errorf("physical type %d", physical)
+		}
+	}
+	if len(values) != 0 {
+		return nil, fmt.Errorf("%d bytes after the last value", len(values))
+	}
+	return out, nil
+}
+
+// rleHybrid — n значений ширины width бит: серии RLE и группы bit-packing
+func rleHybrid(data []byte, width, n int) ([]int, error) {
+	r := &thriftReader{data: data}
+	var out []int
+	for len(out) < n {
+		header, err := r.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		if header&1 == 0 {
+			var v int
+			for i := 0; i < (width+7)/8; i++ {
+				b, err := r.byte()
+				if err != nil {
+					return nil, err
+				}
+				v |= int(b) << (8 * i)
+			}
+			for range header >> 1 {
+				out = append(out, v)
+			}
+			continue
+		}
+
+		groups := int(header >> 1)
+		for i := 0; i < groups*8; i++ {
+			var v int
+			for bit := 0; bit < width; bit++ {
+				pos := i*width + bit
+				if r.pos+pos/8 >= len(data) {
+					return nil, io.ErrUnexpectedEOF
+				}
+				v |= int(data[r.pos+pos/8]>>(pos%8)&1) << bit
+			}
+			out = append(out, v)
+		}
+		r.pos += groups * width
+	}
+	if r.pos != len(data) {
+		return nil, fmt.Errorf("%d bytes after %d levels", len(data)-r.pos, n)
+	}
+	return out[:n], nil
+}
+
+// ====================================================================
+// TESTS
+// ====================================================================
+
+// sliceRows — строки выгрузки из памяти
+type sliceRows struct {
+	rows [][]any
+}
+
+func (s *sliceRows) Next() ([]any, error) {
+	if len(s.rows) == 0 {
+		return nil, io.EOF
+	}
+	row := s.rows[0]
+	s.rows = s.rows[1:]
+	return row, nil
+}
+
+func writeParquet(t *testing.T, columns []postgresql.ExportColumn, rows [][]any) *parquetFile {
+	t.Helper()
+
+	var buf bytes.Buffer
+	n, err := Write(&buf, FormatParquet, columns, &sliceRows{rows: rows})
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if n != len(rows) {
+		t.Fatalf("Write() = %d rows, want %d", n, len(rows))
+	}
+
+	f, err := readParquet(buf.Bytes())
+	if err != nil {
+		t.Fatalf("read parquet: %v", err)
+	}
+	return f
+}
+
+func TestParquetRoundTrip(t *testing.T) {
+	columns := []postgresql.ExportColumn{
+		{Name: "order_id", Type: postgresql.ExportInteger},
+		{Name: "status", Type: postgresql.ExportText},
+		{Name: "total_amount", Type: postgresql.ExportNumeric, Scale: 2},
+		{Name: "order_date", Type: postgresql.ExportTimestamp},
+	}
+	moscow := time.FixedZone("MSK", 3*60*60)
+	created := time.Date(2024, 3, 1, 12, 30, 0, 123456000, moscow)
+
+	rows := [][]any{
+		{int64(1), "completed", []byte("1250.50"), created},
+		{int64(2), []byte("возврат"), []byte("-0.5"), nil},
+		{int64(3), nil, nil, created.Add(time.Hour)},
+		{nil, "", []byte("7"), nil},
+		{int64(math.MaxInt64), "pending", []byte("0"), time.Unix(0, 0)},
+	}
+	want := [][]any{
+		{int64(1), "completed", int64(125050), created.UnixMicro()},
+		{int64(2), "возврат", int64(-50), nil},
+		{int64(3), nil, nil, created.Add(time.Hour).UnixMicro()},
+		{nil, "", int64(700), nil},
+		{int64(math.MaxInt64), "pending", int64(0), int64(0)},
+	}
+
+	f := writeParquet(t, columns, rows)
+
+	if !reflect.DeepEqual(f.rows, want) {
+		t.Errorf("rows = %v, want %v", f.rows, want)
+	}
+	if !reflect.DeepEqual(f.groups, []int{len(rows)}) {
+		t.Errorf("row groups = %v, want one of %d rows", f.groups, len(rows))
+	}
+
+	// схема: все колонки OPTIONAL с логическими типами по типу выгрузки
+	wantSchema := []struct {
+		name      string
+		physical  int64
+		converted any
+		scale     any
+		precision any
+	}{
+		{name: "order_id", physical: 2},
+		{name: "status", physical: 6, converted: int64(0)},
+		{name: "total_amount", physical: 2, converted: int64(5), scale: int64(2), precision: int64(18)},
+		{name: "order_date", physical: 2, converted: int64(10)},
+	}
+	for i, w := range wantSchema {
+		e := f.schema[i+1]
+		if e.str(4) != w.name || e.int(1) != w.physical || e.int(3) != 1 ||
+			e[6] != w.converted || e[7] != w.scale || e[8] != w.precision {
+			t.Errorf("schema[%d] = %v, want %+v", i, e, w)
+		}
+	}
+}
+
+func TestParquetRowGroups(t *testing.T) {
+	columns := []postgresql.ExportColumn{
+		{Name: "id", Type: postgresql.ExportInteger},
+		{Name: "note", Type: postgresql.ExportText},
+	}
+
+	// на границе групп серии уровней определения начинаются заново
+	total := parquetRowGroupRows + 10
+	rows := make([][]any, total)
+	want := make([][]any, total)
+	for i := range rows {
+		var note any
+		if i%3 == 0 {
+			note = fmt.Sprintf("n%d", i)
+		}
+		rows[i] = []any{int64(i), note}
+		want[i] = []any{int64(i), note}
+	}
+
+	f := writeParquet(t, columns, rows)
+
+	if !reflect.DeepEqual(f.groups, []int{parquetRowGroupRows, 10}) {
+		t.Errorf("row groups = %v, want [%d 10]", f.groups, parquetRowGroupRows)
+	}
+	if !reflect.DeepEqual(f.rows, want) {
+		t.Error("rows differ after the round trip")
+	}
+}
+
+func TestParquetShapes(t *testing.T) {
+	// 20 колонок: списки схемы и колонок длиннее 14 элементов пишутся
+	// длинным заголовком
+	wide := make([]postgresql.ExportColumn, 20)
+	wideRow := make([]any, len(wide))
+	for i := range wide {
+		wide[i] = postgresql.ExportColumn{Name: fmt.Sprintf("c%d", i), Type: postgresql.ExportInteger}
+		wideRow[i] = int64(i * 10)
+	}
+
+	tests := []struct {
+		name    string
+		columns []postgresql.ExportColumn
+		rows    [][]any
+	}{
+		{name: "no rows", columns: []postgresql.ExportColumn{{Name: "id", Type: postgresql.ExportInteger}}},
+		{name: "all null", columns: []postgresql.ExportColumn{{Name: "note", Type: postgresql.ExportText}},
+			rows: [][]any{{nil}, {nil}, {nil}}},
+		{name: "wide", columns: wide, rows: [][]any{wideRow}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := writeParquet(t, tt.columns, tt.rows)
+
+			if len(f.schema) != len(tt.columns)+1 {
+				t.Errorf("schema has %d elements, want %d", len(f.schema), len(tt.columns)+1)
+			}
+			got := f.rows
+			if got == nil {
+				got = [][]any{}
+			}
+			want := tt.rows
+			if want == nil {
+				want = [][]any{}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("rows = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestParquetRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns []postgresql.ExportColumn
+		row     []any
+	}{
+		{name: "too many decimal places", columns: []postgresql.ExportColumn{{Name: "amount", Type: postgresql.ExportNumeric, Scale: 2}},
+			row: []any{[]byte("1.005")}},
+		{name: "wrong integer type", columns: []postgresql.ExportColumn{{Name: "id", Type: postgresql.ExportInteger}},
+			row: []any{"1"}},
+		{name: "wrong timestamp type", columns: []postgresql.ExportColumn{{Name: "at", Type: postgresql.ExportTimestamp}},
+			row: []any{"2024-01-01"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Write(io.Discard, FormatParquet, tt.columns, &sliceRows{rows: [][]any{tt.row}})
+			if err == nil {
+				t.Error("Write() error = nil")
+			}
+		})
+	}
+
+	_, err := Write(io.Discard, FormatParquet, []postgresql.ExportColumn{{Name: "x", Type: postgresql.ExportType(99)}}, &sliceRows{})
+	if !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Write() with an unknown column type error = %v, want ErrUnsupportedFormat", err)
+	}
+}
+
+func TestDecimalValue(t *testing.T) {
+	tests := []struct {
+		text    string
+		scale   int
+		want    int64
+		wantErr bool
+	}{
+		{text: "12.3", scale: 2, want: 1230},
+		{text: "-12.34", scale: 2, want: -1234},
+		{text: "0", scale: 2, want: 0},
+		{text: "5", scale: 0, want: 5},
+		{text: "9999999999999999.99", scale: 2, want: 999999999999999999},
+
+		{text: "1.234", scale: 2, wantErr: true},
+		{text: "99999999999999999.99", scale: 2, wantErr: true},
+		{text: "abc", scale: 2, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := decimalValue(tt.text, tt.scale)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decimalValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("decimalValue() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,21 +1,86 @@
 package analytics
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/render"
 
+	"salesTracker/internal/storage"
 	"salesTracker/internal/storage/postgresql"
 )
 
+// Storage - аналитические методы хранилища; реализуется как самим
+// postgresql.Storage, так и кэширующим декоратором cache.CachedAnalytics
+type Storage interface {
+	TotalRevenueByPeriod(ctx context.Context, start, end time.Time, currency string) (*postgresql.PeriodSummary, error)
+	OrdersPerDay(ctx context.Context, start, end time.Time, currency string) ([]postgresql.DailyOrders, error)
+	AverageCheckByPeriod(ctx context.Context, start, end time.Time, currency string) (*postgresql.AverageCheckStats, error)
+	OrdersMedian(ctx context.Context, start, end time.Time, currency string) (*postgresql.MedianStats, error)
+	CustomerSpendingMedian(ctx context.Context, start, end time.Time, currency string) (*postgresql.MedianStats, error)
+	OrdersPercentile(ctx context.Context, start, end time.Time, percentile int, currency string) (*postgresql.PercentileStats, error)
+	CustomerSpendingPercentile(ctx context.Context, start, end time.Time, percentile int, currency string) (*postgresql.PercentileStats, error)
+	GenerateSalesReport(ctx context.Context, start, end time.Time, currency string) (*postgresql.SalesReport, error)
+	ReturnRateByProduct(ctx context.Context, start, end time.Time, currency string) ([]postgresql.ProductReturnRate, error)
+	PromotionsReport(ctx context.Context, start, end time.Time, currency string) ([]postgresql.PromotionStats, error)
+}
+
 // ====================================================================
 // HELPERS
 // ====================================================================
 
-func parseDate(dateStr string) (time.Time, error) {
-	return time.Parse("2006-01-02", dateStr)
+func parseDate(dateStr string, loc *time.Location) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02", dateStr, loc)
+}
+
+// parsePeriod - разобрать start, end и необязательный tz (например, tz=Europe/Moscow).
+// Границы суток считаются в указанном часовом поясе, по умолчанию — в UTC.
+func parsePeriod(r *http.Request) (start, end time.Time, err error) {
+	loc := time.UTC
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		loc, err = time.LoadLocation(tz)
+		if err != nil {
+			return start, end, fmt.Errorf("invalid tz %q, use an IANA time zone name", tz)
+		}
+	}
+
+	start, err = parseDate(r.URL.Query().Get("start"), loc)
+	if err != nil {
+		return start, end, errors.New("invalid start date format, use YYYY-MM-DD")
+	}
+
+	end, err = parseDate(r.URL.Query().Get("end"), loc)
+	if err != nil {
+		return start, end, errors.New("invalid end date format, use YYYY-MM-DD")
+	}
+
+	return start, end, nil
+}
+
+// parseCurrency - необязательная валюта отчета (currency=USD): суммы заказов
+// пересчитываются в нее по курсу на дату заказа. Без параметра суммы не пересчитываются.
+func parseCurrency(r *http.Request) (string, error) {
+	currency := strings.ToUpper(r.URL.Query().Get("currency"))
+	if currency == "" {
+		return "", nil
+	}
+	if len(currency) != 3 || strings.Trim(currency, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return "", fmt.Errorf("invalid currency %q, use a 3-letter ISO 4217 code", currency)
+	}
+	return currency, nil
+}
+
+func respondStorageError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, storage.ErrMissingExchangeRate) {
+		respondError(w, r, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	respondError(w, r, http.StatusInternalServerError, err.Error())
 }
 
 func respondError(w http.ResponseWriter, r *http.Request, status int, message string) {
@@ -28,27 +93,24 @@ func respondError(w http.ResponseWriter, r *http.Request, status int, message st
 // ====================================================================
 
 // TotalRevenueByPeriod - выручка за период
-// GET /analytics/revenue?start=2024-01-01&end=2024-01-31
-func TotalRevenueByPeriod(storage *postgresql.Storage) http.HandlerFunc {
+// GET /analytics/revenue?start=2024-01-01&end=2024-01-31&tz=Europe/Moscow&currency=USD
+func TotalRevenueByPeriod(storage Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		startDate := r.URL.Query().Get("start")
-		endDate := r.URL.Query().Get("end")
-
-		start, err := parseDate(startDate)
+		start, end, err := parsePeriod(r)
 		if err != nil {
-			respondError(w, r, http.StatusBadRequest, "invalid start date format, use YYYY-MM-DD")
+			respondError(w, r, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		end, err := parseDate(endDate)
+		currency, err := parseCurrency(r)
 		if err != nil {
-			respondError(w, r, http.StatusBadRequest, "invalid end date format, use YYYY-MM-DD")
+			respondError(w, r, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		summary, err := storage.TotalRevenueByPeriod(start, end)
+		summary, err := storage.TotalRevenueByPeriod(r.Context(), start, end, currency)
 		if err != nil {
-			respondError(w, r, http.StatusInternalServerError, err.Error())
+			respondStorageError(w, r, err)
 			return
 		}
 
@@ -61,27 +123,24 @@ func TotalRevenueByPeriod(storage *postgresql.Storage) http.HandlerFunc {
 // ====================================================================
 
 // OrdersPerDay - заказы по дням
-// GET /analytics/daily-orders?start=2024-01-01&end=2024-01-31
-func OrdersPerDay(storage *postgresql.Storage) http.HandlerFunc {
+// GET /analytics/daily-orders?start=2024-01-01&end=2024-01-31&tz=Europe/Moscow&currency=USD
+func OrdersPerDay(storage Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		startDate := r.URL.Query().Get("start")
-		endDate := r.URL.Query().Get("end")
-
-		start, err := parseDate(startDate)
+		start, end, err := parsePeriod(r)
 		if err != nil {
-			respondError(w, r, http.StatusBadRequest, "invalid start date format, use YYYY-MM-DD")
+			respondError(w, r, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		end, err := parseDate(endDate)
+		currency, err := parseCurrency(r)
 		if err != nil {
-			respondError(w, r, http.StatusBadRequest, "invalid end date format, use YYYY-MM-DD")
+			respondError(w, r, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		dailyOrders, err := storage.OrdersPerDay(start, end)
+		dailyOrders, err := storage.OrdersPerDay(r.Context(), start, end, currency)
 		if err != nil {
-			respondError(w, r, http.StatusInternalServerError, err.Error())
+			respondStorageError(w, r, err)
 			return
 		}
 
@@ -94,27 +153,24 @@ func OrdersPerDay(storage *postgresql.Storage) http.HandlerFunc {
 // ====================================================================
 
 // AverageCheckByPeriod - средний чек за период
-// GET /analytics/average-check?start=2024-01-01&end=2024-01-31
-func AverageCheckByPeriod(storage *postgresql.Storage) http.HandlerFunc {
+// GET /analytics/average-check?start=2024-01-01&end=2024-01-31&tz=Europe/Moscow&currency=USD
+func AverageCheckByPeriod(storage Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		startDate := r.URL.Query().Get("start")
-		endDate := r.URL.Query().Get("end")
-
-		start, err := parseDate(startDate)
+		start, end, err := parsePeriod(r)
 		if err != nil {
-			respondError(w, r, http.StatusBadRequest, "invalid start date format, use YYYY-MM-DD")
+			respondError(w, r, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		end, err := parseDate(endDate)
+		currency, err := parseCurrency(r)
 		if err != nil {
-			respondError(w, r, http.StatusBadRequest, "invalid end date format, use YYYY-MM-DD")
+			respondError(w, r, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		avgCheck, err := storage.AverageCheckByPeriod(start, end)
+		avgCheck, err := storage.AverageCheckByPeriod(r.Context(), start, end, currency)
 		if err != nil {
-			respondError(w, r, http.StatusInternalServerError, err.Error())
+			respondStorageError(w, r, err)
 			return
 		}
 
@@ -127,27 +183,24 @@ func AverageCheckByPeriod(storage *postgresql.Storage) http.HandlerFunc {
 // ====================================================================
 
 // OrdersMedian - медиана заказов
-// GET /analytics/orders-median?start=2024-01-01&end=2024-01-31
-func OrdersMedian(storage *postgresql.Storage) http.HandlerFunc {
+// GET /analytics/orders-median?start=2024-01-01&end=2024-01-31&tz=Europe/Moscow&currency=USD
+func OrdersMedian(storage Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		startDate := r.URL.Query().Get("start")
-		endDate := r.URL.Query().Get("end")
-
-		start, err := parseDate(startDate)
+		start, end, err := parsePeriod(r)
 		if err != nil {
-			respondError(w, r, http.StatusBadRequest, "invalid start date format, use YYYY-MM-DD")
+			respondError(w, r, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		end, err := parseDate(endDate)
+		currency, err := parseCurrency(r)
 		if err != nil {
-			respondError(w, r, http.StatusBadRequest, "invalid end date format, use YYYY-MM-DD")
+			respondError(w, r, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		median, err := storage.OrdersMedian(start, end)
+		median, err := storage.OrdersMedian(r.Context(), start, end, currency)
 		if err != nil {
-			respondError(w, r, http.StatusInternalServerError, err.Error())
+			respondStorageError(w, r, err)
 			return
 		}
 
@@ -156,27 +209,24 @@ func OrdersMedian(storage *postgresql.Storage) http.HandlerFunc {
 }
 
 // CustomerSpendingMedian - медиана трат покупателей
-// GET /analytics/customer-median?start=2024-01-01&end=2024-01-31
-func CustomerSpendingMedian(storage *postgresql.Storage) http.HandlerFunc {
+// GET /analytics/customer-median?start=2024-01-01&end=2024-01-31&tz=Europe/Moscow&currency=USD
+func CustomerSpendingMedian(storage Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		startDate := r.URL.Query().Get("start")
-		endDate := r.URL.Query().Get("end")
-
-		start, err := parseDate(startDate)
+		start, end, err := parsePeriod(r)
 		if err != nil {
-			respondError(w, r, http.StatusBadRequest, "invalid start date format, use YYYY-MM-DD")
+			respondError(w, r, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		end, err := parseDate(endDate)
+		currency, err := parseCurrency(r)
 		if err != nil {
-			respondError(w, r, http.StatusBadRequest, "invalid end date format, use YYYY-MM-DD")
+			respondError(w, r, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		median, err := storage.CustomerSpendingMedian(start, end)
+		median, err := storage.CustomerSpendingMedian(r.Context(), start, end, currency)
 		if err != nil {
-			respondError(w, r, http.StatusInternalServerError, err.Error())
+			respondStorageError(w, r, err)
 			return
 		}
 
@@ -189,22 +239,20 @@ func CustomerSpendingMedian(storage *postgresql.Storage) http.HandlerFunc {
 // ====================================================================
 
 // OrdersPercentile - перцентиль заказов
-// GET /analytics/orders-percentile?start=2024-01-01&end=2024-01-31&percentile=75
-func OrdersPercentile(storage *postgresql.Storage) http.HandlerFunc {
+// GET /analytics/orders-percentile?start=2024-01-01&end=2024-01-31&tz=Europe/Moscow&currency=USD&percentile=75
+func OrdersPercentile(storage Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		startDate := r.URL.Query().Get("start")
-		endDate := r.URL.Query().Get("end")
 		percentileStr := r.URL.Query().Get("percentile")
 
-		start, err := parseDate(startDate)
+		start, end, err := parsePeriod(r)
 		if err != nil {
-			respondError(w, r, http.StatusBadRequest, "invalid start date format, use YYYY-MM-DD")
+			respondError(w, r, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		end, err := parseDate(endDate)
+		currency, err := parseCurrency(r)
 		if err != nil {
-			respondError(w, r, http.StatusBadRequest, "invalid end date format, use YYYY-MM-DD")
+			respondError(w, r, http.StatusBadRequest, err.Error())
 			return
 		}
 
@@ -214,9 +262,9 @@ func OrdersPercentile(storage *postgresql.Storage) http.HandlerFunc {
 			return
 		}
 
-		result, err := storage.OrdersPercentile(start, end, percentile)
+		result, err := storage.OrdersPercentile(r.Context(), start, end, percentile, currency)
 		if err != nil {
-			respondError(w, r, http.StatusInternalServerError, err.Error())
+			respondStorageError(w, r, err)
 			return
 		}
 
@@ -225,22 +273,20 @@ func OrdersPercentile(storage *postgresql.Storage) http.HandlerFunc {
 }
 
 // CustomerSpendingPercentile - перцентиль трат покупателей
-// GET /analytics/customer-percentile?start=2024-01-01&end=2024-01-31&percentile=75
-func CustomerSpendingPercentile(storage *postgresql.Storage) http.HandlerFunc {
+// GET /analytics/customer-percentile?start=2024-01-01&end=2024-01-31&tz=Europe/Moscow&currency=USD&percentile=75
+func CustomerSpendingPercentile(storage Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		startDate := r.URL.Query().Get("start")
-		endDate := r.URL.Query().Get("end")
 		percentileStr := r.URL.Query().Get("percentile")
 
-		start, err := parseDate(startDate)
+		start, end, err := parsePeriod(r)
 		if err != nil {
-			respondError(w, r, http.StatusBadRequest, "invalid start date format, use YYYY-MM-DD")
+			respondError(w, r, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		end, err := parseDate(endDate)
+		currency, err := parseCurrency(r)
 		if err != nil {
-			respondError(w, r, http.StatusBadRequest, "invalid end date format, use YYYY-MM-DD")
+			respondError(w, r, http.StatusBadRequest, err.Error())
 			return
 		}
 
@@ -250,9 +296,9 @@ func CustomerSpendingPercentile(storage *postgresql.Storage) http.HandlerFunc {
 			return
 		}
 
-		result, err := storage.CustomerSpendingPercentile(start, end, percentile)
+		result, err := storage.CustomerSpendingPercentile(r.Context(), start, end, percentile, currency)
 		if err != nil {
-			respondError(w, r, http.StatusInternalServerError, err.Error())
+			respondStorageError(w, r, err)
 			return
 		}
 
@@ -265,27 +311,24 @@ func CustomerSpendingPercentile(storage *postgresql.Storage) http.HandlerFunc {
 // ====================================================================
 
 // GenerateSalesReport - полный отчет по продажам
-// GET /analytics/sales-report?start=2024-01-01&end=2024-01-31
-func GenerateSalesReport(storage *postgresql.Storage) http.HandlerFunc {
+// GET /analytics/sales-report?start=2024-01-01&end=2024-01-31&tz=Europe/Moscow&currency=USD
+func GenerateSalesReport(storage Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		startDate := r.URL.Query().Get("start")
-		endDate := r.URL.Query().Get("end")
-
-		start, err := parseDate(startDate)
+		start, end, err := parsePeriod(r)
 		if err != nil {
-			respondError(w, r, http.StatusBadRequest, "invalid start date format, use YYYY-MM-DD")
+			respondError(w, r, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		end, err := parseDate(endDate)
+		currency, err := parseCurrency(r)
 		if err != nil {
-			respondError(w, r, http.StatusBadRequest, "invalid end date format, use YYYY-MM-DD")
+			respondError(w, r, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		report, err := storage.GenerateSalesReport(start, end)
+		report, err := storage.GenerateSalesReport(r.Context(), start, end, currency)
 		if err != nil {
-			respondError(w, r, http.StatusInternalServerError, err.Error())
+			respondStorageError(w, r, err)
 			return
 		}
 
@@ -293,6 +336,66 @@ func GenerateSalesReport(storage *postgresql.Storage) http.HandlerFunc {
 	}
 }
 
+// ====================================================================
+// RETURNS
+// ====================================================================
+
+// ReturnRateByProduct - доля возвратов по товарам
+// GET /analytics/return-rate?start=2024-01-01&end=2024-01-31&tz=Europe/Moscow&currency=USD
+func ReturnRateByProduct(storage Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start, end, err := parsePeriod(r)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		currency, err := parseCurrency(r)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		rates, err := storage.ReturnRateByProduct(r.Context(), start, end, currency)
+		if err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+
+		render.JSON(w, r, rates)
+	}
+}
+
+// ====================================================================
+// PROMOTIONS
+// ====================================================================
+
+// PromotionsReport - прирост среднего чека и стоимость скидок по промоакциям
+// GET /analytics/promotions?start=2024-01-01&end=2024-01-31&tz=Europe/Moscow&currency=USD
+func PromotionsReport(storage Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start, end, err := parsePeriod(r)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		currency, err := parseCurrency(r)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		stats, err := storage.PromotionsReport(r.Context(), start, end, currency)
+		if err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+
+		render.JSON(w, r, stats)
+	}
+}
+
 // ====================================================================
 // ROUTE SETUP HELPER
 // ====================================================================
@@ -305,7 +408,7 @@ type Route struct {
 }
 
 // GetAnalyticsRoutes - получить все роуты аналитики
-func GetAnalyticsRoutes(storage *postgresql.Storage) []Route {
+func GetAnalyticsRoutes(storage Storage) []Route {
 	return []Route{
 		// Revenue
 		{"GET", "/analytics/revenue", TotalRevenueByPeriod(storage)},
@@ -326,5 +429,11 @@ func GetAnalyticsRoutes(storage *postgresql.Storage) []Route {
 
 		// Combined reports
 		{"GET", "/analytics/sales-report", GenerateSalesReport(storage)},
+
+		// Returns
+		{"GET", "/analytics/return-rate", ReturnRateByProduct(storage)},
+
+		// Promotions
+		{"GET", "/analytics/promotions", PromotionsReport(storage)},
 	}
 }
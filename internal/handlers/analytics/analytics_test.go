@@ -0,0 +1,94 @@
+package analytics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"salesTracker/internal/storage/postgresql"
+)
+
+func TestParsePeriod(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		wantStart string
+		wantEnd   string
+		wantErr   bool
+	}{
+		{name: "utc by default", query: "start=2024-03-01&end=2024-03-31",
+			wantStart: "2024-03-01T00:00:00Z", wantEnd: "2024-03-31T00:00:00Z"},
+		{name: "moscow", query: "start=2024-03-01&end=2024-03-31&tz=Europe/Moscow",
+			wantStart: "2024-03-01T00:00:00+03:00", wantEnd: "2024-03-31T00:00:00+03:00"},
+		// смена времени: сутки начала и конца в разных смещениях
+		{name: "new york across dst", query: "start=2024-03-09&end=2024-03-11&tz=America/New_York",
+			wantStart: "2024-03-09T00:00:00-05:00", wantEnd: "2024-03-11T00:00:00-04:00"},
+
+		{name: "unknown tz", query: "start=2024-03-01&end=2024-03-31&tz=Mars/Olympus", wantErr: true},
+		{name: "offset instead of zone", query: "start=2024-03-01&end=2024-03-31&tz=%2B03:00", wantErr: true},
+		{name: "timestamp start", query: "start=2024-03-01T10:00:00Z&end=2024-03-31", wantErr: true},
+		{name: "missing end", query: "start=2024-03-01", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/analytics/revenue?"+tt.query, nil)
+
+			start, end, err := parsePeriod(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePeriod() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := start.Format(time.RFC3339); got != tt.wantStart {
+				t.Errorf("start = %s, want %s", got, tt.wantStart)
+			}
+			if got := end.Format(time.RFC3339); got != tt.wantEnd {
+				t.Errorf("end = %s, want %s", got, tt.wantEnd)
+			}
+		})
+	}
+}
+
+// periodStorage — запоминает период, с которым вызвана аналитика
+type periodStorage struct {
+	Storage
+	start, end time.Time
+	currency   string
+}
+
+func (s *periodStorage) TotalRevenueByPeriod(_ context.Context, start, end time.Time, currency string) (*postgresql.PeriodSummary, error) {
+	s.start, s.end, s.currency = start, end, currency
+	return &postgresql.PeriodSummary{StartDate: start, EndDate: end, Currency: currency}, nil
+}
+
+func TestTotalRevenueByPeriodZone(t *testing.T) {
+	storage := &periodStorage{}
+	h := TotalRevenueByPeriod(storage)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/analytics/revenue?start=2024-03-01&end=2024-03-01&tz=Asia/Vladivostok&currency=usd", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	// хранилище получает границы суток в поясе запроса, а не в UTC
+	if got := storage.start.Location().String(); got != "Asia/Vladivostok" {
+		t.Errorf("start location = %s, want Asia/Vladivostok", got)
+	}
+	if want := time.Date(2024, 2, 29, 14, 0, 0, 0, time.UTC); !storage.start.Equal(want) {
+		t.Errorf("start = %v, want %v", storage.start.UTC(), want)
+	}
+	if storage.currency != "USD" {
+		t.Errorf("currency = %q, want USD", storage.currency)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/analytics/revenue?start=2024-03-01&end=2024-03-01&tz=Nowhere", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unknown tz: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,170 @@
+package apikeys
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"salesTracker/internal/auth"
+	"salesTracker/internal/storage"
+	"salesTracker/internal/storage/postgresql"
+)
+
+// ====================================================================
+// REQUEST/RESPONSE DTOs
+// ====================================================================
+
+// APIKeyRequest - DTO для выпуска API-ключа
+type APIKeyRequest struct {
+	Name      string `json:"name"`
+	Role      string `json:"role"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// APIKeyResponse - выпущенный ключ; поле key возвращается только один раз
+type APIKeyResponse struct {
+	postgresql.APIKey
+	Key string `json:"key"`
+}
+
+// ====================================================================
+// HELPERS
+// ====================================================================
+
+func parseURLParamID(r *http.Request) (int, error) {
+	idStr := chi.URLParam(r, "id")
+	return strconv.Atoi(idStr)
+}
+
+func respondError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	render.Status(r, status)
+	render.JSON(w, r, map[string]string{"error": message})
+}
+
+func respondStorageError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, storage.ErrAPIKeyNotFound) {
+		respondError(w, r, http.StatusNotFound, "api key not found")
+		return
+	}
+	respondError(w, r, http.StatusInternalServerError, err.Error())
+}
+
+// ====================================================================
+// API KEYS HANDLERS
+// ====================================================================
+
+// CreateAPIKey - выпустить API-ключ
+// POST /api/v1/api-keys
+// {"name": "bi-export", "role": "analyst", "expires_at": "2025-01-01T00:00:00Z"}
+func CreateAPIKey(storage *postgresql.Storage, policy *auth.Policy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req APIKeyRequest
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid request body")
+			return
+		}
+
+		key := postgresql.APIKey{Name: strings.TrimSpace(req.Name), Role: req.Role}
+		if key.Name == "" {
+			respondError(w, r, http.StatusBadRequest, "name is required")
+			return
+		}
+		if !policy.HasRole(key.Role) {
+			respondError(w, r, http.StatusBadRequest,
+				fmt.Sprintf("unknown role %q, use one of: %s", key.Role, strings.Join(policy.Roles(), ", ")))
+			return
+		}
+
+		if req.ExpiresAt != "" {
+			expiresAt, err := time.Parse(time.RFC3339, req.ExpiresAt)
+			if err != nil {
+				respondError(w, r, http.StatusBadRequest, "invalid expires_at, use RFC 3339")
+				return
+			}
+			if !expiresAt.After(time.Now()) {
+				respondError(w, r, http.StatusBadRequest, "expires_at must be in the future")
+				return
+			}
+			key.ExpiresAt = &expiresAt
+		}
+
+		if principal := auth.PrincipalFrom(r.Context()); principal != nil {
+			key.CreatedBy = principal.Subject
+		}
+
+		plaintext, prefix, hash, err := auth.GenerateAPIKey()
+		if err != nil {
+			respondError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+		key.Prefix = prefix
+		key.KeyHash = hash
+
+		id, err := storage.AddAPIKey(r.Context(), key)
+		if err != nil {
+			respondError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		created, err := storage.GetAPIKey(r.Context(), id)
+		if err != nil {
+			respondError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, APIKeyResponse{APIKey: *created, Key: plaintext})
+	}
+}
+
+// ListAPIKeys - получить список API-ключей (без самих ключей)
+func ListAPIKeys(storage *postgresql.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keys, err := storage.ListAPIKeys(r.Context())
+		if err != nil {
+			respondError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		render.JSON(w, r, keys)
+	}
+}
+
+// RevokeAPIKey - отозвать API-ключ
+// DELETE /api/v1/api-keys/{id}
+func RevokeAPIKey(storage *postgresql.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseURLParamID(r)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid api key id")
+			return
+		}
+
+		if err := storage.RevokeAPIKey(r.Context(), id, time.Now()); err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+
+		render.Status(r, http.StatusNoContent)
+	}
+}
+
+// WhoAmI - клиент, от имени которого выполняется запрос
+// GET /api/v1/auth/whoami
+func WhoAmI() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal := auth.PrincipalFrom(r.Context())
+		if principal == nil {
+			respondError(w, r, http.StatusNotFound, "authentication is disabled")
+			return
+		}
+
+		render.JSON(w, r, principal)
+	}
+}
@@ -0,0 +1,98 @@
+package auditlog
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/render"
+
+	"salesTracker/internal/storage/postgresql"
+)
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// ====================================================================
+// HELPERS
+// ====================================================================
+
+func respondError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	render.Status(r, status)
+	render.JSON(w, r, map[string]string{"error": message})
+}
+
+// parseTimestamp - момент времени в RFC 3339 или дата YYYY-MM-DD (полночь UTC)
+func parseTimestamp(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		if t, err = time.Parse("2006-01-02", value); err != nil {
+			return nil, err
+		}
+	}
+	return &t, nil
+}
+
+func parseFilter(r *http.Request) (postgresql.AuditFilter, string) {
+	query := r.URL.Query()
+	f := postgresql.AuditFilter{
+		EntityType: query.Get("entity"),
+		Actor:      query.Get("actor"),
+		Limit:      defaultLimit,
+	}
+
+	if value := query.Get("entity_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			return f, "invalid entity_id"
+		}
+		f.EntityID = &id
+	}
+
+	var err error
+	if f.Since, err = parseTimestamp(query.Get("since")); err != nil {
+		return f, "invalid since, use RFC 3339 or YYYY-MM-DD"
+	}
+	if f.Until, err = parseTimestamp(query.Get("until")); err != nil {
+		return f, "invalid until, use RFC 3339 or YYYY-MM-DD"
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxLimit {
+			return f, "invalid limit, must be between 1 and 1000"
+		}
+		f.Limit = limit
+	}
+
+	return f, ""
+}
+
+// ====================================================================
+// AUDIT HANDLERS
+// ====================================================================
+
+// ListAuditEntries - журнал изменений, новые записи первыми
+// GET /api/v1/audit?entity=products&entity_id=5&actor=alice&since=2024-01-01&until=2024-02-01&limit=100
+func ListAuditEntries(storage *postgresql.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, problem := parseFilter(r)
+		if problem != "" {
+			respondError(w, r, http.StatusBadRequest, problem)
+			return
+		}
+
+		entries, err := storage.ListAuditEntries(r.Context(), filter)
+		if err != nil {
+			respondError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		render.JSON(w, r, entries)
+	}
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/render"
+
+	"salesTracker/internal/storage/postgresql"
+)
+
+// ====================================================================
+// BATCH - Пакетное создание и обновление товаров и покупателей
+// ====================================================================
+
+// maxBatchItems — предел строк в одном пакете
+const maxBatchItems = 1000
+
+// ProductBatchItem - строка пакета товаров: без product_id товар создается,
+// с product_id и version - обновляется
+type ProductBatchItem struct {
+	ProductID int `json:"product_id"`
+	Version   int `json:"version"`
+	ProductRequest
+}
+
+// ProductBatchRequest - тело POST /api/v1/products:batch
+type ProductBatchRequest struct {
+	Mode  string             `json:"mode"`
+	Items []ProductBatchItem `json:"items"`
+}
+
+// CustomerBatchItem - строка пакета покупателей, см. ProductBatchItem
+type CustomerBatchItem struct {
+	CustomerID int `json:"customer_id"`
+	Version    int `json:"version"`
+	CustomerRequest
+}
+
+// CustomerBatchRequest - тело POST /api/v1/customers:batch
+type CustomerBatchRequest struct {
+	Mode  string              `json:"mode"`
+	Items []CustomerBatchItem `json:"items"`
+}
+
+// BatchResponse - итог пакета; results по одному на каждую строку запроса
+type BatchResponse struct {
+	Mode      string                   `json:"mode"`
+	Committed bool                     `json:"committed"`
+	Succeeded int                      `json:"succeeded"`
+	Failed    int                      `json:"failed"`
+	Results   []postgresql.BatchResult `json:"results"`
+}
+
+// SaveProductsBatch - создать и обновить товары одним запросом
+// POST /api/v1/products:batch {"mode": "best_effort", "items": [{"product_name": "...", ...}]}
+// mode all_or_nothing (по умолчанию): при ошибке в любой строке ничего не
+// записывается, ответ 422 с ошибками строк; best_effort: записываются
+// все корректные строки, ответ 200 (422, если не записано ни одной).
+// Записанный пакет сбрасывает кэш аналитики, как и UpdateProduct.
+func SaveProductsBatch(storage *postgresql.Storage, analytics AnalyticsInvalidator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req ProductBatchRequest
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid request body")
+			return
+		}
+
+		mode, ok := batchMode(w, r, req.Mode, len(req.Items))
+		if !ok {
+			return
+		}
+
+		var (
+			products []postgresql.Product
+			indexes  []int
+		)
+		invalid := map[int]string{}
+		for i, item := range req.Items {
+			if err := item.validate(); err != nil {
+				invalid[i] = err.Error()
+				continue
+			}
+			products = append(products, postgresql.Product{
+				ProductID:     item.ProductID,
+				ProductName:   item.ProductName,
+				CategoryID:    item.CategoryID,
+				Price:         item.Price,
+				Cost:          item.Cost,
+				StockQuantity: item.StockQuantity,
+				Currency:      item.Currency,
+				Version:       item.Version,
+			})
+			indexes = append(indexes, i)
+		}
+
+		respondBatch(w, r, mode, len(req.Items), invalid, indexes, func() ([]postgresql.BatchResult, bool, error) {
+			results, committed, err := storage.SaveProducts(r.Context(), products, mode)
+			if committed {
+				invalidateAll(analytics)
+			}
+			return results, committed, err
+		})
+	}
+}
+
+// SaveCustomersBatch - создать и обновить покупателей одним запросом
+// POST /api/v1/customers:batch, режимы как у SaveProductsBatch
+func SaveCustomersBatch(storage *postgresql.Storage, analytics AnalyticsInvalidator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req CustomerBatchRequest
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid request body")
+			return
+		}
+
+		mode, ok := batchMode(w, r, req.Mode, len(req.Items))
+		if !ok {
+			return
+		}
+
+		var (
+			customers []postgresql.Customer
+			indexes   []int
+		)
+		invalid := map[int]string{}
+		now := time.Now()
+		for i, item := range req.Items {
+			if err := item.validate(); err != nil {
+				invalid[i] = err.Error()
+				continue
+			}
+			customers = append(customers, postgresql.Customer{
+				CustomerID:       item.CustomerID,
+				FirstName:        item.FirstName,
+				LastName:         item.LastName,
+				Email:            item.Email,
+				Phone:            item.Phone,
+				City:             item.City,
+				RegistrationDate: now,
+				Version:          item.Version,
+			})
+			indexes = append(indexes, i)
+		}
+
+		respondBatch(w, r, mode, len(req.Items), invalid, indexes, func() ([]postgresql.BatchResult, bool, error) {
+			results, committed, err := storage.SaveCustomers(r.Context(), customers, mode)
+			if committed {
+				invalidateAll(analytics)
+			}
+			return results, committed, err
+		})
+	}
+}
+
+// ====================================================================
+// HELPERS
+// ====================================================================
+
+func (item *ProductBatchItem) validate() error {
+	if item.ProductID < 0 {
+		return fmt.Errorf("invalid product_id")
+	}
+	if item.ProductID > 0 && item.Version <= 0 {
+		return fmt.Errorf("version is required to update a product")
+	}
+	return item.ProductRequest.validate()
+}
+
+func (item CustomerBatchItem) validate() error {
+	if item.CustomerID < 0 {
+		return fmt.Errorf("invalid customer_id")
+	}
+	if item.CustomerID > 0 && item.Version <= 0 {
+		return fmt.Errorf("version is required to update a customer")
+	}
+	return item.CustomerRequest.validate()
+}
+
+// batchMode - проверить режим и размер пакета; false - ответ 400 уже записан
+func batchMode(w http.ResponseWriter, r *http.Request, mode string, items int) (string, bool) {
+	switch mode {
+	case "":
+		mode = postgresql.BatchAllOrNothing
+	case postgresql.BatchAllOrNothing, postgresql.BatchBestEffort:
+	default:
+		respondError(w, r, http.StatusBadRequest,
+			fmt.Sprintf("invalid mode %q, use %s or %s", mode, postgresql.BatchAllOrNothing, postgresql.BatchBestEffort))
+		return "", false
+	}
+
+	if items == 0 {
+		respondError(w, r, http.StatusBadRequest, "items must not be empty")
+		return "", false
+	}
+	if items > maxBatchItems {
+		respondError(w, r, http.StatusBadRequest, fmt.Sprintf("too many items, at most %d per batch", maxBatchItems))
+		return "", false
+	}
+	return mode, true
+}
+
+// respondBatch - записать корректные строки и ответить итогом по всем строкам.
+// indexes[k] - позиция в запросе k-й строки, переданной в хранилище;
+// строки из invalid в хранилище не попадают. В режиме all_or_nothing
+// ошибка проверки любой строки отменяет запись всего пакета.
+func respondBatch(w http.ResponseWriter, r *http.Request, mode string, total int, invalid map[int]string,
+	indexes []int, save func() ([]postgresql.BatchResult, bool, error)) {
+	resp := BatchResponse{Mode: mode, Results: make([]postgresql.BatchResult, total)}
+	for i := range resp.Results {
+		resp.Results[i] = postgresql.BatchResult{Index: i, Error: invalid[i]}
+	}
+
+	if len(indexes) > 0 && (mode == postgresql.BatchBestEffort || len(invalid) == 0) {
+		results, committed, err := save()
+		if err != nil {
+			// причина — внутренняя ошибка базы, клиенту она ничего не даст
+			log.Printf("batch: %v", err)
+			respondError(w, r, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+			return
+		}
+		for k, res := range results {
+			res.Index = indexes[k]
+			resp.Results[res.Index] = res
+		}
+		resp.Committed = committed
+	}
+
+	for _, res := range resp.Results {
+		if res.Error != "" {
+			resp.Failed++
+		} else if resp.Committed {
+			resp.Succeeded++
+		}
+	}
+
+	// ничего не записано: пакет all_or_nothing с ошибками или все строки ошибочны
+	if resp.Succeeded == 0 {
+		resp.Committed = false
+		render.Status(r, http.StatusUnprocessableEntity)
+	}
+	render.JSON(w, r, resp)
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"salesTracker/internal/storage/postgresql"
+)
+
+func TestRespondBatch(t *testing.T) {
+	type saved struct {
+		results   []postgresql.BatchResult
+		committed bool
+		err       error
+	}
+
+	tests := []struct {
+		name    string
+		mode    string
+		total   int
+		invalid map[int]string
+		indexes []int
+		save    *saved // nil — хранилище не должно вызываться
+
+		wantStatus    int
+		wantCommitted bool
+		wantResults   []postgresql.BatchResult
+	}{
+		{
+			name: "all or nothing written", mode: postgresql.BatchAllOrNothing, total: 2, indexes: []int{0, 1},
+			save: &saved{committed: true, results: []postgresql.BatchResult{
+				{Index: 0, ID: 10, Action: postgresql.BatchCreated},
+				{Index: 1, ID: 4, Action: postgresql.BatchUpdated},
+			}},
+			wantStatus: http.StatusOK, wantCommitted: true,
+			wantResults: []postgresql.BatchResult{
+				{Index: 0, ID: 10, Action: postgresql.BatchCreated},
+				{Index: 1, ID: 4, Action: postgresql.BatchUpdated},
+			},
+		},
+		{
+			name: "all or nothing with invalid row skips storage", mode: postgresql.BatchAllOrNothing, total: 2,
+			invalid: map[int]string{1: "price must be positive"}, indexes: []int{0},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantResults: []postgresql.BatchResult{
+				{Index: 0},
+				{Index: 1, Error: "price must be positive"},
+			},
+		},
+		{
+			name: "all or nothing rolled back by storage", mode: postgresql.BatchAllOrNothing, total: 2, indexes: []int{0, 1},
+			save: &saved{committed: false, results: []postgresql.BatchResult{
+				{Index: 0},
+				{Index: 1, Error: "duplicate key"},
+			}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantResults: []postgresql.BatchResult{
+				{Index: 0},
+				{Index: 1, Error: "duplicate key"},
+			},
+		},
+		{
+			// хранилище нумерует переданные ему строки, ответ — строки запроса
+			name: "best effort maps storage rows to request rows", mode: postgresql.BatchBestEffort, total: 3,
+			invalid: map[int]string{0: "product_name is required"}, indexes: []int{1, 2},
+			save: &saved{committed: true, results: []postgresql.BatchResult{
+				{Index: 0, ID: 7, Action: postgresql.BatchCreated},
+				{Index: 1, Error: "version conflict"},
+			}},
+			wantStatus: http.StatusOK, wantCommitted: true,
+			wantResults: []postgresql.BatchResult{
+				{Index: 0, Error: "product_name is required"},
+				{Index: 1, ID: 7, Action: postgresql.BatchCreated},
+				{Index: 2, Error: "version conflict"},
+			},
+		},
+		{
+			name: "best effort with nothing written", mode: postgresql.BatchBestEffort, total: 1, indexes: []int{0},
+			save:       &saved{committed: true, results: []postgresql.BatchResult{{Index: 0, Error: "version conflict"}}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantResults: []postgresql.BatchResult{
+				{Index: 0, Error: "version conflict"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/products:batch", nil)
+			w := httptest.NewRecorder()
+
+			called := false
+			respondBatch(w, r, tt.mode, tt.total, tt.invalid, tt.indexes, func() ([]postgresql.BatchResult, bool, error) {
+				called = true
+				if tt.save == nil {
+					t.Fatal("storage called")
+				}
+				return tt.save.results, tt.save.committed, tt.save.err
+			})
+			if tt.save != nil && !called {
+				t.Fatal("storage not called")
+			}
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			var resp BatchResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Committed != tt.wantCommitted {
+				t.Errorf("committed = %v, want %v", resp.Committed, tt.wantCommitted)
+			}
+			if !reflect.DeepEqual(resp.Results, tt.wantResults) {
+				t.Errorf("results = %+v, want %+v", resp.Results, tt.wantResults)
+			}
+		})
+	}
+}
+
+func TestRespondBatchStorageError(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/products:batch", nil)
+	w := httptest.NewRecorder()
+
+	respondBatch(w, r, postgresql.BatchAllOrNothing, 1, nil, []int{0}, func() ([]postgresql.BatchResult, bool, error) {
+		return nil, false, errors.New(`storage.postgresql.SaveProducts: pq: relation "products" does not exist`)
+	})
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+	// подробности ошибки базы клиенту не уходят
+	if body := w.Body.String(); strings.Contains(body, "pq:") || !strings.Contains(body, "Internal Server Error") {
+		t.Errorf("body = %s, want the generic message", body)
+	}
+}
+
+func TestBatchMode(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       string
+		items      int
+		want       string
+		wantStatus int
+	}{
+		{name: "default", mode: "", items: 1, want: postgresql.BatchAllOrNothing},
+		{name: "best effort", mode: postgresql.BatchBestEffort, items: 1, want: postgresql.BatchBestEffort},
+		{name: "max items", mode: postgresql.BatchAllOrNothing, items: maxBatchItems, want: postgresql.BatchAllOrNothing},
+
+		{name: "unknown mode", mode: "partial", items: 1, wantStatus: http.StatusBadRequest},
+		{name: "no items", mode: "", items: 0, wantStatus: http.StatusBadRequest},
+		{name: "too many items", mode: "", items: maxBatchItems + 1, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/products:batch", nil)
+			w := httptest.NewRecorder()
+
+			got, ok := batchMode(w, r, tt.mode, tt.items)
+			if ok != (tt.wantStatus == 0) {
+				t.Fatalf("batchMode() ok = %v, status %d", ok, w.Code)
+			}
+			if ok && got != tt.want {
+				t.Errorf("batchMode() = %q, want %q", got, tt.want)
+			}
+			if !ok && w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
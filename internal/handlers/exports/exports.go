@@ -0,0 +1,104 @@
+package exports
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"salesTracker/internal/export"
+	"salesTracker/internal/storage"
+	"salesTracker/internal/storage/postgresql"
+)
+
+// WatermarkHeader — заголовок ответа со значением updated_since для следующей выгрузки
+const WatermarkHeader = "X-Export-Watermark"
+
+// ====================================================================
+// HELPERS
+// ====================================================================
+
+func respondError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	render.Status(r, status)
+	render.JSON(w, r, map[string]string{"error": message})
+}
+
+// parseSince - updated_since в RFC 3339 (как в X-Export-Watermark) или дата YYYY-MM-DD
+func parseSince(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		if t, err = time.Parse("2006-01-02", value); err != nil {
+			return nil, err
+		}
+	}
+	return &t, nil
+}
+
+func respondExportError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, storage.ErrUnknownExport):
+		respondError(w, r, http.StatusNotFound, err.Error())
+	default:
+		respondError(w, r, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// ====================================================================
+// EXPORT HANDLERS
+// ====================================================================
+
+// ExportEntity - потоковая выгрузка сущности целиком или изменений с updated_since
+// GET /api/v1/export/{entity}?format=ndjson|csv|parquet&updated_since=2024-01-15T00:00:00Z
+// entity: categories, products, customers, orders, order_items. Строки читаются
+// серверным курсором и сразу пишутся в ответ. Заголовок X-Export-Watermark -
+// updated_since для следующей инкрементальной выгрузки; при сбое посреди
+// выгрузки соединение обрывается, и отметку сохранять нельзя.
+func ExportEntity(storage *postgresql.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		entity := chi.URLParam(r, "entity")
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = export.FormatNDJSON
+		}
+		contentType, err := export.ContentType(format)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		since, err := parseSince(r.URL.Query().Get("updated_since"))
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid updated_since, use RFC 3339 or YYYY-MM-DD")
+			return
+		}
+
+		cursor, err := storage.OpenExport(r.Context(), entity, since)
+		if err != nil {
+			respondExportError(w, r, err)
+			return
+		}
+		defer cursor.Close()
+
+		// выгрузка идет дольше общего таймаута записи сервера
+		http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, entity, format))
+		w.Header().Set(WatermarkHeader, cursor.Watermark.UTC().Format(time.RFC3339Nano))
+
+		if _, err := export.Write(w, format, cursor.Columns, cursor); err != nil {
+			// статус уже отправлен: обрыв соединения не дает клиенту принять
+			// неполную выгрузку за полную
+			log.Printf("export %s: %v", entity, err)
+			panic(http.ErrAbortHandler)
+		}
+	}
+}
@@ -1,13 +1,20 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
 
+	"salesTracker/internal/etag"
+	"salesTracker/internal/mergepatch"
+	"salesTracker/internal/storage"
 	"salesTracker/internal/storage/postgresql"
 )
 
@@ -28,6 +35,7 @@ type ProductRequest struct {
 	Price         float64 `json:"price"`
 	Cost          float64 `json:"cost"`
 	StockQuantity int     `json:"stock_quantity"`
+	Currency      string  `json:"currency"`
 }
 
 // CustomerRequest - DTO для создания/обновления покупателя
@@ -39,16 +47,49 @@ type CustomerRequest struct {
 	City      string `json:"city"`
 }
 
-// OrderRequest - DTO для создания/обновления заказа
+// OrderRequest - DTO для создания заказа. Цены позиций items и скидки по акциям
+// (и купону coupon_code) считает сервер, как и total_amount: заказ без items
+// создается с нулевой суммой, которую пересчитывают позиции из /order-items.
+// Ненулевой total_amount в запросе отклоняется.
 type OrderRequest struct {
+	CustomerID    int                `json:"customer_id"`
+	OrderDate     string             `json:"order_date"`
+	Status        string             `json:"status"`
+	PaymentMethod string             `json:"payment_method"`
+	Currency      string             `json:"currency"`
+	TotalAmount   float64            `json:"total_amount"`
+	Items         []OrderLineRequest `json:"items"`
+	CouponCode    string             `json:"coupon_code"`
+}
+
+// OrderLineRequest - позиция создаваемого заказа
+type OrderLineRequest struct {
+	ProductID int `json:"product_id"`
+	Quantity  int `json:"quantity"`
+}
+
+// OrderPatch - изменяемые поля заказа для PATCH; позиции заказа
+// меняются через /order-items
+type OrderPatch struct {
 	CustomerID    int     `json:"customer_id"`
 	OrderDate     string  `json:"order_date"`
 	Status        string  `json:"status"`
 	PaymentMethod string  `json:"payment_method"`
+	Currency      string  `json:"currency"`
 	TotalAmount   float64 `json:"total_amount"`
 }
 
-// OrderItemRequest - DTO для создания/обновления позиции заказа
+// OrderItemPatch - поля позиции заказа для PUT и PATCH. Меняется только
+// количество: цену и скидку считает сервер, их можно передать лишь
+// с текущими значениями.
+type OrderItemPatch struct {
+	Quantity int     `json:"quantity"`
+	Price    float64 `json:"price"`
+	Discount float64 `json:"discount"`
+}
+
+// OrderItemRequest - DTO для создания позиции заказа. Цена берется из каталога,
+// скидка считается по акциям заказа, поэтому price и discount передавать нельзя.
 type OrderItemRequest struct {
 	OrderID   int     `json:"order_id"`
 	ProductID int     `json:"product_id"`
@@ -57,6 +98,99 @@ type OrderItemRequest struct {
 	Discount  float64 `json:"discount"`
 }
 
+// ====================================================================
+// VALIDATION - Проверка результата PATCH
+// ====================================================================
+
+func (req CategoryRequest) validate() error {
+	if strings.TrimSpace(req.CategoryName) == "" {
+		return fmt.Errorf("category_name is required")
+	}
+	return nil
+}
+
+func (req *ProductRequest) validate() error {
+	if strings.TrimSpace(req.ProductName) == "" {
+		return fmt.Errorf("product_name is required")
+	}
+	if req.CategoryID < 0 {
+		return fmt.Errorf("invalid category_id")
+	}
+	if req.Price < 0 || req.Cost < 0 {
+		return fmt.Errorf("price and cost must not be negative")
+	}
+	if req.StockQuantity < 0 {
+		return fmt.Errorf("stock_quantity must not be negative")
+	}
+
+	currency, err := normalizeCurrency(req.Currency)
+	if err != nil {
+		return err
+	}
+	req.Currency = currency
+	return nil
+}
+
+func (req CustomerRequest) validate() error {
+	if strings.TrimSpace(req.FirstName) == "" || strings.TrimSpace(req.LastName) == "" {
+		return fmt.Errorf("first_name and last_name are required")
+	}
+	if req.Email != "" && !strings.Contains(req.Email, "@") {
+		return fmt.Errorf("invalid email %q", req.Email)
+	}
+	return nil
+}
+
+// toOrder - проверить поля и наложить их на текущий заказ
+func (p OrderPatch) toOrder(current postgresql.Order) (postgresql.Order, error) {
+	o := current
+	o.CustomerID = p.CustomerID
+	o.Status = p.Status
+	o.PaymentMethod = p.PaymentMethod
+
+	var err error
+	if o.OrderDate, err = parseTimestamp(p.OrderDate); err != nil {
+		return o, fmt.Errorf("invalid order date format, use RFC 3339 or YYYY-MM-DD")
+	}
+	if o.Currency, err = normalizeCurrency(p.Currency); err != nil {
+		return o, err
+	}
+	if o.CustomerID < 0 {
+		return o, fmt.Errorf("invalid customer_id")
+	}
+	if strings.TrimSpace(o.Status) == "" {
+		return o, fmt.Errorf("status is required")
+	}
+	if p.TotalAmount != current.TotalAmount {
+		return o, errTotalAmount
+	}
+	return o, nil
+}
+
+// errTotalAmount - сумму заказа считает сервер по позициям
+var errTotalAmount = errors.New("total_amount is calculated from order items and cannot be set")
+
+func (req OrderItemRequest) validate() error {
+	if req.Quantity <= 0 {
+		return fmt.Errorf("quantity must be positive")
+	}
+	if req.Price != 0 || req.Discount != 0 {
+		return fmt.Errorf("price and discount are calculated by the server, send product_id and quantity")
+	}
+	return nil
+}
+
+// validate - проверить поля позиции current после наложения PUT/PATCH
+func (p OrderItemPatch) validate(current postgresql.OrderItem) error {
+	if p.Quantity <= 0 {
+		return fmt.Errorf("quantity must be positive")
+	}
+	if p.Price != current.Price || p.Discount != current.Discount {
+		return fmt.Errorf("price and discount are calculated by the server, only quantity can be changed")
+	}
+	return nil
+}
+
 // ====================================================================
 // HELPERS
 // ====================================================================
@@ -66,8 +200,58 @@ func parseURLParamID(r *http.Request) (int, error) {
 	return strconv.Atoi(idStr)
 }
 
-func parseDate(dateStr string) (time.Time, error) {
-	return time.Parse("2006-01-02", dateStr)
+// includeDeleted - показывать ли удаленные записи (?include_deleted=true)
+func includeDeleted(r *http.Request) bool {
+	include, _ := strconv.ParseBool(r.URL.Query().Get("include_deleted"))
+	return include
+}
+
+// parseTimestamp - разобрать момент времени в RFC 3339 ("2024-01-15T14:30:00+03:00")
+// или, для совместимости, дату YYYY-MM-DD (полночь UTC)
+func parseTimestamp(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// normalizeCurrency - проверить код валюты ISO 4217; пустой код — учетная валюта
+func normalizeCurrency(code string) (string, error) {
+	if code == "" {
+		return postgresql.BaseCurrency, nil
+	}
+
+	code = strings.ToUpper(code)
+	if len(code) != 3 || strings.Trim(code, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return "", fmt.Errorf("invalid currency %q, use a 3-letter ISO 4217 code", code)
+	}
+	return code, nil
+}
+
+// AnalyticsInvalidator - сбрасывает закэшированную аналитику за период,
+// в который попадают измененные заказы
+type AnalyticsInvalidator interface {
+	InvalidateRange(start, end time.Time)
+}
+
+// NopInvalidator - используется, когда кэш аналитики выключен
+type NopInvalidator struct{}
+
+func (NopInvalidator) InvalidateRange(start, end time.Time) {}
+
+// invalidateAll - сбросить аналитику за все периоды: название и категория
+// товара, город покупателя входят в отчеты за всю историю их заказов
+func invalidateAll(analytics AnalyticsInvalidator) {
+	analytics.InvalidateRange(time.Time{}, time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC))
+}
+
+// invalidateOrder - сбросить аналитику за дату заказа
+func invalidateOrder(ctx context.Context, storage *postgresql.Storage, analytics AnalyticsInvalidator, orderID int) {
+	order, err := storage.GetOrder(ctx, orderID)
+	if err != nil {
+		return
+	}
+	analytics.InvalidateRange(order.OrderDate, order.OrderDate)
 }
 
 func respondError(w http.ResponseWriter, r *http.Request, status int, message string) {
@@ -75,6 +259,63 @@ func respondError(w http.ResponseWriter, r *http.Request, status int, message st
 	render.JSON(w, r, map[string]string{"error": message})
 }
 
+// checkIfMatch - проверить If-Match по текущей версии записи;
+// false - ответ 428/412 уже записан
+func checkIfMatch(w http.ResponseWriter, r *http.Request, version int) bool {
+	err := etag.CheckIfMatch(r, version)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, etag.ErrPreconditionRequired):
+		respondError(w, r, http.StatusPreconditionRequired, err.Error())
+	default:
+		respondError(w, r, http.StatusPreconditionFailed, err.Error())
+	}
+	return false
+}
+
+// decodePatch - наложить merge patch из тела на doc; false - ответ 415/400 уже записан
+func decodePatch(w http.ResponseWriter, r *http.Request, doc any) ([]string, bool) {
+	changed, err := mergepatch.Decode(r, doc)
+	switch {
+	case err == nil:
+		return changed, true
+	case errors.Is(err, mergepatch.ErrUnsupportedMediaType):
+		respondError(w, r, http.StatusUnsupportedMediaType, err.Error())
+	case errors.Is(err, mergepatch.ErrInvalidPatch):
+		respondError(w, r, http.StatusBadRequest, err.Error())
+	default:
+		respondError(w, r, http.StatusInternalServerError, err.Error())
+	}
+	return nil, false
+}
+
+func respondStorageError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, storage.ErrCategoryNotFound),
+		errors.Is(err, storage.ErrProductNotFound),
+		errors.Is(err, storage.ErrCustomerNotFound),
+		errors.Is(err, storage.ErrOrderNotFound),
+		errors.Is(err, storage.ErrOrderItemNotFound):
+		respondError(w, r, http.StatusNotFound, err.Error())
+	case errors.Is(err, storage.ErrVersionConflict):
+		// запись изменили между чтением и записью
+		respondError(w, r, http.StatusPreconditionFailed, etag.ErrPreconditionFailed.Error())
+	case errors.Is(err, storage.ErrCategoryInUse):
+		respondError(w, r, http.StatusConflict, err.Error())
+	default:
+		respondError(w, r, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func respondOrderError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, storage.ErrInvalidOrder) {
+		respondError(w, r, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	respondStorageError(w, r, err)
+}
+
 // ====================================================================
 // CATEGORIES HANDLERS
 // ====================================================================
@@ -88,18 +329,19 @@ func CreateCategory(storage *postgresql.Storage) http.HandlerFunc {
 			return
 		}
 
-		id, err := storage.AddCategory(req.CategoryName, req.Description)
+		id, err := storage.AddCategory(r.Context(), req.CategoryName, req.Description)
 		if err != nil {
 			respondError(w, r, http.StatusInternalServerError, err.Error())
 			return
 		}
 
-		category, err := storage.GetCategory(id)
+		category, err := storage.GetCategory(r.Context(), id)
 		if err != nil {
 			respondError(w, r, http.StatusInternalServerError, err.Error())
 			return
 		}
 
+		etag.Set(w, category.Version)
 		render.Status(r, http.StatusCreated)
 		render.JSON(w, r, category)
 	}
@@ -114,9 +356,12 @@ func GetCategory(storage *postgresql.Storage) http.HandlerFunc {
 			return
 		}
 
-		category, err := storage.GetCategory(id)
+		category, err := storage.GetCategory(r.Context(), id)
 		if err != nil {
-			respondError(w, r, http.StatusNotFound, "category not found")
+			respondStorageError(w, r, err)
+			return
+		}
+		if etag.NotModified(w, r, category.Version) {
 			return
 		}
 
@@ -127,7 +372,7 @@ func GetCategory(storage *postgresql.Storage) http.HandlerFunc {
 // ListCategories - получить список всех категорий
 func ListCategories(storage *postgresql.Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		categories, err := storage.ListCategories()
+		categories, err := storage.ListCategories(r.Context())
 		if err != nil {
 			respondError(w, r, http.StatusInternalServerError, err.Error())
 			return
@@ -152,16 +397,73 @@ func UpdateCategory(storage *postgresql.Storage) http.HandlerFunc {
 			return
 		}
 
-		if err := storage.UpdateCategory(id, req.CategoryName, req.Description); err != nil {
+		current, err := storage.GetCategory(r.Context(), id)
+		if err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+		if !checkIfMatch(w, r, current.Version) {
+			return
+		}
+
+		if err := storage.UpdateCategory(r.Context(), id, current.Version, req.CategoryName, req.Description); err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+
+		category, err := storage.GetCategory(r.Context(), id)
+		if err != nil {
 			respondError(w, r, http.StatusInternalServerError, err.Error())
 			return
 		}
+		etag.Set(w, category.Version)
+
+		render.JSON(w, r, category)
+	}
+}
+
+// PatchCategory - частично обновить категорию
+// PATCH /api/v1/categories/{id} (application/merge-patch+json)
+func PatchCategory(storage *postgresql.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseURLParamID(r)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid category id")
+			return
+		}
+
+		current, err := storage.GetCategory(r.Context(), id)
+		if err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+		if !checkIfMatch(w, r, current.Version) {
+			return
+		}
+
+		req := CategoryRequest{CategoryName: current.CategoryName, Description: current.Description}
+		changed, ok := decodePatch(w, r, &req)
+		if !ok {
+			return
+		}
+		if err := req.validate(); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
 
-		category, err := storage.GetCategory(id)
+		if len(changed) > 0 {
+			if err := storage.UpdateCategory(r.Context(), id, current.Version, req.CategoryName, req.Description); err != nil {
+				respondStorageError(w, r, err)
+				return
+			}
+		}
+
+		category, err := storage.GetCategory(r.Context(), id)
 		if err != nil {
 			respondError(w, r, http.StatusInternalServerError, err.Error())
 			return
 		}
+		etag.Set(w, category.Version)
 
 		render.JSON(w, r, category)
 	}
@@ -176,8 +478,17 @@ func DeleteCategory(storage *postgresql.Storage) http.HandlerFunc {
 			return
 		}
 
-		if err := storage.DeleteCategory(id); err != nil {
-			respondError(w, r, http.StatusInternalServerError, err.Error())
+		current, err := storage.GetCategory(r.Context(), id)
+		if err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+		if !checkIfMatch(w, r, current.Version) {
+			return
+		}
+
+		if err := storage.DeleteCategory(r.Context(), id, current.Version); err != nil {
+			respondStorageError(w, r, err)
 			return
 		}
 
@@ -198,18 +509,25 @@ func CreateProduct(storage *postgresql.Storage) http.HandlerFunc {
 			return
 		}
 
-		id, err := storage.AddProduct(req.ProductName, req.CategoryID, req.Price, req.Cost, req.StockQuantity)
+		currency, err := normalizeCurrency(req.Currency)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		id, err := storage.AddProduct(r.Context(), req.ProductName, req.CategoryID, req.Price, req.Cost, req.StockQuantity, currency)
 		if err != nil {
 			respondError(w, r, http.StatusInternalServerError, err.Error())
 			return
 		}
 
-		product, err := storage.GetProduct(id)
+		product, err := storage.GetProduct(r.Context(), id)
 		if err != nil {
 			respondError(w, r, http.StatusInternalServerError, err.Error())
 			return
 		}
 
+		etag.Set(w, product.Version)
 		render.Status(r, http.StatusCreated)
 		render.JSON(w, r, product)
 	}
@@ -224,11 +542,18 @@ func GetProduct(storage *postgresql.Storage) http.HandlerFunc {
 			return
 		}
 
-		product, err := storage.GetProduct(id)
+		product, err := storage.GetProduct(r.Context(), id)
 		if err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+		if product.DeletedAt != nil && !includeDeleted(r) {
 			respondError(w, r, http.StatusNotFound, "product not found")
 			return
 		}
+		if etag.NotModified(w, r, product.Version) {
+			return
+		}
 
 		render.JSON(w, r, product)
 	}
@@ -237,7 +562,7 @@ func GetProduct(storage *postgresql.Storage) http.HandlerFunc {
 // ListProducts - получить список всех товаров
 func ListProducts(storage *postgresql.Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		products, err := storage.ListProducts()
+		products, err := storage.ListProducts(r.Context(), includeDeleted(r))
 		if err != nil {
 			respondError(w, r, http.StatusInternalServerError, err.Error())
 			return
@@ -256,7 +581,7 @@ func ListProductsByCategory(storage *postgresql.Storage) http.HandlerFunc {
 			return
 		}
 
-		products, err := storage.ListProductsByCategory(categoryID)
+		products, err := storage.ListProductsByCategory(r.Context(), categoryID, includeDeleted(r))
 		if err != nil {
 			respondError(w, r, http.StatusInternalServerError, err.Error())
 			return
@@ -267,7 +592,7 @@ func ListProductsByCategory(storage *postgresql.Storage) http.HandlerFunc {
 }
 
 // UpdateProduct - обновить товар
-func UpdateProduct(storage *postgresql.Storage) http.HandlerFunc {
+func UpdateProduct(storage *postgresql.Storage, analytics AnalyticsInvalidator) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id, err := parseURLParamID(r)
 		if err != nil {
@@ -281,22 +606,104 @@ func UpdateProduct(storage *postgresql.Storage) http.HandlerFunc {
 			return
 		}
 
-		if err := storage.UpdateProduct(id, req.ProductName, req.CategoryID, req.Price, req.Cost, req.StockQuantity); err != nil {
+		currency, err := normalizeCurrency(req.Currency)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		current, err := storage.GetProduct(r.Context(), id)
+		if err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+		if current.DeletedAt != nil {
+			respondError(w, r, http.StatusNotFound, "product not found")
+			return
+		}
+		if !checkIfMatch(w, r, current.Version) {
+			return
+		}
+
+		err = storage.UpdateProduct(r.Context(), id, current.Version, req.ProductName, req.CategoryID, req.Price, req.Cost, req.StockQuantity, currency)
+		if err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+		invalidateAll(analytics)
+
+		product, err := storage.GetProduct(r.Context(), id)
+		if err != nil {
 			respondError(w, r, http.StatusInternalServerError, err.Error())
 			return
 		}
+		etag.Set(w, product.Version)
 
-		product, err := storage.GetProduct(id)
+		render.JSON(w, r, product)
+	}
+}
+
+// PatchProduct - частично обновить товар
+// PATCH /api/v1/products/{id} (application/merge-patch+json) {"price": 59990}
+func PatchProduct(storage *postgresql.Storage, analytics AnalyticsInvalidator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseURLParamID(r)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid product id")
+			return
+		}
+
+		current, err := storage.GetProduct(r.Context(), id)
+		if err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+		if current.DeletedAt != nil {
+			respondError(w, r, http.StatusNotFound, "product not found")
+			return
+		}
+		if !checkIfMatch(w, r, current.Version) {
+			return
+		}
+
+		req := ProductRequest{
+			ProductName:   current.ProductName,
+			CategoryID:    current.CategoryID,
+			Price:         current.Price,
+			Cost:          current.Cost,
+			StockQuantity: current.StockQuantity,
+			Currency:      current.Currency,
+		}
+		changed, ok := decodePatch(w, r, &req)
+		if !ok {
+			return
+		}
+		if err := req.validate(); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+
+		if len(changed) > 0 {
+			err := storage.UpdateProduct(r.Context(), id, current.Version, req.ProductName, req.CategoryID, req.Price, req.Cost, req.StockQuantity, req.Currency)
+			if err != nil {
+				respondStorageError(w, r, err)
+				return
+			}
+			invalidateAll(analytics)
+		}
+
+		product, err := storage.GetProduct(r.Context(), id)
 		if err != nil {
 			respondError(w, r, http.StatusInternalServerError, err.Error())
 			return
 		}
+		etag.Set(w, product.Version)
 
 		render.JSON(w, r, product)
 	}
 }
 
-// DeleteProduct - удалить товар
+// DeleteProduct - удалить товар (мягко, см. RestoreProduct)
 func DeleteProduct(storage *postgresql.Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id, err := parseURLParamID(r)
@@ -305,8 +712,21 @@ func DeleteProduct(storage *postgresql.Storage) http.HandlerFunc {
 			return
 		}
 
-		if err := storage.DeleteProduct(id); err != nil {
-			respondError(w, r, http.StatusInternalServerError, err.Error())
+		current, err := storage.GetProduct(r.Context(), id)
+		if err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+		if current.DeletedAt != nil {
+			respondError(w, r, http.StatusNotFound, "product not found")
+			return
+		}
+		if !checkIfMatch(w, r, current.Version) {
+			return
+		}
+
+		if err := storage.DeleteProduct(r.Context(), id, current.Version); err != nil {
+			respondStorageError(w, r, err)
 			return
 		}
 
@@ -314,6 +734,32 @@ func DeleteProduct(storage *postgresql.Storage) http.HandlerFunc {
 	}
 }
 
+// RestoreProduct - восстановить удаленный товар
+// POST /api/v1/products/{id}/restore
+func RestoreProduct(storage *postgresql.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseURLParamID(r)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid product id")
+			return
+		}
+
+		if err := storage.RestoreProduct(r.Context(), id); err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+
+		product, err := storage.GetProduct(r.Context(), id)
+		if err != nil {
+			respondError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+		etag.Set(w, product.Version)
+
+		render.JSON(w, r, product)
+	}
+}
+
 // ====================================================================
 // CUSTOMERS HANDLERS
 // ====================================================================
@@ -327,18 +773,19 @@ func CreateCustomer(storage *postgresql.Storage) http.HandlerFunc {
 			return
 		}
 
-		id, err := storage.AddCustomer(req.FirstName, req.LastName, req.Email, req.Phone, req.City, time.Now())
+		id, err := storage.AddCustomer(r.Context(), req.FirstName, req.LastName, req.Email, req.Phone, req.City, time.Now())
 		if err != nil {
 			respondError(w, r, http.StatusInternalServerError, err.Error())
 			return
 		}
 
-		customer, err := storage.GetCustomer(id)
+		customer, err := storage.GetCustomer(r.Context(), id)
 		if err != nil {
 			respondError(w, r, http.StatusInternalServerError, err.Error())
 			return
 		}
 
+		etag.Set(w, customer.Version)
 		render.Status(r, http.StatusCreated)
 		render.JSON(w, r, customer)
 	}
@@ -353,11 +800,18 @@ func GetCustomer(storage *postgresql.Storage) http.HandlerFunc {
 			return
 		}
 
-		customer, err := storage.GetCustomer(id)
+		customer, err := storage.GetCustomer(r.Context(), id)
 		if err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+		if customer.DeletedAt != nil && !includeDeleted(r) {
 			respondError(w, r, http.StatusNotFound, "customer not found")
 			return
 		}
+		if etag.NotModified(w, r, customer.Version) {
+			return
+		}
 
 		render.JSON(w, r, customer)
 	}
@@ -366,7 +820,7 @@ func GetCustomer(storage *postgresql.Storage) http.HandlerFunc {
 // ListCustomers - получить список всех покупателей
 func ListCustomers(storage *postgresql.Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		customers, err := storage.ListCustomers()
+		customers, err := storage.ListCustomers(r.Context(), includeDeleted(r))
 		if err != nil {
 			respondError(w, r, http.StatusInternalServerError, err.Error())
 			return
@@ -377,7 +831,7 @@ func ListCustomers(storage *postgresql.Storage) http.HandlerFunc {
 }
 
 // UpdateCustomer - обновить покупателя
-func UpdateCustomer(storage *postgresql.Storage) http.HandlerFunc {
+func UpdateCustomer(storage *postgresql.Storage, analytics AnalyticsInvalidator) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id, err := parseURLParamID(r)
 		if err != nil {
@@ -391,22 +845,95 @@ func UpdateCustomer(storage *postgresql.Storage) http.HandlerFunc {
 			return
 		}
 
-		if err := storage.UpdateCustomer(id, req.FirstName, req.LastName, req.Email, req.Phone, req.City); err != nil {
+		current, err := storage.GetCustomer(r.Context(), id)
+		if err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+		if current.DeletedAt != nil {
+			respondError(w, r, http.StatusNotFound, "customer not found")
+			return
+		}
+		if !checkIfMatch(w, r, current.Version) {
+			return
+		}
+
+		if err := storage.UpdateCustomer(r.Context(), id, current.Version, req.FirstName, req.LastName, req.Email, req.Phone, req.City); err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+		invalidateAll(analytics)
+
+		customer, err := storage.GetCustomer(r.Context(), id)
+		if err != nil {
 			respondError(w, r, http.StatusInternalServerError, err.Error())
 			return
 		}
+		etag.Set(w, customer.Version)
+
+		render.JSON(w, r, customer)
+	}
+}
+
+// PatchCustomer - частично обновить покупателя
+// PATCH /api/v1/customers/{id} (application/merge-patch+json) {"phone": null, "city": "Казань"}
+func PatchCustomer(storage *postgresql.Storage, analytics AnalyticsInvalidator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseURLParamID(r)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid customer id")
+			return
+		}
 
-		customer, err := storage.GetCustomer(id)
+		current, err := storage.GetCustomer(r.Context(), id)
+		if err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+		if current.DeletedAt != nil {
+			respondError(w, r, http.StatusNotFound, "customer not found")
+			return
+		}
+		if !checkIfMatch(w, r, current.Version) {
+			return
+		}
+
+		req := CustomerRequest{
+			FirstName: current.FirstName,
+			LastName:  current.LastName,
+			Email:     current.Email,
+			Phone:     current.Phone,
+			City:      current.City,
+		}
+		changed, ok := decodePatch(w, r, &req)
+		if !ok {
+			return
+		}
+		if err := req.validate(); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+
+		if len(changed) > 0 {
+			if err := storage.UpdateCustomer(r.Context(), id, current.Version, req.FirstName, req.LastName, req.Email, req.Phone, req.City); err != nil {
+				respondStorageError(w, r, err)
+				return
+			}
+			invalidateAll(analytics)
+		}
+
+		customer, err := storage.GetCustomer(r.Context(), id)
 		if err != nil {
 			respondError(w, r, http.StatusInternalServerError, err.Error())
 			return
 		}
+		etag.Set(w, customer.Version)
 
 		render.JSON(w, r, customer)
 	}
 }
 
-// DeleteCustomer - удалить покупателя
+// DeleteCustomer - удалить покупателя (мягко, его заказы остаются в аналитике)
 func DeleteCustomer(storage *postgresql.Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id, err := parseURLParamID(r)
@@ -415,8 +942,21 @@ func DeleteCustomer(storage *postgresql.Storage) http.HandlerFunc {
 			return
 		}
 
-		if err := storage.DeleteCustomer(id); err != nil {
-			respondError(w, r, http.StatusInternalServerError, err.Error())
+		current, err := storage.GetCustomer(r.Context(), id)
+		if err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+		if current.DeletedAt != nil {
+			respondError(w, r, http.StatusNotFound, "customer not found")
+			return
+		}
+		if !checkIfMatch(w, r, current.Version) {
+			return
+		}
+
+		if err := storage.DeleteCustomer(r.Context(), id, current.Version); err != nil {
+			respondStorageError(w, r, err)
 			return
 		}
 
@@ -424,12 +964,38 @@ func DeleteCustomer(storage *postgresql.Storage) http.HandlerFunc {
 	}
 }
 
+// RestoreCustomer - восстановить удаленного покупателя
+// POST /api/v1/customers/{id}/restore
+func RestoreCustomer(storage *postgresql.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseURLParamID(r)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid customer id")
+			return
+		}
+
+		if err := storage.RestoreCustomer(r.Context(), id); err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+
+		customer, err := storage.GetCustomer(r.Context(), id)
+		if err != nil {
+			respondError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+		etag.Set(w, customer.Version)
+
+		render.JSON(w, r, customer)
+	}
+}
+
 // ====================================================================
 // ORDERS HANDLERS
 // ====================================================================
 
 // CreateOrder - создать заказ
-func CreateOrder(storage *postgresql.Storage) http.HandlerFunc {
+func CreateOrder(storage *postgresql.Storage, analytics AnalyticsInvalidator) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req OrderRequest
 		if err := render.DecodeJSON(r.Body, &req); err != nil {
@@ -437,24 +1003,61 @@ func CreateOrder(storage *postgresql.Storage) http.HandlerFunc {
 			return
 		}
 
-		orderDate, err := parseDate(req.OrderDate)
+		orderDate, err := parseTimestamp(req.OrderDate)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid order date format, use RFC 3339 or YYYY-MM-DD")
+			return
+		}
+
+		currency, err := normalizeCurrency(req.Currency)
 		if err != nil {
-			respondError(w, r, http.StatusBadRequest, "invalid order date format, use YYYY-MM-DD")
+			respondError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		if req.TotalAmount != 0 {
+			respondError(w, r, http.StatusUnprocessableEntity, errTotalAmount.Error())
+			return
+		}
+
+		if len(req.Items) > 0 {
+			lines := make([]postgresql.OrderLineInput, 0, len(req.Items))
+			for _, item := range req.Items {
+				lines = append(lines, postgresql.OrderLineInput{ProductID: item.ProductID, Quantity: item.Quantity})
+			}
+
+			placed, err := storage.PlaceOrder(r.Context(), postgresql.Order{
+				CustomerID:    req.CustomerID,
+				OrderDate:     orderDate,
+				Status:        req.Status,
+				PaymentMethod: req.PaymentMethod,
+				Currency:      currency,
+			}, lines, strings.TrimSpace(req.CouponCode))
+			if err != nil {
+				respondOrderError(w, r, err)
+				return
+			}
+			analytics.InvalidateRange(orderDate, orderDate)
+			etag.Set(w, placed.Version)
+
+			render.Status(r, http.StatusCreated)
+			render.JSON(w, r, placed)
 			return
 		}
 
-		id, err := storage.AddOrder(req.CustomerID, orderDate, req.Status, req.PaymentMethod, req.TotalAmount)
+		id, err := storage.AddOrder(r.Context(), req.CustomerID, orderDate, req.Status, req.PaymentMethod, currency)
 		if err != nil {
 			respondError(w, r, http.StatusInternalServerError, err.Error())
 			return
 		}
+		analytics.InvalidateRange(orderDate, orderDate)
 
-		order, err := storage.GetOrder(id)
+		order, err := storage.GetOrder(r.Context(), id)
 		if err != nil {
 			respondError(w, r, http.StatusInternalServerError, err.Error())
 			return
 		}
 
+		etag.Set(w, order.Version)
 		render.Status(r, http.StatusCreated)
 		render.JSON(w, r, order)
 	}
@@ -469,11 +1072,18 @@ func GetOrder(storage *postgresql.Storage) http.HandlerFunc {
 			return
 		}
 
-		order, err := storage.GetOrder(id)
+		order, err := storage.GetOrder(r.Context(), id)
 		if err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+		if order.DeletedAt != nil && !includeDeleted(r) {
 			respondError(w, r, http.StatusNotFound, "order not found")
 			return
 		}
+		if etag.NotModified(w, r, order.Version) {
+			return
+		}
 
 		render.JSON(w, r, order)
 	}
@@ -482,7 +1092,7 @@ func GetOrder(storage *postgresql.Storage) http.HandlerFunc {
 // ListOrders - получить список всех заказов
 func ListOrders(storage *postgresql.Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		orders, err := storage.ListOrders()
+		orders, err := storage.ListOrders(r.Context(), includeDeleted(r))
 		if err != nil {
 			respondError(w, r, http.StatusInternalServerError, err.Error())
 			return
@@ -501,7 +1111,7 @@ func ListOrdersByCustomer(storage *postgresql.Storage) http.HandlerFunc {
 			return
 		}
 
-		orders, err := storage.ListOrdersByCustomer(customerID)
+		orders, err := storage.ListOrdersByCustomer(r.Context(), customerID, includeDeleted(r))
 		if err != nil {
 			respondError(w, r, http.StatusInternalServerError, err.Error())
 			return
@@ -512,7 +1122,7 @@ func ListOrdersByCustomer(storage *postgresql.Storage) http.HandlerFunc {
 }
 
 // UpdateOrder - обновить заказ
-func UpdateOrder(storage *postgresql.Storage) http.HandlerFunc {
+func UpdateOrder(storage *postgresql.Storage, analytics AnalyticsInvalidator) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id, err := parseURLParamID(r)
 		if err != nil {
@@ -520,32 +1130,71 @@ func UpdateOrder(storage *postgresql.Storage) http.HandlerFunc {
 			return
 		}
 
+		// total_amount принимается только текущим: сумму считает сервер
 		var req struct {
-			Status      string  `json:"status"`
-			TotalAmount float64 `json:"total_amount"`
+			OrderDate   string   `json:"order_date"`
+			Status      string   `json:"status"`
+			TotalAmount *float64 `json:"total_amount"`
 		}
 		if err := render.DecodeJSON(r.Body, &req); err != nil {
 			respondError(w, r, http.StatusBadRequest, "invalid request body")
 			return
 		}
 
-		if err := storage.UpdateOrder(id, req.Status, req.TotalAmount); err != nil {
-			respondError(w, r, http.StatusInternalServerError, err.Error())
+		current, err := storage.GetOrder(r.Context(), id)
+		if err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+		if current.DeletedAt != nil {
+			respondError(w, r, http.StatusNotFound, "order not found")
+			return
+		}
+		if !checkIfMatch(w, r, current.Version) {
+			return
+		}
+		if req.TotalAmount != nil && *req.TotalAmount != current.TotalAmount {
+			respondError(w, r, http.StatusUnprocessableEntity, errTotalAmount.Error())
+			return
+		}
+
+		// дата заказа необязательна: без нее сохраняется прежняя
+		orderDate := current.OrderDate
+		if req.OrderDate != "" {
+			orderDate, err = parseTimestamp(req.OrderDate)
+			if err != nil {
+				respondError(w, r, http.StatusBadRequest, "invalid order date format, use RFC 3339 or YYYY-MM-DD")
+				return
+			}
+		}
+
+		update := *current
+		update.OrderDate = orderDate
+		if req.Status != "" {
+			update.Status = req.Status
+		}
+
+		if err := storage.UpdateOrder(r.Context(), id, current.Version, update); err != nil {
+			respondStorageError(w, r, err)
 			return
 		}
 
-		order, err := storage.GetOrder(id)
+		order, err := storage.GetOrder(r.Context(), id)
 		if err != nil {
 			respondError(w, r, http.StatusInternalServerError, err.Error())
 			return
 		}
+		etag.Set(w, order.Version)
+		analytics.InvalidateRange(current.OrderDate, current.OrderDate)
+		analytics.InvalidateRange(order.OrderDate, order.OrderDate)
 
 		render.JSON(w, r, order)
 	}
 }
 
-// DeleteOrder - удалить заказ
-func DeleteOrder(storage *postgresql.Storage) http.HandlerFunc {
+// PatchOrder - частично обновить заказ; в отличие от PUT меняет любые поля заказа
+// PATCH /api/v1/orders/{id} (application/merge-patch+json) {"status": "cancelled"}
+func PatchOrder(storage *postgresql.Storage, analytics AnalyticsInvalidator) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id, err := parseURLParamID(r)
 		if err != nil {
@@ -553,35 +1202,145 @@ func DeleteOrder(storage *postgresql.Storage) http.HandlerFunc {
 			return
 		}
 
-		if err := storage.DeleteOrder(id); err != nil {
+		current, err := storage.GetOrder(r.Context(), id)
+		if err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+		if current.DeletedAt != nil {
+			respondError(w, r, http.StatusNotFound, "order not found")
+			return
+		}
+		if !checkIfMatch(w, r, current.Version) {
+			return
+		}
+
+		req := OrderPatch{
+			CustomerID:    current.CustomerID,
+			OrderDate:     current.OrderDate.Format(time.RFC3339Nano),
+			Status:        current.Status,
+			PaymentMethod: current.PaymentMethod,
+			Currency:      current.Currency,
+			TotalAmount:   current.TotalAmount,
+		}
+		changed, ok := decodePatch(w, r, &req)
+		if !ok {
+			return
+		}
+		update, err := req.toOrder(*current)
+		if err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+
+		if len(changed) > 0 {
+			if err := storage.UpdateOrder(r.Context(), id, current.Version, update); err != nil {
+				respondStorageError(w, r, err)
+				return
+			}
+		}
+
+		order, err := storage.GetOrder(r.Context(), id)
+		if err != nil {
 			respondError(w, r, http.StatusInternalServerError, err.Error())
 			return
 		}
+		if len(changed) > 0 {
+			analytics.InvalidateRange(current.OrderDate, current.OrderDate)
+			analytics.InvalidateRange(order.OrderDate, order.OrderDate)
+		}
+		etag.Set(w, order.Version)
+
+		render.JSON(w, r, order)
+	}
+}
+
+// DeleteOrder - удалить заказ (мягко, заказ исключается из аналитики)
+func DeleteOrder(storage *postgresql.Storage, analytics AnalyticsInvalidator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseURLParamID(r)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid order id")
+			return
+		}
+
+		// версия для If-Match и дата заказа для сброса аналитики
+		order, err := storage.GetOrder(r.Context(), id)
+		if err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+		if order.DeletedAt != nil {
+			respondError(w, r, http.StatusNotFound, "order not found")
+			return
+		}
+		if !checkIfMatch(w, r, order.Version) {
+			return
+		}
+
+		if err := storage.DeleteOrder(r.Context(), id, order.Version); err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+		analytics.InvalidateRange(order.OrderDate, order.OrderDate)
 
 		render.Status(r, http.StatusNoContent)
 	}
 }
 
+// RestoreOrder - восстановить удаленный заказ
+// POST /api/v1/orders/{id}/restore
+func RestoreOrder(storage *postgresql.Storage, analytics AnalyticsInvalidator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseURLParamID(r)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid order id")
+			return
+		}
+
+		if err := storage.RestoreOrder(r.Context(), id); err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+
+		order, err := storage.GetOrder(r.Context(), id)
+		if err != nil {
+			respondError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+		analytics.InvalidateRange(order.OrderDate, order.OrderDate)
+		etag.Set(w, order.Version)
+
+		render.JSON(w, r, order)
+	}
+}
+
 // ====================================================================
 // ORDER ITEMS HANDLERS
 // ====================================================================
 
-// CreateOrderItem - создать позицию заказа
-func CreateOrderItem(storage *postgresql.Storage) http.HandlerFunc {
+// CreateOrderItem - добавить позицию в заказ; цену и скидку считает сервер,
+// сумма заказа пересчитывается
+func CreateOrderItem(storage *postgresql.Storage, analytics AnalyticsInvalidator) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req OrderItemRequest
 		if err := render.DecodeJSON(r.Body, &req); err != nil {
 			respondError(w, r, http.StatusBadRequest, "invalid request body")
 			return
 		}
+		if err := req.validate(); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
 
-		id, err := storage.AddOrderItem(req.OrderID, req.ProductID, req.Quantity, req.Price, req.Discount)
+		id, err := storage.AddOrderItem(r.Context(), req.OrderID, req.ProductID, req.Quantity)
 		if err != nil {
-			respondError(w, r, http.StatusInternalServerError, err.Error())
+			respondOrderError(w, r, err)
 			return
 		}
+		invalidateOrder(r.Context(), storage, analytics, req.OrderID)
 
-		item, err := storage.GetOrderItem(id)
+		item, err := storage.GetOrderItem(r.Context(), id)
 		if err != nil {
 			respondError(w, r, http.StatusInternalServerError, err.Error())
 			return
@@ -601,9 +1360,9 @@ func GetOrderItem(storage *postgresql.Storage) http.HandlerFunc {
 			return
 		}
 
-		item, err := storage.GetOrderItem(id)
+		item, err := storage.GetOrderItem(r.Context(), id)
 		if err != nil {
-			respondError(w, r, http.StatusNotFound, "order item not found")
+			respondStorageError(w, r, err)
 			return
 		}
 
@@ -620,7 +1379,7 @@ func ListOrderItems(storage *postgresql.Storage) http.HandlerFunc {
 			return
 		}
 
-		items, err := storage.ListOrderItems(orderID)
+		items, err := storage.ListOrderItems(r.Context(), orderID)
 		if err != nil {
 			respondError(w, r, http.StatusInternalServerError, err.Error())
 			return
@@ -630,8 +1389,8 @@ func ListOrderItems(storage *postgresql.Storage) http.HandlerFunc {
 	}
 }
 
-// UpdateOrderItem - обновить позицию заказа
-func UpdateOrderItem(storage *postgresql.Storage) http.HandlerFunc {
+// UpdateOrderItem - изменить количество в позиции заказа
+func UpdateOrderItem(storage *postgresql.Storage, analytics AnalyticsInvalidator) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id, err := parseURLParamID(r)
 		if err != nil {
@@ -639,33 +1398,42 @@ func UpdateOrderItem(storage *postgresql.Storage) http.HandlerFunc {
 			return
 		}
 
-		var req struct {
-			Quantity int     `json:"quantity"`
-			Price    float64 `json:"price"`
-			Discount float64 `json:"discount"`
+		current, err := storage.GetOrderItem(r.Context(), id)
+		if err != nil {
+			respondStorageError(w, r, err)
+			return
 		}
+
+		// поля, которых нет в теле, остаются текущими
+		req := OrderItemPatch{Quantity: current.Quantity, Price: current.Price, Discount: current.Discount}
 		if err := render.DecodeJSON(r.Body, &req); err != nil {
 			respondError(w, r, http.StatusBadRequest, "invalid request body")
 			return
 		}
+		if err := req.validate(*current); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
 
-		if err := storage.UpdateOrderItem(id, req.Quantity, req.Price, req.Discount); err != nil {
-			respondError(w, r, http.StatusInternalServerError, err.Error())
+		if err := storage.UpdateOrderItem(r.Context(), id, req.Quantity); err != nil {
+			respondOrderError(w, r, err)
 			return
 		}
 
-		item, err := storage.GetOrderItem(id)
+		item, err := storage.GetOrderItem(r.Context(), id)
 		if err != nil {
 			respondError(w, r, http.StatusInternalServerError, err.Error())
 			return
 		}
+		invalidateOrder(r.Context(), storage, analytics, item.OrderID)
 
 		render.JSON(w, r, item)
 	}
 }
 
-// DeleteOrderItem - удалить позицию заказа
-func DeleteOrderItem(storage *postgresql.Storage) http.HandlerFunc {
+// PatchOrderItem - частично обновить позицию заказа
+// PATCH /api/v1/order-items/{id} (application/merge-patch+json) {"quantity": 3}
+func PatchOrderItem(storage *postgresql.Storage, analytics AnalyticsInvalidator) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id, err := parseURLParamID(r)
 		if err != nil {
@@ -673,10 +1441,63 @@ func DeleteOrderItem(storage *postgresql.Storage) http.HandlerFunc {
 			return
 		}
 
-		if err := storage.DeleteOrderItem(id); err != nil {
+		current, err := storage.GetOrderItem(r.Context(), id)
+		if err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+
+		req := OrderItemPatch{Quantity: current.Quantity, Price: current.Price, Discount: current.Discount}
+		changed, ok := decodePatch(w, r, &req)
+		if !ok {
+			return
+		}
+		if err := req.validate(*current); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+
+		if len(changed) > 0 {
+			if err := storage.UpdateOrderItem(r.Context(), id, req.Quantity); err != nil {
+				respondOrderError(w, r, err)
+				return
+			}
+		}
+
+		item, err := storage.GetOrderItem(r.Context(), id)
+		if err != nil {
 			respondError(w, r, http.StatusInternalServerError, err.Error())
 			return
 		}
+		if len(changed) > 0 {
+			invalidateOrder(r.Context(), storage, analytics, item.OrderID)
+		}
+
+		render.JSON(w, r, item)
+	}
+}
+
+// DeleteOrderItem - удалить позицию заказа; сумма заказа пересчитывается
+func DeleteOrderItem(storage *postgresql.Storage, analytics AnalyticsInvalidator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseURLParamID(r)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid order item id")
+			return
+		}
+
+		// заказ позиции нужно узнать до удаления
+		item, err := storage.GetOrderItem(r.Context(), id)
+		if err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+
+		if err := storage.DeleteOrderItem(r.Context(), id); err != nil {
+			respondOrderError(w, r, err)
+			return
+		}
+		invalidateOrder(r.Context(), storage, analytics, item.OrderID)
 
 		render.Status(r, http.StatusNoContent)
 	}
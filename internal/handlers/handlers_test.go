@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"salesTracker/internal/storage"
+	"salesTracker/internal/storage/postgresql"
+)
+
+func TestNormalizeCurrency(t *testing.T) {
+	tests := []struct {
+		code    string
+		want    string
+		wantErr bool
+	}{
+		{code: "", want: postgresql.BaseCurrency},
+		{code: "USD", want: "USD"},
+		{code: "eur", want: "EUR"},
+		{code: "Kzt", want: "KZT"},
+
+		{code: "US", wantErr: true},
+		{code: "USDT", wantErr: true},
+		{code: "U$D", wantErr: true},
+		{code: "12A", wantErr: true},
+		{code: " USD", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			got, err := normalizeCurrency(tt.code)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalizeCurrency(%q) error = %v, wantErr %v", tt.code, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("normalizeCurrency(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrderItemPatchValidate(t *testing.T) {
+	current := postgresql.OrderItem{OrderItemID: 1, Quantity: 2, Price: 100, Discount: 10, DiscountAmount: 20}
+
+	tests := []struct {
+		name    string
+		patch   OrderItemPatch
+		wantErr bool
+	}{
+		{name: "quantity changed", patch: OrderItemPatch{Quantity: 5, Price: 100, Discount: 10}},
+		{name: "nothing changed", patch: OrderItemPatch{Quantity: 2, Price: 100, Discount: 10}},
+
+		{name: "zero quantity", patch: OrderItemPatch{Quantity: 0, Price: 100, Discount: 10}, wantErr: true},
+		{name: "client price", patch: OrderItemPatch{Quantity: 2, Price: 1, Discount: 10}, wantErr: true},
+		{name: "client discount", patch: OrderItemPatch{Quantity: 2, Price: 100, Discount: 99}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.patch.validate(current); (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOrderItemRequestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     OrderItemRequest
+		wantErr bool
+	}{
+		{name: "product and quantity", req: OrderItemRequest{OrderID: 1, ProductID: 2, Quantity: 3}},
+
+		{name: "no quantity", req: OrderItemRequest{OrderID: 1, ProductID: 2}, wantErr: true},
+		{name: "client price", req: OrderItemRequest{OrderID: 1, ProductID: 2, Quantity: 1, Price: 10}, wantErr: true},
+		{name: "client discount", req: OrderItemRequest{OrderID: 1, ProductID: 2, Quantity: 1, Discount: 50}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.req.validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOrderPatchKeepsTotal(t *testing.T) {
+	current := postgresql.Order{OrderID: 1, OrderDate: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), Status: "completed",
+		TotalAmount: 150, Currency: "RUB"}
+	patch := OrderPatch{OrderDate: "2024-01-15", Status: "cancelled", Currency: "RUB", TotalAmount: 150}
+
+	if _, err := patch.toOrder(current); err != nil {
+		t.Fatalf("toOrder() with the current total error = %v", err)
+	}
+
+	patch.TotalAmount = 1
+	if _, err := patch.toOrder(current); !errors.Is(err, errTotalAmount) {
+		t.Fatalf("toOrder() with a client total error = %v, want %v", err, errTotalAmount)
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		// смещение клиента сохраняется: момент заказа не сдвигается
+		{value: "2024-03-01T10:15:00+03:00", want: "2024-03-01T10:15:00+03:00"},
+		{value: "2024-03-01T07:15:00Z", want: "2024-03-01T07:15:00Z"},
+		{value: "2024-03-01T07:15:00.250Z", want: "2024-03-01T07:15:00.25Z"},
+		// дата без времени — полночь UTC, как раньше
+		{value: "2024-03-01", want: "2024-03-01T00:00:00Z"},
+
+		{value: "2024-03-01 10:15:00", wantErr: true},
+		{value: "01.03.2024", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseTimestamp(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTimestamp(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !tt.wantErr && got.Format(time.RFC3339Nano) != tt.want {
+				t.Errorf("parseTimestamp(%q) = %s, want %s", tt.value, got.Format(time.RFC3339Nano), tt.want)
+			}
+		})
+	}
+}
+
+func TestIncludeDeleted(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{query: "", want: false},
+		{query: "?include_deleted=true", want: true},
+		{query: "?include_deleted=1", want: true},
+		{query: "?include_deleted=false", want: false},
+		// непонятное значение не открывает удаленные записи
+		{query: "?include_deleted=yes", want: false},
+		{query: "?include_deleted", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/customers"+tt.query, nil)
+			if got := includeDeleted(r); got != tt.want {
+				t.Errorf("includeDeleted(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRespondStorageError(t *testing.T) {
+	tests := []struct {
+		err        error
+		wantStatus int
+	}{
+		// восстановление или удаление несуществующей записи
+		{err: fmt.Errorf("storage.postgresql.RestoreCustomer: %w", storage.ErrCustomerNotFound), wantStatus: http.StatusNotFound},
+		{err: fmt.Errorf("storage.postgresql.RestoreProduct: %w", storage.ErrProductNotFound), wantStatus: http.StatusNotFound},
+		{err: fmt.Errorf("storage.postgresql.DeleteOrder: %w", storage.ErrOrderNotFound), wantStatus: http.StatusNotFound},
+		// удаление по устаревшей версии
+		{err: fmt.Errorf("storage.postgresql.DeleteCustomer: %w", storage.ErrVersionConflict), wantStatus: http.StatusPreconditionFailed},
+		{err: fmt.Errorf("storage.postgresql.DeleteCategory: %w", storage.ErrCategoryInUse), wantStatus: http.StatusConflict},
+		{err: errors.New("storage.postgresql.RestoreOrder: connection refused"), wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/customers/1/restore", nil)
+			w := httptest.NewRecorder()
+			respondStorageError(w, r, tt.err)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
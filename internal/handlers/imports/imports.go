@@ -0,0 +1,170 @@
+package imports
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"salesTracker/internal/handlers"
+	"salesTracker/internal/importer"
+	"salesTracker/internal/storage"
+	"salesTracker/internal/storage/postgresql"
+)
+
+// ContentTypeJSONL — тип тела для загрузки JSONL
+const ContentTypeJSONL = "application/x-ndjson"
+
+// maxReportErrors — предел ошибок заказов в ответе; счетчики считают все
+const maxReportErrors = 1000
+
+// ====================================================================
+// REQUEST/RESPONSE DTOs
+// ====================================================================
+
+// ImportFailure - загрузка прервана; report содержит прогресс до сбоя,
+// загрузку можно продолжить с ?resume=<job_id>
+type ImportFailure struct {
+	Error  string           `json:"error"`
+	Report *importer.Report `json:"report,omitempty"`
+}
+
+// ====================================================================
+// HELPERS
+// ====================================================================
+
+func respondError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	render.Status(r, status)
+	render.JSON(w, r, map[string]string{"error": message})
+}
+
+// formatOf - формат из параметра format, а без него - из Content-Type
+func formatOf(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return importer.FormatCSV
+	case ContentTypeJSONL:
+		return importer.FormatJSONL
+	default:
+		return ""
+	}
+}
+
+func parseOptions(r *http.Request) (importer.Options, string) {
+	query := r.URL.Query()
+	opts := importer.Options{
+		Source:    query.Get("source"),
+		Format:    formatOf(r),
+		MaxErrors: maxReportErrors,
+	}
+	if opts.Source == "" {
+		opts.Source = "upload"
+	}
+
+	if value := query.Get("dry_run"); value != "" {
+		dryRun, err := strconv.ParseBool(value)
+		if err != nil {
+			return opts, "invalid dry_run, use true or false"
+		}
+		opts.DryRun = dryRun
+	}
+
+	if value := query.Get("resume"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			return opts, "invalid resume, use the job_id of an interrupted import"
+		}
+		opts.ResumeJobID = id
+	}
+
+	if value := query.Get("batch_size"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size <= 0 || size > 10000 {
+			return opts, "invalid batch_size, must be between 1 and 10000"
+		}
+		opts.BatchSize = size
+	}
+
+	return opts, ""
+}
+
+// ====================================================================
+// IMPORT HANDLERS
+// ====================================================================
+
+// ImportOrders - загрузить исторические заказы из CSV или JSONL
+// POST /api/v1/imports/orders?dry_run=true&resume=12&source=legacy-2019.csv
+// Content-Type: text/csv (строка на позицию) или application/x-ndjson (строка на заказ)
+//
+//	order_ref,order_date,customer_email,product_name,quantity,price,discount
+//	A-1001,2019-03-14,ivanov@example.com,Ноутбук ASUS,1,65000,5
+func ImportOrders(storage *postgresql.Storage, analytics handlers.AnalyticsInvalidator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		opts, problem := parseOptions(r)
+		if problem != "" {
+			respondError(w, r, http.StatusBadRequest, problem)
+			return
+		}
+		if opts.Format == "" {
+			respondError(w, r, http.StatusUnsupportedMediaType,
+				"unknown file format, use Content-Type text/csv or "+ContentTypeJSONL+", or ?format=csv|jsonl")
+			return
+		}
+
+		// файл читается по мере загрузки и может идти дольше общего таймаута чтения сервера
+		rc := http.NewResponseController(w)
+		rc.SetReadDeadline(time.Time{})
+		rc.SetWriteDeadline(time.Time{})
+
+		report, err := importer.New(storage, analytics).Run(r.Context(), r.Body, opts)
+		if err != nil {
+			respondImportError(w, r, report, err)
+			return
+		}
+
+		render.JSON(w, r, report)
+	}
+}
+
+// GetImportJob - прогресс загрузки
+// GET /api/v1/imports/{id}
+func GetImportJob(storage *postgresql.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid import job id")
+			return
+		}
+
+		job, err := storage.GetImportJob(r.Context(), id)
+		if err != nil {
+			respondImportError(w, r, nil, err)
+			return
+		}
+
+		render.JSON(w, r, job)
+	}
+}
+
+func respondImportError(w http.ResponseWriter, r *http.Request, report *importer.Report, err error) {
+	switch {
+	case errors.Is(err, importer.ErrInvalidInput):
+		respondError(w, r, http.StatusBadRequest, err.Error())
+	case errors.Is(err, storage.ErrImportJobNotFound):
+		respondError(w, r, http.StatusNotFound, err.Error())
+	case errors.Is(err, storage.ErrImportJobFinished):
+		respondError(w, r, http.StatusConflict, err.Error())
+	default:
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, ImportFailure{Error: err.Error(), Report: report})
+	}
+}
@@ -0,0 +1,317 @@
+package promotions
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"salesTracker/internal/mergepatch"
+	"salesTracker/internal/storage"
+	"salesTracker/internal/storage/postgresql"
+)
+
+// ====================================================================
+// REQUEST/RESPONSE DTOs
+// ====================================================================
+
+// PromotionRequest - DTO для создания/обновления промоакции
+type PromotionRequest struct {
+	Name        string  `json:"name"`
+	Kind        string  `json:"kind"`
+	Value       float64 `json:"value"`
+	Currency    string  `json:"currency"`
+	BuyQuantity int     `json:"buy_quantity"`
+	GetQuantity int     `json:"get_quantity"`
+	CategoryID  *int    `json:"category_id"`
+	ProductID   *int    `json:"product_id"`
+	CouponCode  string  `json:"coupon_code"`
+	StartsAt    string  `json:"starts_at"`
+	EndsAt      string  `json:"ends_at"`
+	UsageLimit  *int    `json:"usage_limit"`
+	Active      *bool   `json:"active"`
+}
+
+func (req PromotionRequest) toPromotion() (postgresql.Promotion, error) {
+	p := postgresql.Promotion{
+		Name:        strings.TrimSpace(req.Name),
+		Kind:        req.Kind,
+		Value:       req.Value,
+		Currency:    strings.ToUpper(req.Currency),
+		BuyQuantity: req.BuyQuantity,
+		GetQuantity: req.GetQuantity,
+		CategoryID:  req.CategoryID,
+		ProductID:   req.ProductID,
+		CouponCode:  strings.TrimSpace(req.CouponCode),
+		UsageLimit:  req.UsageLimit,
+		Active:      req.Active == nil || *req.Active,
+	}
+	if p.Currency == "" {
+		p.Currency = postgresql.BaseCurrency
+	}
+
+	if p.Name == "" {
+		return p, fmt.Errorf("name is required")
+	}
+	if err := p.Rule().Validate(); err != nil {
+		return p, err
+	}
+	if len(p.Currency) != 3 || strings.Trim(p.Currency, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return p, fmt.Errorf("invalid currency %q, use a 3-letter ISO 4217 code", req.Currency)
+	}
+	if p.UsageLimit != nil && *p.UsageLimit <= 0 {
+		return p, fmt.Errorf("usage_limit must be positive")
+	}
+
+	var err error
+	if p.StartsAt, err = parseOptionalTime(req.StartsAt); err != nil {
+		return p, fmt.Errorf("invalid starts_at, use RFC 3339")
+	}
+	if p.EndsAt, err = parseOptionalTime(req.EndsAt); err != nil {
+		return p, fmt.Errorf("invalid ends_at, use RFC 3339")
+	}
+	if p.StartsAt != nil && p.EndsAt != nil && !p.EndsAt.After(*p.StartsAt) {
+		return p, fmt.Errorf("ends_at must be after starts_at")
+	}
+
+	return p, nil
+}
+
+// newPromotionRequest - DTO с текущими условиями акции, основа для PATCH
+func newPromotionRequest(p *postgresql.Promotion) PromotionRequest {
+	return PromotionRequest{
+		Name:        p.Name,
+		Kind:        p.Kind,
+		Value:       p.Value,
+		Currency:    p.Currency,
+		BuyQuantity: p.BuyQuantity,
+		GetQuantity: p.GetQuantity,
+		CategoryID:  p.CategoryID,
+		ProductID:   p.ProductID,
+		CouponCode:  p.CouponCode,
+		StartsAt:    formatOptionalTime(p.StartsAt),
+		EndsAt:      formatOptionalTime(p.EndsAt),
+		UsageLimit:  p.UsageLimit,
+		Active:      &p.Active,
+	}
+}
+
+// ====================================================================
+// HELPERS
+// ====================================================================
+
+func parseURLParamID(r *http.Request) (int, error) {
+	idStr := chi.URLParam(r, "id")
+	return strconv.Atoi(idStr)
+}
+
+func parseOptionalTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+func respondError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	render.Status(r, status)
+	render.JSON(w, r, map[string]string{"error": message})
+}
+
+func respondStorageError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, storage.ErrPromotionNotFound) {
+		respondError(w, r, http.StatusNotFound, "promotion not found")
+		return
+	}
+	respondError(w, r, http.StatusInternalServerError, err.Error())
+}
+
+// ====================================================================
+// PROMOTIONS HANDLERS
+// ====================================================================
+
+// CreatePromotion - создать промоакцию
+// POST /api/v1/promotions
+// {"name": "Зимняя распродажа", "kind": "percent_off", "value": 15, "category_id": 1,
+// "starts_at": "2024-12-01T00:00:00+03:00", "ends_at": "2025-01-01T00:00:00+03:00"}
+func CreatePromotion(storage *postgresql.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req PromotionRequest
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid request body")
+			return
+		}
+
+		p, err := req.toPromotion()
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		id, err := storage.AddPromotion(r.Context(), p)
+		if err != nil {
+			respondError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		created, err := storage.GetPromotion(r.Context(), id)
+		if err != nil {
+			respondError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, created)
+	}
+}
+
+// GetPromotion - получить промоакцию по ID
+func GetPromotion(storage *postgresql.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseURLParamID(r)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid promotion id")
+			return
+		}
+
+		p, err := storage.GetPromotion(r.Context(), id)
+		if err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+
+		render.JSON(w, r, p)
+	}
+}
+
+// ListPromotions - получить список промоакций
+func ListPromotions(storage *postgresql.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, err := storage.ListPromotions(r.Context())
+		if err != nil {
+			respondError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		render.JSON(w, r, list)
+	}
+}
+
+// UpdatePromotion - обновить условия промоакции
+func UpdatePromotion(storage *postgresql.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseURLParamID(r)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid promotion id")
+			return
+		}
+
+		var req PromotionRequest
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid request body")
+			return
+		}
+
+		p, err := req.toPromotion()
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if err := storage.UpdatePromotion(r.Context(), id, p); err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+
+		updated, err := storage.GetPromotion(r.Context(), id)
+		if err != nil {
+			respondError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		render.JSON(w, r, updated)
+	}
+}
+
+// PatchPromotion - частично обновить условия промоакции
+// PATCH /api/v1/promotions/{id} (application/merge-patch+json) {"ends_at": "2025-02-01T00:00:00+03:00"}
+func PatchPromotion(storage *postgresql.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseURLParamID(r)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid promotion id")
+			return
+		}
+
+		current, err := storage.GetPromotion(r.Context(), id)
+		if err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+
+		req := newPromotionRequest(current)
+		changed, err := mergepatch.Decode(r, &req)
+		if errors.Is(err, mergepatch.ErrUnsupportedMediaType) {
+			respondError(w, r, http.StatusUnsupportedMediaType, err.Error())
+			return
+		}
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		p, err := req.toPromotion()
+		if err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+
+		if len(changed) > 0 {
+			if err := storage.UpdatePromotion(r.Context(), id, p); err != nil {
+				respondStorageError(w, r, err)
+				return
+			}
+		}
+
+		updated, err := storage.GetPromotion(r.Context(), id)
+		if err != nil {
+			respondError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		render.JSON(w, r, updated)
+	}
+}
+
+// DeletePromotion - отключить промоакцию; история применения в заказах сохраняется
+func DeletePromotion(storage *postgresql.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseURLParamID(r)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid promotion id")
+			return
+		}
+
+		if err := storage.DeactivatePromotion(r.Context(), id); err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+
+		render.Status(r, http.StatusNoContent)
+	}
+}
@@ -0,0 +1,216 @@
+package rates
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/render"
+
+	"salesTracker/internal/handlers"
+	"salesTracker/internal/storage/postgresql"
+)
+
+// ====================================================================
+// REQUEST/RESPONSE DTOs
+// ====================================================================
+
+// RateRequest - DTO курса валюты: 1 единица currency стоит rate рублей
+type RateRequest struct {
+	RateDate string  `json:"rate_date"`
+	Currency string  `json:"currency"`
+	Rate     float64 `json:"rate"`
+}
+
+// ImportResult - итог загрузки курсов
+type ImportResult struct {
+	Loaded int `json:"loaded"`
+}
+
+// ====================================================================
+// HELPERS
+// ====================================================================
+
+func respondError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	render.Status(r, status)
+	render.JSON(w, r, map[string]string{"error": message})
+}
+
+func parseDate(dateStr string) (time.Time, error) {
+	return time.Parse("2006-01-02", dateStr)
+}
+
+func toExchangeRate(req RateRequest) (postgresql.ExchangeRate, error) {
+	rateDate, err := parseDate(strings.TrimSpace(req.RateDate))
+	if err != nil {
+		return postgresql.ExchangeRate{}, fmt.Errorf("invalid rate date %q, use YYYY-MM-DD", req.RateDate)
+	}
+
+	currency := strings.ToUpper(strings.TrimSpace(req.Currency))
+	if len(currency) != 3 || strings.Trim(currency, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return postgresql.ExchangeRate{}, fmt.Errorf("invalid currency %q, use a 3-letter ISO 4217 code", req.Currency)
+	}
+	if currency == postgresql.BaseCurrency {
+		return postgresql.ExchangeRate{}, fmt.Errorf("%s is the base currency, its rate is always 1", currency)
+	}
+
+	if req.Rate <= 0 {
+		return postgresql.ExchangeRate{}, fmt.Errorf("invalid rate %v for %s, must be positive", req.Rate, currency)
+	}
+
+	return postgresql.ExchangeRate{RateDate: rateDate, Currency: currency, Rate: req.Rate}, nil
+}
+
+// parseCSV - разобрать CSV с колонками date,currency,rate; строка заголовка необязательна
+func parseCSV(body io.Reader) ([]postgresql.ExchangeRate, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+
+	var rates []postgresql.ExchangeRate
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if line == 1 && strings.EqualFold(record[0], "date") {
+			continue
+		}
+
+		value, err := strconv.ParseFloat(record[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid rate %q", line, record[2])
+		}
+
+		rate, err := toExchangeRate(RateRequest{RateDate: record[0], Currency: record[1], Rate: value})
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rates = append(rates, rate)
+	}
+
+	return rates, nil
+}
+
+// store - сохранить курсы и сбросить аналитику, посчитанную по старым курсам:
+// курс действует с rate_date до следующего известного курса
+func store(ctx context.Context, storage *postgresql.Storage, analytics handlers.AnalyticsInvalidator, rates []postgresql.ExchangeRate) (int, error) {
+	loaded, err := storage.UpsertExchangeRates(ctx, rates)
+	if err != nil {
+		return 0, err
+	}
+
+	earliest := rates[0].RateDate
+	for _, rate := range rates[1:] {
+		if rate.RateDate.Before(earliest) {
+			earliest = rate.RateDate
+		}
+	}
+	analytics.InvalidateRange(earliest, time.Now())
+
+	return loaded, nil
+}
+
+// ====================================================================
+// EXCHANGE RATES HANDLERS
+// ====================================================================
+
+// UpsertRates - загрузить курсы валют
+// POST /api/v1/exchange-rates
+// [{"rate_date": "2024-01-15", "currency": "USD", "rate": 89.69}]
+func UpsertRates(storage *postgresql.Storage, analytics handlers.AnalyticsInvalidator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req []RateRequest
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid request body, expected an array of rates")
+			return
+		}
+		if len(req) == 0 {
+			respondError(w, r, http.StatusBadRequest, "no rates in request")
+			return
+		}
+
+		rates := make([]postgresql.ExchangeRate, 0, len(req))
+		for i, item := range req {
+			rate, err := toExchangeRate(item)
+			if err != nil {
+				respondError(w, r, http.StatusBadRequest, fmt.Sprintf("rate %d: %v", i, err))
+				return
+			}
+			rates = append(rates, rate)
+		}
+
+		loaded, err := store(r.Context(), storage, analytics, rates)
+		if err != nil {
+			respondError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		render.JSON(w, r, ImportResult{Loaded: loaded})
+	}
+}
+
+// ImportRatesCSV - загрузить курсы из CSV (Content-Type: text/csv)
+// POST /api/v1/exchange-rates/import
+//
+//	date,currency,rate
+//	2024-01-15,USD,89.69
+func ImportRatesCSV(storage *postgresql.Storage, analytics handlers.AnalyticsInvalidator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rates, err := parseCSV(r.Body)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		if len(rates) == 0 {
+			respondError(w, r, http.StatusBadRequest, "no rates in file")
+			return
+		}
+
+		loaded, err := store(r.Context(), storage, analytics, rates)
+		if err != nil {
+			respondError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		render.JSON(w, r, ImportResult{Loaded: loaded})
+	}
+}
+
+// ListRates - курсы за период
+// GET /api/v1/exchange-rates?currency=USD&start=2024-01-01&end=2024-01-31
+func ListRates(storage *postgresql.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start, err := parseDate(r.URL.Query().Get("start"))
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid start date format, use YYYY-MM-DD")
+			return
+		}
+
+		end, err := parseDate(r.URL.Query().Get("end"))
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid end date format, use YYYY-MM-DD")
+			return
+		}
+
+		currency := strings.ToUpper(r.URL.Query().Get("currency"))
+
+		rates, err := storage.ListExchangeRates(r.Context(), currency, start, end)
+		if err != nil {
+			respondError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		render.JSON(w, r, rates)
+	}
+}
@@ -0,0 +1,102 @@
+package rates
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"salesTracker/internal/storage/postgresql"
+)
+
+func TestToExchangeRate(t *testing.T) {
+	day := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		req     RateRequest
+		want    postgresql.ExchangeRate
+		wantErr string
+	}{
+		{name: "valid", req: RateRequest{RateDate: "2024-01-15", Currency: "USD", Rate: 89.69},
+			want: postgresql.ExchangeRate{RateDate: day, Currency: "USD", Rate: 89.69}},
+		{name: "lower case and spaces", req: RateRequest{RateDate: " 2024-01-15 ", Currency: " eur ", Rate: 98.1},
+			want: postgresql.ExchangeRate{RateDate: day, Currency: "EUR", Rate: 98.1}},
+		{name: "fractional rate", req: RateRequest{RateDate: "2024-01-15", Currency: "KZT", Rate: 0.1973},
+			want: postgresql.ExchangeRate{RateDate: day, Currency: "KZT", Rate: 0.1973}},
+
+		{name: "bad date", req: RateRequest{RateDate: "15.01.2024", Currency: "USD", Rate: 1}, wantErr: "invalid rate date"},
+		{name: "empty date", req: RateRequest{Currency: "USD", Rate: 1}, wantErr: "invalid rate date"},
+		{name: "short code", req: RateRequest{RateDate: "2024-01-15", Currency: "US", Rate: 1}, wantErr: "invalid currency"},
+		{name: "digits in code", req: RateRequest{RateDate: "2024-01-15", Currency: "US1", Rate: 1}, wantErr: "invalid currency"},
+		{name: "base currency", req: RateRequest{RateDate: "2024-01-15", Currency: "rub", Rate: 1}, wantErr: "base currency"},
+		{name: "zero rate", req: RateRequest{RateDate: "2024-01-15", Currency: "USD"}, wantErr: "invalid rate"},
+		{name: "negative rate", req: RateRequest{RateDate: "2024-01-15", Currency: "USD", Rate: -89}, wantErr: "invalid rate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := toExchangeRate(tt.req)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("toExchangeRate() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("toExchangeRate() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("toExchangeRate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    []string
+		wantErr string
+	}{
+		{name: "with header", body: "date,currency,rate\n2024-01-15,USD,89.69\n2024-01-16,EUR,98.1\n",
+			want: []string{"2024-01-15 USD 89.69", "2024-01-16 EUR 98.1"}},
+		{name: "without header", body: "2024-01-15,usd,89.69\n",
+			want: []string{"2024-01-15 USD 89.69"}},
+		{name: "header in upper case", body: "DATE,CURRENCY,RATE\n2024-01-15,USD,89.69\n",
+			want: []string{"2024-01-15 USD 89.69"}},
+		{name: "spaces after commas", body: "2024-01-15, USD, 89.69\n",
+			want: []string{"2024-01-15 USD 89.69"}},
+		{name: "empty", body: ""},
+
+		{name: "bad rate", body: "date,currency,rate\n2024-01-15,USD,abc\n", wantErr: "line 2: invalid rate"},
+		{name: "base currency", body: "2024-01-15,RUB,1\n", wantErr: "line 1:"},
+		{name: "header not first", body: "2024-01-15,USD,89.69\ndate,currency,rate\n", wantErr: "line 2:"},
+		{name: "missing column", body: "2024-01-15,USD\n", wantErr: "wrong number of fields"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rates, err := parseCSV(strings.NewReader(tt.body))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseCSV() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCSV() error = %v", err)
+			}
+
+			var got []string
+			for _, rate := range rates {
+				got = append(got, rate.RateDate.Format("2006-01-02")+" "+rate.Currency+" "+
+					strconv.FormatFloat(rate.Rate, 'f', -1, 64))
+			}
+			if strings.Join(got, "; ") != strings.Join(tt.want, "; ") {
+				t.Errorf("parseCSV() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
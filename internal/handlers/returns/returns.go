@@ -0,0 +1,146 @@
+package returns
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"salesTracker/internal/handlers"
+	"salesTracker/internal/storage"
+	"salesTracker/internal/storage/postgresql"
+)
+
+// ====================================================================
+// REQUEST/RESPONSE DTOs
+// ====================================================================
+
+// ReturnRequest - DTO для оформления возврата по заказу
+type ReturnRequest struct {
+	ReturnDate string              `json:"return_date"`
+	Reason     string              `json:"reason"`
+	Restock    *bool               `json:"restock"`
+	Items      []ReturnItemRequest `json:"items"`
+}
+
+// ReturnItemRequest - возвращаемая позиция; refund_amount необязателен:
+// по умолчанию возвращается уплаченная за эти единицы сумма
+type ReturnItemRequest struct {
+	OrderItemID  int      `json:"order_item_id"`
+	Quantity     int      `json:"quantity"`
+	RefundAmount *float64 `json:"refund_amount"`
+	Reason       string   `json:"reason"`
+}
+
+// ====================================================================
+// HELPERS
+// ====================================================================
+
+func parseURLParamID(r *http.Request) (int, error) {
+	idStr := chi.URLParam(r, "id")
+	return strconv.Atoi(idStr)
+}
+
+func respondError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	render.Status(r, status)
+	render.JSON(w, r, map[string]string{"error": message})
+}
+
+func respondStorageError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, storage.ErrOrderNotFound):
+		respondError(w, r, http.StatusNotFound, "order not found")
+	case errors.Is(err, storage.ErrInvalidReturn):
+		respondError(w, r, http.StatusUnprocessableEntity, err.Error())
+	default:
+		respondError(w, r, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// ====================================================================
+// RETURNS HANDLERS
+// ====================================================================
+
+// CreateReturn - оформить возврат по заказу
+// POST /api/v1/orders/{id}/returns
+func CreateReturn(storage *postgresql.Storage, analytics handlers.AnalyticsInvalidator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orderID, err := parseURLParamID(r)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid order id")
+			return
+		}
+
+		var req ReturnRequest
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid request body")
+			return
+		}
+
+		returnDate := time.Now()
+		if req.ReturnDate != "" {
+			returnDate, err = time.Parse(time.RFC3339, req.ReturnDate)
+			if err != nil {
+				respondError(w, r, http.StatusBadRequest, "invalid return date format, use RFC 3339")
+				return
+			}
+		}
+
+		items := make([]postgresql.ReturnItemInput, 0, len(req.Items))
+		for _, item := range req.Items {
+			items = append(items, postgresql.ReturnItemInput{
+				OrderItemID:  item.OrderItemID,
+				Quantity:     item.Quantity,
+				RefundAmount: item.RefundAmount,
+				Reason:       item.Reason,
+			})
+		}
+
+		restock := req.Restock == nil || *req.Restock
+
+		id, err := storage.AddReturn(r.Context(), orderID, returnDate, req.Reason, restock, items)
+		if err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+
+		// возврат уменьшает чистую выручку за дату возврата и меняет долю
+		// возвратов товаров, проданных в дату заказа
+		analytics.InvalidateRange(returnDate, returnDate)
+		if order, err := storage.GetOrder(r.Context(), orderID); err == nil {
+			analytics.InvalidateRange(order.OrderDate, order.OrderDate)
+		}
+
+		ret, err := storage.GetReturn(r.Context(), id)
+		if err != nil {
+			respondError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, ret)
+	}
+}
+
+// ListReturns - получить возвраты заказа
+// GET /api/v1/orders/{id}/returns
+func ListReturns(storage *postgresql.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orderID, err := parseURLParamID(r)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid order id")
+			return
+		}
+
+		returns, err := storage.ListReturnsByOrder(r.Context(), orderID)
+		if err != nil {
+			respondError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		render.JSON(w, r, returns)
+	}
+}
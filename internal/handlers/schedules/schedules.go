@@ -0,0 +1,304 @@
+package schedules
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"salesTracker/internal/mergepatch"
+	"salesTracker/internal/scheduler"
+	"salesTracker/internal/storage"
+	"salesTracker/internal/storage/postgresql"
+)
+
+// ====================================================================
+// REQUEST/RESPONSE DTOs
+// ====================================================================
+
+// ScheduleRequest - DTO для создания/обновления расписания отчета
+type ScheduleRequest struct {
+	Name       string   `json:"name"`
+	CronExpr   string   `json:"cron_expr"`
+	ReportType string   `json:"report_type"`
+	Range      string   `json:"range"`
+	Percentile int      `json:"percentile"`
+	TimeZone   string   `json:"time_zone"`
+	Currency   string   `json:"currency"`
+	Delivery   string   `json:"delivery"`
+	Recipients []string `json:"recipients"`
+	Enabled    *bool    `json:"enabled"`
+}
+
+func (req ScheduleRequest) toSchedule() (postgresql.ReportSchedule, error) {
+	sch := postgresql.ReportSchedule{
+		Name:       req.Name,
+		CronExpr:   req.CronExpr,
+		ReportType: req.ReportType,
+		Range:      req.Range,
+		Percentile: req.Percentile,
+		TimeZone:   req.TimeZone,
+		Currency:   strings.ToUpper(req.Currency),
+		Delivery:   req.Delivery,
+		Recipients: req.Recipients,
+		Enabled:    req.Enabled == nil || *req.Enabled,
+	}
+	if sch.TimeZone == "" {
+		sch.TimeZone = "UTC"
+	}
+
+	if err := scheduler.ValidateSchedule(sch); err != nil {
+		return sch, err
+	}
+
+	if sch.Enabled {
+		next, err := scheduler.NextRun(sch, time.Now())
+		if err != nil {
+			return sch, err
+		}
+		sch.NextRunAt = next
+	}
+
+	return sch, nil
+}
+
+// newScheduleRequest - DTO с текущими параметрами расписания, основа для PATCH
+func newScheduleRequest(sch *postgresql.ReportSchedule) ScheduleRequest {
+	return ScheduleRequest{
+		Name:       sch.Name,
+		CronExpr:   sch.CronExpr,
+		ReportType: sch.ReportType,
+		Range:      sch.Range,
+		Percentile: sch.Percentile,
+		TimeZone:   sch.TimeZone,
+		Currency:   sch.Currency,
+		Delivery:   sch.Delivery,
+		Recipients: sch.Recipients,
+		Enabled:    &sch.Enabled,
+	}
+}
+
+// ====================================================================
+// HELPERS
+// ====================================================================
+
+func parseURLParamID(r *http.Request) (int, error) {
+	idStr := chi.URLParam(r, "id")
+	return strconv.Atoi(idStr)
+}
+
+func respondError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	render.Status(r, status)
+	render.JSON(w, r, map[string]string{"error": message})
+}
+
+// ====================================================================
+// REPORT SCHEDULES HANDLERS
+// ====================================================================
+
+// CreateSchedule - создать расписание отчета
+// POST /api/v1/report-schedules
+func CreateSchedule(storage *postgresql.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req ScheduleRequest
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid request body")
+			return
+		}
+
+		sch, err := req.toSchedule()
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		id, err := storage.AddReportSchedule(r.Context(), sch)
+		if err != nil {
+			respondError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		created, err := storage.GetReportSchedule(r.Context(), id)
+		if err != nil {
+			respondError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, created)
+	}
+}
+
+// GetSchedule - получить расписание по ID
+func GetSchedule(storage *postgresql.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseURLParamID(r)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid schedule id")
+			return
+		}
+
+		sch, err := storage.GetReportSchedule(r.Context(), id)
+		if err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+
+		render.JSON(w, r, sch)
+	}
+}
+
+// ListSchedules - получить список расписаний
+func ListSchedules(storage *postgresql.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		schedules, err := storage.ListReportSchedules(r.Context())
+		if err != nil {
+			respondError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		render.JSON(w, r, schedules)
+	}
+}
+
+// UpdateSchedule - обновить расписание
+func UpdateSchedule(storage *postgresql.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseURLParamID(r)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid schedule id")
+			return
+		}
+
+		var req ScheduleRequest
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid request body")
+			return
+		}
+
+		sch, err := req.toSchedule()
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if err := storage.UpdateReportSchedule(r.Context(), id, sch); err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+
+		updated, err := storage.GetReportSchedule(r.Context(), id)
+		if err != nil {
+			respondError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		render.JSON(w, r, updated)
+	}
+}
+
+// PatchSchedule - частично обновить расписание
+// PATCH /api/v1/report-schedules/{id} (application/merge-patch+json) {"enabled": false}
+func PatchSchedule(storage *postgresql.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseURLParamID(r)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid schedule id")
+			return
+		}
+
+		current, err := storage.GetReportSchedule(r.Context(), id)
+		if err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+
+		req := newScheduleRequest(current)
+		changed, err := mergepatch.Decode(r, &req)
+		if errors.Is(err, mergepatch.ErrUnsupportedMediaType) {
+			respondError(w, r, http.StatusUnsupportedMediaType, err.Error())
+			return
+		}
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		sch, err := req.toSchedule()
+		if err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+
+		if len(changed) > 0 {
+			if err := storage.UpdateReportSchedule(r.Context(), id, sch); err != nil {
+				respondStorageError(w, r, err)
+				return
+			}
+		}
+
+		updated, err := storage.GetReportSchedule(r.Context(), id)
+		if err != nil {
+			respondError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		render.JSON(w, r, updated)
+	}
+}
+
+// DeleteSchedule - удалить расписание
+func DeleteSchedule(storage *postgresql.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseURLParamID(r)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid schedule id")
+			return
+		}
+
+		if err := storage.DeleteReportSchedule(r.Context(), id); err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+
+		render.Status(r, http.StatusNoContent)
+	}
+}
+
+// RunSchedule - сформировать и доставить отчет немедленно, не дожидаясь расписания
+// POST /api/v1/report-schedules/{id}/run
+func RunSchedule(storage *postgresql.Storage, sched *scheduler.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := parseURLParamID(r)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid schedule id")
+			return
+		}
+
+		sch, err := storage.GetReportSchedule(r.Context(), id)
+		if err != nil {
+			respondStorageError(w, r, err)
+			return
+		}
+
+		if err := sched.RunSchedule(r.Context(), *sch, time.Now()); err != nil {
+			respondError(w, r, http.StatusBadGateway, err.Error())
+			return
+		}
+
+		render.Status(r, http.StatusAccepted)
+		render.JSON(w, r, map[string]string{"status": "delivered"})
+	}
+}
+
+func respondStorageError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, storage.ErrScheduleNotFound) {
+		respondError(w, r, http.StatusNotFound, "report schedule not found")
+		return
+	}
+	respondError(w, r, http.StatusInternalServerError, err.Error())
+}
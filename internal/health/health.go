@@ -0,0 +1,109 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+// ====================================================================
+// HEALTH - Проверки живости и готовности сервиса
+// ====================================================================
+
+const (
+	StatusOK       = "ok"
+	StatusFail     = "fail"
+	StatusDisabled = "disabled"
+)
+
+// Check — проверка зависимости для /health/ready. Details попадают в ответ,
+// ошибка делает сервис неготовым.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) (details map[string]any, err error)
+}
+
+// Result — итог одной проверки
+type Result struct {
+	Status   string         `json:"status"`
+	Error    string         `json:"error,omitempty"`
+	Duration string         `json:"duration"`
+	Details  map[string]any `json:"details,omitempty"`
+}
+
+// Report — ответ /health/ready: общий статус и результаты проверок по именам
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Live - GET /health/live: процесс жив и обслуживает HTTP. Зависимости не
+// проверяются, чтобы недоступная база не приводила к перезапуску сервиса.
+func Live() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, map[string]string{"status": StatusOK})
+	}
+}
+
+// Ready - GET /health/ready: все проверки параллельно, не дольше timeout.
+// 200 — сервис готов принимать запросы, 503 — хотя бы одна проверка не прошла.
+func Ready(timeout time.Duration, checks ...Check) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := Run(r.Context(), timeout, checks...)
+		if report.Status != StatusOK {
+			render.Status(r, http.StatusServiceUnavailable)
+		}
+		render.JSON(w, r, report)
+	}
+}
+
+// Run — выполнить проверки параллельно с общим таймаутом. Проверка, которая
+// не уложилась в таймаут, считается неуспешной, даже если не следит за ctx.
+func Run(ctx context.Context, timeout time.Duration, checks ...Check) Report {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type finished struct {
+		index  int
+		result Result
+	}
+	// буфер на все проверки: зависшая проверка допишет результат и завершится
+	done := make(chan finished, len(checks))
+	start := time.Now()
+	for i, check := range checks {
+		go func() {
+			details, err := check.Run(ctx)
+			result := Result{Status: StatusOK, Duration: time.Since(start).Round(time.Microsecond).String(), Details: details}
+			if err != nil {
+				result.Status, result.Error = StatusFail, err.Error()
+			}
+			done <- finished{index: i, result: result}
+		}()
+	}
+
+	results := make([]*Result, len(checks))
+wait:
+	for range checks {
+		select {
+		case f := <-done:
+			results[f.index] = &f.result
+		case <-ctx.Done():
+			break wait
+		}
+	}
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+	for i, check := range checks {
+		result := results[i]
+		if result == nil {
+			result = &Result{Status: StatusFail, Error: "timed out after " + timeout.String(), Duration: timeout.String()}
+		}
+		report.Checks[check.Name] = *result
+		if result.Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
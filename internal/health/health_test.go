@@ -0,0 +1,146 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func check(name string, err error) Check {
+	return Check{Name: name, Run: func(ctx context.Context) (map[string]any, error) {
+		return map[string]any{"name": name}, err
+	}}
+}
+
+func TestReady(t *testing.T) {
+	tests := []struct {
+		name       string
+		checks     []Check
+		wantStatus int
+		wantReport string
+	}{
+		{name: "all pass", checks: []Check{check("database", nil), check("migrations", nil)},
+			wantStatus: http.StatusOK, wantReport: StatusOK},
+		{name: "one fails", checks: []Check{check("database", errors.New("connection refused")), check("migrations", nil)},
+			wantStatus: http.StatusServiceUnavailable, wantReport: StatusFail},
+		{name: "no checks", wantStatus: http.StatusOK, wantReport: StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			Ready(time.Second, tt.checks...)(w, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			var report Report
+			if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+				t.Fatal(err)
+			}
+			if report.Status != tt.wantReport || len(report.Checks) != len(tt.checks) {
+				t.Errorf("report = %+v", report)
+			}
+		})
+	}
+}
+
+func TestRunReportsEachCheck(t *testing.T) {
+	report := Run(context.Background(), time.Second, check("database", errors.New("connection refused")), check("workers", nil))
+
+	if got := report.Checks["database"]; got.Status != StatusFail || got.Error != "connection refused" || got.Details["name"] != "database" {
+		t.Errorf("database = %+v", got)
+	}
+	if got := report.Checks["workers"]; got.Status != StatusOK || got.Error != "" || got.Duration == "" {
+		t.Errorf("workers = %+v", got)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	// одна проверка ждет ctx, другая его не слушает — ответ не ждет ни одну
+	waits := Check{Name: "database", Run: func(ctx context.Context) (map[string]any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+	hangs := Check{Name: "migrations", Run: func(ctx context.Context) (map[string]any, error) {
+		<-release
+		return nil, nil
+	}}
+
+	start := time.Now()
+	report := Run(context.Background(), 50*time.Millisecond, waits, hangs, check("workers", nil))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Run() returned after %v, want about the timeout", elapsed)
+	}
+
+	if report.Status != StatusFail {
+		t.Errorf("status = %s, want %s", report.Status, StatusFail)
+	}
+	if got := report.Checks["migrations"]; got.Status != StatusFail || !strings.Contains(got.Error, "timed out") {
+		t.Errorf("migrations = %+v, want timed out", got)
+	}
+	if got := report.Checks["database"]; got.Status != StatusFail {
+		t.Errorf("database = %+v, want fail", got)
+	}
+	if got := report.Checks["workers"]; got.Status != StatusOK {
+		t.Errorf("workers = %+v, want ok", got)
+	}
+}
+
+func TestLive(t *testing.T) {
+	w := httptest.NewRecorder()
+	Live()(w, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"status":"ok"`) {
+		t.Errorf("live = %d %s", w.Code, w.Body)
+	}
+}
+
+func TestWorkers(t *testing.T) {
+	ws := NewWorkers()
+	ws.Disable("scheduler")
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		ws.Run("rollup_drain", func() { <-stop })
+		close(stopped)
+	}()
+
+	// задача запущена: сервис готов, выключенная задача не мешает
+	deadline := time.Now().Add(time.Second)
+	for {
+		details, err := ws.Check().Run(context.Background())
+		if status, ok := details["rollup_drain"].(WorkerStatus); ok && status.State == WorkerRunning {
+			if err != nil {
+				t.Fatalf("Check() error = %v with a running worker", err)
+			}
+			if details["scheduler"].(WorkerStatus).State != StatusDisabled {
+				t.Errorf("scheduler = %+v, want disabled", details["scheduler"])
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("worker did not start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(stop)
+	<-stopped
+	details, err := ws.Check().Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "rollup_drain") {
+		t.Errorf("Check() error = %v, want the stopped worker named", err)
+	}
+	if status := details["rollup_drain"].(WorkerStatus); status.State != WorkerStopped || status.Since.IsZero() {
+		t.Errorf("rollup_drain = %+v", status)
+	}
+}
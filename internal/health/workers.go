@@ -0,0 +1,78 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ====================================================================
+// WORKERS - Состояние фоновых задач
+// ====================================================================
+
+const (
+	WorkerRunning = "running"
+	WorkerStopped = "stopped"
+)
+
+// WorkerStatus — состояние фоновой задачи и время его смены
+type WorkerStatus struct {
+	State string    `json:"state"`
+	Since time.Time `json:"since"`
+}
+
+// Workers — реестр фоновых задач для проверки готовности: задача, которая
+// должна работать, но остановилась, делает сервис неготовым
+type Workers struct {
+	mu       sync.Mutex
+	statuses map[string]WorkerStatus
+}
+
+func NewWorkers() *Workers {
+	return &Workers{statuses: map[string]WorkerStatus{}}
+}
+
+// Run — выполнить run, отмечая задачу name запущенной, пока run не вернется
+func (ws *Workers) Run(name string, run func()) {
+	ws.set(name, WorkerRunning)
+	defer ws.set(name, WorkerStopped)
+
+	run()
+}
+
+// Disable — задача name выключена в конфигурации и не проверяется
+func (ws *Workers) Disable(name string) {
+	ws.set(name, StatusDisabled)
+}
+
+// Check — проверка готовности по состоянию всех задач
+func (ws *Workers) Check() Check {
+	return Check{Name: "workers", Run: func(ctx context.Context) (map[string]any, error) {
+		ws.mu.Lock()
+		defer ws.mu.Unlock()
+
+		details := make(map[string]any, len(ws.statuses))
+		var stopped []string
+		for name, status := range ws.statuses {
+			details[name] = status
+			if status.State == WorkerStopped {
+				stopped = append(stopped, name)
+			}
+		}
+		if len(stopped) > 0 {
+			sort.Strings(stopped)
+			return details, fmt.Errorf("stopped: %s", strings.Join(stopped, ", "))
+		}
+		return details, nil
+	}}
+}
+
+func (ws *Workers) set(name, state string) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	ws.statuses[name] = WorkerStatus{State: state, Since: time.Now().UTC()}
+}
@@ -0,0 +1,231 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+
+	"salesTracker/internal/auth"
+	"salesTracker/internal/config"
+	"salesTracker/internal/storage/postgresql"
+)
+
+// ====================================================================
+// IDEMPOTENCY - Повторы POST-запросов без дублей
+// ====================================================================
+
+// Header — заголовок с ключом идемпотентности от клиента
+const Header = "Idempotency-Key"
+
+// ReplayedHeader — признак ответа, воспроизведенного из сохраненного
+const ReplayedHeader = "Idempotent-Replayed"
+
+// SharedScope — область ключей без аутентификации: все клиенты делят одну
+// область, поэтому ключи должны быть уникальными (UUID)
+const SharedScope = "anonymous"
+
+const (
+	maxKeyLength = 255
+	maxBodySize  = 32 << 20
+)
+
+// replayedHeaders — заголовки ответа, которые сохраняются вместе с телом
+var replayedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+// Store — хранилище ключей
+type Store interface {
+	ClaimIdempotencyKey(ctx context.Context, k postgresql.IdempotencyKey) (*postgresql.IdempotencyKey, bool, error)
+	CompleteIdempotencyKey(ctx context.Context, scope, key string, status int, headers map[string]string, body []byte) error
+	ReleaseIdempotencyKey(ctx context.Context, scope, key string) error
+	PurgeIdempotencyKeys(ctx context.Context, now time.Time) (int64, error)
+}
+
+// Keeper — middleware идемпотентности и очистка истекших ключей
+type Keeper struct {
+	store         Store
+	ttl           time.Duration
+	purgeInterval time.Duration
+	streaming     []string
+}
+
+// New — streaming: типы тел потоковых загрузок (CSV, JSONL). Такие тела
+// не буферизуются, и запрос загрузки с ключом отклоняется: у загрузок свои
+// повторы — прерванная загрузка продолжается по resume, заказы с уже
+// загруженным номером пропускаются, курсы на дату перезаписываются.
+func New(store Store, cfg config.Idempotency, streaming ...string) *Keeper {
+	return &Keeper{store: store, ttl: cfg.TTL, purgeInterval: cfg.PurgeInterval, streaming: streaming}
+}
+
+// Middleware — для POST с заголовком Idempotency-Key: первый запрос выполняется
+// и его ответ сохраняется, повтор с тем же телом получает сохраненный ответ,
+// повтор с другим телом — 422, повтор во время выполнения первого — 409.
+// Ответы 5xx не сохраняются, чтобы клиент мог повторить запрос. Ключи
+// принадлежат клиенту, чтобы клиенты не получали ответы на чужие запросы;
+// без аутентификации все ключи в SharedScope.
+func (k *Keeper) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(Header)
+		if r.Method != http.MethodPost || key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxKeyLength {
+			respondError(w, r, http.StatusBadRequest, "Idempotency-Key must be at most 255 characters")
+			return
+		}
+		if k.isStreaming(r) {
+			respondError(w, r, http.StatusBadRequest,
+				"Idempotency-Key is not supported for streaming uploads, resume an interrupted import instead")
+			return
+		}
+
+		scope := SharedScope
+		if principal := auth.PrincipalFrom(r.Context()); principal != nil {
+			scope = principal.Subject
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		if len(body) > maxBodySize {
+			respondError(w, r, http.StatusRequestEntityTooLarge, "request body is too large for an idempotent request")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := requestHash(r, body)
+		existing, claimed, err := k.store.ClaimIdempotencyKey(r.Context(), postgresql.IdempotencyKey{
+			Scope:       scope,
+			Key:         key,
+			Method:      r.Method,
+			Path:        r.URL.RequestURI(),
+			RequestHash: hash,
+			ExpiresAt:   time.Now().Add(k.ttl),
+		})
+		if err != nil {
+			log.Printf("idempotency: %v", err)
+			respondError(w, r, http.StatusServiceUnavailable, "idempotency store unavailable")
+			return
+		}
+
+		if !claimed {
+			replay(w, r, existing, hash)
+			return
+		}
+
+		// ответ сохраняется, даже если клиент уже отключился: иначе повторы
+		// получали бы 409 до истечения TTL
+		ctx := context.WithoutCancel(r.Context())
+
+		// при панике обработчика ключ освобождается, иначе повторы получали бы 409 до истечения TTL
+		defer func() {
+			if p := recover(); p != nil {
+				k.release(ctx, scope, key)
+				panic(p)
+			}
+		}()
+
+		var recorded bytes.Buffer
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ww.Tee(&recorded)
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			// обработчик ничего не записал, net/http ответит 200
+			status = http.StatusOK
+		}
+		if status >= http.StatusInternalServerError {
+			k.release(ctx, scope, key)
+			return
+		}
+
+		headers := map[string]string{}
+		for _, name := range replayedHeaders {
+			if value := ww.Header().Get(name); value != "" {
+				headers[name] = value
+			}
+		}
+		if err := k.store.CompleteIdempotencyKey(ctx, scope, key, status, headers, recorded.Bytes()); err != nil {
+			log.Printf("idempotency: %v", err)
+		}
+	})
+}
+
+// Run — периодическая очистка истекших ключей, завершается при отмене контекста
+func (k *Keeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(k.purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := k.store.PurgeIdempotencyKeys(ctx, time.Now()); err != nil {
+			log.Printf("idempotency: purge: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ====================================================================
+// HELPERS
+// ====================================================================
+
+func (k *Keeper) release(ctx context.Context, scope, key string) {
+	if err := k.store.ReleaseIdempotencyKey(ctx, scope, key); err != nil {
+		log.Printf("idempotency: %v", err)
+	}
+}
+
+func replay(w http.ResponseWriter, r *http.Request, existing *postgresql.IdempotencyKey, hash string) {
+	switch {
+	case existing.RequestHash != hash:
+		respondError(w, r, http.StatusUnprocessableEntity,
+			"Idempotency-Key has already been used with a different request")
+	case existing.StatusCode == 0:
+		respondError(w, r, http.StatusConflict,
+			"a request with this Idempotency-Key is still being processed, retry later")
+	default:
+		for name, value := range existing.Headers {
+			w.Header().Set(name, value)
+		}
+		w.Header().Set(ReplayedHeader, "true")
+		w.WriteHeader(existing.StatusCode)
+		w.Write(existing.Body)
+	}
+}
+
+// requestHash — отпечаток запроса: метод, путь с параметрами и тело
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// isStreaming — тело запроса — потоковая загрузка
+func (k *Keeper) isStreaming(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return slices.Contains(k.streaming, mediaType)
+}
+
+func respondError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	render.Status(r, status)
+	render.JSON(w, r, map[string]string{"error": message})
+}
@@ -0,0 +1,270 @@
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"salesTracker/internal/auth"
+	"salesTracker/internal/config"
+	"salesTracker/internal/storage/postgresql"
+)
+
+// memStore — хранилище ключей в памяти с теми же правилами, что и таблица
+type memStore struct {
+	mu   sync.Mutex
+	keys map[string]postgresql.IdempotencyKey
+}
+
+func newMemStore() *memStore {
+	return &memStore{keys: map[string]postgresql.IdempotencyKey{}}
+}
+
+func (s *memStore) ClaimIdempotencyKey(_ context.Context, k postgresql.IdempotencyKey) (*postgresql.IdempotencyKey, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := k.Scope + "\x00" + k.Key
+	if existing, ok := s.keys[id]; ok && existing.ExpiresAt.After(time.Now()) {
+		return &existing, false, nil
+	}
+	s.keys[id] = k
+	return nil, true, nil
+}
+
+func (s *memStore) CompleteIdempotencyKey(_ context.Context, scope, key string, status int, headers map[string]string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := s.keys[scope+"\x00"+key]
+	k.StatusCode, k.Headers, k.Body = status, headers, body
+	s.keys[scope+"\x00"+key] = k
+	return nil
+}
+
+func (s *memStore) ReleaseIdempotencyKey(_ context.Context, scope, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.keys, scope+"\x00"+key)
+	return nil
+}
+
+func (s *memStore) PurgeIdempotencyKeys(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (s *memStore) has(scope, key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.keys[scope+"\x00"+key]
+	return ok
+}
+
+func newKeeper(store Store) *Keeper {
+	return New(store, config.Idempotency{TTL: time.Hour, PurgeInterval: time.Hour}, "text/csv", "application/x-ndjson")
+}
+
+// createHandler — обработчик создания: отвечает 201 с номером вызова
+func createHandler(calls *atomic.Int32) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", fmt.Sprintf("/api/v1/customers/%d", n))
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"customer_id":%d}`, n)
+	})
+}
+
+func post(h http.Handler, key, body string, principal *auth.Principal) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/customers", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		r.Header.Set(Header, key)
+	}
+	if principal != nil {
+		r = r.WithContext(auth.WithPrincipal(r.Context(), principal))
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestMiddlewareReplay(t *testing.T) {
+	var calls atomic.Int32
+	h := newKeeper(newMemStore()).Middleware(createHandler(&calls))
+	alice := &auth.Principal{Subject: "alice"}
+
+	first := post(h, "k1", `{"first_name":"Anna"}`, alice)
+	second := post(h, "k1", `{"first_name":"Anna"}`, alice)
+
+	if calls.Load() != 1 {
+		t.Fatalf("handler called %d times, want 1", calls.Load())
+	}
+	if second.Code != first.Code || second.Body.String() != first.Body.String() {
+		t.Errorf("replay = %d %s, want %d %s", second.Code, second.Body, first.Code, first.Body)
+	}
+	if got := second.Header().Get("Location"); got != "/api/v1/customers/1" {
+		t.Errorf("replayed Location = %q, want /api/v1/customers/1", got)
+	}
+	if second.Header().Get(ReplayedHeader) != "true" {
+		t.Errorf("replay has no %s header", ReplayedHeader)
+	}
+	if first.Header().Get(ReplayedHeader) != "" {
+		t.Errorf("first response has %s header", ReplayedHeader)
+	}
+}
+
+func TestMiddlewareScopes(t *testing.T) {
+	var calls atomic.Int32
+	h := newKeeper(newMemStore()).Middleware(createHandler(&calls))
+
+	// один и тот же ключ разных клиентов не пересекается
+	post(h, "k1", `{}`, &auth.Principal{Subject: "alice"})
+	post(h, "k1", `{}`, &auth.Principal{Subject: "bob"})
+	if calls.Load() != 2 {
+		t.Fatalf("handler called %d times for two clients, want 2", calls.Load())
+	}
+
+	// без аутентификации ключи общие, но работают
+	first := post(h, "k2", `{}`, nil)
+	second := post(h, "k2", `{}`, nil)
+	if first.Code != http.StatusCreated || second.Header().Get(ReplayedHeader) != "true" {
+		t.Errorf("without auth: first %d, second replayed=%q, want 201 and replay",
+			first.Code, second.Header().Get(ReplayedHeader))
+	}
+	if calls.Load() != 3 {
+		t.Errorf("handler called %d times, want 3", calls.Load())
+	}
+}
+
+func TestMiddlewareRejects(t *testing.T) {
+	tests := []struct {
+		name        string
+		key         string
+		contentType string
+		prepare     func(h http.Handler)
+		wantStatus  int
+	}{
+		{name: "different body", key: "k1", contentType: "application/json",
+			prepare:    func(h http.Handler) { post(h, "k1", `{"first_name":"Boris"}`, nil) },
+			wantStatus: http.StatusUnprocessableEntity},
+		{name: "key too long", key: strings.Repeat("k", maxKeyLength+1), contentType: "application/json",
+			wantStatus: http.StatusBadRequest},
+		{name: "csv upload", key: "k1", contentType: "text/csv; charset=utf-8",
+			wantStatus: http.StatusBadRequest},
+		{name: "jsonl upload", key: "k1", contentType: "application/x-ndjson",
+			wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			h := newKeeper(newMemStore()).Middleware(createHandler(&calls))
+			if tt.prepare != nil {
+				tt.prepare(h)
+			}
+			before := calls.Load()
+
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/customers", strings.NewReader(`{"first_name":"Anna"}`))
+			r.Header.Set("Content-Type", tt.contentType)
+			r.Header.Set(Header, tt.key)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if calls.Load() != before {
+				t.Errorf("handler called for a rejected request")
+			}
+		})
+	}
+}
+
+func TestMiddlewarePassThrough(t *testing.T) {
+	var calls atomic.Int32
+	h := newKeeper(newMemStore()).Middleware(createHandler(&calls))
+
+	// без ключа каждый POST выполняется
+	post(h, "", `{}`, nil)
+	post(h, "", `{}`, nil)
+
+	// ключ не действует на другие методы
+	for range 2 {
+		r := httptest.NewRequest(http.MethodPut, "/api/v1/customers/1", strings.NewReader(`{}`))
+		r.Header.Set(Header, "k1")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	if calls.Load() != 4 {
+		t.Errorf("handler called %d times, want 4", calls.Load())
+	}
+}
+
+func TestMiddlewareInFlight(t *testing.T) {
+	started, finish := make(chan struct{}), make(chan struct{})
+	h := newKeeper(newMemStore()).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-finish
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- post(h, "k1", `{}`, nil) }()
+	<-started
+
+	if w := post(h, "k1", `{}`, nil); w.Code != http.StatusConflict {
+		t.Errorf("retry while in flight: status = %d, want %d", w.Code, http.StatusConflict)
+	}
+
+	close(finish)
+	if w := <-done; w.Code != http.StatusCreated {
+		t.Errorf("first request: status = %d, want %d", w.Code, http.StatusCreated)
+	}
+	if w := post(h, "k1", `{}`, nil); w.Code != http.StatusCreated || w.Header().Get(ReplayedHeader) != "true" {
+		t.Errorf("retry after completion: status = %d, replayed = %q", w.Code, w.Header().Get(ReplayedHeader))
+	}
+}
+
+func TestMiddlewareReleasesKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		release bool
+	}{
+		{name: "server error", release: true,
+			handler: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusInternalServerError) }},
+		{name: "unavailable", release: true,
+			handler: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusServiceUnavailable) }},
+		{name: "panic", release: true,
+			handler: func(w http.ResponseWriter, r *http.Request) { panic("boom") }},
+		// ошибки клиента сохраняются: повтор того же запроса получит тот же ответ
+		{name: "client error", release: false,
+			handler: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusBadRequest) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemStore()
+			h := newKeeper(store).Middleware(tt.handler)
+
+			func() {
+				defer func() { recover() }()
+				post(h, "k1", `{}`, nil)
+			}()
+
+			if got := store.has(SharedScope, "k1"); got == tt.release {
+				t.Errorf("key kept = %v, want %v", got, !tt.release)
+			}
+		})
+	}
+}
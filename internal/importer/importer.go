@@ -0,0 +1,415 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"salesTracker/internal/storage"
+	"salesTracker/internal/storage/postgresql"
+)
+
+// ====================================================================
+// IMPORTER - Загрузка исторических заказов из CSV и JSONL
+// ====================================================================
+
+// Форматы файлов заказов
+const (
+	// FormatCSV — строка на позицию заказа, позиции одного заказа идут подряд с одним order_ref
+	FormatCSV = "csv"
+	// FormatJSONL — строка на заказ: {"order_ref": "A-1", ..., "items": [{"product_name": ...}]}
+	FormatJSONL = "jsonl"
+)
+
+// DefaultBatchSize — заказов в одной транзакции по умолчанию
+const DefaultBatchSize = 500
+
+// ErrInvalidInput — файл или параметры загрузки не подходят, загрузка не начата
+var ErrInvalidInput = errors.New("invalid import")
+
+// dateLayouts — допустимые форматы order_date; без часового пояса время считается UTC
+var dateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// Store — хранилище заказов и прогресса загрузок
+type Store interface {
+	CreateImportJob(ctx context.Context, source, format string) (*postgresql.ImportJob, error)
+	ResumeImportJob(ctx context.Context, id int) (*postgresql.ImportJob, error)
+	FinishImportJob(ctx context.Context, id int, status, message string) error
+	FindCustomerID(ctx context.Context, email, name string) (int, error)
+	FindProductID(ctx context.Context, name string) (int, error)
+	ImportOrders(ctx context.Context, b postgresql.ImportBatch, dryRun bool) ([]postgresql.ImportOutcome, error)
+}
+
+// Invalidator — сброс закэшированной аналитики за период загруженных заказов
+type Invalidator interface {
+	InvalidateRange(start, end time.Time)
+}
+
+// Options — параметры загрузки
+type Options struct {
+	// Source — имя файла для журнала загрузок
+	Source string
+	Format string
+	// DryRun — проверить файл и записать заказы в откатываемых транзакциях
+	DryRun bool
+	// BatchSize — заказов в одной транзакции, 0 — DefaultBatchSize
+	BatchSize int
+	// ResumeJobID — продолжить прерванную загрузку того же файла
+	ResumeJobID int
+	// MaxErrors — предел ошибок в отчете, 0 — без предела; счетчики считают все
+	MaxErrors int
+}
+
+// RowError — ошибка заказа; Line — первая строка заказа в файле
+type RowError struct {
+	Line     int    `json:"line"`
+	OrderRef string `json:"order_ref,omitempty"`
+	Error    string `json:"error"`
+}
+
+// Report — итог загрузки. Skipped — заказы, номер которых (order_ref) уже загружен.
+type Report struct {
+	JobID           int        `json:"job_id,omitempty"`
+	DryRun          bool       `json:"dry_run"`
+	ResumedAfter    int        `json:"resumed_after_line,omitempty"`
+	Orders          int        `json:"orders"`
+	Imported        int        `json:"imported"`
+	Skipped         int        `json:"skipped"`
+	Failed          int        `json:"failed"`
+	Errors          []RowError `json:"errors"`
+	ErrorsTruncated bool       `json:"errors_truncated,omitempty"`
+}
+
+// Importer — загрузка файлов заказов пачками транзакций
+type Importer struct {
+	store     Store
+	analytics Invalidator
+}
+
+// New — analytics может быть nil, если кэша аналитики нет
+func New(store Store, analytics Invalidator) *Importer {
+	return &Importer{store: store, analytics: analytics}
+}
+
+// Run — загрузить заказы из r. Покупатель ищется по customer_email, а без него —
+// по customer_name, товар — по product_name; заказ с ненайденным покупателем
+// или товаром не загружается и попадает в отчет. Каждая пачка записывается
+// одной транзакцией вместе с прогрессом загрузки: после сбоя или отмены ctx
+// загрузку можно продолжить с Options.ResumeJobID, а заказы с уже загруженным
+// order_ref в любом случае пропускаются. Ошибка возвращается только при сбое
+// чтения файла или базы; отчет при этом содержит прогресс до сбоя.
+func (im *Importer) Run(ctx context.Context, r io.Reader, opts Options) (*Report, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.DryRun && opts.ResumeJobID != 0 {
+		return nil, fmt.Errorf("%w: a dry run cannot resume an import job", ErrInvalidInput)
+	}
+
+	reader, err := newReader(opts.Format, r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+
+	sess := &session{
+		importer:  im,
+		opts:      opts,
+		report:    &Report{DryRun: opts.DryRun, Errors: []RowError{}},
+		customers: map[string]lookup{},
+		products:  map[string]lookup{},
+	}
+
+	if !opts.DryRun {
+		var job *postgresql.ImportJob
+		if opts.ResumeJobID != 0 {
+			job, err = im.store.ResumeImportJob(ctx, opts.ResumeJobID)
+			if err == nil && job.Format != opts.Format {
+				err = fmt.Errorf("%w: import job %d was started for a %s file, not %s",
+					ErrInvalidInput, job.JobID, job.Format, opts.Format)
+			}
+		} else {
+			job, err = im.store.CreateImportJob(ctx, opts.Source, opts.Format)
+		}
+		if err != nil {
+			return nil, err
+		}
+		sess.report.JobID = job.JobID
+		sess.report.ResumedAfter = job.LastLine
+	}
+
+	err = sess.load(ctx, reader)
+	if sess.report.JobID != 0 {
+		status, message := postgresql.ImportCompleted, ""
+		if err != nil {
+			status, message = postgresql.ImportFailed, err.Error()
+		}
+		// задание отмечается и после отмены ctx, чтобы его можно было продолжить
+		finishCtx := context.WithoutCancel(ctx)
+		if finishErr := im.store.FinishImportJob(finishCtx, sess.report.JobID, status, message); finishErr != nil && err == nil {
+			err = finishErr
+		}
+	}
+	sess.invalidate()
+
+	return sess.report, err
+}
+
+// ====================================================================
+// SESSION - Одна загрузка
+// ====================================================================
+
+type lookup struct {
+	id  int
+	err error
+}
+
+type session struct {
+	importer *Importer
+	opts     Options
+	report   *Report
+	batch    postgresql.ImportBatch
+	refs     []string
+
+	customers map[string]lookup
+	products  map[string]lookup
+
+	// период загруженных заказов для сброса кэша аналитики
+	first, last time.Time
+}
+
+func (sess *session) load(ctx context.Context, reader recordReader) error {
+	sess.batch.JobID = sess.report.JobID
+
+	for {
+		record, err := reader.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		// строки, записанные до прерывания, уже в базе
+		if record.lastLine <= sess.report.ResumedAfter {
+			continue
+		}
+
+		sess.report.Orders++
+		sess.batch.LastLine = record.lastLine
+
+		order, err := sess.resolve(ctx, record)
+		if err != nil {
+			var fatal *storeError
+			if errors.As(err, &fatal) {
+				return fatal.err
+			}
+			sess.addError(record.line, record.OrderRef, err.Error())
+			sess.report.Failed++
+			sess.batch.Rejected++
+		} else {
+			sess.batch.Orders = append(sess.batch.Orders, order)
+			sess.refs = append(sess.refs, record.OrderRef)
+		}
+
+		if len(sess.batch.Orders)+sess.batch.Rejected >= sess.opts.BatchSize {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := sess.flush(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	if sess.batch.LastLine == 0 {
+		return nil
+	}
+	return sess.flush(ctx)
+}
+
+// flush — записать накопленную пачку
+func (sess *session) flush(ctx context.Context) error {
+	outcomes, err := sess.importer.store.ImportOrders(ctx, sess.batch, sess.opts.DryRun)
+	if err != nil {
+		return err
+	}
+
+	for i, outcome := range outcomes {
+		switch {
+		case outcome.Error != "":
+			sess.addError(outcome.Line, sess.refs[i], outcome.Error)
+			sess.report.Failed++
+		case outcome.Skipped:
+			sess.report.Skipped++
+		default:
+			sess.report.Imported++
+			sess.track(sess.batch.Orders[i].OrderDate)
+		}
+	}
+
+	sess.batch = postgresql.ImportBatch{JobID: sess.batch.JobID}
+	sess.refs = sess.refs[:0]
+	return nil
+}
+
+// resolve — проверить заказ из файла и найти его покупателя и товары.
+// Сбой базы возвращается как *storeError и прерывает загрузку.
+func (sess *session) resolve(ctx context.Context, record *orderRecord) (postgresql.ImportOrder, error) {
+	if record.err != nil {
+		return postgresql.ImportOrder{}, record.err
+	}
+
+	order := postgresql.ImportOrder{
+		Line:          record.line,
+		ExternalRef:   strings.TrimSpace(record.OrderRef),
+		Status:        strings.TrimSpace(record.Status),
+		PaymentMethod: strings.TrimSpace(record.PaymentMethod),
+	}
+
+	if len(order.ExternalRef) > 100 {
+		return order, fmt.Errorf("order_ref must be at most 100 characters")
+	}
+
+	orderDate, err := parseDate(record.OrderDate)
+	if err != nil {
+		return order, err
+	}
+	order.OrderDate = orderDate
+
+	order.Currency = strings.ToUpper(strings.TrimSpace(record.Currency))
+	if order.Currency == "" {
+		order.Currency = postgresql.BaseCurrency
+	}
+	if len(order.Currency) != 3 || strings.Trim(order.Currency, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return order, fmt.Errorf("invalid currency %q, use a 3-letter ISO 4217 code", record.Currency)
+	}
+
+	email := strings.TrimSpace(record.CustomerEmail)
+	name := strings.Join(strings.Fields(record.CustomerName), " ")
+	if email != "" || name != "" {
+		if order.CustomerID, err = sess.customer(ctx, email, name); err != nil {
+			return order, err
+		}
+	}
+
+	if len(record.Items) == 0 {
+		return order, fmt.Errorf("order has no items")
+	}
+	for _, item := range record.Items {
+		switch {
+		case item.Quantity <= 0:
+			return order, fmt.Errorf("product %q: quantity must be positive", item.ProductName)
+		case item.Price < 0:
+			return order, fmt.Errorf("product %q: price must not be negative", item.ProductName)
+		case item.Discount < 0 || item.Discount > 100:
+			return order, fmt.Errorf("product %q: discount must be between 0 and 100", item.ProductName)
+		}
+
+		productID, err := sess.product(ctx, strings.TrimSpace(item.ProductName))
+		if err != nil {
+			return order, err
+		}
+		order.Items = append(order.Items, postgresql.ImportOrderItem{
+			ProductID: productID,
+			Quantity:  item.Quantity,
+			Price:     item.Price,
+			Discount:  item.Discount,
+		})
+	}
+
+	return order, nil
+}
+
+// storeError — сбой хранилища при поиске, а не ошибка данных заказа
+type storeError struct {
+	err error
+}
+
+func (e *storeError) Error() string { return e.err.Error() }
+
+func (sess *session) customer(ctx context.Context, email, name string) (int, error) {
+	key := "email:" + strings.ToLower(email)
+	if email == "" {
+		key = "name:" + strings.ToLower(name)
+	}
+
+	found, ok := sess.customers[key]
+	if !ok {
+		found.id, found.err = sess.importer.store.FindCustomerID(ctx, email, name)
+		sess.customers[key] = found
+	}
+
+	switch {
+	case found.err == nil:
+		return found.id, nil
+	case errors.Is(found.err, storage.ErrCustomerNotFound) && email != "":
+		return 0, fmt.Errorf("customer with email %q not found", email)
+	case errors.Is(found.err, storage.ErrCustomerNotFound):
+		return 0, fmt.Errorf("customer %q not found", name)
+	case errors.Is(found.err, storage.ErrAmbiguousMatch):
+		return 0, fmt.Errorf("customer name %q matches several customers, use customer_email", name)
+	default:
+		return 0, &storeError{err: found.err}
+	}
+}
+
+func (sess *session) product(ctx context.Context, name string) (int, error) {
+	if name == "" {
+		return 0, fmt.Errorf("product_name is required")
+	}
+
+	key := strings.ToLower(name)
+	found, ok := sess.products[key]
+	if !ok {
+		found.id, found.err = sess.importer.store.FindProductID(ctx, name)
+		sess.products[key] = found
+	}
+
+	switch {
+	case found.err == nil:
+		return found.id, nil
+	case errors.Is(found.err, storage.ErrProductNotFound):
+		return 0, fmt.Errorf("product %q not found", name)
+	case errors.Is(found.err, storage.ErrAmbiguousMatch):
+		return 0, fmt.Errorf("product name %q matches several products", name)
+	default:
+		return 0, &storeError{err: found.err}
+	}
+}
+
+func (sess *session) addError(line int, ref, message string) {
+	if sess.opts.MaxErrors > 0 && len(sess.report.Errors) >= sess.opts.MaxErrors {
+		sess.report.ErrorsTruncated = true
+		return
+	}
+	sess.report.Errors = append(sess.report.Errors, RowError{Line: line, OrderRef: ref, Error: message})
+}
+
+func (sess *session) track(date time.Time) {
+	if sess.first.IsZero() || date.Before(sess.first) {
+		sess.first = date
+	}
+	if date.After(sess.last) {
+		sess.last = date
+	}
+}
+
+// invalidate — сбросить кэш аналитики за период загруженных заказов
+func (sess *session) invalidate() {
+	if sess.importer.analytics == nil || sess.opts.DryRun || sess.first.IsZero() {
+		return
+	}
+	sess.importer.analytics.InvalidateRange(sess.first, sess.last)
+}
+
+func parseDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid order_date %q, use YYYY-MM-DD or RFC 3339", value)
+}
@@ -0,0 +1,422 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"salesTracker/internal/storage"
+	"salesTracker/internal/storage/postgresql"
+)
+
+// fakeStore — хранилище в памяти: заказ с уже записанным order_ref
+// пропускается, пачка и прогресс задания пишутся вместе, как в транзакции
+type fakeStore struct {
+	customers map[string]int // email или имя
+	products  map[string]int
+
+	// failBatch — номер вызова ImportOrders (с 1), который завершится сбоем базы
+	failBatch int
+	batches   int
+
+	job      postgresql.ImportJob
+	finished []string
+	refs     map[string]bool
+	orders   []postgresql.ImportOrder
+	lookups  int
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		customers: map[string]int{"anna@example.com": 1, "Борис Петров": 2, "Иван Иванов": storageAmbiguous},
+		products:  map[string]int{"Чайник": 10, "Кружка": 11},
+		refs:      map[string]bool{},
+	}
+}
+
+// storageAmbiguous — имя, под которым в fakeStore несколько покупателей
+const storageAmbiguous = -1
+
+func (f *fakeStore) CreateImportJob(ctx context.Context, source, format string) (*postgresql.ImportJob, error) {
+	f.job = postgresql.ImportJob{JobID: 7, Source: source, Format: format, Status: postgresql.ImportRunning}
+	job := f.job
+	return &job, nil
+}
+
+func (f *fakeStore) ResumeImportJob(ctx context.Context, id int) (*postgresql.ImportJob, error) {
+	if id != f.job.JobID {
+		return nil, storage.ErrImportJobNotFound
+	}
+	job := f.job
+	return &job, nil
+}
+
+func (f *fakeStore) FinishImportJob(ctx context.Context, id int, status, message string) error {
+	f.job.Status = status
+	f.finished = append(f.finished, status)
+	return nil
+}
+
+func (f *fakeStore) FindCustomerID(ctx context.Context, email, name string) (int, error) {
+	f.lookups++
+	key := email
+	if key == "" {
+		key = name
+	}
+	switch id, ok := f.customers[key]; {
+	case !ok:
+		return 0, storage.ErrCustomerNotFound
+	case id == storageAmbiguous:
+		return 0, storage.ErrAmbiguousMatch
+	default:
+		return id, nil
+	}
+}
+
+func (f *fakeStore) FindProductID(ctx context.Context, name string) (int, error) {
+	f.lookups++
+	if id, ok := f.products[name]; ok {
+		return id, nil
+	}
+	return 0, storage.ErrProductNotFound
+}
+
+func (f *fakeStore) ImportOrders(ctx context.Context, b postgresql.ImportBatch, dryRun bool) ([]postgresql.ImportOutcome, error) {
+	f.batches++
+	if f.batches == f.failBatch {
+		return nil, errors.New("storage.postgresql.ImportOrders: connection reset")
+	}
+
+	outcomes := make([]postgresql.ImportOutcome, len(b.Orders))
+	refs := map[string]bool{}
+	for i, order := range b.Orders {
+		outcomes[i].Line = order.Line
+		if order.ExternalRef != "" && (f.refs[order.ExternalRef] || refs[order.ExternalRef]) {
+			outcomes[i].Skipped = true
+			continue
+		}
+		refs[order.ExternalRef] = true
+		outcomes[i].OrderID = len(f.orders) + i + 1
+	}
+	if dryRun {
+		return outcomes, nil
+	}
+
+	for i, order := range b.Orders {
+		if !outcomes[i].Skipped {
+			f.orders = append(f.orders, order)
+			f.refs[order.ExternalRef] = true
+		}
+	}
+	f.job.LastLine = b.LastLine
+	return outcomes, nil
+}
+
+func readAll(t *testing.T, format, data string) []*orderRecord {
+	t.Helper()
+
+	reader, err := newReader(format, strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("newReader() error = %v", err)
+	}
+	var records []*orderRecord
+	for {
+		record, err := reader.next()
+		if errors.Is(err, io.EOF) {
+			return records
+		}
+		if err != nil {
+			t.Fatalf("next() error = %v", err)
+		}
+		records = append(records, record)
+	}
+}
+
+// summary — заказ как "ref:первая-последняя строка:позиций" или "ошибка"
+func summary(records []*orderRecord) []string {
+	var out []string
+	for _, r := range records {
+		if r.err != nil {
+			out = append(out, "error: "+r.err.Error())
+			continue
+		}
+		out = append(out, r.OrderRef+":"+strconv.Itoa(r.line)+"-"+strconv.Itoa(r.lastLine)+":"+strconv.Itoa(len(r.Items)))
+	}
+	return out
+}
+
+func TestCSVGrouping(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{
+			name: "consecutive rows of one order",
+			data: "order_ref,order_date,product_name,quantity,price\n" +
+				"A-1,2024-01-05,Чайник,1,1500\n" +
+				"A-1,2024-01-05,Кружка,2,300\n" +
+				"A-2,2024-01-06,Кружка,1,300\n",
+			want: []string{"A-1:2-3:2", "A-2:4-4:1"},
+		},
+		{
+			// без order_ref строки не склеиваются
+			name: "rows without order_ref",
+			data: "order_date,product_name,quantity,price\n" +
+				"2024-01-05,Чайник,1,1500\n" +
+				"2024-01-05,Чайник,1,1500\n",
+			want: []string{":2-2:1", ":3-3:1"},
+		},
+		{
+			name: "any column order, BOM and spaces in header",
+			data: "\ufeffPrice, Quantity ,product_name,order_date,order_ref\n" +
+				"1500,1,Чайник,2024-01-05,A-1\n",
+			want: []string{"A-1:2-2:1"},
+		},
+		{
+			name: "quoted field spans lines",
+			data: "order_ref,order_date,product_name,quantity,price\n" +
+				"A-1,2024-01-05,\"Чайник\nсо свистком\",1,1500\n" +
+				"A-2,2024-01-06,Кружка,1,300\n",
+			want: []string{"A-1:2-2:1", "A-2:4-4:1"},
+		},
+		{
+			// ошибка одной строки отклоняет весь заказ, а не одну позицию
+			name: "invalid row fails its order",
+			data: "order_ref,order_date,product_name,quantity,price\n" +
+				"A-1,2024-01-05,Чайник,1,1500\n" +
+				"A-1,2024-01-05,Кружка,два,300\n" +
+				"A-2,2024-01-06,Кружка,1,300\n",
+			want: []string{`error: line 3: invalid quantity "два"`, "A-2:4-4:1"},
+		},
+		{
+			name: "malformed CSV row is reported on its own",
+			data: "order_ref,order_date,product_name,quantity,price\n" +
+				"A-1,2024-01-05,Чай\"ник,1,1500\n" +
+				"A-2,2024-01-06,Кружка,1,300\n",
+			want: []string{`error: line 2: bare " in non-quoted-field`, "A-2:3-3:1"},
+		},
+		{
+			name: "order split across the file",
+			data: "order_ref,order_date,product_name,quantity,price\n" +
+				"A-1,2024-01-05,Чайник,1,1500\n" +
+				"A-2,2024-01-06,Кружка,1,300\n" +
+				"A-1,2024-01-05,Кружка,2,300\n",
+			want: []string{"A-1:2-2:1", "A-2:3-3:1",
+				`error: line 4: order_ref "A-1" already used at line 2, rows of one order must be consecutive`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := summary(readAll(t, FormatCSV, tt.data)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("orders = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSVHeader(t *testing.T) {
+	for name, data := range map[string]string{
+		"empty file":     "",
+		"missing column": "order_ref,order_date,product_name,quantity\nA-1,2024-01-05,Чайник,1\n",
+	} {
+		if _, err := newReader(FormatCSV, strings.NewReader(data)); err == nil {
+			t.Errorf("%s: newReader() error = nil", name)
+		}
+	}
+	if _, err := newReader("xlsx", strings.NewReader("")); err == nil {
+		t.Error("newReader(xlsx) error = nil")
+	}
+}
+
+func TestJSONLReader(t *testing.T) {
+	data := `{"order_ref":"A-1","order_date":"2024-01-05","items":[{"product_name":"Чайник","quantity":1,"price":1500}]}
+
+{"order_ref":"A-2","order_date":"2024-01-06","itmes":[]}
+{"order_ref":"A-3","order_date":"2024-01-07","items":[{"product_name":"Кружка","quantity":2,"price":300},{"product_name":"Чайник","quantity":1,"price":1500}]}
+`
+	got := summary(readAll(t, FormatJSONL, data))
+	want := []string{"A-1:1-1:1", `error: line 3: invalid JSON: json: unknown field "itmes"`, "A-3:4-4:2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("orders = %q, want %q", got, want)
+	}
+}
+
+const ordersCSV = "order_ref,order_date,customer_email,customer_name,product_name,quantity,price,discount,currency\n" +
+	"A-1,2024-01-05,anna@example.com,,Чайник,1,1500,,\n" +
+	"A-1,2024-01-05,anna@example.com,,Кружка,2,300,10,\n" +
+	"A-2,2024-01-06,,Борис  Петров,Кружка,1,300,,usd\n" +
+	"A-3,2024-01-07,nobody@example.com,,Кружка,1,300,,\n" +
+	"A-4,2024-01-08,,Иван Иванов,Кружка,1,300,,\n" +
+	"A-5,2024-01-09,anna@example.com,,Самовар,1,9000,,\n" +
+	"A-6,05.01.2024,anna@example.com,,Кружка,1,300,,\n" +
+	"A-7,2024-01-10,anna@example.com,,Кружка,1,300,,RUBLE\n" +
+	"A-8,2024-01-11,anna@example.com,,Кружка,0,300,,\n" +
+	"A-9,2024-01-12,anna@example.com,,Кружка,1,300,,\n"
+
+type invalidated struct {
+	start, end time.Time
+}
+
+func (i *invalidated) InvalidateRange(start, end time.Time) { *i = invalidated{start, end} }
+
+func TestRunReport(t *testing.T) {
+	store := newFakeStore()
+	var cache invalidated
+
+	report, err := New(store, &cache).Run(context.Background(), strings.NewReader(ordersCSV),
+		Options{Source: "orders.csv", Format: FormatCSV, BatchSize: 3})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	wantErrors := []RowError{
+		{Line: 5, OrderRef: "A-3", Error: `customer with email "nobody@example.com" not found`},
+		{Line: 6, OrderRef: "A-4", Error: `customer name "Иван Иванов" matches several customers, use customer_email`},
+		{Line: 7, OrderRef: "A-5", Error: `product "Самовар" not found`},
+		{Line: 8, OrderRef: "A-6", Error: `invalid order_date "05.01.2024", use YYYY-MM-DD or RFC 3339`},
+		{Line: 9, OrderRef: "A-7", Error: `invalid currency "RUBLE", use a 3-letter ISO 4217 code`},
+		{Line: 10, OrderRef: "A-8", Error: `product "Кружка": quantity must be positive`},
+	}
+	if !reflect.DeepEqual(report.Errors, wantErrors) {
+		t.Errorf("errors = %+v, want %+v", report.Errors, wantErrors)
+	}
+	if report.JobID != 7 || report.Orders != 9 || report.Imported != 3 || report.Failed != 6 || report.Skipped != 0 {
+		t.Errorf("report = %+v", report)
+	}
+	if !reflect.DeepEqual(store.finished, []string{postgresql.ImportCompleted}) || store.job.LastLine != 11 {
+		t.Errorf("job = %+v, finished %v", store.job, store.finished)
+	}
+
+	first := store.orders[0]
+	if first.ExternalRef != "A-1" || first.CustomerID != 1 || first.Currency != postgresql.BaseCurrency || len(first.Items) != 2 ||
+		first.Items[1] != (postgresql.ImportOrderItem{ProductID: 11, Quantity: 2, Price: 300, Discount: 10}) {
+		t.Errorf("first order = %+v", first)
+	}
+	// имя покупателя сравнивается без лишних пробелов, валюта — в верхнем регистре
+	if second := store.orders[1]; second.CustomerID != 2 || second.Currency != "USD" {
+		t.Errorf("second order = %+v", second)
+	}
+	// покупатели и товары ищутся один раз на загрузку
+	if store.lookups != 7 {
+		t.Errorf("lookups = %d, want 7", store.lookups)
+	}
+	if cache.start != time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC) || cache.end != time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC) {
+		t.Errorf("invalidated %v..%v, want the imported orders' dates", cache.start, cache.end)
+	}
+}
+
+func TestRunResume(t *testing.T) {
+	store := newFakeStore()
+	store.failBatch = 2
+	im := New(store, nil)
+	opts := Options{Source: "orders.csv", Format: FormatCSV, BatchSize: 3}
+
+	// вторая пачка падает: в базе остается первая пачка и прогресс до ее конца
+	report, err := im.Run(context.Background(), strings.NewReader(ordersCSV), opts)
+	if err == nil {
+		t.Fatal("Run() error = nil after a storage failure")
+	}
+	if report.Imported != 2 || store.job.LastLine != 5 || store.job.Status != postgresql.ImportFailed {
+		t.Fatalf("after failure: report = %+v, job = %+v", report, store.job)
+	}
+
+	opts.ResumeJobID = report.JobID
+	report, err = im.Run(context.Background(), strings.NewReader(ordersCSV), opts)
+	if err != nil {
+		t.Fatalf("resumed Run() error = %v", err)
+	}
+	// A-1..A-3 уже прочитаны: продолжение начинается с A-4
+	if report.ResumedAfter != 5 || report.Orders != 6 || report.Imported != 1 || report.Failed != 5 {
+		t.Errorf("resumed report = %+v", report)
+	}
+	var refs []string
+	for _, order := range store.orders {
+		refs = append(refs, order.ExternalRef)
+	}
+	if want := []string{"A-1", "A-2", "A-9"}; !reflect.DeepEqual(refs, want) {
+		t.Errorf("orders in store = %v, want %v", refs, want)
+	}
+
+	// повторная загрузка всего файла ничего не дублирует
+	report, err = im.Run(context.Background(), strings.NewReader(ordersCSV), Options{Format: FormatCSV})
+	if err != nil {
+		t.Fatalf("repeated Run() error = %v", err)
+	}
+	if report.Imported != 0 || report.Skipped != 3 || len(store.orders) != 3 {
+		t.Errorf("repeated report = %+v, %d orders in store", report, len(store.orders))
+	}
+}
+
+func TestRunResumeRejects(t *testing.T) {
+	store := newFakeStore()
+	im := New(store, nil)
+	if _, err := im.Run(context.Background(), strings.NewReader(ordersCSV), Options{Format: FormatCSV}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr error
+	}{
+		{name: "other format", opts: Options{Format: FormatJSONL, ResumeJobID: 7}, wantErr: ErrInvalidInput},
+		{name: "dry run", opts: Options{Format: FormatCSV, ResumeJobID: 7, DryRun: true}, wantErr: ErrInvalidInput},
+		{name: "unknown job", opts: Options{Format: FormatCSV, ResumeJobID: 8}, wantErr: storage.ErrImportJobNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := im.Run(context.Background(), strings.NewReader(ordersCSV), tt.opts); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Run() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunDryRun(t *testing.T) {
+	store := newFakeStore()
+	var cache invalidated
+
+	report, err := New(store, &cache).Run(context.Background(), strings.NewReader(ordersCSV),
+		Options{Format: FormatCSV, DryRun: true, MaxErrors: 2})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !report.DryRun || report.JobID != 0 || report.Imported != 3 || report.Failed != 6 {
+		t.Errorf("report = %+v", report)
+	}
+	// счетчики считают все ошибки, отчет обрезан
+	if len(report.Errors) != 2 || !report.ErrorsTruncated {
+		t.Errorf("errors = %d, truncated %v, want 2 and true", len(report.Errors), report.ErrorsTruncated)
+	}
+	if len(store.orders) != 0 || store.finished != nil || !cache.start.IsZero() {
+		t.Errorf("dry run wrote: %d orders, finished %v, invalidated %v", len(store.orders), store.finished, cache.start)
+	}
+}
+
+func TestRunLookupFailure(t *testing.T) {
+	store := &failingLookups{fakeStore: newFakeStore()}
+
+	report, err := New(store, nil).Run(context.Background(), strings.NewReader(ordersCSV), Options{Format: FormatCSV})
+	if err == nil {
+		t.Fatal("Run() error = nil when the customer lookup fails")
+	}
+	// сбой базы — не ошибка строки: заказ не попадает в отчет как отклоненный
+	if report.Failed != 0 || len(store.orders) != 0 || store.job.Status != postgresql.ImportFailed {
+		t.Errorf("report = %+v, job = %+v", report, store.job)
+	}
+}
+
+type failingLookups struct {
+	*fakeStore
+}
+
+func (f *failingLookups) FindCustomerID(ctx context.Context, email, name string) (int, error) {
+	return 0, errors.New("storage.postgresql.FindCustomerID: connection refused")
+}
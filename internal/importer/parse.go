@@ -0,0 +1,251 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ====================================================================
+// PARSE - Чтение заказов из CSV и JSONL
+// ====================================================================
+
+// orderRecord — заказ в том виде, в каком он записан в файле. line и lastLine —
+// первая и последняя строки файла заказа, err — ошибка разбора его строк.
+type orderRecord struct {
+	OrderRef      string       `json:"order_ref"`
+	OrderDate     string       `json:"order_date"`
+	CustomerEmail string       `json:"customer_email"`
+	CustomerName  string       `json:"customer_name"`
+	Status        string       `json:"status"`
+	PaymentMethod string       `json:"payment_method"`
+	Currency      string       `json:"currency"`
+	Items         []itemRecord `json:"items"`
+
+	line     int
+	lastLine int
+	err      error
+}
+
+// itemRecord — позиция заказа в файле; discount — скидка в процентах
+type itemRecord struct {
+	ProductName string  `json:"product_name"`
+	Quantity    int     `json:"quantity"`
+	Price       float64 `json:"price"`
+	Discount    float64 `json:"discount"`
+}
+
+// recordReader — источник заказов; io.EOF — файл прочитан. Ошибка разбора
+// одного заказа возвращается в orderRecord.err, ошибка чтения файла — вторым значением.
+type recordReader interface {
+	next() (*orderRecord, error)
+}
+
+func newReader(format string, r io.Reader) (recordReader, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVReader(r)
+	case FormatJSONL:
+		return newJSONLReader(r), nil
+	default:
+		return nil, fmt.Errorf("unsupported format %q, use %s or %s", format, FormatCSV, FormatJSONL)
+	}
+}
+
+// ====================================================================
+// CSV - Строка на позицию, позиции заказа идут подряд с одним order_ref
+// ====================================================================
+
+// csvColumns — колонки CSV; обязательна строка заголовка, порядок колонок любой
+var csvColumns = []string{
+	"order_ref", "order_date", "customer_email", "customer_name", "status", "payment_method", "currency",
+	"product_name", "quantity", "price", "discount",
+}
+
+var csvRequired = []string{"order_date", "product_name", "quantity", "price"}
+
+type csvReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+	// pending — первая строка следующего заказа, прочитанная при поиске конца текущего
+	pending *orderRecord
+	// seen — первая строка каждого встреченного order_ref
+	seen map[string]int
+	done bool
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("empty file, expected a header line")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, name := range csvRequired {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing column %q, expected columns: %s", name, strings.Join(csvColumns, ","))
+		}
+	}
+
+	return &csvReader{reader: reader, columns: columns, seen: map[string]int{}}, nil
+}
+
+func (c *csvReader) next() (*orderRecord, error) {
+	order := c.pending
+	c.pending = nil
+	if order == nil {
+		if c.done {
+			return nil, io.EOF
+		}
+		row, err := c.readRow()
+		if err != nil {
+			return nil, err
+		}
+		order = row
+	}
+
+	// без order_ref каждая строка — отдельный заказ
+	for order.OrderRef != "" && !c.done {
+		row, err := c.readRow()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if row.OrderRef != order.OrderRef {
+			c.pending = row
+			break
+		}
+
+		order.lastLine = row.line
+		order.Items = append(order.Items, row.Items...)
+		if order.err == nil && row.err != nil {
+			order.err = row.err
+		}
+	}
+
+	// строки заказа, разнесенные по файлу, иначе стали бы вторым заказом
+	// с тем же номером, и база молча пропустила бы его позиции
+	if order.OrderRef != "" {
+		if first, ok := c.seen[order.OrderRef]; ok {
+			order.err = fmt.Errorf("line %d: order_ref %q already used at line %d, rows of one order must be consecutive",
+				order.line, order.OrderRef, first)
+		} else {
+			c.seen[order.OrderRef] = order.line
+		}
+	}
+
+	return order, nil
+}
+
+// readRow — строка CSV как заказ из одной позиции
+func (c *csvReader) readRow() (*orderRecord, error) {
+	record, err := c.reader.Read()
+	if errors.Is(err, io.EOF) {
+		c.done = true
+		return nil, io.EOF
+	}
+
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		// строка с ошибкой разбора становится отдельным заказом, чтобы попасть в отчет
+		line := parseErr.StartLine
+		return &orderRecord{line: line, lastLine: line, err: fmt.Errorf("line %d: %w", line, parseErr.Err)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	line, _ := c.reader.FieldPos(0)
+	row := &orderRecord{line: line, lastLine: line}
+
+	field := func(name string) string {
+		if i, ok := c.columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	row.OrderRef = field("order_ref")
+	row.OrderDate = field("order_date")
+	row.CustomerEmail = field("customer_email")
+	row.CustomerName = field("customer_name")
+	row.Status = field("status")
+	row.PaymentMethod = field("payment_method")
+	row.Currency = field("currency")
+
+	item := itemRecord{ProductName: field("product_name")}
+	if item.Quantity, err = strconv.Atoi(field("quantity")); err != nil {
+		row.err = fmt.Errorf("line %d: invalid quantity %q", line, field("quantity"))
+	}
+	if item.Price, err = strconv.ParseFloat(field("price"), 64); err != nil && row.err == nil {
+		row.err = fmt.Errorf("line %d: invalid price %q", line, field("price"))
+	}
+	if discount := field("discount"); discount != "" {
+		if item.Discount, err = strconv.ParseFloat(discount, 64); err != nil && row.err == nil {
+			row.err = fmt.Errorf("line %d: invalid discount %q", line, discount)
+		}
+	}
+	row.Items = []itemRecord{item}
+
+	return row, nil
+}
+
+// ====================================================================
+// JSONL - Строка на заказ с массивом items
+// ====================================================================
+
+// maxLineSize — предел длины строки JSONL
+const maxLineSize = 1 << 20
+
+type jsonlReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newJSONLReader(r io.Reader) *jsonlReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	return &jsonlReader{scanner: scanner}
+}
+
+func (j *jsonlReader) next() (*orderRecord, error) {
+	for j.scanner.Scan() {
+		j.line++
+		data := bytes.TrimSpace(j.scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		order := &orderRecord{}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(order); err != nil {
+			order = &orderRecord{err: fmt.Errorf("line %d: invalid JSON: %v", j.line, err)}
+		}
+		order.line, order.lastLine = j.line, j.line
+		return order, nil
+	}
+
+	if err := j.scanner.Err(); err != nil {
+		return nil, fmt.Errorf("line %d: %w", j.line+1, err)
+	}
+	return nil, io.EOF
+}
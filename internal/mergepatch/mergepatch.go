@@ -0,0 +1,126 @@
+package mergepatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+)
+
+// ====================================================================
+// MERGE PATCH - Частичное обновление по RFC 7396
+// ====================================================================
+
+// ContentType — тип тела PATCH-запроса
+const ContentType = "application/merge-patch+json"
+
+// maxBodySize — предел размера патча
+const maxBodySize = 1 << 20
+
+var (
+	// ErrUnsupportedMediaType — тело PATCH не merge-patch+json (415)
+	ErrUnsupportedMediaType = errors.New("unsupported media type, use " + ContentType)
+	// ErrInvalidPatch — патч не разбирается или не подходит к сущности (400)
+	ErrInvalidPatch = errors.New("invalid merge patch")
+)
+
+// Decode — применить merge patch из тела запроса к doc (указатель на DTO
+// с текущим состоянием сущности). Поля, отсутствующие в патче, сохраняются,
+// null сбрасывает поле в нулевое значение, неизвестные поля — ошибка.
+// Возвращает отсортированные имена полей, значение которых изменилось.
+func Decode(r *http.Request, doc any) ([]string, error) {
+	// application/json принимается как синоним для клиентов, которые не умеют задавать тип
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (mediaType != ContentType && mediaType != "application/json") {
+		return nil, ErrUnsupportedMediaType
+	}
+
+	patch, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	return Apply(doc, patch)
+}
+
+// Apply — применить merge patch к doc, см. Decode
+func Apply(doc any, patch []byte) ([]string, error) {
+	var changes map[string]any
+	if err := unmarshal(patch, &changes); err != nil || changes == nil {
+		return nil, fmt.Errorf("%w: body must be a JSON object", ErrInvalidPatch)
+	}
+
+	current, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var original map[string]any
+	if err := unmarshal(current, &original); err != nil {
+		return nil, err
+	}
+
+	var merged map[string]any
+	if err := unmarshal(current, &merged); err != nil {
+		return nil, err
+	}
+	merged = merge(merged, changes).(map[string]any)
+
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+
+	// поля, удаленные патчем, должны стать нулевыми, а не остаться прежними
+	target := reflect.ValueOf(doc).Elem()
+	target.Set(reflect.Zero(target.Type()))
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	var changed []string
+	for field := range changes {
+		if !reflect.DeepEqual(original[field], merged[field]) {
+			changed = append(changed, field)
+		}
+	}
+	sort.Strings(changed)
+
+	return changed, nil
+}
+
+// merge — алгоритм MergePatch из RFC 7396
+func merge(target, patch any) any {
+	fields, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	doc, ok := target.(map[string]any)
+	if !ok {
+		doc = map[string]any{}
+	}
+	for name, value := range fields {
+		if value == nil {
+			delete(doc, name)
+			continue
+		}
+		doc[name] = merge(doc[name], value)
+	}
+	return doc
+}
+
+// unmarshal — числа сохраняются как json.Number, чтобы сравнение
+// значений не зависело от округления float64
+func unmarshal(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
@@ -0,0 +1,170 @@
+package mergepatch
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testAttrs struct {
+	Color string `json:"color,omitempty"`
+	Size  string `json:"size,omitempty"`
+}
+
+type testDoc struct {
+	Name  string     `json:"name"`
+	SKU   string     `json:"sku,omitempty"`
+	Price float64    `json:"price"`
+	Tags  []string   `json:"tags,omitempty"`
+	Attrs *testAttrs `json:"attrs,omitempty"`
+}
+
+func newTestDoc() testDoc {
+	return testDoc{
+		Name:  "Coffee",
+		SKU:   "CF-1",
+		Price: 10,
+		Tags:  []string{"hot", "drink"},
+		Attrs: &testAttrs{Color: "black", Size: "L"},
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name        string
+		patch       string
+		want        func(d *testDoc)
+		wantChanged []string
+		wantErr     error
+	}{
+		{name: "replace field", patch: `{"name":"Tea"}`,
+			want: func(d *testDoc) { d.Name = "Tea" }, wantChanged: []string{"name"}},
+		{name: "several fields sorted", patch: `{"price":12.5,"name":"Tea"}`,
+			want: func(d *testDoc) { d.Name, d.Price = "Tea", 12.5 }, wantChanged: []string{"name", "price"}},
+		{name: "same value not changed", patch: `{"name":"Coffee","price":10}`,
+			want: func(d *testDoc) {}},
+		{name: "empty patch", patch: `{}`,
+			want: func(d *testDoc) {}},
+		{name: "null resets field", patch: `{"sku":null}`,
+			want: func(d *testDoc) { d.SKU = "" }, wantChanged: []string{"sku"}},
+		{name: "nested merge keeps siblings", patch: `{"attrs":{"color":"white"}}`,
+			want: func(d *testDoc) { d.Attrs = &testAttrs{Color: "white", Size: "L"} }, wantChanged: []string{"attrs"}},
+		{name: "nested null removes member", patch: `{"attrs":{"size":null}}`,
+			want: func(d *testDoc) { d.Attrs = &testAttrs{Color: "black"} }, wantChanged: []string{"attrs"}},
+		{name: "null removes object", patch: `{"attrs":null}`,
+			want: func(d *testDoc) { d.Attrs = nil }, wantChanged: []string{"attrs"}},
+		{name: "array replaced as a whole", patch: `{"tags":["cold"]}`,
+			want: func(d *testDoc) { d.Tags = []string{"cold"} }, wantChanged: []string{"tags"}},
+
+		{name: "array body", patch: `[{"name":"Tea"}]`, wantErr: ErrInvalidPatch},
+		{name: "null body", patch: `null`, wantErr: ErrInvalidPatch},
+		{name: "scalar body", patch: `"Tea"`, wantErr: ErrInvalidPatch},
+		{name: "not JSON", patch: `{name:}`, wantErr: ErrInvalidPatch},
+		{name: "unknown field", patch: `{"colour":"red"}`, wantErr: ErrInvalidPatch},
+		{name: "wrong type", patch: `{"price":"free"}`, wantErr: ErrInvalidPatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := newTestDoc()
+			changed, err := Apply(&doc, []byte(tt.patch))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Apply(%s) error = %v, want %v", tt.patch, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply(%s) error = %v", tt.patch, err)
+			}
+
+			want := newTestDoc()
+			tt.want(&want)
+			if !reflect.DeepEqual(doc, want) {
+				t.Errorf("Apply(%s) doc = %+v, want %+v", tt.patch, doc, want)
+			}
+			if !reflect.DeepEqual(changed, tt.wantChanged) {
+				t.Errorf("Apply(%s) changed = %v, want %v", tt.patch, changed, tt.wantChanged)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	// примеры из приложения A RFC 7396
+	tests := []struct {
+		name          string
+		target, patch any
+		want          any
+	}{
+		{name: "replace member",
+			target: map[string]any{"a": "b"}, patch: map[string]any{"a": "c"},
+			want: map[string]any{"a": "c"}},
+		{name: "add member",
+			target: map[string]any{"a": "b"}, patch: map[string]any{"b": "c"},
+			want: map[string]any{"a": "b", "b": "c"}},
+		{name: "remove member",
+			target: map[string]any{"a": "b", "b": "c"}, patch: map[string]any{"a": nil},
+			want: map[string]any{"b": "c"}},
+		{name: "array replaced",
+			target: map[string]any{"a": []any{"b"}}, patch: map[string]any{"a": "c"},
+			want: map[string]any{"a": "c"}},
+		{name: "object over scalar",
+			target: map[string]any{"a": "c"}, patch: map[string]any{"a": map[string]any{"b": "c"}},
+			want: map[string]any{"a": map[string]any{"b": "c"}}},
+		{name: "nested null dropped on new object",
+			target: map[string]any{}, patch: map[string]any{"a": map[string]any{"bb": map[string]any{"ccc": nil}}},
+			want: map[string]any{"a": map[string]any{"bb": map[string]any{}}}},
+		{name: "non-object patch replaces target",
+			target: map[string]any{"a": "foo"}, patch: "bar",
+			want: "bar"},
+		{name: "scalar target becomes object",
+			target: []any{1, 2}, patch: map[string]any{"a": "b", "c": nil},
+			want: map[string]any{"a": "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := merge(tt.target, tt.patch); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("merge() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		wantErr     error
+	}{
+		{name: "merge patch", contentType: ContentType},
+		{name: "merge patch with charset", contentType: ContentType + "; charset=utf-8"},
+		{name: "plain json", contentType: "application/json"},
+
+		{name: "json patch", contentType: "application/json-patch+json", wantErr: ErrUnsupportedMediaType},
+		{name: "form", contentType: "application/x-www-form-urlencoded", wantErr: ErrUnsupportedMediaType},
+		{name: "missing", contentType: "", wantErr: ErrUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPatch, "/api/v1/products/1", strings.NewReader(`{"name":"Tea"}`))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+
+			doc := newTestDoc()
+			_, err := Decode(r, &doc)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Decode() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && doc.Name != "Tea" {
+				t.Errorf("Decode() name = %q, want %q", doc.Name, "Tea")
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"salesTracker/internal/storage/postgresql"
+)
+
+// ====================================================================
+// DATABASE - Пул соединений и операции хранилища
+// ====================================================================
+
+// QueryBuckets — границы длительности операций хранилища: отчеты идут
+// заметно дольше запросов CRUD
+var QueryBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// Queries — длительность операций хранилища, подключается в Storage.Observe
+type Queries struct {
+	duration *prometheus.HistogramVec
+}
+
+func NewQueries(r prometheus.Registerer) *Queries {
+	return &Queries{
+		duration: promauto.With(r).NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "salestracker_storage_operation_duration_seconds",
+			Help:    "Storage operation duration by operation name and class (crud or analytics).",
+			Buckets: QueryBuckets,
+		}, []string{"op", "class"}),
+	}
+}
+
+// Observe — учесть операцию op класса class
+func (q *Queries) Observe(op, class string, elapsed time.Duration) {
+	q.duration.WithLabelValues(op, class).Observe(elapsed.Seconds())
+}
+
+// DBStats — состояние пула соединений из sql.DBStats на момент опроса
+// (go_sql_* с меткой db_name="salestracker")
+func DBStats(db *sql.DB) prometheus.Collector {
+	return collectors.NewDBStatsCollector(db, "salestracker")
+}
+
+// ====================================================================
+// BUSINESS - Показатели продаж
+// ====================================================================
+
+// Business — показатели продаж: созданные заказы считаются при записи,
+// выручка читается из дневной сводки при опросе
+type Business struct {
+	ordersCreated *prometheus.CounterVec
+}
+
+func NewBusiness(r prometheus.Registerer, storage *postgresql.Storage) *Business {
+	r.MustRegister(&revenueCollector{storage: storage})
+	return &Business{
+		ordersCreated: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Name: "salestracker_orders_created_total",
+			Help: "Orders created through the API or import by this instance, by status and currency.",
+		}, []string{"status", "currency"}),
+	}
+}
+
+// OrderCreated — учесть созданный заказ, подключается в Storage.OrderCreated
+func (b *Business) OrderCreated(status, currency string) {
+	b.ordersCreated.WithLabelValues(status, currency).Inc()
+}
+
+var revenueDesc = prometheus.NewDesc("salestracker_revenue_total",
+	"Total amount of orders that are not deleted, by currency, from the daily sales rollup.",
+	[]string{"currency"}, nil)
+
+// revenueCollector — выручка по валютам из дневной сводки на момент опроса
+type revenueCollector struct {
+	storage *postgresql.Storage
+}
+
+func (c *revenueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- revenueDesc
+}
+
+func (c *revenueCollector) Collect(ch chan<- prometheus.Metric) {
+	// время запроса ограничивает QueryTimeout хранилища
+	totals, err := c.storage.RevenueTotals(context.Background())
+	if err != nil {
+		// ошибку пишет в журнал обработчик, остальные метрики отдаются
+		ch <- prometheus.NewInvalidMetric(revenueDesc, err)
+		return
+	}
+
+	for _, t := range totals {
+		ch <- prometheus.MustNewConstMetric(revenueDesc, prometheus.CounterValue, t.Amount, t.Currency)
+	}
+}
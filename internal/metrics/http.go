@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ====================================================================
+// HTTP - Запросы по шаблонам маршрутов chi
+// ====================================================================
+
+// unmatchedRoute — метка запросов, не попавших ни в один маршрут: путь
+// в метку не пишется, чтобы сканеры не плодили значения
+const unmatchedRoute = "unmatched"
+
+// HTTP — счетчик и гистограмма длительности запросов
+type HTTP struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+func NewHTTP(r prometheus.Registerer) *HTTP {
+	f := promauto.With(r)
+	return &HTTP{
+		requests: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "salestracker_http_requests_total",
+			Help: "HTTP requests by method, chi route pattern and status code.",
+		}, []string{"method", "route", "status"}),
+		duration: f.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "salestracker_http_request_duration_seconds",
+			Help:    "HTTP request latency by method and chi route pattern.",
+			Buckets: DefBuckets,
+		}, []string{"method", "route"}),
+	}
+}
+
+// Middleware — учитывает запрос после ответа, когда chi уже знает шаблон
+// маршрута ("/api/v1/orders/{id}"), а не конкретный путь
+func (m *HTTP) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		defer func() {
+			route := unmatchedRoute
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+			status := ww.Status()
+			p := recover()
+			switch {
+			case p != nil:
+				// ответ 500 запишет Recoverer выше по цепочке
+				status = http.StatusInternalServerError
+			case status == 0:
+				status = http.StatusOK
+			}
+
+			m.requests.WithLabelValues(r.Method, route, strconv.Itoa(status)).Inc()
+			m.duration.WithLabelValues(r.Method, route).Observe(time.Since(started).Seconds())
+
+			if p != nil {
+				panic(p)
+			}
+		}()
+
+		next.ServeHTTP(ww, r)
+	})
+}
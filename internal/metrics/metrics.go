@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// ====================================================================
+// REGISTRY - Реестры метрик Prometheus
+// ====================================================================
+
+// Метрики разделены на два реестра: служебные (HTTP, хранилище, пул
+// соединений, рантайм Go) отдаются на /metrics без аутентификации, как
+// health, а показатели продаж — на /api/v1/metrics/business с правом metrics:read.
+
+// DefBuckets — границы гистограмм длительности в секундах
+var DefBuckets = prometheus.DefBuckets
+
+// NewRegistry — реестр служебных метрик с метриками процесса и рантайма Go
+func NewRegistry() *prometheus.Registry {
+	r := prometheus.NewRegistry()
+	r.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+	return r
+}
+
+// Handler — GET метрик реестра в формате Prometheus. Сбой одного сборщика
+// не мешает остальным: его метрики пропускаются, ошибка пишется в журнал.
+func Handler(g prometheus.Gatherer) http.Handler {
+	return promhttp.HandlerFor(g, promhttp.HandlerOpts{
+		ErrorLog:      log.Default(),
+		ErrorHandling: promhttp.ContinueOnError,
+	})
+}
@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"salesTracker/internal/storage/postgresql"
+)
+
+func TestHTTPMiddleware(t *testing.T) {
+	m := NewHTTP(prometheus.NewRegistry())
+
+	r := chi.NewRouter()
+	r.Use(m.Middleware)
+	r.Get("/orders/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if chi.URLParam(r, "id") == "0" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	r.Get("/panic", func(w http.ResponseWriter, r *http.Request) { panic("boom") })
+
+	for _, path := range []string{"/orders/1", "/orders/2", "/orders/0", "/wp-login.php", "/panic"} {
+		func() {
+			defer func() { recover() }()
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		}()
+	}
+
+	tests := []struct {
+		route  string
+		status string
+		want   float64
+	}{
+		// путь заказа в метку не попадает — только шаблон маршрута
+		{route: "/orders/{id}", status: "200", want: 2},
+		{route: "/orders/{id}", status: "404", want: 1},
+		{route: unmatchedRoute, status: "404", want: 1},
+		{route: "/panic", status: "500", want: 1},
+	}
+	for _, tt := range tests {
+		if got := testutil.ToFloat64(m.requests.WithLabelValues(http.MethodGet, tt.route, tt.status)); got != tt.want {
+			t.Errorf("requests{route=%q,status=%s} = %v, want %v", tt.route, tt.status, got, tt.want)
+		}
+	}
+	if got := testutil.CollectAndCount(m.duration); got != 3 {
+		t.Errorf("duration series = %d, want 3 (two routes and unmatched)", got)
+	}
+}
+
+func TestHandlerExposition(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	queries := NewQueries(reg)
+	queries.Observe("storage.postgresql.GetOrder", postgresql.ClassCRUD, 3*time.Millisecond)
+
+	w := httptest.NewRecorder()
+	Handler(reg).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q, want the text format", ct)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		"# TYPE salestracker_storage_operation_duration_seconds histogram",
+		`salestracker_storage_operation_duration_seconds_bucket{class="crud",op="storage.postgresql.GetOrder",le="0.005"} 1`,
+		`salestracker_storage_operation_duration_seconds_count{class="crud",op="storage.postgresql.GetOrder"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("exposition has no %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestBusiness(t *testing.T) {
+	// база недоступна: выручка не собирается, но счетчик заказов отдается
+	db, err := sql.Open("postgres", "host=127.0.0.1 port=1 sslmode=disable connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	reg := prometheus.NewRegistry()
+	b := NewBusiness(reg, &postgresql.Storage{DB: db, QueryTimeout: time.Second})
+	b.OrderCreated("completed", "RUB")
+	b.OrderCreated("completed", "RUB")
+	b.OrderCreated("pending", "USD")
+
+	if got := testutil.ToFloat64(b.ordersCreated.WithLabelValues("completed", "RUB")); got != 2 {
+		t.Errorf("orders_created{completed,RUB} = %v, want 2", got)
+	}
+
+	w := httptest.NewRecorder()
+	Handler(reg).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/metrics/business", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d with a failed collector", w.Code, http.StatusOK)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, `salestracker_orders_created_total{currency="USD",status="pending"} 1`) {
+		t.Errorf("exposition has no orders created:\n%s", body)
+	}
+	if strings.Contains(body, "salestracker_revenue_total{") {
+		t.Errorf("exposition has revenue without a database:\n%s", body)
+	}
+}
@@ -0,0 +1,502 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"salesTracker/migrations"
+)
+
+// ====================================================================
+// FAKE DATABASE - Драйвер database/sql с таблицей schema_migrations в памяти
+// ====================================================================
+
+// fakeDB — база для мигратора: транзакции применяют изменения schema_migrations
+// и скрипты только при Commit, pg_advisory_lock — сессионный лок одного соединения.
+// Скрипт с "FAIL" завершается ошибкой.
+type fakeDB struct {
+	lock chan struct{}
+
+	mu      sync.Mutex
+	table   bool
+	applied map[int]time.Time
+	scripts []string
+	hasData bool
+	// unlocked — скрипты, выполненные без лока
+	unlocked int
+}
+
+var (
+	fakeMu  sync.Mutex
+	fakeDBs = map[string]*fakeDB{}
+)
+
+func init() {
+	sql.Register("fakemigrate", fakeDriver{})
+}
+
+func openFake(t *testing.T) (*sql.DB, *fakeDB) {
+	t.Helper()
+
+	fake := &fakeDB{lock: make(chan struct{}, 1), applied: map[int]time.Time{}}
+	fakeMu.Lock()
+	fakeDBs[t.Name()] = fake
+	fakeMu.Unlock()
+
+	db, err := sql.Open("fakemigrate", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, fake
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fakeMu.Lock()
+	defer fakeMu.Unlock()
+	return &fakeConn{db: fakeDBs[name]}, nil
+}
+
+type fakeConn struct {
+	db     *fakeDB
+	locked bool
+	tx     *fakeTx
+}
+
+type fakeTx struct {
+	conn    *fakeConn
+	ops     []func()
+	scripts []string
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("fake: prepared statements are not supported")
+}
+
+func (c *fakeConn) Close() error {
+	// сессионный лок снимается вместе с соединением
+	if c.locked {
+		c.locked = false
+		<-c.db.lock
+	}
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.tx = &fakeTx{conn: c}
+	return c.tx, nil
+}
+
+func (tx *fakeTx) Commit() error {
+	db := tx.conn.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, op := range tx.ops {
+		op()
+	}
+	db.scripts = append(db.scripts, tx.scripts...)
+	tx.conn.tx = nil
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.conn.tx = nil
+	return nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	db := c.db
+	switch {
+	case strings.HasPrefix(query, "SELECT pg_advisory_lock"):
+		select {
+		case db.lock <- struct{}{}:
+			c.locked = true
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	case strings.HasPrefix(query, "SELECT pg_advisory_unlock"):
+		if c.locked {
+			c.locked = false
+			<-db.lock
+		}
+	case strings.HasPrefix(query, "CREATE TABLE IF NOT EXISTS schema_migrations"):
+		db.mu.Lock()
+		db.table = true
+		db.mu.Unlock()
+	case strings.HasPrefix(query, "INSERT INTO schema_migrations"):
+		version := int(args[0].Value.(int64))
+		c.inTx(func() { db.applied[version] = time.Date(2024, 3, 1, 0, 0, version, 0, time.UTC) })
+	case strings.HasPrefix(query, "DELETE FROM schema_migrations"):
+		version := int(args[0].Value.(int64))
+		c.inTx(func() { delete(db.applied, version) })
+	default:
+		if strings.Contains(query, "FAIL") {
+			return nil, errors.New("fake: syntax error")
+		}
+		if !c.locked {
+			db.mu.Lock()
+			db.unlocked++
+			db.mu.Unlock()
+		}
+		// окно для гонки двух запусков без лока
+		time.Sleep(time.Millisecond)
+		if c.tx == nil {
+			return nil, errors.New("fake: script outside a transaction")
+		}
+		c.tx.scripts = append(c.tx.scripts, strings.TrimSpace(query))
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) inTx(op func()) {
+	c.tx.ops = append(c.tx.ops, op)
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	db := c.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	switch {
+	case strings.HasPrefix(query, "SELECT version, applied_at FROM schema_migrations"):
+		rows := &fakeRows{columns: []string{"version", "applied_at"}}
+		for version, at := range db.applied {
+			rows.values = append(rows.values, []driver.Value{int64(version), at})
+		}
+		return rows, nil
+	case strings.Contains(query, "to_regclass('schema_migrations')"):
+		return &fakeRows{columns: []string{"exists"}, values: [][]driver.Value{{db.table}}}, nil
+	case strings.Contains(query, "MAX(version)"):
+		latest := 0
+		for version := range db.applied {
+			latest = max(latest, version)
+		}
+		return &fakeRows{columns: []string{"max"}, values: [][]driver.Value{{int64(latest)}}}, nil
+	case strings.Contains(query, "FROM categories"):
+		return &fakeRows{columns: []string{"exists"}, values: [][]driver.Value{{db.hasData}}}, nil
+	}
+	return nil, fmt.Errorf("fake: unexpected query %q", query)
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// ====================================================================
+// TESTS
+// ====================================================================
+
+func testMigrations() fstest.MapFS {
+	return fstest.MapFS{
+		"0001_create_a.up.sql":   {Data: []byte("CREATE TABLE a")},
+		"0001_create_a.down.sql": {Data: []byte("DROP TABLE a")},
+		"0002_create_b.up.sql":   {Data: []byte("CREATE TABLE b")},
+		"0002_create_b.down.sql": {Data: []byte("DROP TABLE b")},
+		"0010_create_c.up.sql":   {Data: []byte("CREATE TABLE c")},
+		"0010_create_c.down.sql": {Data: []byte("DROP TABLE c")},
+	}
+}
+
+func versions(migrations []Migration) []int {
+	var out []int
+	for _, m := range migrations {
+		out = append(out, m.Version)
+	}
+	return out
+}
+
+func TestLoad(t *testing.T) {
+	got, err := Load(testMigrations())
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	// версии сортируются как числа, а не как строки
+	if want := []int{1, 2, 10}; !reflect.DeepEqual(versions(got), want) {
+		t.Errorf("versions = %v, want %v", versions(got), want)
+	}
+	if got[2].Name != "create_c" || got[2].Up != "CREATE TABLE c" || got[2].Down != "DROP TABLE c" {
+		t.Errorf("migration = %+v", got[2])
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(fsys fstest.MapFS)
+		wantErr string
+	}{
+		{name: "no down", modify: func(fsys fstest.MapFS) { delete(fsys, "0002_create_b.down.sql") },
+			wantErr: "version 2 needs both up and down files"},
+		{name: "bad name", modify: func(fsys fstest.MapFS) { fsys["3_Create.sql"] = &fstest.MapFile{} },
+			wantErr: "name must look like"},
+		{name: "zero version", modify: func(fsys fstest.MapFS) {
+			fsys["0000_zero.up.sql"] = &fstest.MapFile{Data: []byte("SELECT 1")}
+		}, wantErr: "version must be positive"},
+		{name: "two names", modify: func(fsys fstest.MapFS) {
+			fsys["0002_create_bb.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE bb")}
+		}, wantErr: "version 2 has two names"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := testMigrations()
+			tt.modify(fsys)
+			if _, err := Load(fsys); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpDown(t *testing.T) {
+	db, fake := openFake(t)
+	m, err := New(db, testMigrations())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if current, latest, err := m.Version(ctx); err != nil || current != 0 || latest != 10 {
+		t.Fatalf("Version() before up = %d, %d, %v", current, latest, err)
+	}
+
+	done, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	if !reflect.DeepEqual(versions(done), []int{1, 2, 10}) || len(fake.applied) != 3 {
+		t.Fatalf("Up() = %v, applied %v", versions(done), fake.applied)
+	}
+	if done, err := m.Up(ctx); err != nil || len(done) != 0 {
+		t.Errorf("second Up() = %v, %v, want nothing to apply", versions(done), err)
+	}
+	if current, _, _ := m.Version(ctx); current != 10 {
+		t.Errorf("Version() after up = %d, want 10", current)
+	}
+
+	// откат идет от последней версии
+	done, err = m.Down(ctx, 1)
+	if err != nil || !reflect.DeepEqual(versions(done), []int{10}) {
+		t.Fatalf("Down(1) = %v, %v", versions(done), err)
+	}
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range statuses {
+		if applied := s.AppliedAt != nil; applied != (s.Version != 10) {
+			t.Errorf("status %d applied = %v", s.Version, applied)
+		}
+	}
+
+	done, err = m.Down(ctx, 5)
+	if err != nil || !reflect.DeepEqual(versions(done), []int{2, 1}) {
+		t.Fatalf("Down(5) = %v, %v", versions(done), err)
+	}
+
+	want := []string{"CREATE TABLE a", "CREATE TABLE b", "CREATE TABLE c", "DROP TABLE c", "DROP TABLE b", "DROP TABLE a"}
+	if !reflect.DeepEqual(fake.scripts, want) || len(fake.applied) != 0 {
+		t.Errorf("scripts = %q, applied %v", fake.scripts, fake.applied)
+	}
+}
+
+func TestUpStopsAtFailure(t *testing.T) {
+	db, fake := openFake(t)
+	fsys := testMigrations()
+	fsys["0002_create_b.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE b FAIL")}
+	m, err := New(db, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done, err := m.Up(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "0002_create_b") {
+		t.Fatalf("Up() error = %v, want the failed migration named", err)
+	}
+	// первая миграция остается примененной, упавшая откатывается целиком, третья не начинается
+	if !reflect.DeepEqual(versions(done), []int{1}) {
+		t.Errorf("Up() = %v, want [1]", versions(done))
+	}
+	if _, ok := fake.applied[2]; ok || len(fake.applied) != 1 || !reflect.DeepEqual(fake.scripts, []string{"CREATE TABLE a"}) {
+		t.Errorf("applied %v, scripts %q", fake.applied, fake.scripts)
+	}
+	// лок снят: следующий запуск не ждет
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := m.Status(ctx); err != nil {
+		t.Errorf("Status() after a failed Up() error = %v", err)
+	}
+}
+
+func TestUnknownVersion(t *testing.T) {
+	db, fake := openFake(t)
+	fake.applied[11] = time.Now()
+	m, err := New(db, testMigrations())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// бинарник старее базы не трогает схему
+	if _, err := m.Up(context.Background()); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("Up() error = %v, want ErrUnknownVersion", err)
+	}
+	if _, err := m.Down(context.Background(), 1); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("Down() error = %v, want ErrUnknownVersion", err)
+	}
+	if _, err := m.Status(context.Background()); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("Status() error = %v, want ErrUnknownVersion", err)
+	}
+	if len(fake.scripts) != 0 {
+		t.Errorf("scripts = %q, want none", fake.scripts)
+	}
+}
+
+func TestConcurrentUp(t *testing.T) {
+	db, fake := openFake(t)
+
+	const runners = 4
+	var wg sync.WaitGroup
+	applied := make([]int, runners)
+	errs := make([]error, runners)
+	for i := range runners {
+		m, err := New(db, testMigrations())
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			done, err := m.Up(context.Background())
+			applied[i], errs[i] = len(done), err
+		}()
+	}
+	wg.Wait()
+
+	total := 0
+	for i := range runners {
+		if errs[i] != nil {
+			t.Errorf("runner %d: Up() error = %v", i, errs[i])
+		}
+		total += applied[i]
+	}
+	// каждая миграция выполнена ровно один раз, и только под локом
+	if total != 3 || len(fake.scripts) != 3 || fake.unlocked != 0 {
+		t.Errorf("applied %v, scripts %q, unlocked %d", applied, fake.scripts, fake.unlocked)
+	}
+}
+
+func TestLockWaitsForContext(t *testing.T) {
+	db, fake := openFake(t)
+	m, err := New(db, testMigrations())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// лок держит другой запуск
+	fake.lock <- struct{}{}
+	defer func() { <-fake.lock }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := m.Up(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Up() error = %v, want the context deadline", err)
+	}
+	if len(fake.scripts) != 0 {
+		t.Errorf("scripts = %q, want none without the lock", fake.scripts)
+	}
+}
+
+func TestSeed(t *testing.T) {
+	db, fake := openFake(t)
+	m, err := New(db, testMigrations())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	fill := func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "INSERT INTO categories")
+		return err
+	}
+
+	if err := m.Seed(ctx, fill); err == nil || !strings.Contains(err.Error(), "run migrate up first") {
+		t.Errorf("Seed() before up error = %v", err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	fake.scripts = nil
+
+	// ошибка заполнения откатывает все, что успело записаться
+	failing := func(ctx context.Context, tx *sql.Tx) error {
+		if err := fill(ctx, tx); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "INSERT INTO products FAIL")
+		return err
+	}
+	if err := m.Seed(ctx, failing); err == nil || len(fake.scripts) != 0 {
+		t.Errorf("failed Seed() error = %v, scripts %q", err, fake.scripts)
+	}
+
+	if err := m.Seed(ctx, fill); err != nil || !reflect.DeepEqual(fake.scripts, []string{"INSERT INTO categories"}) {
+		t.Errorf("Seed() error = %v, scripts %q", err, fake.scripts)
+	}
+
+	fake.hasData = true
+	if err := m.Seed(ctx, fill); !errors.Is(err, ErrNotEmpty) {
+		t.Errorf("Seed() into a filled database error = %v, want ErrNotEmpty", err)
+	}
+}
+
+// TestEmbeddedMigrations — встроенные миграции загружаются, и down
+// удаляет все, что создает up той же версии
+func TestEmbeddedMigrations(t *testing.T) {
+	loaded, err := Load(migrations.FS)
+	if err != nil {
+		t.Fatalf("Load(migrations.FS) error = %v", err)
+	}
+	if len(loaded) == 0 {
+		t.Fatal("no embedded migrations")
+	}
+	if _, err := fs.ReadFile(migrations.FS, "seed/catalog.sql"); err == nil {
+		t.Error("seed data is embedded with the migrations")
+	}
+
+	created := regexp.MustCompile(`(?m)^CREATE (TABLE|VIEW|FUNCTION) (\w+)`)
+	for _, m := range loaded {
+		for _, match := range created.FindAllStringSubmatch(m.Up, -1) {
+			drop := regexp.MustCompile(`(?m)^DROP ` + match[1] + ` IF EXISTS ` + match[2] + `\b`)
+			if !drop.MatchString(m.Down) {
+				t.Errorf("%04d_%s: down does not drop %s %s", m.Version, m.Name, strings.ToLower(match[1]), match[2])
+			}
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package pricing
+
+import (
+	"fmt"
+	"math"
+)
+
+// ====================================================================
+// PRICING - Расчет скидок по промоакциям
+// ====================================================================
+
+// Виды промоакций
+const (
+	KindPercentOff = "percent_off"
+	KindFixedOff   = "fixed_off"
+	KindBuyXGetY   = "buy_x_get_y"
+)
+
+// Rule — действующая промоакция. Пустые CategoryID и ProductID — акция на весь заказ.
+//   - percent_off: Value процентов от стоимости подходящих позиций;
+//   - fixed_off: Value единиц валюты на заказ, делится между подходящими позициями
+//     пропорционально их стоимости;
+//   - buy_x_get_y: из каждых BuyQuantity+GetQuantity единиц позиции GetQuantity бесплатно.
+type Rule struct {
+	ID          int
+	Kind        string
+	Value       float64
+	BuyQuantity int
+	GetQuantity int
+	CategoryID  *int
+	ProductID   *int
+}
+
+// Line — позиция заказа по цене каталога
+type Line struct {
+	ProductID  int
+	CategoryID int
+	Quantity   int
+	Price      float64
+}
+
+// Discount — скидка на позицию; PromotionID == 0 — скидки нет
+type Discount struct {
+	PromotionID int
+	Amount      float64
+	Percent     float64
+}
+
+// Total — стоимость позиции без скидки
+func (l Line) Total() float64 {
+	return l.Price * float64(l.Quantity)
+}
+
+// Validate — проверить параметры акции перед сохранением
+func (r Rule) Validate() error {
+	switch r.Kind {
+	case KindPercentOff:
+		if r.Value <= 0 || r.Value > 100 {
+			return fmt.Errorf("percent_off value must be between 0 and 100")
+		}
+	case KindFixedOff:
+		if r.Value <= 0 {
+			return fmt.Errorf("fixed_off value must be positive")
+		}
+	case KindBuyXGetY:
+		if r.BuyQuantity <= 0 || r.GetQuantity <= 0 {
+			return fmt.Errorf("buy_x_get_y requires positive buy_quantity and get_quantity")
+		}
+	default:
+		return fmt.Errorf("unknown promotion kind %q, use %q, %q or %q", r.Kind, KindPercentOff, KindFixedOff, KindBuyXGetY)
+	}
+	return nil
+}
+
+// Matches — подпадает ли позиция под акцию
+func (r Rule) Matches(l Line) bool {
+	if r.ProductID != nil && *r.ProductID != l.ProductID {
+		return false
+	}
+	if r.CategoryID != nil && *r.CategoryID != l.CategoryID {
+		return false
+	}
+	return true
+}
+
+// Apply — рассчитать скидки по позициям. Акции не суммируются: каждой позиции
+// достается самая выгодная для покупателя акция, при равенстве — с меньшим ID.
+func Apply(rules []Rule, lines []Line) []Discount {
+	best := make([]Discount, len(lines))
+
+	for _, rule := range rules {
+		for i, amount := range rule.discounts(lines) {
+			if amount <= 0 {
+				continue
+			}
+			if amount > best[i].Amount || (amount == best[i].Amount && rule.ID < best[i].PromotionID) {
+				best[i] = Discount{PromotionID: rule.ID, Amount: amount}
+			}
+		}
+	}
+
+	for i, l := range lines {
+		if total := l.Total(); best[i].PromotionID != 0 && total > 0 {
+			best[i].Percent = round(best[i].Amount / total * 100)
+		}
+	}
+
+	return best
+}
+
+// Applied — ID акций, давших скидку хотя бы одной позиции
+func Applied(discounts []Discount) []int {
+	seen := make(map[int]bool)
+	var ids []int
+	for _, d := range discounts {
+		if d.PromotionID != 0 && !seen[d.PromotionID] {
+			seen[d.PromotionID] = true
+			ids = append(ids, d.PromotionID)
+		}
+	}
+	return ids
+}
+
+// discounts — скидка акции по каждой позиции, если бы она применялась одна
+func (r Rule) discounts(lines []Line) []float64 {
+	amounts := make([]float64, len(lines))
+
+	switch r.Kind {
+	case KindPercentOff:
+		for i, l := range lines {
+			if r.Matches(l) {
+				amounts[i] = round(l.Total() * math.Min(r.Value, 100) / 100)
+			}
+		}
+
+	case KindFixedOff:
+		var eligible float64
+		for _, l := range lines {
+			if r.Matches(l) {
+				eligible += l.Total()
+			}
+		}
+		if eligible <= 0 {
+			return amounts
+		}
+
+		// сумма не больше стоимости подходящих позиций; остаток от округления
+		// относится на последнюю подходящую позицию
+		amount := math.Min(r.Value, eligible)
+		left, last := amount, -1
+		for i, l := range lines {
+			if r.Matches(l) {
+				amounts[i] = round(amount * l.Total() / eligible)
+				left -= amounts[i]
+				last = i
+			}
+		}
+		amounts[last] = round(amounts[last] + left)
+
+	case KindBuyXGetY:
+		set := r.BuyQuantity + r.GetQuantity
+		if r.BuyQuantity <= 0 || r.GetQuantity <= 0 {
+			return amounts
+		}
+		for i, l := range lines {
+			if r.Matches(l) {
+				free := l.Quantity / set * r.GetQuantity
+				amounts[i] = round(float64(free) * l.Price)
+			}
+		}
+	}
+
+	return amounts
+}
+
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,165 @@
+package pricing
+
+import (
+	"reflect"
+	"testing"
+)
+
+func intPtr(v int) *int { return &v }
+
+func TestApply(t *testing.T) {
+	// 1 — кофе (категория 10), 2 — чай (категория 10), 3 — кружка (категория 20)
+	coffee := Line{ProductID: 1, CategoryID: 10, Quantity: 1, Price: 100}
+	tea := Line{ProductID: 2, CategoryID: 10, Quantity: 1, Price: 100}
+	mug := Line{ProductID: 3, CategoryID: 20, Quantity: 1, Price: 100}
+
+	tests := []struct {
+		name  string
+		rules []Rule
+		lines []Line
+		want  []Discount
+	}{
+		{
+			name:  "no rules",
+			lines: []Line{coffee},
+			want:  []Discount{{}},
+		},
+		{
+			name:  "percent off whole order",
+			rules: []Rule{{ID: 1, Kind: KindPercentOff, Value: 10}},
+			lines: []Line{coffee, {ProductID: 3, CategoryID: 20, Quantity: 3, Price: 33.33}},
+			want:  []Discount{{PromotionID: 1, Amount: 10, Percent: 10}, {PromotionID: 1, Amount: 10, Percent: 10}},
+		},
+		{
+			name:  "category rule skips other lines",
+			rules: []Rule{{ID: 1, Kind: KindPercentOff, Value: 20, CategoryID: intPtr(10)}},
+			lines: []Line{coffee, mug},
+			want:  []Discount{{PromotionID: 1, Amount: 20, Percent: 20}, {}},
+		},
+		{
+			name:  "product rule",
+			rules: []Rule{{ID: 1, Kind: KindPercentOff, Value: 50, ProductID: intPtr(2)}},
+			lines: []Line{coffee, tea},
+			want:  []Discount{{}, {PromotionID: 1, Amount: 50, Percent: 50}},
+		},
+		{
+			name: "best rule chosen per line",
+			rules: []Rule{
+				{ID: 1, Kind: KindPercentOff, Value: 10},
+				{ID: 2, Kind: KindPercentOff, Value: 30, CategoryID: intPtr(20)},
+			},
+			lines: []Line{coffee, mug},
+			want:  []Discount{{PromotionID: 1, Amount: 10, Percent: 10}, {PromotionID: 2, Amount: 30, Percent: 30}},
+		},
+		{
+			name: "rules are not combined",
+			rules: []Rule{
+				{ID: 1, Kind: KindPercentOff, Value: 10},
+				{ID: 2, Kind: KindFixedOff, Value: 15},
+			},
+			lines: []Line{coffee},
+			want:  []Discount{{PromotionID: 2, Amount: 15, Percent: 15}},
+		},
+		{
+			name: "tie goes to lower id",
+			rules: []Rule{
+				{ID: 7, Kind: KindPercentOff, Value: 10},
+				{ID: 3, Kind: KindFixedOff, Value: 10},
+			},
+			lines: []Line{coffee},
+			want:  []Discount{{PromotionID: 3, Amount: 10, Percent: 10}},
+		},
+		{
+			name:  "fixed off split by line value",
+			rules: []Rule{{ID: 1, Kind: KindFixedOff, Value: 30}},
+			lines: []Line{coffee, {ProductID: 3, CategoryID: 20, Quantity: 2, Price: 100}},
+			want:  []Discount{{PromotionID: 1, Amount: 10, Percent: 10}, {PromotionID: 1, Amount: 20, Percent: 10}},
+		},
+		{
+			name:  "fixed off rounding remainder on last line",
+			rules: []Rule{{ID: 1, Kind: KindFixedOff, Value: 100}},
+			lines: []Line{coffee, tea, mug},
+			want: []Discount{
+				{PromotionID: 1, Amount: 33.33, Percent: 33.33},
+				{PromotionID: 1, Amount: 33.33, Percent: 33.33},
+				{PromotionID: 1, Amount: 33.34, Percent: 33.34},
+			},
+		},
+		{
+			name:  "fixed off remainder on last matching line",
+			rules: []Rule{{ID: 1, Kind: KindFixedOff, Value: 100, CategoryID: intPtr(10)}},
+			lines: []Line{coffee, {ProductID: 2, CategoryID: 10, Quantity: 2, Price: 100}, mug},
+			want: []Discount{
+				{PromotionID: 1, Amount: 33.33, Percent: 33.33},
+				{PromotionID: 1, Amount: 66.67, Percent: 33.34},
+				{},
+			},
+		},
+		{
+			name:  "fixed off capped at eligible value",
+			rules: []Rule{{ID: 1, Kind: KindFixedOff, Value: 500}},
+			lines: []Line{coffee},
+			want:  []Discount{{PromotionID: 1, Amount: 100, Percent: 100}},
+		},
+		{
+			name:  "buy 2 get 1",
+			rules: []Rule{{ID: 1, Kind: KindBuyXGetY, BuyQuantity: 2, GetQuantity: 1}},
+			lines: []Line{{ProductID: 1, CategoryID: 10, Quantity: 7, Price: 50}},
+			want:  []Discount{{PromotionID: 1, Amount: 100, Percent: 28.57}},
+		},
+		{
+			name:  "buy 2 get 1 below set size",
+			rules: []Rule{{ID: 1, Kind: KindBuyXGetY, BuyQuantity: 2, GetQuantity: 1}},
+			lines: []Line{{ProductID: 1, CategoryID: 10, Quantity: 2, Price: 50}},
+			want:  []Discount{{}},
+		},
+		{
+			name:  "buy 3 get 2 on matching product only",
+			rules: []Rule{{ID: 1, Kind: KindBuyXGetY, BuyQuantity: 3, GetQuantity: 2, ProductID: intPtr(1)}},
+			lines: []Line{{ProductID: 1, CategoryID: 10, Quantity: 10, Price: 10}, {ProductID: 2, CategoryID: 10, Quantity: 10, Price: 10}},
+			want:  []Discount{{PromotionID: 1, Amount: 40, Percent: 40}, {}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Apply(tt.rules, tt.lines); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Apply() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplied(t *testing.T) {
+	discounts := []Discount{{PromotionID: 2}, {}, {PromotionID: 1}, {PromotionID: 2}}
+	if got, want := Applied(discounts), []int{2, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Applied() = %v, want %v", got, want)
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    Rule
+		wantErr bool
+	}{
+		{name: "percent", rule: Rule{Kind: KindPercentOff, Value: 15}},
+		{name: "whole price", rule: Rule{Kind: KindPercentOff, Value: 100}},
+		{name: "fixed", rule: Rule{Kind: KindFixedOff, Value: 500}},
+		{name: "buy x get y", rule: Rule{Kind: KindBuyXGetY, BuyQuantity: 2, GetQuantity: 1}},
+
+		{name: "percent above 100", rule: Rule{Kind: KindPercentOff, Value: 120}, wantErr: true},
+		{name: "zero percent", rule: Rule{Kind: KindPercentOff}, wantErr: true},
+		{name: "negative fixed", rule: Rule{Kind: KindFixedOff, Value: -5}, wantErr: true},
+		{name: "buy x get y without get", rule: Rule{Kind: KindBuyXGetY, BuyQuantity: 2}, wantErr: true},
+		{name: "unknown kind", rule: Rule{Kind: "bogo", Value: 1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rule.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
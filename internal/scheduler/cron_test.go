@@ -0,0 +1,103 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		wantErr bool
+	}{
+		{name: "every minute", expr: "* * * * *"},
+		{name: "fixed time", expr: "0 9 * * 1"},
+		{name: "lists ranges and steps", expr: "*/15 9-18 1,15 1-12/3 1-5"},
+		{name: "start with step", expr: "5/20 * * * *"},
+		{name: "sunday as 7", expr: "0 0 * * 7"},
+		{name: "alias", expr: "@daily"},
+		{name: "surrounding spaces", expr: "  @hourly "},
+
+		{name: "empty", expr: "", wantErr: true},
+		{name: "too few fields", expr: "0 9 * *", wantErr: true},
+		{name: "too many fields", expr: "0 9 * * * *", wantErr: true},
+		{name: "minute out of range", expr: "60 * * * *", wantErr: true},
+		{name: "hour out of range", expr: "0 24 * * *", wantErr: true},
+		{name: "day of month zero", expr: "0 0 0 * *", wantErr: true},
+		{name: "month out of range", expr: "0 0 1 13 *", wantErr: true},
+		{name: "day of week out of range", expr: "0 0 * * 8", wantErr: true},
+		{name: "reversed range", expr: "0 18-9 * * *", wantErr: true},
+		{name: "zero step", expr: "*/0 * * * *", wantErr: true},
+		{name: "not a number", expr: "a * * * *", wantErr: true},
+		{name: "unknown alias", expr: "@often", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseCron(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCron(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	at := func(value string) time.Time {
+		t.Helper()
+		v, err := time.Parse("2006-01-02 15:04", value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	// 2024-01-15 — понедельник
+	tests := []struct {
+		name string
+		expr string
+		from string
+		// seconds — секунды внутри минуты from
+		seconds int
+		want    string
+	}{
+		{name: "next minute", expr: "* * * * *", from: "2024-01-15 10:00", want: "2024-01-15 10:01"},
+		{name: "strictly after", expr: "0 9 * * *", from: "2024-01-15 09:00", want: "2024-01-16 09:00"},
+		{name: "later today", expr: "30 18 * * *", from: "2024-01-15 09:00", want: "2024-01-15 18:30"},
+		{name: "step", expr: "*/15 * * * *", from: "2024-01-15 10:16", want: "2024-01-15 10:30"},
+		{name: "hour rolls over", expr: "*/15 * * * *", from: "2024-01-15 10:50", want: "2024-01-15 11:00"},
+		{name: "weekly on monday", expr: "0 9 * * 1", from: "2024-01-15 09:30", want: "2024-01-22 09:00"},
+		{name: "sunday as 7", expr: "0 0 * * 7", from: "2024-01-15 00:00", want: "2024-01-21 00:00"},
+		{name: "weekdays skip weekend", expr: "0 8 * * 1-5", from: "2024-01-19 09:00", want: "2024-01-22 08:00"},
+		{name: "monthly", expr: "@monthly", from: "2024-01-15 00:00", want: "2024-02-01 00:00"},
+		{name: "year rolls over", expr: "0 0 1 1 *", from: "2024-06-01 00:00", want: "2025-01-01 00:00"},
+		{name: "31st skips short months", expr: "0 0 31 * *", from: "2024-01-31 00:00", want: "2024-03-31 00:00"},
+		{name: "leap day", expr: "0 0 29 2 *", from: "2024-03-01 00:00", want: "2028-02-29 00:00"},
+		{name: "day of month or day of week", expr: "0 0 1 * 1", from: "2024-01-16 00:00", want: "2024-01-22 00:00"},
+		{name: "seconds truncated", expr: "* * * * *", from: "2024-01-15 10:00", seconds: 59, want: "2024-01-15 10:01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCron(%q) error = %v", tt.expr, err)
+			}
+			from := at(tt.from).Add(time.Duration(tt.seconds) * time.Second)
+			if got := c.Next(from); !got.Equal(at(tt.want)) {
+				t.Errorf("Next(%s) = %s, want %s", tt.from, got.Format("2006-01-02 15:04"), tt.want)
+			}
+		})
+	}
+}
+
+func TestCronNextNoMatch(t *testing.T) {
+	c, err := ParseCron("0 0 30 2 *")
+	if err != nil {
+		t.Fatalf("ParseCron error = %v", err)
+	}
+	if got := c.Next(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)); !got.IsZero() {
+		t.Errorf("Next() = %s, want zero time for February 30", got)
+	}
+}
@@ -0,0 +1,224 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"salesTracker/internal/config"
+)
+
+// ====================================================================
+// DELIVERY - Доставка готовых отчетов
+// ====================================================================
+
+const (
+	DeliverySMTP = "smtp"
+	DeliveryFile = "file"
+)
+
+// Report — готовый к доставке отчет
+type Report struct {
+	ScheduleID  int
+	Name        string
+	GeneratedAt time.Time
+	Body        []byte
+}
+
+// FileName — имя файла отчета, уникальное для расписания и момента генерации
+func (r Report) FileName() string {
+	return fmt.Sprintf("schedule-%d-%s.json", r.ScheduleID, r.GeneratedAt.UTC().Format("20060102T150405Z"))
+}
+
+// Deliverer — способ доставки отчета получателям
+type Deliverer interface {
+	Deliver(ctx context.Context, report Report, recipients []string) error
+}
+
+// FileDeliverer — складывает отчеты в каталог
+type FileDeliverer struct {
+	Dir string
+}
+
+func (d FileDeliverer) Deliver(_ context.Context, report Report, _ []string) error {
+	const op = "scheduler.FileDeliverer.Deliver"
+
+	if err := os.MkdirAll(d.Dir, 0o755); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// пишем во временный файл и переименовываем, чтобы потребитель каталога
+	// никогда не увидел недописанный отчет
+	path := filepath.Join(d.Dir, report.FileName())
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, report.Body, 0o644); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SMTPDeliverer — отправляет отчеты письмом с JSON-вложением
+type SMTPDeliverer struct {
+	cfg config.SMTP
+}
+
+func NewSMTPDeliverer(cfg config.SMTP) *SMTPDeliverer {
+	return &SMTPDeliverer{cfg: cfg}
+}
+
+func (d *SMTPDeliverer) Deliver(ctx context.Context, report Report, recipients []string) error {
+	const op = "scheduler.SMTPDeliverer.Deliver"
+
+	if len(recipients) == 0 {
+		return fmt.Errorf("%s: no recipients", op)
+	}
+
+	to, err := parseRecipients(recipients)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	rcpt := make([]string, len(to))
+	for i, addr := range to {
+		rcpt[i] = addr.Address
+	}
+
+	var auth smtp.Auth
+	if d.cfg.User != "" {
+		auth = smtp.PlainAuth("", d.cfg.User, d.cfg.Password, d.cfg.Host)
+	}
+
+	msg := buildMessage(d.cfg.From, to, report)
+	if err := d.send(ctx, auth, rcpt, msg); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// send — то же, что smtp.SendMail, но с ограничением по времени: зависший
+// почтовый сервер не должен останавливать тик планировщика
+func (d *SMTPDeliverer) send(ctx context.Context, auth smtp.Auth, rcpt []string, msg []byte) error {
+	if d.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.cfg.Timeout)
+		defer cancel()
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(d.cfg.Host, d.cfg.Port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// дедлайн ограничивает каждое чтение и запись, а отмена контекста
+	// прерывает обмен сразу
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, d.cfg.Host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: d.cfg.Host}); err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(d.cfg.From); err != nil {
+		return err
+	}
+	for _, addr := range rcpt {
+		if err := c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// parseRecipients — адреса получателей по RFC 5322, по одному в элементе
+func parseRecipients(recipients []string) ([]*mail.Address, error) {
+	addrs := make([]*mail.Address, 0, len(recipients))
+	for _, r := range recipients {
+		addr, err := mail.ParseAddress(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient %q: %w", r, err)
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+// buildMessage — письмо с отчетом. Название расписания задает пользователь,
+// поэтому в заголовок Subject оно попадает только в кодировке RFC 2047.
+func buildMessage(from string, to []*mail.Address, report Report) []byte {
+	const boundary = "sales-tracker-report"
+
+	header := make([]string, len(to))
+	for i, addr := range to {
+		header[i] = addr.String()
+	}
+	subject := fmt.Sprintf("%s (%s)", report.Name, report.GeneratedAt.Format("2006-01-02"))
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(header, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", report.GeneratedAt.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&b, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", boundary)
+
+	fmt.Fprintf(&b, "--%s\r\n", boundary)
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&b, "Отчет \"%s\" сформирован %s.\r\n\r\n", report.Name, report.GeneratedAt.Format(time.RFC3339))
+
+	fmt.Fprintf(&b, "--%s\r\n", boundary)
+	b.WriteString("Content-Type: application/json; charset=utf-8\r\n")
+	fmt.Fprintf(&b, "Content-Disposition: attachment; filename=%q\r\n\r\n", report.FileName())
+	b.Write(report.Body)
+	b.WriteString("\r\n")
+
+	fmt.Fprintf(&b, "--%s--\r\n", boundary)
+	return b.Bytes()
+}
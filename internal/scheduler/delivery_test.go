@@ -0,0 +1,192 @@
+package scheduler
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"salesTracker/internal/config"
+	"salesTracker/internal/storage/postgresql"
+)
+
+// fakeSMTP — почтовый сервер на одно соединение: handle получает
+// принятое соединение, сервер закрывается вместе с тестом
+func fakeSMTP(t *testing.T, handle func(conn net.Conn)) config.SMTP {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handle(conn)
+	}()
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	return config.SMTP{Host: host, Port: port, From: "reports@example.com", Timeout: 5 * time.Second}
+}
+
+// acceptMail — минимальный диалог SMTP без расширений; текст письма
+// после DATA уходит в канал
+func acceptMail(data chan<- string) func(conn net.Conn) {
+	return func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+		reply("220 fake ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 fake")
+			case strings.HasPrefix(cmd, "DATA"):
+				reply("354 go ahead")
+				var b strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					b.WriteString(line)
+				}
+				data <- b.String()
+				reply("250 queued")
+			case strings.HasPrefix(cmd, "QUIT"):
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}
+}
+
+func testReport() Report {
+	return Report{
+		ScheduleID:  7,
+		Name:        "Выручка",
+		GeneratedAt: time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC),
+		Body:        []byte(`{"result":1}`),
+	}
+}
+
+func TestSMTPDelivererSends(t *testing.T) {
+	data := make(chan string, 1)
+	cfg := fakeSMTP(t, acceptMail(data))
+
+	err := NewSMTPDeliverer(cfg).Deliver(context.Background(), testReport(), []string{"Анна <anna@example.com>"})
+	if err != nil {
+		t.Fatalf("Deliver() error = %v", err)
+	}
+
+	msg := <-data
+	for _, want := range []string{
+		"From: reports@example.com",
+		"Subject: =?utf-8?q?",
+		`filename="schedule-7-20240301T080000Z.json"`,
+		`{"result":1}`,
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message has no %q:\n%s", want, msg)
+		}
+	}
+}
+
+func TestSMTPDelivererTimeout(t *testing.T) {
+	// сервер принимает соединение и молчит: без ограничения клиент ждал бы приветствия вечно
+	stall := func(conn net.Conn) { conn.Read(make([]byte, 1)) }
+
+	t.Run("timeout", func(t *testing.T) {
+		cfg := fakeSMTP(t, stall)
+		cfg.Timeout = 100 * time.Millisecond
+
+		start := time.Now()
+		err := NewSMTPDeliverer(cfg).Deliver(context.Background(), testReport(), []string{"anna@example.com"})
+		if err == nil {
+			t.Fatal("Deliver() error = nil for a stalled server")
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("Deliver() returned after %v, want about %v", elapsed, cfg.Timeout)
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		cfg := fakeSMTP(t, stall)
+		cfg.Timeout = time.Minute
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		if err := NewSMTPDeliverer(cfg).Deliver(ctx, testReport(), []string{"anna@example.com"}); err == nil {
+			t.Fatal("Deliver() error = nil after the context is done")
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("Deliver() returned after %v, want it to stop with the context", elapsed)
+		}
+	})
+}
+
+func TestSMTPDelivererRecipients(t *testing.T) {
+	d := NewSMTPDeliverer(config.SMTP{Host: "127.0.0.1", Port: "1", Timeout: time.Second})
+
+	if err := d.Deliver(context.Background(), testReport(), nil); err == nil {
+		t.Error("Deliver() error = nil without recipients")
+	}
+	if err := d.Deliver(context.Background(), testReport(), []string{"not an address"}); err == nil {
+		t.Error("Deliver() error = nil for an invalid recipient")
+	}
+}
+
+func TestFileDeliverer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "reports")
+	report := testReport()
+
+	if err := (FileDeliverer{Dir: dir}).Deliver(context.Background(), report, nil); err != nil {
+		t.Fatalf("Deliver() error = %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, report.FileName()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(report.Body) {
+		t.Errorf("report = %s, want %s", got, report.Body)
+	}
+	if _, err := os.Stat(filepath.Join(dir, report.FileName()+".tmp")); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+}
+
+func TestValidateScheduleReportTypes(t *testing.T) {
+	for name := range reportTypes {
+		sch := postgresql.ReportSchedule{
+			Name: "Отчет", CronExpr: "0 8 * * *", ReportType: name, Range: "yesterday",
+			TimeZone: "Europe/Moscow", Delivery: DeliveryFile,
+		}
+		if err := ValidateSchedule(sch); err != nil {
+			t.Errorf("ValidateSchedule(%s) error = %v", name, err)
+		}
+	}
+	if _, ok := reportTypes["return_rate"]; !ok {
+		t.Error("return_rate is not a schedulable report")
+	}
+}
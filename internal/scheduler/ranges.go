@@ -0,0 +1,54 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ====================================================================
+// RELATIVE RANGES - Относительные периоды отчетов
+// ====================================================================
+
+// ResolveRange — вычислить границы периода относительно момента now.
+// Поддерживаются: today, yesterday, last_N_days ("last 7 days"),
+// previous_week, previous_month, week_to_date, month_to_date.
+// Границы — календарные дни: end указывает на последний день периода.
+func ResolveRange(spec string, now time.Time) (start, end time.Time, err error) {
+	const op = "scheduler.ResolveRange"
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	norm := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(spec)), " ", "_")
+
+	switch norm {
+	case "today":
+		return today, today, nil
+	case "yesterday":
+		y := today.AddDate(0, 0, -1)
+		return y, y, nil
+	case "previous_week", "last_week":
+		// неделя начинается с понедельника
+		monday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		return monday.AddDate(0, 0, -7), monday.AddDate(0, 0, -1), nil
+	case "previous_month", "last_month":
+		first := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location())
+		return first.AddDate(0, -1, 0), first.AddDate(0, 0, -1), nil
+	case "week_to_date":
+		monday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		return monday, today, nil
+	case "month_to_date":
+		first := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location())
+		return first, today, nil
+	}
+
+	// last_N_days — N полных дней, заканчивая вчерашним
+	if strings.HasPrefix(norm, "last_") && strings.HasSuffix(norm, "_days") {
+		n, convErr := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(norm, "last_"), "_days"))
+		if convErr == nil && n > 0 {
+			return today.AddDate(0, 0, -n), today.AddDate(0, 0, -1), nil
+		}
+	}
+
+	return time.Time{}, time.Time{}, fmt.Errorf("%s: unknown range %q", op, spec)
+}
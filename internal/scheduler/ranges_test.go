@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResolveRange(t *testing.T) {
+	day := func(value string) time.Time {
+		t.Helper()
+		v, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	// среда, 2024-03-13, середина дня
+	now := time.Date(2024, 3, 13, 15, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		spec      string
+		now       time.Time
+		wantStart string
+		wantEnd   string
+		wantErr   bool
+	}{
+		{spec: "today", wantStart: "2024-03-13", wantEnd: "2024-03-13"},
+		{spec: "yesterday", wantStart: "2024-03-12", wantEnd: "2024-03-12"},
+		{spec: "last_7_days", wantStart: "2024-03-06", wantEnd: "2024-03-12"},
+		{spec: "Last 30 Days", wantStart: "2024-02-12", wantEnd: "2024-03-12"},
+		{spec: "previous_week", wantStart: "2024-03-04", wantEnd: "2024-03-10"},
+		{spec: "last_week", wantStart: "2024-03-04", wantEnd: "2024-03-10"},
+		{spec: "previous_month", wantStart: "2024-02-01", wantEnd: "2024-02-29"},
+		{spec: "week_to_date", wantStart: "2024-03-11", wantEnd: "2024-03-13"},
+		{spec: "month_to_date", wantStart: "2024-03-01", wantEnd: "2024-03-13"},
+		{spec: "week_to_date", now: time.Date(2024, 3, 17, 10, 0, 0, 0, time.UTC), wantStart: "2024-03-11", wantEnd: "2024-03-17"},
+		{spec: "previous_month", now: time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC), wantStart: "2023-12-01", wantEnd: "2023-12-31"},
+
+		{spec: "last_0_days", wantErr: true},
+		{spec: "last_x_days", wantErr: true},
+		{spec: "tomorrow", wantErr: true},
+		{spec: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			ref := now
+			if !tt.now.IsZero() {
+				ref = tt.now
+			}
+			start, end, err := ResolveRange(tt.spec, ref)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ResolveRange(%q) = %s..%s, want error", tt.spec, start, end)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveRange(%q) error = %v", tt.spec, err)
+			}
+			if !start.Equal(day(tt.wantStart)) || !end.Equal(day(tt.wantEnd)) {
+				t.Errorf("ResolveRange(%q) = %s..%s, want %s..%s", tt.spec,
+					start.Format("2006-01-02"), end.Format("2006-01-02"), tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"salesTracker/internal/config"
+	"salesTracker/internal/storage/postgresql"
+)
+
+// ====================================================================
+// REPORT TYPES - Отчеты, доступные для расписаний
+// ====================================================================
+
+type reportFunc func(ctx context.Context, s *postgresql.Storage, start, end time.Time, sch postgresql.ReportSchedule) (any, error)
+
+var reportTypes = map[string]reportFunc{
+	"sales_report": func(ctx context.Context, s *postgresql.Storage, start, end time.Time, sch postgresql.ReportSchedule) (any, error) {
+		return s.GenerateSalesReport(ctx, start, end, sch.Currency)
+	},
+	"revenue": func(ctx context.Context, s *postgresql.Storage, start, end time.Time, sch postgresql.ReportSchedule) (any, error) {
+		return s.TotalRevenueByPeriod(ctx, start, end, sch.Currency)
+	},
+	"daily_orders": func(ctx context.Context, s *postgresql.Storage, start, end time.Time, sch postgresql.ReportSchedule) (any, error) {
+		return s.OrdersPerDay(ctx, start, end, sch.Currency)
+	},
+	"average_check": func(ctx context.Context, s *postgresql.Storage, start, end time.Time, sch postgresql.ReportSchedule) (any, error) {
+		return s.AverageCheckByPeriod(ctx, start, end, sch.Currency)
+	},
+	"orders_median": func(ctx context.Context, s *postgresql.Storage, start, end time.Time, sch postgresql.ReportSchedule) (any, error) {
+		return s.OrdersMedian(ctx, start, end, sch.Currency)
+	},
+	"customer_median": func(ctx context.Context, s *postgresql.Storage, start, end time.Time, sch postgresql.ReportSchedule) (any, error) {
+		return s.CustomerSpendingMedian(ctx, start, end, sch.Currency)
+	},
+	"orders_percentile": func(ctx context.Context, s *postgresql.Storage, start, end time.Time, sch postgresql.ReportSchedule) (any, error) {
+		return s.OrdersPercentile(ctx, start, end, sch.Percentile, sch.Currency)
+	},
+	"customer_percentile": func(ctx context.Context, s *postgresql.Storage, start, end time.Time, sch postgresql.ReportSchedule) (any, error) {
+		return s.CustomerSpendingPercentile(ctx, start, end, sch.Percentile, sch.Currency)
+	},
+	"return_rate": func(ctx context.Context, s *postgresql.Storage, start, end time.Time, sch postgresql.ReportSchedule) (any, error) {
+		return s.ReturnRateByProduct(ctx, start, end, sch.Currency)
+	},
+	"promotions": func(ctx context.Context, s *postgresql.Storage, start, end time.Time, sch postgresql.ReportSchedule) (any, error) {
+		return s.PromotionsReport(ctx, start, end, sch.Currency)
+	},
+}
+
+// ValidateSchedule — проверить расписание перед сохранением
+func ValidateSchedule(sch postgresql.ReportSchedule) error {
+	if sch.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	// название уходит в заголовок письма
+	if strings.ContainsAny(sch.Name, "\r\n") {
+		return fmt.Errorf("name must not contain line breaks")
+	}
+	if _, err := ParseCron(sch.CronExpr); err != nil {
+		return err
+	}
+	if _, err := time.LoadLocation(sch.TimeZone); err != nil {
+		return fmt.Errorf("invalid time zone %q", sch.TimeZone)
+	}
+	if _, ok := reportTypes[sch.ReportType]; !ok {
+		return fmt.Errorf("unknown report type %q", sch.ReportType)
+	}
+	if _, _, err := ResolveRange(sch.Range, time.Now()); err != nil {
+		return err
+	}
+	if sch.Currency != "" && (len(sch.Currency) != 3 || strings.Trim(sch.Currency, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "") {
+		return fmt.Errorf("invalid currency %q, use a 3-letter ISO 4217 code", sch.Currency)
+	}
+	if sch.Percentile < 0 || sch.Percentile > 100 {
+		return fmt.Errorf("invalid percentile, must be between 0 and 100")
+	}
+
+	switch sch.Delivery {
+	case DeliveryFile:
+	case DeliverySMTP:
+		if len(sch.Recipients) == 0 {
+			return fmt.Errorf("smtp delivery requires at least one recipient")
+		}
+		if _, err := parseRecipients(sch.Recipients); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown delivery %q, use %q or %q", sch.Delivery, DeliverySMTP, DeliveryFile)
+	}
+
+	return nil
+}
+
+// NextRun — время следующего запуска расписания после момента t;
+// cron-выражение вычисляется в часовом поясе расписания
+func NextRun(sch postgresql.ReportSchedule, t time.Time) (*time.Time, error) {
+	c, err := ParseCron(sch.CronExpr)
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := time.LoadLocation(sch.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+
+	next := c.Next(t.In(loc))
+	if next.IsZero() {
+		return nil, nil
+	}
+	return &next, nil
+}
+
+// ====================================================================
+// SCHEDULER - Внутрипроцессный планировщик
+// ====================================================================
+
+// claimLease — на сколько захваченное расписание скрыто от других экземпляров:
+// с запасом больше analytics_timeout и доставки, чтобы медленный отчет
+// не запустился повторно
+const claimLease = 30 * time.Minute
+
+// Scheduler — периодически выбирает наступившие расписания из БД,
+// формирует отчеты и доставляет их
+type Scheduler struct {
+	storage    *postgresql.Storage
+	interval   time.Duration
+	deliverers map[string]Deliverer
+}
+
+func New(storage *postgresql.Storage, cfg config.Scheduler) *Scheduler {
+	return &Scheduler{
+		storage:  storage,
+		interval: cfg.Interval,
+		deliverers: map[string]Deliverer{
+			DeliveryFile: FileDeliverer{Dir: cfg.ReportDir},
+			DeliverySMTP: NewSMTPDeliverer(cfg.SMTP),
+		},
+	}
+}
+
+// Run — цикл планировщика, завершается при отмене контекста
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.tick(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) tick(ctx context.Context, now time.Time) {
+	const op = "scheduler.tick"
+
+	due, err := s.storage.ClaimDueReportSchedules(ctx, now, claimLease)
+	if err != nil {
+		log.Printf("%s: %v", op, err)
+		return
+	}
+
+	for _, sch := range due {
+		runErr := s.runRecovered(ctx, sch, now)
+		if runErr != nil {
+			log.Printf("%s: schedule %d: %v", op, sch.ScheduleID, runErr)
+		}
+
+		next, err := NextRun(sch, now)
+		if err != nil {
+			log.Printf("%s: schedule %d: %v", op, sch.ScheduleID, err)
+		}
+		if err := s.storage.MarkReportScheduleRun(ctx, sch.ScheduleID, now, runErr, next); err != nil {
+			log.Printf("%s: schedule %d: %v", op, sch.ScheduleID, err)
+		}
+	}
+}
+
+// runRecovered — RunSchedule, у которого паника становится ошибкой запуска:
+// она пишется в last_error, а остальные расписания тика выполняются
+func (s *Scheduler) runRecovered(ctx context.Context, sch postgresql.ReportSchedule, now time.Time) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("scheduler.RunSchedule: panic: %v", p)
+		}
+	}()
+
+	return s.RunSchedule(ctx, sch, now)
+}
+
+// RunSchedule — сформировать и доставить отчет по расписанию
+func (s *Scheduler) RunSchedule(ctx context.Context, sch postgresql.ReportSchedule, now time.Time) error {
+	const op = "scheduler.RunSchedule"
+
+	generate, ok := reportTypes[sch.ReportType]
+	if !ok {
+		return fmt.Errorf("%s: unknown report type %q", op, sch.ReportType)
+	}
+
+	deliverer, ok := s.deliverers[sch.Delivery]
+	if !ok {
+		return fmt.Errorf("%s: unknown delivery %q", op, sch.Delivery)
+	}
+
+	loc, err := time.LoadLocation(sch.TimeZone)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// относительный период ("previous_month") считается в часовом поясе расписания
+	start, end, err := ResolveRange(sch.Range, now.In(loc))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	result, err := generate(ctx, s.storage, start, end, sch)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	body, err := json.MarshalIndent(map[string]any{
+		"schedule_id": sch.ScheduleID,
+		"name":        sch.Name,
+		"report_type": sch.ReportType,
+		"time_zone":   sch.TimeZone,
+		"currency":    sch.Currency,
+		"start":       start.Format("2006-01-02"),
+		"end":         end.Format("2006-01-02"),
+		"result":      result,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	report := Report{
+		ScheduleID:  sch.ScheduleID,
+		Name:        sch.Name,
+		GeneratedAt: now,
+		Body:        body,
+	}
+	if err := deliverer.Deliver(ctx, report, sch.Recipients); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
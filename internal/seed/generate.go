@@ -0,0 +1,422 @@
+package seed
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ====================================================================
+// GENERATE - Синтетические покупатели и заказы
+// ====================================================================
+
+// msk — часы заказов считаются по Москве; фиксированный пояс не зависит
+// от базы часовых поясов на машине
+var msk = time.FixedZone("MSK", 3*60*60)
+
+// Product — товар каталога, из которого собираются заказы
+type Product struct {
+	ID       int
+	Category string
+	// Price — цена в копейках
+	Price int64
+}
+
+// Customer — сгенерированный покупатель
+type Customer struct {
+	ID               int
+	FirstName        string
+	LastName         string
+	Email            string
+	Phone            string
+	City             string
+	RegistrationDate time.Time
+}
+
+// Order — сгенерированный заказ; суммы в копейках
+type Order struct {
+	ID            int
+	CustomerID    int
+	OrderDate     time.Time
+	Status        string
+	PaymentMethod string
+	Total         int64
+	Items         []Item
+}
+
+// Item — позиция заказа; Discount — процент скидки
+type Item struct {
+	ProductID      int
+	Quantity       int
+	Price          int64
+	Discount       int
+	DiscountAmount int64
+}
+
+// Dataset — результат генерации
+type Dataset struct {
+	Customers []Customer
+	Orders    []Order
+}
+
+// Generate — покупатели и заказы по каталогу products. Одинаковые opts
+// и каталог дают одинаковый результат на любой машине.
+func Generate(opts Options, products []Product) (*Dataset, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	if len(products) == 0 {
+		return nil, fmt.Errorf("catalog has no products")
+	}
+
+	g := &generator{
+		opts: opts,
+		rnd:  rand.New(rand.NewPCG(opts.Seed, opts.Seed^0x9e3779b97f4a7c15)),
+	}
+	g.indexCatalog(products)
+	g.makeCustomers()
+	g.makeDays()
+	g.makeOrders()
+	g.assignRegistration()
+
+	return &Dataset{Customers: g.customers, Orders: g.orders}, nil
+}
+
+// ====================================================================
+// СПРАВОЧНИКИ
+// ====================================================================
+
+var (
+	maleNames   = []string{"Александр", "Алексей", "Андрей", "Дмитрий", "Иван", "Игорь", "Максим", "Михаил", "Никита", "Павел", "Роман", "Сергей", "Владимир", "Евгений", "Артем"}
+	femaleNames = []string{"Анна", "Екатерина", "Елена", "Мария", "Наталья", "Ольга", "Светлана", "Татьяна", "Юлия", "Виктория", "Дарья", "Ксения", "Алина", "Ирина", "Полина"}
+	// lastNames — мужская форма, женская получается окончанием «а»
+	lastNames = []string{"Иванов", "Петров", "Сидоров", "Смирнов", "Кузнецов", "Попов", "Волков", "Соколов", "Лебедев", "Козлов", "Новиков", "Морозов", "Васильев", "Зайцев", "Федоров", "Михайлов", "Александров", "Егоров", "Семенов", "Титов", "Орлов", "Никитин", "Захаров", "Белов", "Комаров"}
+
+	emailDomains = []string{"mail.ru", "gmail.com", "yandex.ru"}
+
+	cities = []string{"Москва", "Санкт-Петербург", "Новосибирск", "Екатеринбург", "Казань", "Нижний Новгород", "Краснодар",
+		"Челябинск", "Самара", "Омск", "Ростов-на-Дону", "Уфа", "Воронеж", "Пермь", "Волгоград"}
+	cityWeights = []float64{30, 14, 5, 5, 5, 4, 4, 3, 3, 3, 3, 3, 3, 3, 3}
+
+	paymentMethods        = []string{"Карта", "Онлайн перевод", "Наличные", "Электронный кошелек"}
+	paymentMethodsWeights = []float64{50, 25, 15, 10}
+
+	// monthWeights — спрос по месяцам: провал в январе, пик в ноябре и декабре
+	monthWeights = [12]float64{0.75, 0.85, 1.0, 0.95, 1.0, 0.95, 0.9, 0.95, 1.0, 1.05, 1.3, 1.6}
+	// weekdayWeights — с воскресенья: по выходным заказов больше
+	weekdayWeights = [7]float64{1.25, 0.9, 0.9, 0.95, 1.0, 1.1, 1.3}
+	// hourWeights — часы по Москве: ночью почти нет заказов, пик вечером
+	hourWeights = []float64{0.3, 0.15, 0.1, 0.05, 0.05, 0.1, 0.3, 0.6, 1.0, 1.3, 1.5, 1.6,
+		1.7, 1.6, 1.5, 1.5, 1.6, 1.8, 2.0, 2.2, 2.1, 1.7, 1.1, 0.6}
+
+	// categorySeason — сезонный спрос категорий каталога по месяцам
+	categorySeason = map[string][12]float64{
+		"Электроника":   {0.8, 0.8, 0.9, 0.9, 0.9, 0.9, 0.9, 1.0, 1.1, 1.1, 1.5, 1.8},
+		"Одежда":        {0.9, 0.8, 1.1, 1.2, 1.1, 1.0, 0.9, 1.1, 1.3, 1.2, 1.1, 1.2},
+		"Спорт и отдых": {0.6, 0.6, 0.8, 1.2, 1.6, 1.8, 1.8, 1.5, 1.0, 0.7, 0.6, 0.8},
+		"Дом и сад":     {0.6, 0.7, 1.1, 1.6, 1.7, 1.4, 1.2, 1.1, 1.0, 0.8, 0.7, 0.9},
+		"Книги":         {1.1, 1.0, 1.0, 0.9, 0.8, 0.8, 0.9, 1.2, 1.3, 1.1, 1.0, 1.2},
+	}
+	// bulkCategories — категории, которые покупают по несколько штук
+	bulkCategories = map[string]bool{"Продукты питания": true}
+)
+
+// translit — латиница для адресов почты
+var translit = strings.NewReplacer(
+	"а", "a", "б", "b", "в", "v", "г", "g", "д", "d", "е", "e", "ё", "e", "ж", "zh", "з", "z", "и", "i",
+	"й", "y", "к", "k", "л", "l", "м", "m", "н", "n", "о", "o", "п", "p", "р", "r", "с", "s", "т", "t",
+	"у", "u", "ф", "f", "х", "kh", "ц", "ts", "ч", "ch", "ш", "sh", "щ", "sch", "ъ", "", "ы", "y", "ь", "",
+	"э", "e", "ю", "yu", "я", "ya",
+)
+
+// ====================================================================
+// GENERATOR
+// ====================================================================
+
+type generator struct {
+	opts Options
+	rnd  *rand.Rand
+
+	categories []string
+	products   int
+	byCategory map[string][]Product
+	// popularity — вес товара внутри категории: дешевые покупают чаще
+	popularity map[string]weights
+
+	customers []Customer
+	// activity — частота покупок покупателя: немногие постоянные покупатели
+	// делают большую часть заказов
+	activity weights
+	favorite []string
+
+	days    []time.Time
+	dayPick weights
+	hours   weights
+
+	orders []Order
+}
+
+func (g *generator) indexCatalog(products []Product) {
+	g.products = len(products)
+	g.byCategory = make(map[string][]Product)
+	for _, p := range products {
+		if _, ok := g.byCategory[p.Category]; !ok {
+			g.categories = append(g.categories, p.Category)
+		}
+		g.byCategory[p.Category] = append(g.byCategory[p.Category], p)
+	}
+	sort.Strings(g.categories)
+
+	g.popularity = make(map[string]weights, len(g.categories))
+	for _, category := range g.categories {
+		w := make([]float64, len(g.byCategory[category]))
+		for i, p := range g.byCategory[category] {
+			w[i] = 1 / math.Sqrt(float64(max(p.Price, 100)))
+		}
+		g.popularity[category] = newWeights(w)
+	}
+}
+
+func (g *generator) makeCustomers() {
+	cityPick := newWeights(cityWeights)
+	activity := make([]float64, g.opts.Customers)
+	g.favorite = make([]string, g.opts.Customers)
+	g.customers = make([]Customer, g.opts.Customers)
+
+	for i := range g.customers {
+		id := i + 1
+		var first, last string
+		if g.rnd.IntN(2) == 0 {
+			first, last = pick(g.rnd, maleNames), pick(g.rnd, lastNames)
+		} else {
+			first, last = pick(g.rnd, femaleNames), pick(g.rnd, lastNames)+"а"
+		}
+
+		g.customers[i] = Customer{
+			ID:        id,
+			FirstName: first,
+			LastName:  last,
+			Email: fmt.Sprintf("%s.%s%d@%s", translit.Replace(strings.ToLower(first)),
+				translit.Replace(strings.ToLower(last)), id, pick(g.rnd, emailDomains)),
+			Phone: fmt.Sprintf("+79%09d", g.rnd.IntN(1_000_000_000)),
+			City:  cities[cityPick.pick(g.rnd)],
+		}
+		// логнормальное распределение: длинный хвост частых покупателей
+		activity[i] = math.Exp(g.rnd.NormFloat64() * 1.1)
+		g.favorite[i] = pick(g.rnd, g.categories)
+	}
+
+	g.activity = newWeights(activity)
+}
+
+func (g *generator) makeDays() {
+	from, to := g.opts.From, g.opts.To
+	span := to.Sub(from).Hours() / 24
+
+	var w []float64
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		// рост продаж на 30% за период
+		trend := 1.0
+		if span > 0 {
+			trend += 0.3 * d.Sub(from).Hours() / 24 / span
+		}
+		g.days = append(g.days, d)
+		w = append(w, trend*monthWeights[d.Month()-1]*weekdayWeights[d.Weekday()]*holidayBoost(d))
+	}
+
+	g.dayPick = newWeights(w)
+	g.hours = newWeights(hourWeights)
+}
+
+// holidayBoost — всплески перед праздниками и в «черную пятницу»
+func holidayBoost(d time.Time) float64 {
+	switch {
+	case d.Month() == time.December && d.Day() >= 20:
+		return 1.8
+	case d.Month() == time.November && d.Day() >= 24:
+		return 1.6
+	case d.Month() == time.February && d.Day() >= 20 && d.Day() <= 23,
+		d.Month() == time.March && d.Day() >= 5 && d.Day() <= 8:
+		return 1.4
+	default:
+		return 1
+	}
+}
+
+func (g *generator) makeOrders() {
+	payment := newWeights(paymentMethodsWeights)
+	g.orders = make([]Order, g.opts.Orders)
+
+	for i := range g.orders {
+		customer := g.activity.pick(g.rnd)
+		day := g.days[g.dayPick.pick(g.rnd)]
+		date := time.Date(day.Year(), day.Month(), day.Day(), g.hours.pick(g.rnd), g.rnd.IntN(60), g.rnd.IntN(60), 0, msk)
+
+		order := Order{
+			ID:            i + 1,
+			CustomerID:    customer + 1,
+			OrderDate:     date,
+			PaymentMethod: paymentMethods[payment.pick(g.rnd)],
+		}
+
+		count := min(itemsCount(g.rnd), g.products)
+		seen := make(map[int]bool, count)
+		for len(order.Items) < count {
+			item := g.item(customer, date)
+			// товар повторяется в заказе количеством, а не второй позицией
+			if seen[item.ProductID] {
+				continue
+			}
+			seen[item.ProductID] = true
+			order.Items = append(order.Items, item)
+			order.Total += item.Price*int64(item.Quantity) - item.DiscountAmount
+		}
+
+		order.Status = "completed"
+		if g.rnd.Float64() < cancelRate(order) {
+			order.Status = "cancelled"
+		}
+		g.orders[i] = order
+	}
+
+	// номера заказов идут по времени, как в рабочей базе
+	sort.SliceStable(g.orders, func(i, j int) bool { return g.orders[i].OrderDate.Before(g.orders[j].OrderDate) })
+	for i := range g.orders {
+		g.orders[i].ID = i + 1
+	}
+}
+
+// item — позиция: чаще из любимой категории покупателя, с учетом сезона
+func (g *generator) item(customer int, date time.Time) Item {
+	category := g.favorite[customer]
+	if g.rnd.Float64() >= 0.45 {
+		w := make([]float64, len(g.categories))
+		for i, c := range g.categories {
+			w[i] = 1
+			if season, ok := categorySeason[c]; ok {
+				w[i] = season[date.Month()-1]
+			}
+		}
+		category = g.categories[newWeights(w).pick(g.rnd)]
+	}
+
+	products := g.byCategory[category]
+	p := products[g.popularity[category].pick(g.rnd)]
+
+	quantity := 1
+	switch {
+	case bulkCategories[category]:
+		quantity = 1 + g.rnd.IntN(5)
+	case g.rnd.Float64() < 0.15:
+		quantity = 2
+	}
+
+	discount := discountFor(g.rnd, date)
+	gross := p.Price * int64(quantity)
+	return Item{
+		ProductID: p.ID,
+		Quantity:  quantity,
+		Price:     p.Price,
+		Discount:  discount,
+		// округление до копейки, как NUMERIC(12, 2)
+		DiscountAmount: (gross*int64(discount) + 50) / 100,
+	}
+}
+
+// discountFor — скидка в процентах: в распродажи чаще и больше
+func discountFor(rnd *rand.Rand, date time.Time) int {
+	sale := holidayBoost(date) > 1
+	x := rnd.Float64()
+	switch {
+	case sale && x < 0.4, !sale && x < 0.7:
+		return 0
+	case sale && x < 0.6, !sale && x < 0.85:
+		return 5
+	case sale && x < 0.85, !sale && x < 0.95:
+		return 10
+	default:
+		return 15
+	}
+}
+
+// itemsCount — позиций в заказе: 1–5, чаще одна-две
+func itemsCount(rnd *rand.Rand) int {
+	x := rnd.Float64()
+	switch {
+	case x < 0.4:
+		return 1
+	case x < 0.7:
+		return 2
+	case x < 0.85:
+		return 3
+	case x < 0.95:
+		return 4
+	default:
+		return 5
+	}
+}
+
+// cancelRate — дорогие заказы и оплата при получении отменяют чаще
+func cancelRate(o Order) float64 {
+	rate := 0.04
+	if o.Total > 100_000_00 {
+		rate += 0.05
+	}
+	if o.PaymentMethod == "Наличные" {
+		rate += 0.03
+	}
+	return rate
+}
+
+// assignRegistration — регистрация до первого заказа; покупатели без
+// заказов регистрируются в любой день периода
+func (g *generator) assignRegistration() {
+	first := make([]time.Time, len(g.customers))
+	for _, o := range g.orders {
+		i := o.CustomerID - 1
+		if first[i].IsZero() || o.OrderDate.Before(first[i]) {
+			first[i] = o.OrderDate
+		}
+	}
+
+	for i := range g.customers {
+		var date time.Time
+		if first[i].IsZero() {
+			date = g.days[g.rnd.IntN(len(g.days))]
+		} else {
+			date = first[i].AddDate(0, 0, -g.rnd.IntN(180))
+		}
+		g.customers[i].RegistrationDate = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// ====================================================================
+// HELPERS
+// ====================================================================
+
+// weights — выбор индекса с вероятностью, пропорциональной весу
+type weights struct {
+	cumulative []float64
+}
+
+func newWeights(w []float64) weights {
+	cumulative := make([]float64, len(w))
+	var sum float64
+	for i, v := range w {
+		sum += v
+		cumulative[i] = sum
+	}
+	return weights{cumulative: cumulative}
+}
+
+func (w weights) pick(rnd *rand.Rand) int {
+	x := rnd.Float64() * w.cumulative[len(w.cumulative)-1]
+	return sort.Search(len(w.cumulative), func(i int) bool { return w.cumulative[i] > x })
+}
+
+func pick[T any](rnd *rand.Rand, values []T) T {
+	return values[rnd.IntN(len(values))]
+}
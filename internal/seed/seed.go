@@ -0,0 +1,229 @@
+package seed
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ====================================================================
+// SEED - Воспроизводимые тестовые данные
+// ====================================================================
+
+// Options — параметры генерации. Один Seed — одни и те же данные,
+// поэтому тесты аналитики могут проверять точные числа.
+type Options struct {
+	Seed      uint64
+	Customers int
+	Orders    int
+	// From, To — первый и последний день заказов
+	From time.Time
+	To   time.Time
+}
+
+// DefaultOptions — 200 покупателей и 5000 заказов за 2024 год и январь 2025
+func DefaultOptions() Options {
+	return Options{
+		Seed:      1,
+		Customers: 200,
+		Orders:    5000,
+		From:      time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		To:        time.Date(2025, time.January, 31, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func (o Options) validate() error {
+	switch {
+	case o.Customers <= 0:
+		return fmt.Errorf("customers must be positive")
+	case o.Orders < 0:
+		return fmt.Errorf("orders must not be negative")
+	case o.From.IsZero() || o.To.IsZero() || o.To.Before(o.From):
+		return fmt.Errorf("period must be set and end after it starts")
+	}
+	return nil
+}
+
+// Summary — сколько строк загружено
+type Summary struct {
+	Categories int
+	Products   int
+	Customers  int
+	Orders     int
+	Items      int
+}
+
+// Fill — загрузить каталог catalogSQL и сгенерированные по нему данные в tx.
+// Таблицы должны быть пустыми: номера строк задаются явно.
+func Fill(ctx context.Context, tx *sql.Tx, catalogSQL string, opts Options) (*Summary, error) {
+	const op = "seed.Fill"
+
+	if _, err := tx.ExecContext(ctx, catalogSQL); err != nil {
+		return nil, fmt.Errorf("%s: catalog: %w", op, err)
+	}
+
+	products, err := loadProducts(ctx, tx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	data, err := Generate(opts, products)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	summary := &Summary{Products: len(products), Customers: len(data.Customers), Orders: len(data.Orders)}
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM categories`).Scan(&summary.Categories); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := copyCustomers(ctx, tx, data.Customers); err != nil {
+		return nil, fmt.Errorf("%s: customers: %w", op, err)
+	}
+	if err := copyOrders(ctx, tx, data.Orders); err != nil {
+		return nil, fmt.Errorf("%s: orders: %w", op, err)
+	}
+	if summary.Items, err = copyItems(ctx, tx, data.Orders); err != nil {
+		return nil, fmt.Errorf("%s: order items: %w", op, err)
+	}
+
+	// последовательности продолжаются после заданных id; дни, которые
+	// COPY отметил в очереди сводки, пересчитываются сразу, чтобы отчеты
+	// по сиду читали готовую сводку
+	_, err = tx.ExecContext(ctx, `SELECT setval(pg_get_serial_sequence('categories', 'category_id'), COALESCE(MAX(category_id), 0) + 1, false) FROM categories;
+			SELECT setval(pg_get_serial_sequence('products', 'product_id'), COALESCE(MAX(product_id), 0) + 1, false) FROM products;
+			SELECT setval(pg_get_serial_sequence('customers', 'customer_id'), COALESCE(MAX(customer_id), 0) + 1, false) FROM customers;
+			SELECT setval(pg_get_serial_sequence('orders', 'order_id'), COALESCE(MAX(order_id), 0) + 1, false) FROM orders;
+			SELECT setval(pg_get_serial_sequence('order_items', 'order_item_id'), COALESCE(MAX(order_item_id), 0) + 1, false) FROM order_items;
+			SELECT drain_daily_sales_rollup(NULL, NULL)`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return summary, nil
+}
+
+// ====================================================================
+// HELPERS
+// ====================================================================
+
+func loadProducts(ctx context.Context, tx *sql.Tx) ([]Product, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT p.product_id, COALESCE(c.category_name, ''), p.price::text
+			FROM products p
+			LEFT JOIN categories c ON c.category_id = p.category_id
+			WHERE p.deleted_at IS NULL
+			ORDER BY p.product_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []Product
+	for rows.Next() {
+		var p Product
+		var price string
+		if err := rows.Scan(&p.ID, &p.Category, &price); err != nil {
+			return nil, err
+		}
+		if p.Price, err = parseKopecks(price); err != nil {
+			return nil, fmt.Errorf("product %d: %w", p.ID, err)
+		}
+		products = append(products, p)
+	}
+
+	return products, rows.Err()
+}
+
+func copyCustomers(ctx context.Context, tx *sql.Tx, customers []Customer) error {
+	return copyRows(ctx, tx, pq.CopyIn("customers", "customer_id", "first_name", "last_name", "email", "phone", "city",
+		"registration_date"), func(row func(values ...any) error) error {
+		for _, c := range customers {
+			if err := row(c.ID, c.FirstName, c.LastName, c.Email, c.Phone, c.City, c.RegistrationDate.Format("2006-01-02")); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func copyOrders(ctx context.Context, tx *sql.Tx, orders []Order) error {
+	return copyRows(ctx, tx, pq.CopyIn("orders", "order_id", "customer_id", "order_date", "status", "total_amount",
+		"payment_method"), func(row func(values ...any) error) error {
+		for _, o := range orders {
+			if err := row(o.ID, o.CustomerID, o.OrderDate, o.Status, formatKopecks(o.Total), o.PaymentMethod); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func copyItems(ctx context.Context, tx *sql.Tx, orders []Order) (int, error) {
+	id := 0
+	err := copyRows(ctx, tx, pq.CopyIn("order_items", "order_item_id", "order_id", "product_id", "quantity", "price",
+		"discount", "discount_amount"), func(row func(values ...any) error) error {
+		for _, o := range orders {
+			for _, item := range o.Items {
+				id++
+				err := row(id, o.ID, item.ProductID, item.Quantity, formatKopecks(item.Price), item.Discount,
+					formatKopecks(item.DiscountAmount))
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return id, err
+}
+
+// copyRows — COPY FROM STDIN: строки передает write через row
+func copyRows(ctx context.Context, tx *sql.Tx, query string, write func(row func(values ...any) error) error) error {
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	err = write(func(values ...any) error {
+		_, err := stmt.ExecContext(ctx, values...)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.ExecContext(ctx)
+	return err
+}
+
+// parseKopecks — NUMERIC(10, 2) в копейках без потерь float
+func parseKopecks(value string) (int64, error) {
+	whole, frac, _ := strings.Cut(value, ".")
+	frac = (frac + "00")[:2]
+	rubles, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	kopecks, err := strconv.ParseInt(frac, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if strings.HasPrefix(whole, "-") {
+		return rubles*100 - kopecks, nil
+	}
+	return rubles*100 + kopecks, nil
+}
+
+func formatKopecks(value int64) string {
+	sign := ""
+	if value < 0 {
+		sign, value = "-", -value
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, value/100, value%100)
+}
@@ -0,0 +1,252 @@
+package seed
+
+import (
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"salesTracker/migrations"
+)
+
+// catalogProducts — товары из встроенного каталога в том виде, в каком
+// их читает loadProducts
+func catalogProducts(t *testing.T) []Product {
+	t.Helper()
+
+	categories := map[int]string{}
+	for _, m := range regexp.MustCompile(`(?m)^\s+\((\d+), '([^']+)', '`).FindAllStringSubmatch(migrations.Catalog, -1) {
+		id, _ := strconv.Atoi(m[1])
+		categories[id] = m[2]
+	}
+
+	var products []Product
+	for _, m := range regexp.MustCompile(`(?m)^\((\d+), '[^']+', (\d+), ([\d.]+),`).FindAllStringSubmatch(migrations.Catalog, -1) {
+		id, _ := strconv.Atoi(m[1])
+		categoryID, _ := strconv.Atoi(m[2])
+		category, ok := categories[categoryID]
+		if !ok {
+			t.Fatalf("product %d refers to unknown category %d", id, categoryID)
+		}
+		price, err := parseKopecks(m[3])
+		if err != nil {
+			t.Fatal(err)
+		}
+		products = append(products, Product{ID: id, Category: category, Price: price})
+	}
+
+	if len(categories) != 6 || len(products) != 30 {
+		t.Fatalf("catalog has %d categories and %d products, want 6 and 30", len(categories), len(products))
+	}
+	// номера заданы явно и идут подряд: сид не зависит от последовательностей
+	for i, p := range products {
+		if p.ID != i+1 {
+			t.Fatalf("product #%d has id %d", i+1, p.ID)
+		}
+	}
+	return products
+}
+
+func TestGenerateDeterministic(t *testing.T) {
+	products := catalogProducts(t)
+	opts := DefaultOptions()
+
+	first, err := Generate(opts, products)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	second, err := Generate(opts, products)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Fatal("Generate() with the same seed produced different data")
+	}
+
+	opts.Seed++
+	other, err := Generate(opts, products)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.DeepEqual(first.Orders, other.Orders) {
+		t.Error("Generate() with another seed produced the same orders")
+	}
+}
+
+func TestGenerateConsistency(t *testing.T) {
+	opts := DefaultOptions()
+	data, err := Generate(opts, catalogProducts(t))
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if len(data.Customers) != opts.Customers || len(data.Orders) != opts.Orders {
+		t.Fatalf("got %d customers and %d orders", len(data.Customers), len(data.Orders))
+	}
+
+	emails := map[string]bool{}
+	for i, c := range data.Customers {
+		if c.ID != i+1 || c.Email == "" || emails[c.Email] {
+			t.Fatalf("customer %+v: id out of order or email empty or repeated", c)
+		}
+		emails[c.Email] = true
+	}
+
+	day := func(t time.Time) string { return t.In(msk).Format(time.DateOnly) }
+	firstOrder := map[int]time.Time{}
+	for i, o := range data.Orders {
+		// номера заказов идут по времени
+		if o.ID != i+1 || i > 0 && o.OrderDate.Before(data.Orders[i-1].OrderDate) {
+			t.Fatalf("order %d is out of order", o.ID)
+		}
+		if d := day(o.OrderDate); d < opts.From.Format(time.DateOnly) || d > opts.To.Format(time.DateOnly) {
+			t.Fatalf("order %d on %s is outside the period", o.ID, d)
+		}
+		if o.Status != "completed" && o.Status != "cancelled" {
+			t.Fatalf("order %d status %q", o.ID, o.Status)
+		}
+		if len(o.Items) == 0 || len(o.Items) > 5 {
+			t.Fatalf("order %d has %d items", o.ID, len(o.Items))
+		}
+
+		// сумма заказа сходится с позициями до копейки
+		var total int64
+		seen := map[int]bool{}
+		for _, item := range o.Items {
+			if seen[item.ProductID] || item.Quantity <= 0 {
+				t.Fatalf("order %d: item %+v repeated or empty", o.ID, item)
+			}
+			seen[item.ProductID] = true
+			switch item.Discount {
+			case 0, 5, 10, 15:
+			default:
+				t.Fatalf("order %d: discount %d%%", o.ID, item.Discount)
+			}
+			gross := item.Price * int64(item.Quantity)
+			if want := (gross*int64(item.Discount) + 50) / 100; item.DiscountAmount != want {
+				t.Fatalf("order %d: discount amount %d, want %d", o.ID, item.DiscountAmount, want)
+			}
+			total += gross - item.DiscountAmount
+		}
+		if o.Total != total {
+			t.Fatalf("order %d: total %d, items sum to %d", o.ID, o.Total, total)
+		}
+
+		if first, ok := firstOrder[o.CustomerID]; !ok || o.OrderDate.Before(first) {
+			firstOrder[o.CustomerID] = o.OrderDate
+		}
+	}
+
+	// покупатель зарегистрирован не позже первого заказа
+	for _, c := range data.Customers {
+		if first, ok := firstOrder[c.ID]; ok && c.RegistrationDate.Format(time.DateOnly) > day(first) {
+			t.Errorf("customer %d registered on %s after the first order on %s", c.ID, c.RegistrationDate.Format(time.DateOnly), day(first))
+		}
+	}
+}
+
+func TestGenerateShape(t *testing.T) {
+	opts := DefaultOptions()
+	data, err := Generate(opts, catalogProducts(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var january, december, cancelled int
+	perCustomer := map[int]int{}
+	for _, o := range data.Orders {
+		switch d := o.OrderDate.In(msk); {
+		case d.Year() == 2024 && d.Month() == time.January:
+			january++
+		case d.Year() == 2024 && d.Month() == time.December:
+			december++
+		}
+		if o.Status == "cancelled" {
+			cancelled++
+		}
+		perCustomer[o.CustomerID]++
+	}
+
+	// сезонность: декабрь с праздниками заметно сильнее января
+	if float64(december) < 1.5*float64(january) {
+		t.Errorf("December has %d orders, January %d, want a seasonal peak", december, january)
+	}
+	if rate := float64(cancelled) / float64(len(data.Orders)); rate < 0.02 || rate > 0.15 {
+		t.Errorf("cancellation rate = %.3f, want a few percent", rate)
+	}
+
+	// повторные покупки: десятая часть покупателей делает заметную долю заказов
+	counts := make([]int, 0, len(perCustomer))
+	for _, n := range perCustomer {
+		counts = append(counts, n)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(counts)))
+	top := 0
+	for _, n := range counts[:opts.Customers/10] {
+		top += n
+	}
+	if share := float64(top) / float64(len(data.Orders)); share < 0.25 {
+		t.Errorf("top 10%% of customers place %.2f of orders, want repeat buyers", share)
+	}
+}
+
+func TestGenerateRejects(t *testing.T) {
+	products := []Product{{ID: 1, Category: "Книги", Price: 55000}}
+	valid := DefaultOptions()
+
+	tests := []struct {
+		name     string
+		modify   func(o *Options)
+		products []Product
+	}{
+		{name: "no customers", modify: func(o *Options) { o.Customers = 0 }, products: products},
+		{name: "negative orders", modify: func(o *Options) { o.Orders = -1 }, products: products},
+		{name: "no period", modify: func(o *Options) { o.From = time.Time{} }, products: products},
+		{name: "period backwards", modify: func(o *Options) { o.From, o.To = o.To, o.From }, products: products},
+		{name: "empty catalog", modify: func(o *Options) {}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := valid
+			tt.modify(&opts)
+			if _, err := Generate(opts, tt.products); err == nil {
+				t.Error("Generate() error = nil")
+			}
+		})
+	}
+
+	// один день и ни одного заказа — допустимо
+	opts := valid
+	opts.Orders, opts.To = 0, opts.From
+	if data, err := Generate(opts, products); err != nil || len(data.Orders) != 0 {
+		t.Errorf("Generate() = %v, %v", data, err)
+	}
+}
+
+func TestKopecks(t *testing.T) {
+	tests := []struct {
+		text    string
+		kopecks int64
+		format  string
+	}{
+		{text: "65000.00", kopecks: 6500000, format: "65000.00"},
+		{text: "450.5", kopecks: 45050, format: "450.50"},
+		{text: "12", kopecks: 1200, format: "12.00"},
+		{text: "0.07", kopecks: 7, format: "0.07"},
+		{text: "-0.50", kopecks: -50, format: "-0.50"},
+		{text: "-12.34", kopecks: -1234, format: "-12.34"},
+	}
+	for _, tt := range tests {
+		got, err := parseKopecks(tt.text)
+		if err != nil || got != tt.kopecks {
+			t.Errorf("parseKopecks(%q) = %d, %v, want %d", tt.text, got, err, tt.kopecks)
+		}
+		if got := formatKopecks(tt.kopecks); got != tt.format {
+			t.Errorf("formatKopecks(%d) = %q, want %q", tt.kopecks, got, tt.format)
+		}
+	}
+	if _, err := parseKopecks("abc"); err == nil {
+		t.Error("parseKopecks(abc) error = nil")
+	}
+}
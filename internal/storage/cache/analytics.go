@@ -0,0 +1,144 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"salesTracker/internal/storage/postgresql"
+)
+
+// ====================================================================
+// ANALYTICS CACHE - Кэширующий декоратор аналитики
+// ====================================================================
+
+// Analytics — аналитические методы хранилища
+type Analytics interface {
+	TotalRevenueByPeriod(ctx context.Context, start, end time.Time, currency string) (*postgresql.PeriodSummary, error)
+	OrdersPerDay(ctx context.Context, start, end time.Time, currency string) ([]postgresql.DailyOrders, error)
+	AverageCheckByPeriod(ctx context.Context, start, end time.Time, currency string) (*postgresql.AverageCheckStats, error)
+	OrdersMedian(ctx context.Context, start, end time.Time, currency string) (*postgresql.MedianStats, error)
+	CustomerSpendingMedian(ctx context.Context, start, end time.Time, currency string) (*postgresql.MedianStats, error)
+	OrdersPercentile(ctx context.Context, start, end time.Time, percentile int, currency string) (*postgresql.PercentileStats, error)
+	CustomerSpendingPercentile(ctx context.Context, start, end time.Time, percentile int, currency string) (*postgresql.PercentileStats, error)
+	GenerateSalesReport(ctx context.Context, start, end time.Time, currency string) (*postgresql.SalesReport, error)
+	ReturnRateByProduct(ctx context.Context, start, end time.Time, currency string) ([]postgresql.ProductReturnRate, error)
+	PromotionsReport(ctx context.Context, start, end time.Time, currency string) ([]postgresql.PromotionStats, error)
+}
+
+// CachedAnalytics — декоратор, кэширующий результаты аналитики в LRU.
+// Ключ — имя метода и параметры; записи истекают по TTL и сбрасываются
+// через InvalidateRange при изменении заказов внутри их периода.
+type CachedAnalytics struct {
+	inner Analytics
+	lru   *LRU
+}
+
+func NewAnalytics(inner Analytics, size int, ttl time.Duration) *CachedAnalytics {
+	return &CachedAnalytics{
+		inner: inner,
+		lru:   NewLRU(size, ttl),
+	}
+}
+
+// InvalidateRange — сбросить результаты, посчитанные по периодам,
+// пересекающимся с [start, end]. Периоды запрашиваются в разных часовых
+// поясах, поэтому диапазон расширяется на сутки в обе стороны.
+func (c *CachedAnalytics) InvalidateRange(start, end time.Time) {
+	c.lru.InvalidateRange(dayOf(start).AddDate(0, 0, -1), dayOf(end).AddDate(0, 0, 1))
+}
+
+func (c *CachedAnalytics) TotalRevenueByPeriod(ctx context.Context, start, end time.Time, currency string) (*postgresql.PeriodSummary, error) {
+	return cached(c, key("TotalRevenueByPeriod", start, end, currency), start, end, func() (*postgresql.PeriodSummary, error) {
+		return c.inner.TotalRevenueByPeriod(ctx, start, end, currency)
+	})
+}
+
+func (c *CachedAnalytics) OrdersPerDay(ctx context.Context, start, end time.Time, currency string) ([]postgresql.DailyOrders, error) {
+	return cached(c, key("OrdersPerDay", start, end, currency), start, end, func() ([]postgresql.DailyOrders, error) {
+		return c.inner.OrdersPerDay(ctx, start, end, currency)
+	})
+}
+
+func (c *CachedAnalytics) AverageCheckByPeriod(ctx context.Context, start, end time.Time, currency string) (*postgresql.AverageCheckStats, error) {
+	return cached(c, key("AverageCheckByPeriod", start, end, currency), start, end, func() (*postgresql.AverageCheckStats, error) {
+		return c.inner.AverageCheckByPeriod(ctx, start, end, currency)
+	})
+}
+
+func (c *CachedAnalytics) OrdersMedian(ctx context.Context, start, end time.Time, currency string) (*postgresql.MedianStats, error) {
+	return cached(c, key("OrdersMedian", start, end, currency), start, end, func() (*postgresql.MedianStats, error) {
+		return c.inner.OrdersMedian(ctx, start, end, currency)
+	})
+}
+
+func (c *CachedAnalytics) CustomerSpendingMedian(ctx context.Context, start, end time.Time, currency string) (*postgresql.MedianStats, error) {
+	return cached(c, key("CustomerSpendingMedian", start, end, currency), start, end, func() (*postgresql.MedianStats, error) {
+		return c.inner.CustomerSpendingMedian(ctx, start, end, currency)
+	})
+}
+
+func (c *CachedAnalytics) OrdersPercentile(ctx context.Context, start, end time.Time, percentile int, currency string) (*postgresql.PercentileStats, error) {
+	return cached(c, key("OrdersPercentile", start, end, currency, percentile), start, end, func() (*postgresql.PercentileStats, error) {
+		return c.inner.OrdersPercentile(ctx, start, end, percentile, currency)
+	})
+}
+
+func (c *CachedAnalytics) CustomerSpendingPercentile(ctx context.Context, start, end time.Time, percentile int, currency string) (*postgresql.PercentileStats, error) {
+	return cached(c, key("CustomerSpendingPercentile", start, end, currency, percentile), start, end, func() (*postgresql.PercentileStats, error) {
+		return c.inner.CustomerSpendingPercentile(ctx, start, end, percentile, currency)
+	})
+}
+
+func (c *CachedAnalytics) GenerateSalesReport(ctx context.Context, start, end time.Time, currency string) (*postgresql.SalesReport, error) {
+	return cached(c, key("GenerateSalesReport", start, end, currency), start, end, func() (*postgresql.SalesReport, error) {
+		return c.inner.GenerateSalesReport(ctx, start, end, currency)
+	})
+}
+
+func (c *CachedAnalytics) ReturnRateByProduct(ctx context.Context, start, end time.Time, currency string) ([]postgresql.ProductReturnRate, error) {
+	return cached(c, key("ReturnRateByProduct", start, end, currency), start, end, func() ([]postgresql.ProductReturnRate, error) {
+		return c.inner.ReturnRateByProduct(ctx, start, end, currency)
+	})
+}
+
+func (c *CachedAnalytics) PromotionsReport(ctx context.Context, start, end time.Time, currency string) ([]postgresql.PromotionStats, error) {
+	return cached(c, key("PromotionsReport", start, end, currency), start, end, func() ([]postgresql.PromotionStats, error) {
+		return c.inner.PromotionsReport(ctx, start, end, currency)
+	})
+}
+
+// ====================================================================
+// HELPERS
+// ====================================================================
+
+// cached — вернуть результат из кэша или посчитать и сохранить его.
+// Ошибки не кэшируются. Результат, во время расчета которого кэш сбрасывался,
+// возвращается, но не сохраняется: он мог прочитать данные до изменения.
+func cached[T any](c *CachedAnalytics, k string, start, end time.Time, load func() (T, error)) (T, error) {
+	if v, ok := c.lru.Get(k); ok {
+		return v.(T), nil
+	}
+
+	generation := c.lru.Generation()
+	v, err := load()
+	if err != nil {
+		return v, err
+	}
+
+	c.lru.Set(k, v, dayOf(start), dayOf(end), generation)
+	return v, nil
+}
+
+func key(method string, start, end time.Time, params ...any) string {
+	k := fmt.Sprintf("%s|%s|%s|%s", method, start.Format(time.RFC3339), end.Format(time.RFC3339), start.Location())
+	for _, p := range params {
+		k += fmt.Sprintf("|%v", p)
+	}
+	return k
+}
+
+// dayOf — начало календарного дня: аналитика оперирует целыми днями
+func dayOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,34 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCachedSkipsStaleResult(t *testing.T) {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := &CachedAnalytics{lru: NewLRU(10, time.Hour)}
+
+	// заказ изменился и кэш сброшен, пока результат считался
+	v, err := cached(c, "k", day, day, func() (int, error) {
+		c.InvalidateRange(day, day)
+		return 1, nil
+	})
+	if err != nil || v != 1 {
+		t.Fatalf("cached() = %v, %v, want 1, nil", v, err)
+	}
+	if _, ok := c.lru.Get("k"); ok {
+		t.Fatal("result computed across an invalidation is cached")
+	}
+
+	calls := 0
+	load := func() (int, error) { calls++; return 2, nil }
+	for range 2 {
+		if v, _ := cached(c, "k", day, day, load); v != 2 {
+			t.Fatalf("cached() = %v, want 2", v)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("load called %d times, want 1", calls)
+	}
+}
@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// ====================================================================
+// LRU - Кэш с вытеснением давно неиспользуемых записей и TTL
+// ====================================================================
+
+type entry struct {
+	key       string
+	value     any
+	start     time.Time
+	end       time.Time
+	expiresAt time.Time
+}
+
+// LRU — потокобезопасный кэш фиксированного размера. Каждая запись помнит
+// диапазон дат, по которому она посчитана, чтобы ее можно было сбросить
+// при изменении данных внутри этого диапазона. Счетчик generation растет
+// с каждым сбросом: значение, посчитанное до сброса, не сохраняется после него.
+type LRU struct {
+	mu         sync.Mutex
+	size       int
+	ttl        time.Duration
+	order      *list.List
+	entries    map[string]*list.Element
+	generation uint64
+}
+
+func NewLRU(size int, ttl time.Duration) *LRU {
+	return &LRU{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element, size),
+	}
+}
+
+// Get — значение по ключу, если оно есть и не просрочено
+func (c *LRU) Get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	e := el.Value.(*entry)
+	if time.Now().After(e.expiresAt) {
+		c.remove(el)
+		return nil, false
+	}
+
+	c.order.MoveToFront(el)
+	return e.value, true
+}
+
+// Generation — текущее значение счетчика сбросов; читается перед
+// вычислением значения и передается в Set
+func (c *LRU) Generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generation
+}
+
+// Set — сохранить значение, посчитанное по диапазону [start, end].
+// Если после Generation() == generation был сброс, значение могло быть
+// посчитано по старым данным и не сохраняется; false — не сохранено.
+func (c *LRU) Set(key string, value any, start, end time.Time, generation uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return false
+	}
+
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+
+	c.entries[key] = c.order.PushFront(&entry{
+		key:       key,
+		value:     value,
+		start:     start,
+		end:       end,
+		expiresAt: time.Now().Add(c.ttl),
+	})
+
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+	return true
+}
+
+// InvalidateRange — удалить записи, чей диапазон пересекается с [start, end]
+func (c *LRU) InvalidateRange(start, end time.Time) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	removed := 0
+	for el := c.order.Front(); el != nil; {
+		next := el.Next()
+		e := el.Value.(*entry)
+		if !e.start.After(end) && !e.end.Before(start) {
+			c.remove(el)
+			removed++
+		}
+		el = next
+	}
+
+	return removed
+}
+
+// Len — текущее количество записей
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+func (c *LRU) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*entry).key)
+}
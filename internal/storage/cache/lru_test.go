@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUEviction(t *testing.T) {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		size int
+		// ops — "set:k" сохраняет k, "get:k" читает k
+		ops  []string
+		want map[string]bool
+	}{
+		{
+			name: "under capacity",
+			size: 3,
+			ops:  []string{"set:a", "set:b"},
+			want: map[string]bool{"a": true, "b": true},
+		},
+		{
+			name: "oldest evicted",
+			size: 2,
+			ops:  []string{"set:a", "set:b", "set:c"},
+			want: map[string]bool{"a": false, "b": true, "c": true},
+		},
+		{
+			name: "get refreshes recency",
+			size: 2,
+			ops:  []string{"set:a", "set:b", "get:a", "set:c"},
+			want: map[string]bool{"a": true, "b": false, "c": true},
+		},
+		{
+			name: "overwrite refreshes recency",
+			size: 2,
+			ops:  []string{"set:a", "set:b", "set:a", "set:c"},
+			want: map[string]bool{"a": true, "b": false, "c": true},
+		},
+		{
+			name: "size one",
+			size: 1,
+			ops:  []string{"set:a", "set:b"},
+			want: map[string]bool{"a": false, "b": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewLRU(tt.size, time.Hour)
+			for _, op := range tt.ops {
+				switch k := op[4:]; op[:4] {
+				case "set:":
+					c.Set(k, k, day, day, c.Generation())
+				case "get:":
+					c.Get(k)
+				}
+			}
+
+			for k, present := range tt.want {
+				if _, ok := c.Get(k); ok != present {
+					t.Errorf("Get(%q) present = %v, want %v", k, ok, present)
+				}
+			}
+			if c.Len() > tt.size {
+				t.Errorf("Len() = %d, want at most %d", c.Len(), tt.size)
+			}
+		})
+	}
+}
+
+func TestLRUTTL(t *testing.T) {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		want bool
+	}{
+		{name: "fresh", ttl: time.Hour, want: true},
+		{name: "expired", ttl: -time.Second, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewLRU(10, tt.ttl)
+			c.Set("k", 1, day, day, c.Generation())
+
+			if _, ok := c.Get("k"); ok != tt.want {
+				t.Errorf("Get() present = %v, want %v", ok, tt.want)
+			}
+			// просроченная запись удаляется при чтении
+			if !tt.want && c.Len() != 0 {
+				t.Errorf("Len() = %d after reading an expired entry, want 0", c.Len())
+			}
+		})
+	}
+}
+
+func TestLRUInvalidateRange(t *testing.T) {
+	date := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+
+	// записи: jan1-5, jan10-10, jan20-31
+	tests := []struct {
+		name        string
+		start, end  time.Time
+		wantRemoved int
+		want        map[string]bool
+	}{
+		{name: "no overlap", start: date(6), end: date(9), wantRemoved: 0,
+			want: map[string]bool{"early": true, "day": true, "late": true}},
+		{name: "touches boundary", start: date(5), end: date(5), wantRemoved: 1,
+			want: map[string]bool{"early": false, "day": true, "late": true}},
+		{name: "inside single day", start: date(10), end: date(10), wantRemoved: 1,
+			want: map[string]bool{"early": true, "day": false, "late": true}},
+		{name: "spans several", start: date(3), end: date(25), wantRemoved: 3,
+			want: map[string]bool{"early": false, "day": false, "late": false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewLRU(10, time.Hour)
+			c.Set("early", 1, date(1), date(5), c.Generation())
+			c.Set("day", 2, date(10), date(10), c.Generation())
+			c.Set("late", 3, date(20), date(31), c.Generation())
+
+			if removed := c.InvalidateRange(tt.start, tt.end); removed != tt.wantRemoved {
+				t.Errorf("InvalidateRange() = %d, want %d", removed, tt.wantRemoved)
+			}
+			for k, present := range tt.want {
+				if _, ok := c.Get(k); ok != present {
+					t.Errorf("Get(%q) present = %v, want %v", k, ok, present)
+				}
+			}
+		})
+	}
+}
+
+func TestLRUSetAfterInvalidation(t *testing.T) {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewLRU(10, time.Hour)
+
+	generation := c.Generation()
+	c.InvalidateRange(day, day)
+	if c.Set("k", 1, day, day, generation) {
+		t.Fatal("Set() with a generation from before the invalidation = true, want false")
+	}
+	if _, ok := c.Get("k"); ok {
+		t.Fatal("value computed before the invalidation is cached")
+	}
+
+	if !c.Set("k", 1, day, day, c.Generation()) {
+		t.Fatal("Set() with the current generation = false, want true")
+	}
+}
@@ -1,9 +1,9 @@
 package postgresql
 
 import (
+	"context"
 	"fmt"
 	_ "github.com/lib/pq"
-	"math"
 	"time"
 )
 
@@ -14,39 +14,71 @@ const packageOp = "storage.postgresql.analytics."
 // ANALYTICS - Аналитические функции
 // ====================================================================
 
-// PeriodSummary — суммарные показатели за период
+// PeriodSummary — суммарные показатели за период. TotalRevenue — валовая выручка
+// по заказам периода, Refunds — возвраты, оформленные в этом периоде (по дате
+// возврата, а не заказа: закрытый период не меняется от поздних возвратов),
+// NetRevenue — разность.
 type PeriodSummary struct {
 	StartDate    time.Time `json:"start_date"`
 	EndDate      time.Time `json:"end_date"`
 	TotalRevenue float64   `json:"total_revenue"`
+	Refunds      float64   `json:"refunds"`
+	NetRevenue   float64   `json:"net_revenue"`
 	OrderCount   int       `json:"order_count"`
+	Currency     string    `json:"currency,omitempty"`
 }
 
 // TotalRevenueByPeriod — получить сумму заказов за определенный период
-func (s *Storage) TotalRevenueByPeriod(start, end time.Time) (*PeriodSummary, error) {
+func (s *Storage) TotalRevenueByPeriod(ctx context.Context, start, end time.Time, currency string) (*PeriodSummary, error) {
 	const op = packageOp + "TotalRevenueByPeriod"
-	query := `SELECT SUM(total_amount), COUNT(*)
+
+	ctx, done := s.analytics(ctx, op)
+	defer done()
+
+	from, to := periodBounds(start, end)
+	if err := s.checkExchangeRates(ctx, from, to, currency); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `SELECT COALESCE(SUM(convert_amount(total_amount, currency, $3, utc_day(order_date))), 0), COUNT(*)
 			FROM orders
-			WHERE order_date BETWEEN $1 AND $2`
+			WHERE order_date >= $1 AND order_date < $2 AND deleted_at IS NULL`
+	args := []any{from, to, currency}
+	if useRollup(end) {
+		query = `SELECT COALESCE(SUM(convert_amount(total_amount, currency, $3, sale_date)), 0),
+				COALESCE(SUM(order_count), 0)
+			FROM daily_sales_rollup
+			WHERE category_id IS NULL AND sale_date BETWEEN $1 AND $2`
+		args = []any{dateParam(start), dateParam(end), currency}
+		if err := s.syncRollup(ctx, start, end); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
 
 	var (
 		totalRevenue float64
 		ordersAmount int
 	)
 
-	err := s.DB.QueryRow(query, start, end).Scan(&totalRevenue, &ordersAmount)
+	// период без заказов — нулевая сводка, а не ошибка
+	err := s.DB.QueryRowContext(ctx, query, args...).Scan(&totalRevenue, &ordersAmount)
 	if err != nil {
-		return nil, fmt.Errorf("getting total revenue by period error; %s, %v", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	if ordersAmount < 1 || totalRevenue < 0 {
-		return nil, fmt.Errorf("invalid meaning; %s", op)
+	refunds, err := s.refundsByPeriod(ctx, from, to, currency)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+
 	return &PeriodSummary{
 		StartDate:    start,
 		EndDate:      end,
 		TotalRevenue: totalRevenue,
+		Refunds:      refunds,
+		NetRevenue:   totalRevenue - refunds,
 		OrderCount:   ordersAmount,
+		Currency:     currency,
 	}, nil
 }
 
@@ -58,45 +90,71 @@ type DailyOrders struct {
 }
 
 // OrdersPerDay — количество заказов в день за период
-func (s *Storage) OrdersPerDay(start, end time.Time) ([]DailyOrders, error) {
+func (s *Storage) OrdersPerDay(ctx context.Context, start, end time.Time, currency string) ([]DailyOrders, error) {
 	const op = packageOp + "OrdersPerDay"
+
+	ctx, done := s.analytics(ctx, op)
+	defer done()
+
 	var dailyOrders []DailyOrders
 
-	query := `SELECT COUNT(*), SUM(total_amount)
-			FROM orders
-			WHERE order_date $1`
+	// дни считаются в часовом поясе периода
+	from, to := periodBounds(start, end)
+	if err := s.checkExchangeRates(ctx, from, to, currency); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
 
-	stmt, err := s.DB.Prepare(query)
+	query := `WITH o AS (
+				SELECT (order_date AT TIME ZONE $3)::date AS day,
+					convert_amount(total_amount, currency, $6, utc_day(order_date)) AS total_amount
+				FROM orders
+				WHERE order_date >= $4 AND order_date < $5 AND deleted_at IS NULL
+			)
+			SELECT d::date, COUNT(o.day), COALESCE(SUM(o.total_amount), 0)
+			FROM generate_series($1::date, $2::date, INTERVAL '1 day') d
+			LEFT JOIN o ON o.day = d::date
+			GROUP BY d
+			ORDER BY d`
+	args := []any{dateParam(start), dateParam(end), start.Location().String(), from, to, currency}
+	if useRollup(end) {
+		query = `SELECT d::date, COALESCE(SUM(r.order_count), 0),
+				COALESCE(SUM(convert_amount(r.total_amount, r.currency, $3, r.sale_date)), 0)
+			FROM generate_series($1::date, $2::date, INTERVAL '1 day') d
+			LEFT JOIN daily_sales_rollup r ON r.sale_date = d::date AND r.category_id IS NULL
+			GROUP BY d
+			ORDER BY d`
+		args = []any{dateParam(start), dateParam(end), currency}
+		if err := s.syncRollup(ctx, start, end); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	rows, err := s.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("%s,%v", op, err)
 	}
+	defer rows.Close()
 
-	for curDate := start; !curDate.After(end); curDate = curDate.AddDate(0, 0, 1) {
+	for rows.Next() {
 		var (
+			day         time.Time
 			orderCount  int
 			totalAmount float64
 		)
-		row := stmt.QueryRow(curDate)
-		err = row.Scan(&orderCount, &totalAmount)
-		if err != nil {
+		if err := rows.Scan(&day, &orderCount, &totalAmount); err != nil {
 			return nil, fmt.Errorf("%s,%v", op, err)
 		}
 		dailyOrders = append(dailyOrders, DailyOrders{
-			Date:        curDate.Format("2006-01-02"),
+			Date:        day.Format("2006-01-02"),
 			OrderCount:  orderCount,
 			TotalAmount: totalAmount},
 		)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s,%v", op, err)
+	}
 
 	return dailyOrders, nil
-	// Mock implementation
-	//return []DailyOrders{
-	//	{Date: "2024-01-15", OrderCount: 12, TotalAmount: 125000.50},
-	//	{Date: "2024-01-16", OrderCount: 15, TotalAmount: 187500.00},
-	//	{Date: "2024-01-17", OrderCount: 8, TotalAmount: 95000.00},
-	//	{Date: "2024-01-18", OrderCount: 20, TotalAmount: 250000.00},
-	//	{Date: "2024-01-19", OrderCount: 18, TotalAmount: 210000.00},
-	//}, nil
 }
 
 // AverageCheck — средний чек за период
@@ -106,65 +164,67 @@ type AverageCheckStats struct {
 	AverageCheck float64   `json:"average_check"`
 	MinCheck     float64   `json:"min_check"`
 	MaxCheck     float64   `json:"max_check"`
+	Currency     string    `json:"currency,omitempty"`
 }
 
 // AverageCheckByPeriod — средний чек за определенный период
-func (s *Storage) AverageCheckByPeriod(start, end time.Time) (*AverageCheckStats, error) {
-	const op = "AverageCheckByPeriod"
-	query := `SELECT COUNT(*), SUM(total_amount), MIN(total_amount), MAX(total_amount)
-			FROM orders
-			WHERE order_date $1`
+func (s *Storage) AverageCheckByPeriod(ctx context.Context, start, end time.Time, currency string) (*AverageCheckStats, error) {
+	const op = packageOp + "AverageCheckByPeriod"
 
-	stmt, err := s.DB.Prepare(query)
-	if err != nil {
-		return nil, fmt.Errorf("%s,%v", op, err)
-	}
+	ctx, done := s.analytics(ctx, op)
+	defer done()
 
-	var MinCheckTotally = math.MaxFloat64
-	var MaxCheckTotally = -1.
-	var SumOfBills = 0.
-	var ordersAmount = 0
+	from, to := periodBounds(start, end)
+	if err := s.checkExchangeRates(ctx, from, to, currency); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
 
-	for curDate := start; !curDate.After(end); curDate = curDate.AddDate(0, 0, 1) {
-		var (
-			orderCount  int
-			totalAmount float64
-			minCheck    float64
-			maxCheck    float64
-		)
-		row := stmt.QueryRow(curDate)
-		err = row.Scan(&orderCount, &totalAmount, &minCheck, &maxCheck)
-		if err != nil {
-			return nil, fmt.Errorf("%s,%v", op, err)
+	query := `WITH o AS (
+				SELECT convert_amount(total_amount, currency, $3, utc_day(order_date)) AS amount
+				FROM orders
+				WHERE order_date >= $1 AND order_date < $2 AND deleted_at IS NULL
+			)
+			SELECT COUNT(*), COALESCE(SUM(amount), 0), COALESCE(MIN(amount), 0), COALESCE(MAX(amount), 0)
+			FROM o`
+	args := []any{from, to, currency}
+	if useRollup(end) {
+		query = `SELECT COALESCE(SUM(order_count), 0),
+				COALESCE(SUM(convert_amount(total_amount, currency, $3, sale_date)), 0),
+				COALESCE(MIN(convert_amount(min_check, currency, $3, sale_date)), 0),
+				COALESCE(MAX(convert_amount(max_check, currency, $3, sale_date)), 0)
+			FROM daily_sales_rollup
+			WHERE category_id IS NULL AND sale_date BETWEEN $1 AND $2`
+		args = []any{dateParam(start), dateParam(end), currency}
+		if err := s.syncRollup(ctx, start, end); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
 		}
+	}
 
-		if minCheck < MinCheckTotally {
-			MinCheckTotally = minCheck
-		}
-		if maxCheck > MaxCheckTotally {
-			MaxCheckTotally = maxCheck
-		}
+	var (
+		ordersAmount int
+		sumOfBills   float64
+		minCheck     float64
+		maxCheck     float64
+	)
+
+	err := s.DB.QueryRowContext(ctx, query, args...).Scan(&ordersAmount, &sumOfBills, &minCheck, &maxCheck)
+	if err != nil {
+		return nil, fmt.Errorf("%s,%v", op, err)
+	}
 
-		SumOfBills = SumOfBills + totalAmount
-		ordersAmount += orderCount
+	var averageCheck float64
+	if ordersAmount > 0 {
+		averageCheck = sumOfBills / float64(ordersAmount)
 	}
 
 	return &AverageCheckStats{
 		StartDate:    start,
 		EndDate:      end,
-		AverageCheck: SumOfBills / float64(ordersAmount),
-		MinCheck:     MinCheckTotally,
-		MaxCheck:     MaxCheckTotally,
+		AverageCheck: averageCheck,
+		MinCheck:     minCheck,
+		MaxCheck:     maxCheck,
+		Currency:     currency,
 	}, nil
-
-	// Mock implementation
-	//return &AverageCheckStats{
-	//	StartDate:    start,
-	//	EndDate:      end,
-	//	AverageCheck: 8500.00,
-	//	MinCheck:     450.00,
-	//	MaxCheck:     125000.00,
-	//}, nil
 }
 
 // MedianStats — результаты расчета медианы
@@ -175,23 +235,27 @@ type MedianStats struct {
 }
 
 // OrdersMedian — медиана суммы заказов за период
-func (s *Storage) OrdersMedian(start, end time.Time) (*MedianStats, error) {
-	// Mock implementation
-	return &MedianStats{
-		Metric:     "order_total",
-		Median:     7200.00,
-		SampleSize: 150,
-	}, nil
+func (s *Storage) OrdersMedian(ctx context.Context, start, end time.Time, currency string) (*MedianStats, error) {
+	const op = packageOp + "OrdersMedian"
+
+	value, sampleSize, err := s.percentile(ctx, op, ordersSample, start, end, 0.5, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MedianStats{Metric: "order_total", Median: value, SampleSize: sampleSize}, nil
 }
 
 // CustomerSpendingMedian — медиана трат покупателей за период
-func (s *Storage) CustomerSpendingMedian(start, end time.Time) (*MedianStats, error) {
-	// Mock implementation
-	return &MedianStats{
-		Metric:     "customer_spending",
-		Median:     12500.00,
-		SampleSize: 85,
-	}, nil
+func (s *Storage) CustomerSpendingMedian(ctx context.Context, start, end time.Time, currency string) (*MedianStats, error) {
+	const op = packageOp + "CustomerSpendingMedian"
+
+	value, sampleSize, err := s.percentile(ctx, op, customersSample, start, end, 0.5, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MedianStats{Metric: "customer_spending", Median: value, SampleSize: sampleSize}, nil
 }
 
 // PercentileStats — результаты расчета перцентиля
@@ -203,51 +267,239 @@ type PercentileStats struct {
 }
 
 // OrdersPercentile — перцентиль суммы заказов за период
-func (s *Storage) OrdersPercentile(start, end time.Time, percentile int) (*PercentileStats, error) {
-	// Mock implementation
-	mockValues := map[int]float64{
-		50: 7200.00,  // медиана
-		75: 12000.00, // третий квартиль
-		90: 18500.00,
-		95: 25000.00,
-		99: 45000.00,
-	}
-
-	value := mockValues[percentile]
-	if value == 0 {
-		value = 10000.00 // default
-	}
-
-	return &PercentileStats{
-		Metric:     "order_total",
-		Percentile: percentile,
-		Value:      value,
-		SampleSize: 150,
-	}, nil
+func (s *Storage) OrdersPercentile(ctx context.Context, start, end time.Time, percentile int, currency string) (*PercentileStats, error) {
+	const op = packageOp + "OrdersPercentile"
+
+	value, sampleSize, err := s.percentile(ctx, op, ordersSample, start, end, float64(percentile)/100, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PercentileStats{Metric: "order_total", Percentile: percentile, Value: value, SampleSize: sampleSize}, nil
 }
 
 // CustomerSpendingPercentile — перцентиль трат покупателей за период
-func (s *Storage) CustomerSpendingPercentile(start, end time.Time, percentile int) (*PercentileStats, error) {
-	// Mock implementation
-	mockValues := map[int]float64{
-		50: 12500.00,
-		75: 22000.00,
-		90: 35000.00,
-		95: 50000.00,
-		99: 95000.00,
-	}
-
-	value := mockValues[percentile]
-	if value == 0 {
-		value = 15000.00 // default
-	}
-
-	return &PercentileStats{
-		Metric:     "customer_spending",
-		Percentile: percentile,
-		Value:      value,
-		SampleSize: 85,
-	}, nil
+func (s *Storage) CustomerSpendingPercentile(ctx context.Context, start, end time.Time, percentile int, currency string) (*PercentileStats, error) {
+	const op = packageOp + "CustomerSpendingPercentile"
+
+	value, sampleSize, err := s.percentile(ctx, op, customersSample, start, end, float64(percentile)/100, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PercentileStats{Metric: "customer_spending", Percentile: percentile, Value: value, SampleSize: sampleSize}, nil
+}
+
+// Выборки для медиан и перцентилей: суммы заказов периода и траты
+// покупателей за период. Каждый заказ пересчитывается по курсу на день
+// заказа, границы периода — в часовом поясе запроса ($1, $2 из periodBounds).
+const (
+	ordersSample = `SELECT convert_amount(total_amount, currency, $3, utc_day(order_date)) AS amount
+			FROM orders
+			WHERE order_date >= $1 AND order_date < $2 AND deleted_at IS NULL`
+
+	// заказы без покупателя в траты покупателей не входят
+	customersSample = `SELECT SUM(convert_amount(total_amount, currency, $3, utc_day(order_date))) AS amount
+			FROM orders
+			WHERE order_date >= $1 AND order_date < $2 AND deleted_at IS NULL AND customer_id IS NOT NULL
+			GROUP BY customer_id`
+)
+
+// percentile — непрерывный перцентиль (percentile_cont) выборки sample
+// и ее размер; fraction — доля от 0 до 1. Пустая выборка — ноль, а не ошибка.
+func (s *Storage) percentile(ctx context.Context, op, sample string, start, end time.Time, fraction float64, currency string) (float64, int, error) {
+	ctx, done := s.analytics(ctx, op)
+	defer done()
+
+	from, to := periodBounds(start, end)
+	if err := s.checkExchangeRates(ctx, from, to, currency); err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `WITH sample AS (` + sample + `)
+			SELECT COUNT(*), COALESCE(percentile_cont($4::float8) WITHIN GROUP (ORDER BY amount::float8), 0)
+			FROM sample`
+
+	var (
+		sampleSize int
+		value      float64
+	)
+	if err := s.DB.QueryRowContext(ctx, query, from, to, currency, fraction).Scan(&sampleSize, &value); err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return value, sampleSize, nil
+}
+
+// ====================================================================
+// RETURNS ANALYTICS — Аналитика возвратов
+// ====================================================================
+
+// refundsByPeriod — сумма возвратов, оформленных в [from, to), по курсу на день возврата
+func (s *Storage) refundsByPeriod(ctx context.Context, from, to time.Time, currency string) (float64, error) {
+	query := `SELECT COALESCE(SUM(convert_amount(r.refund_amount, o.currency, $3, utc_day(r.return_date))), 0)
+			FROM returns r
+			JOIN orders o ON o.order_id = r.order_id
+			WHERE r.return_date >= $1 AND r.return_date < $2 AND o.deleted_at IS NULL`
+
+	var refunds float64
+	if err := s.DB.QueryRowContext(ctx, query, from, to, currency).Scan(&refunds); err != nil {
+		return 0, err
+	}
+
+	return refunds, nil
+}
+
+// ProductReturnRate — доля возвратов по товару
+type ProductReturnRate struct {
+	ProductID        int     `json:"product_id"`
+	ProductName      string  `json:"product_name"`
+	SoldQuantity     int     `json:"sold_quantity"`
+	ReturnedQuantity int     `json:"returned_quantity"`
+	ReturnRate       float64 `json:"return_rate"`
+	RefundAmount     float64 `json:"refund_amount"`
+}
+
+// ReturnRateByProduct — доля возвращенных единиц по товарам, проданным за период
+func (s *Storage) ReturnRateByProduct(ctx context.Context, start, end time.Time, currency string) ([]ProductReturnRate, error) {
+	const op = packageOp + "ReturnRateByProduct"
+
+	ctx, done := s.analytics(ctx, op)
+	defer done()
+
+	from, to := periodBounds(start, end)
+	if err := s.checkExchangeRates(ctx, from, to, currency); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `SELECT p.product_id, p.product_name, SUM(oi.quantity), COALESCE(SUM(ret.quantity), 0),
+				COALESCE(SUM(convert_amount(ret.refund_amount, o.currency, $3, utc_day(o.order_date))), 0)
+			FROM order_items oi
+			JOIN orders o ON o.order_id = oi.order_id
+			JOIN products p ON p.product_id = oi.product_id
+			LEFT JOIN (
+				SELECT order_item_id, SUM(quantity) AS quantity, SUM(refund_amount) AS refund_amount
+				FROM return_items
+				GROUP BY order_item_id
+			) ret ON ret.order_item_id = oi.order_item_id
+			WHERE o.order_date >= $1 AND o.order_date < $2 AND o.deleted_at IS NULL
+			GROUP BY p.product_id, p.product_name
+			ORDER BY COALESCE(SUM(ret.quantity), 0)::numeric / SUM(oi.quantity) DESC, p.product_id`
+
+	rows, err := s.DB.QueryContext(ctx, query, from, to, currency)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var rates []ProductReturnRate
+	for rows.Next() {
+		var rate ProductReturnRate
+		err := rows.Scan(&rate.ProductID, &rate.ProductName, &rate.SoldQuantity, &rate.ReturnedQuantity, &rate.RefundAmount)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if rate.SoldQuantity > 0 {
+			rate.ReturnRate = float64(rate.ReturnedQuantity) / float64(rate.SoldQuantity)
+		}
+		rates = append(rates, rate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rates, nil
+}
+
+// ====================================================================
+// PROMOTIONS ANALYTICS — Эффективность промоакций
+// ====================================================================
+
+// PromotionStats — результаты промоакции за период. Uplift — насколько средний
+// чек заказов с акцией выше среднего чека заказов без акций за тот же период.
+type PromotionStats struct {
+	PromotionID       int     `json:"promotion_id"`
+	Name              string  `json:"name"`
+	Kind              string  `json:"kind"`
+	CouponCode        string  `json:"coupon_code,omitempty"`
+	OrderCount        int     `json:"order_count"`
+	Revenue           float64 `json:"revenue"`
+	DiscountCost      float64 `json:"discount_cost"`
+	AverageCheck      float64 `json:"average_check"`
+	BaselineCheck     float64 `json:"baseline_check"`
+	Uplift            float64 `json:"uplift"`
+	UpliftPercent     float64 `json:"uplift_percent"`
+	CostPerOrder      float64 `json:"cost_per_order"`
+	DiscountToRevenue float64 `json:"discount_to_revenue"`
+}
+
+// PromotionsReport — выручка, стоимость скидок и прирост среднего чека по каждой
+// акции, применявшейся в заказах за период
+func (s *Storage) PromotionsReport(ctx context.Context, start, end time.Time, currency string) ([]PromotionStats, error) {
+	const op = packageOp + "PromotionsReport"
+
+	ctx, done := s.analytics(ctx, op)
+	defer done()
+
+	from, to := periodBounds(start, end)
+	if err := s.checkExchangeRates(ctx, from, to, currency); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `WITH promo_orders AS (
+				SELECT oi.promotion_id, o.order_id,
+					convert_amount(o.total_amount, o.currency, $3, utc_day(o.order_date)) AS revenue,
+					SUM(convert_amount(oi.discount_amount, o.currency, $3, utc_day(o.order_date))) AS cost
+				FROM order_items oi
+				JOIN orders o ON o.order_id = oi.order_id
+				WHERE oi.promotion_id IS NOT NULL AND o.order_date >= $1 AND o.order_date < $2 AND o.deleted_at IS NULL
+				GROUP BY oi.promotion_id, o.order_id, o.total_amount, o.currency, o.order_date
+			), baseline AS (
+				SELECT COALESCE(AVG(convert_amount(o.total_amount, o.currency, $3, utc_day(o.order_date))), 0) AS avg_check
+				FROM orders o
+				WHERE o.order_date >= $1 AND o.order_date < $2 AND o.deleted_at IS NULL
+					AND NOT EXISTS (SELECT 1 FROM order_items oi WHERE oi.order_id = o.order_id AND oi.promotion_id IS NOT NULL)
+			)
+			SELECT p.promotion_id, p.name, p.kind, COALESCE(p.coupon_code, ''),
+				COUNT(*), SUM(po.revenue), SUM(po.cost), AVG(po.revenue), b.avg_check
+			FROM promo_orders po
+			JOIN promotions p ON p.promotion_id = po.promotion_id
+			CROSS JOIN baseline b
+			GROUP BY p.promotion_id, p.name, p.kind, p.coupon_code, b.avg_check
+			ORDER BY SUM(po.cost) DESC, p.promotion_id`
+
+	rows, err := s.DB.QueryContext(ctx, query, from, to, currency)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var stats []PromotionStats
+	for rows.Next() {
+		var st PromotionStats
+		err := rows.Scan(&st.PromotionID, &st.Name, &st.Kind, &st.CouponCode,
+			&st.OrderCount, &st.Revenue, &st.DiscountCost, &st.AverageCheck, &st.BaselineCheck)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		st.Uplift = st.AverageCheck - st.BaselineCheck
+		if st.BaselineCheck > 0 {
+			st.UpliftPercent = st.Uplift / st.BaselineCheck * 100
+		}
+		if st.OrderCount > 0 {
+			st.CostPerOrder = st.DiscountCost / float64(st.OrderCount)
+		}
+		if st.Revenue > 0 {
+			st.DiscountToRevenue = st.DiscountCost / st.Revenue
+		}
+		stats = append(stats, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return stats, nil
 }
 
 // ====================================================================
@@ -265,14 +517,34 @@ type SalesReport struct {
 }
 
 // GenerateSalesReport — сгенерировать полный отчет по продажам
-func (s *Storage) GenerateSalesReport(start, end time.Time) (*SalesReport, error) {
-	// Mock implementation
-	period, _ := s.TotalRevenueByPeriod(start, end)
-	dailyStats, _ := s.OrdersPerDay(start, end)
-	avgCheck, _ := s.AverageCheckByPeriod(start, end)
-	median, _ := s.OrdersMedian(start, end)
-	p75, _ := s.OrdersPercentile(start, end, 75)
-	p95, _ := s.OrdersPercentile(start, end, 95)
+// Ошибка любой части отчета возвращается целиком: неполный отчет не отдается.
+func (s *Storage) GenerateSalesReport(ctx context.Context, start, end time.Time, currency string) (*SalesReport, error) {
+	const op = packageOp + "GenerateSalesReport"
+
+	period, err := s.TotalRevenueByPeriod(ctx, start, end, currency)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	dailyStats, err := s.OrdersPerDay(ctx, start, end, currency)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	avgCheck, err := s.AverageCheckByPeriod(ctx, start, end, currency)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	median, err := s.OrdersMedian(ctx, start, end, currency)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	p75, err := s.OrdersPercentile(ctx, start, end, 75, currency)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	p95, err := s.OrdersPercentile(ctx, start, end, 95, currency)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
 
 	return &SalesReport{
 		Period:       *period,
@@ -283,3 +555,20 @@ func (s *Storage) GenerateSalesReport(start, end time.Time) (*SalesReport, error
 		Percentile95: *p95,
 	}, nil
 }
+
+// ====================================================================
+// HELPERS
+// ====================================================================
+
+// periodBounds — период [start, end] по календарным дням в виде полуинтервала
+// [from, to) моментов времени. Границы суток берутся из часового пояса start/end.
+func periodBounds(start, end time.Time) (from, to time.Time) {
+	from = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	to = time.Date(end.Year(), end.Month(), end.Day()+1, 0, 0, 0, 0, end.Location())
+	return from, to
+}
+
+// dateParam — календарная дата без часового пояса для параметров типа date
+func dateParam(t time.Time) string {
+	return t.Format("2006-01-02")
+}
@@ -0,0 +1,178 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"salesTracker/internal/storage"
+)
+
+// ====================================================================
+// API KEYS - Ключи доступа к API
+// ====================================================================
+
+// APIKey — API-ключ; сам ключ не хранится, только его хэш
+type APIKey struct {
+	KeyID      int        `json:"key_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Role       string     `json:"role"`
+	CreatedBy  string     `json:"created_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Usable — ключ не отозван и не истек
+func (k APIKey) Usable(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+const apiKeyColumns = `key_id, name, prefix, key_hash, role, created_by, created_at, expires_at, last_used_at, revoked_at`
+
+func scanAPIKey(row interface{ Scan(...any) error }) (*APIKey, error) {
+	var (
+		key        APIKey
+		createdBy  sql.NullString
+		expiresAt  sql.NullTime
+		lastUsedAt sql.NullTime
+		revokedAt  sql.NullTime
+	)
+
+	err := row.Scan(&key.KeyID, &key.Name, &key.Prefix, &key.KeyHash, &key.Role, &createdBy, &key.CreatedAt,
+		&expiresAt, &lastUsedAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	key.CreatedBy = createdBy.String
+	key.ExpiresAt = nullTime(expiresAt)
+	key.LastUsedAt = nullTime(lastUsedAt)
+	key.RevokedAt = nullTime(revokedAt)
+
+	return &key, nil
+}
+
+func (s *Storage) AddAPIKey(ctx context.Context, key APIKey) (int, error) {
+	const op = "storage.postgresql.AddAPIKey"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	query := `INSERT INTO api_keys (name, prefix, key_hash, role, created_by, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING key_id`
+
+	return audited(ctx, s.DB, op, apiKeysAudit, AuditCreate, 0, func(tx *sql.Tx) (int, error) {
+		var id int
+		err := tx.QueryRowContext(ctx, query, key.Name, key.Prefix, key.KeyHash, key.Role, nullString(key.CreatedBy), key.ExpiresAt).Scan(&id)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		return id, nil
+	})
+}
+
+func (s *Storage) GetAPIKey(ctx context.Context, id int) (*APIKey, error) {
+	const op = "storage.postgresql.GetAPIKey"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	key, err := scanAPIKey(s.DB.QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE key_id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, nil
+}
+
+// GetAPIKeyByPrefix — ключ по открытой части, используется при аутентификации
+func (s *Storage) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*APIKey, error) {
+	const op = "storage.postgresql.GetAPIKeyByPrefix"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	key, err := scanAPIKey(s.DB.QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE prefix = $1`, prefix))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, nil
+}
+
+func (s *Storage) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	const op = "storage.postgresql.ListAPIKeys"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	rows, err := s.DB.QueryContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY key_id`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var keys []APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		keys = append(keys, *key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey — отозвать ключ; повторный отзыв сохраняет исходное время
+func (s *Storage) RevokeAPIKey(ctx context.Context, id int, at time.Time) error {
+	const op = "storage.postgresql.RevokeAPIKey"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	_, err := audited(ctx, s.DB, op, apiKeysAudit, AuditDelete, id, func(tx *sql.Tx) (int, error) {
+		res, err := tx.ExecContext(ctx, `UPDATE api_keys SET revoked_at = COALESCE(revoked_at, $2) WHERE key_id = $1`, id, at)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		return id, checkAffected(op, res, storage.ErrAPIKeyNotFound)
+	})
+	return err
+}
+
+// TouchAPIKey — отметить использование ключа не чаще раза в минуту,
+// чтобы не писать в базу на каждый запрос
+func (s *Storage) TouchAPIKey(ctx context.Context, id int, at time.Time) error {
+	const op = "storage.postgresql.TouchAPIKey"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	_, err := s.DB.ExecContext(ctx, `UPDATE api_keys SET last_used_at = $2
+			WHERE key_id = $1 AND (last_used_at IS NULL OR last_used_at < $2 - INTERVAL '1 minute')`, id, at)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,417 @@
+package postgresql
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ====================================================================
+// AUDIT LOG - Журнал изменений
+// ====================================================================
+
+// AuditEntry — запись журнала об одном изменении сущности
+type AuditEntry struct {
+	AuditID    int64           `json:"audit_id"`
+	EntityType string          `json:"entity_type"`
+	EntityID   *int            `json:"entity_id,omitempty"`
+	Action     string          `json:"action"`
+	Actor      string          `json:"actor"`
+	RequestID  string          `json:"request_id,omitempty"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// AuditFilter — условия выборки журнала; пустые поля не ограничивают выборку
+type AuditFilter struct {
+	EntityType string
+	EntityID   *int
+	Actor      string
+	Since      *time.Time
+	Until      *time.Time
+	Limit      int
+}
+
+// ListAuditEntries — записи журнала, новые первыми
+func (s *Storage) ListAuditEntries(ctx context.Context, f AuditFilter) ([]AuditEntry, error) {
+	const op = "storage.postgresql.ListAuditEntries"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	var (
+		where []string
+		args  []any
+	)
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.EntityType != "" {
+		add("entity_type = $%d", f.EntityType)
+	}
+	if f.EntityID != nil {
+		add("entity_id = $%d", *f.EntityID)
+	}
+	if f.Actor != "" {
+		add("actor = $%d", f.Actor)
+	}
+	if f.Since != nil {
+		add("created_at >= $%d", *f.Since)
+	}
+	if f.Until != nil {
+		add("created_at < $%d", *f.Until)
+	}
+
+	query := `SELECT audit_id, entity_type, entity_id, action, actor, COALESCE(request_id, ''),
+				before_data, after_data, created_at
+			FROM audit_log`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	args = append(args, f.Limit)
+	query += fmt.Sprintf(` ORDER BY audit_id DESC LIMIT $%d`, len(args))
+
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var (
+			e             AuditEntry
+			before, after []byte
+		)
+		err := rows.Scan(&e.AuditID, &e.EntityType, &e.EntityID, &e.Action, &e.Actor, &e.RequestID,
+			&before, &after, &e.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		e.Before = before
+		e.After = after
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return entries, nil
+}
+
+// ====================================================================
+// CHANGES - Построчный журнал операций хранилища
+// ====================================================================
+
+// Каждая операция хранилища, которая создает, меняет или удаляет записи,
+// журналирует каждую запись сама, в той же транзакции, что и изменение:
+// запись журнала фиксируется или откатывается вместе с ним. Автора и ID
+// запроса передает контекст (WithAuditActor), без него автор — SystemActor.
+// Для обновлений в before/after остаются только изменившиеся поля.
+
+// Действия журнала
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+)
+
+// SystemActor — автор изменений вне HTTP-запроса: команды CLI, фоновые задачи
+const SystemActor = "system"
+
+type auditActorKey struct{}
+
+type auditActor struct {
+	actor     string
+	requestID string
+}
+
+// WithAuditActor — контекст, изменения в котором журналируются от имени actor
+func WithAuditActor(ctx context.Context, actor, requestID string) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, auditActor{actor: actor, requestID: requestID})
+}
+
+func auditActorFrom(ctx context.Context) auditActor {
+	if a, ok := ctx.Value(auditActorKey{}).(auditActor); ok {
+		return a
+	}
+	return auditActor{actor: SystemActor}
+}
+
+// auditChange — изменение одной записи; Before и After — ее состояния
+// до и после, nil — состояния нет
+type auditChange struct {
+	EntityType string
+	EntityID   *int
+	Action     string
+	Before     any
+	After      any
+}
+
+// addAuditChanges — записать изменения одним запросом в транзакции изменения
+func addAuditChanges(ctx context.Context, db dbtx, changes []auditChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	n := len(changes)
+	types, actions := make([]string, n), make([]string, n)
+	befores, afters := make([]string, n), make([]string, n)
+	ids := make([]sql.NullInt64, n)
+	for i, c := range changes {
+		types[i], actions[i] = c.EntityType, c.Action
+		if c.EntityID != nil {
+			ids[i] = sql.NullInt64{Int64: int64(*c.EntityID), Valid: true}
+		}
+		var err error
+		if befores[i], err = auditJSON(c.Before); err != nil {
+			return err
+		}
+		if afters[i], err = auditJSON(c.After); err != nil {
+			return err
+		}
+		if c.Action == AuditUpdate {
+			befores[i], afters[i] = changedFields(befores[i], afters[i])
+		}
+	}
+
+	a := auditActorFrom(ctx)
+	_, err := db.ExecContext(ctx, `INSERT INTO audit_log (entity_type, entity_id, action, actor, request_id, before_data, after_data)
+			SELECT t.entity_type, t.entity_id, t.action, $4, NULLIF($5, ''),
+				NULLIF(t.before_data, '')::jsonb, NULLIF(t.after_data, '')::jsonb
+			FROM unnest($1::text[], $2::int[], $3::text[], $6::text[], $7::text[])
+				AS t(entity_type, entity_id, action, before_data, after_data)`,
+		pq.Array(types), pq.Array(ids), pq.Array(actions), a.actor, a.requestID, pq.Array(befores), pq.Array(afters))
+	return err
+}
+
+// auditJSON — состояние записи для журнала; пустая строка — NULL
+func auditJSON(v any) (string, error) {
+	if v == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// changedFields — только различающиеся поля верхнего уровня двух состояний;
+// если одно из состояний неизвестно, оба возвращаются как есть
+func changedFields(before, after string) (string, string) {
+	var old, cur map[string]json.RawMessage
+	if json.Unmarshal([]byte(before), &old) != nil || json.Unmarshal([]byte(after), &cur) != nil {
+		return before, after
+	}
+
+	oldDiff := map[string]json.RawMessage{}
+	curDiff := map[string]json.RawMessage{}
+	for field, value := range cur {
+		if prev, ok := old[field]; !ok || !bytes.Equal(prev, value) {
+			curDiff[field] = value
+			if ok {
+				oldDiff[field] = prev
+			}
+		}
+	}
+	for field, prev := range old {
+		if _, ok := cur[field]; !ok {
+			oldDiff[field] = prev
+		}
+	}
+
+	b, _ := json.Marshal(oldDiff)
+	a, _ := json.Marshal(curDiff)
+	return string(b), string(a)
+}
+
+// auditEntity — журналируемая сущность: имя в журнале и чтение записи по ID
+type auditEntity[T any] struct {
+	Type string
+	// query выбирает запись по ID ($1)
+	query string
+	scan  func(row interface{ Scan(...any) error }) (*T, error)
+}
+
+var (
+	categoriesAudit = auditEntity[Category]{Type: "categories",
+		query: `SELECT ` + categoryColumns + ` FROM categories WHERE category_id = $1`, scan: scanCategory}
+	productsAudit = auditEntity[Product]{Type: "products",
+		query: `SELECT ` + productColumns + ` FROM products WHERE product_id = $1`, scan: scanProduct}
+	customersAudit = auditEntity[Customer]{Type: "customers",
+		query: `SELECT ` + customerColumns + ` FROM customers WHERE customer_id = $1`, scan: scanCustomer}
+	ordersAudit = auditEntity[Order]{Type: "orders",
+		query: `SELECT ` + orderColumns + ` FROM orders WHERE order_id = $1`, scan: scanOrder}
+	promotionsAudit = auditEntity[Promotion]{Type: "promotions",
+		query: `SELECT ` + promotionColumns + ` FROM promotions WHERE promotion_id = $1`, scan: scanPromotion}
+	apiKeysAudit = auditEntity[APIKey]{Type: "api_keys",
+		query: `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_id = $1`, scan: scanAPIKey}
+	schedulesAudit = auditEntity[ReportSchedule]{Type: "report_schedules",
+		query: `SELECT ` + scheduleColumns + ` FROM report_schedules WHERE schedule_id = $1`, scan: scanSchedule}
+)
+
+// load — состояние записи id для журнала, nil — записи нет; lock блокирует
+// запись до конца транзакции, чтобы before совпал с тем, что изменится
+func (e auditEntity[T]) load(ctx context.Context, tx *sql.Tx, id int, lock bool) (any, error) {
+	query := e.query
+	if lock {
+		query += ` FOR UPDATE`
+	}
+
+	v, err := e.scan(tx.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// audited — выполнить change над записью id сущности e в транзакции вместе
+// с записью журнала action. Для создания id не нужен: change возвращает ID
+// новой записи. Ошибки change возвращаются как есть, остальные — с op.
+func audited[T any](ctx context.Context, db *sql.DB, op string, e auditEntity[T], action string, id int,
+	change func(tx *sql.Tx) (int, error)) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var before any
+	if action != AuditCreate {
+		if before, err = e.load(ctx, tx, id, true); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if id, err = change(tx); err != nil {
+		return 0, err
+	}
+
+	after, err := e.load(ctx, tx, id, false)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = addAuditChanges(ctx, tx, []auditChange{{EntityType: e.Type, EntityID: &id, Action: action, Before: before, After: after}})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// orderState — заказ и его позиции по ID для журнала изменений позиций
+type orderState struct {
+	order any
+	items map[int]any
+}
+
+func loadOrderState(ctx context.Context, tx *sql.Tx, orderID int) (orderState, error) {
+	order, err := ordersAudit.load(ctx, tx, orderID, false)
+	if err != nil {
+		return orderState{}, err
+	}
+
+	items, err := rowsByID(ctx, tx, `SELECT `+orderItemColumns+` FROM order_items WHERE order_id = ANY($1)`, []int{orderID},
+		scanOrderItem, func(item *OrderItem) int { return item.OrderItemID })
+	if err != nil {
+		return orderState{}, err
+	}
+
+	return orderState{order: order, items: items}, nil
+}
+
+// auditOrderState — журнал изменений позиций заказа orderID относительно
+// состояния before: изменение позиции пересчитывает скидки соседних позиций
+// и сумму заказа, поэтому журналируются все затронутые записи
+func auditOrderState(ctx context.Context, tx *sql.Tx, orderID int, before orderState) error {
+	after, err := loadOrderState(ctx, tx, orderID)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]int, 0, len(after.items))
+	for id := range before.items {
+		ids = append(ids, id)
+	}
+	for id := range after.items {
+		if _, ok := before.items[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	var changes []auditChange
+	for _, id := range ids {
+		old, cur := before.items[id], after.items[id]
+		change := auditChange{EntityType: "order_items", EntityID: &id, Action: AuditUpdate, Before: old, After: cur}
+		switch {
+		case old == nil:
+			change.Action = AuditCreate
+		case cur == nil:
+			change.Action = AuditDelete
+		case reflect.DeepEqual(old, cur):
+			continue
+		}
+		changes = append(changes, change)
+	}
+	changes = append(changes, auditChange{EntityType: ordersAudit.Type, EntityID: &orderID, Action: AuditUpdate,
+		Before: before.order, After: after.order})
+
+	return addAuditChanges(ctx, tx, changes)
+}
+
+// rowsByID — записи таблицы по ID в виде map для before/after журнала;
+// query выбирает строки по ANY($1)
+func rowsByID[T any](ctx context.Context, tx *sql.Tx, query string, ids []int,
+	scan func(row interface{ Scan(...any) error }) (*T, error), id func(*T) int) (map[int]any, error) {
+	byID := make(map[int]any, len(ids))
+	if len(ids) == 0 {
+		return byID, nil
+	}
+
+	rows, err := tx.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		v, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		byID[id(v)] = v
+	}
+	return byID, rows.Err()
+}
+
+func productsByID(ctx context.Context, tx *sql.Tx, ids []int) (map[int]any, error) {
+	return rowsByID(ctx, tx, `SELECT `+productColumns+` FROM products WHERE product_id = ANY($1)`, ids,
+		scanProduct, func(p *Product) int { return p.ProductID })
+}
+
+func customersByID(ctx context.Context, tx *sql.Tx, ids []int) (map[int]any, error) {
+	return rowsByID(ctx, tx, `SELECT `+customerColumns+` FROM customers WHERE customer_id = ANY($1)`, ids,
+		scanCustomer, func(c *Customer) int { return c.CustomerID })
+}
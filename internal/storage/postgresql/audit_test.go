@@ -0,0 +1,87 @@
+package postgresql
+
+import (
+	"context"
+	"testing"
+)
+
+func TestChangedFields(t *testing.T) {
+	tests := []struct {
+		name       string
+		before     string
+		after      string
+		wantBefore string
+		wantAfter  string
+	}{
+		{name: "one field changed",
+			before: `{"price":10,"name":"Coffee","version":1}`, after: `{"price":12,"name":"Coffee","version":2}`,
+			wantBefore: `{"price":10,"version":1}`, wantAfter: `{"price":12,"version":2}`},
+		{name: "field appears",
+			before: `{"name":"Coffee"}`, after: `{"name":"Coffee","deleted_at":"2025-01-01T00:00:00Z"}`,
+			wantBefore: `{}`, wantAfter: `{"deleted_at":"2025-01-01T00:00:00Z"}`},
+		{name: "field disappears",
+			before: `{"name":"Coffee","deleted_at":"2025-01-01T00:00:00Z"}`, after: `{"name":"Coffee"}`,
+			wantBefore: `{"deleted_at":"2025-01-01T00:00:00Z"}`, wantAfter: `{}`},
+		{name: "nested object compared whole",
+			before: `{"recipients":["a@x.ru"]}`, after: `{"recipients":["a@x.ru","b@x.ru"]}`,
+			wantBefore: `{"recipients":["a@x.ru"]}`, wantAfter: `{"recipients":["a@x.ru","b@x.ru"]}`},
+		{name: "nothing changed",
+			before: `{"name":"Coffee"}`, after: `{"name":"Coffee"}`,
+			wantBefore: `{}`, wantAfter: `{}`},
+
+		// неизвестное состояние: журнал сохраняет оба как есть
+		{name: "no before",
+			before: "", after: `{"name":"Coffee"}`,
+			wantBefore: "", wantAfter: `{"name":"Coffee"}`},
+		{name: "no after",
+			before: `{"name":"Coffee"}`, after: "",
+			wantBefore: `{"name":"Coffee"}`, wantAfter: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotBefore, gotAfter := changedFields(tt.before, tt.after)
+			if gotBefore != tt.wantBefore || gotAfter != tt.wantAfter {
+				t.Errorf("changedFields() = %s, %s, want %s, %s", gotBefore, gotAfter, tt.wantBefore, tt.wantAfter)
+			}
+		})
+	}
+}
+
+func TestAuditJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		state any
+		want  string
+	}{
+		{name: "no state", state: nil, want: ""},
+		{name: "record", state: &Category{CategoryID: 1, CategoryName: "Coffee", Version: 2},
+			want: `{"category_id":1,"category_name":"Coffee","description":"","version":2}`},
+		// хэш ключа не попадает в журнал
+		{name: "api key without hash", state: &APIKey{KeyID: 3, Name: "pos", KeyHash: "secret"},
+			want: `{"key_id":3,"name":"pos","prefix":"","role":"","created_at":"0001-01-01T00:00:00Z"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := auditJSON(tt.state)
+			if err != nil {
+				t.Fatalf("auditJSON() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("auditJSON() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuditActorFrom(t *testing.T) {
+	if got := auditActorFrom(context.Background()); got.actor != SystemActor || got.requestID != "" {
+		t.Errorf("auditActorFrom(background) = %+v, want system actor", got)
+	}
+
+	ctx := WithAuditActor(context.Background(), "alice", "req-1")
+	if got := auditActorFrom(ctx); got.actor != "alice" || got.requestID != "req-1" {
+		t.Errorf("auditActorFrom() = %+v, want alice, req-1", got)
+	}
+}
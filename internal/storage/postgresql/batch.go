@@ -0,0 +1,426 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"salesTracker/internal/storage"
+)
+
+// ====================================================================
+// BATCH - Пакетное создание и обновление товаров и покупателей
+// ====================================================================
+
+// Режимы пакетной записи
+const (
+	// BatchAllOrNothing — пакет записывается целиком или не записывается вовсе
+	BatchAllOrNothing = "all_or_nothing"
+	// BatchBestEffort — записываются все строки, кроме ошибочных
+	BatchBestEffort = "best_effort"
+)
+
+// Действия над строкой пакета
+const (
+	BatchCreated = "created"
+	BatchUpdated = "updated"
+)
+
+// BatchResult — итог по одной строке пакета; Index — позиция строки во входном массиве
+type BatchResult struct {
+	Index  int    `json:"index"`
+	ID     int    `json:"id,omitempty"`
+	Action string `json:"action,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// SaveProducts — записать пакет товаров одной транзакцией: товары без
+// ProductID создаются, с ProductID — обновляются при совпадении Version.
+// В режиме BatchAllOrNothing новые товары вставляются одним запросом, а при
+// любой ошибке ничего не записывается и committed = false. Каждая записанная
+// строка журналируется в той же транзакции.
+func (s *Storage) SaveProducts(ctx context.Context, products []Product, mode string) (results []BatchResult, committed bool, err error) {
+	const op = "storage.postgresql.SaveProducts"
+
+	ctx, done := s.analytics(ctx, op)
+	defer done()
+
+	save := func(db dbtx, i int) (int, string, error) {
+		p := products[i]
+		if p.ProductID == 0 {
+			id, err := insertProduct(ctx, db, p)
+			return id, BatchCreated, err
+		}
+		return p.ProductID, BatchUpdated, updateProduct(ctx, db, op, p)
+	}
+
+	journal := batchJournal{entityType: "products", load: productsByID}
+	for _, p := range products {
+		if p.ProductID != 0 {
+			journal.updates = append(journal.updates, p.ProductID)
+		}
+	}
+
+	if mode == BatchBestEffort {
+		results, err = s.saveEach(ctx, len(products), save, true, journal)
+		if err != nil {
+			return nil, false, fmt.Errorf("%s: %w", op, err)
+		}
+		return results, true, nil
+	}
+
+	results, err = s.saveAll(ctx, len(products), func(tx *sql.Tx) ([]BatchResult, error) {
+		return saveProductsBulk(ctx, tx, op, products)
+	}, save, journal)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", op, err)
+	}
+	return results, !failed(results), nil
+}
+
+// SaveCustomers — записать пакет покупателей, см. SaveProducts
+func (s *Storage) SaveCustomers(ctx context.Context, customers []Customer, mode string) (results []BatchResult, committed bool, err error) {
+	const op = "storage.postgresql.SaveCustomers"
+
+	ctx, done := s.analytics(ctx, op)
+	defer done()
+
+	save := func(db dbtx, i int) (int, string, error) {
+		c := customers[i]
+		if c.CustomerID == 0 {
+			id, err := insertCustomer(ctx, db, c)
+			return id, BatchCreated, err
+		}
+		return c.CustomerID, BatchUpdated, updateCustomer(ctx, db, op, c)
+	}
+
+	journal := batchJournal{entityType: "customers", load: customersByID}
+	for _, c := range customers {
+		if c.CustomerID != 0 {
+			journal.updates = append(journal.updates, c.CustomerID)
+		}
+	}
+
+	if mode == BatchBestEffort {
+		results, err = s.saveEach(ctx, len(customers), save, true, journal)
+		if err != nil {
+			return nil, false, fmt.Errorf("%s: %w", op, err)
+		}
+		return results, true, nil
+	}
+
+	results, err = s.saveAll(ctx, len(customers), func(tx *sql.Tx) ([]BatchResult, error) {
+		return saveCustomersBulk(ctx, tx, op, customers)
+	}, save, journal)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", op, err)
+	}
+	return results, !failed(results), nil
+}
+
+// ====================================================================
+// BULK - Многострочные INSERT
+// ====================================================================
+
+// ID новых строк берутся из последовательности SERIAL еще во входном CTE,
+// вместе с номером строки ord, поэтому ответ сопоставляет ID строкам явно,
+// а не по порядку выдачи ID.
+const insertProductsBulk = `WITH input AS (
+		SELECT nextval(pg_get_serial_sequence('products', 'product_id'))::int AS product_id, t.*
+		FROM unnest($1::text[], $2::int[], $3::numeric[], $4::numeric[], $5::int[], $6::text[])
+			WITH ORDINALITY AS t(product_name, category_id, price, cost, stock_quantity, currency, ord)
+	), inserted AS (
+		INSERT INTO products (product_id, product_name, category_id, price, cost, stock_quantity, currency)
+		SELECT product_id, product_name, NULLIF(category_id, 0), price, cost, stock_quantity, currency
+		FROM input
+		RETURNING product_id
+	)
+	SELECT input.ord, input.product_id FROM input JOIN inserted USING (product_id)`
+
+const insertCustomersBulk = `WITH input AS (
+		SELECT nextval(pg_get_serial_sequence('customers', 'customer_id'))::int AS customer_id, t.*
+		FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::text[], $6::date[])
+			WITH ORDINALITY AS t(first_name, last_name, email, phone, city, registration_date, ord)
+	), inserted AS (
+		INSERT INTO customers (customer_id, first_name, last_name, email, phone, city, registration_date)
+		SELECT customer_id, first_name, last_name, NULLIF(btrim(email), ''), NULLIF(btrim(phone), ''), NULLIF(btrim(city), ''),
+			registration_date
+		FROM input
+		RETURNING customer_id
+	)
+	SELECT input.ord, input.customer_id FROM input JOIN inserted USING (customer_id)`
+
+func saveProductsBulk(ctx context.Context, tx *sql.Tx, op string, products []Product) ([]BatchResult, error) {
+	results := make([]BatchResult, len(products))
+
+	var (
+		created           []int
+		names, currencies []string
+		categories, stock []int64
+		prices, costs     []float64
+	)
+	for i, p := range products {
+		results[i].Index = i
+		if p.ProductID != 0 {
+			if err := updateProduct(ctx, tx, op, p); err != nil {
+				return nil, err
+			}
+			results[i].ID, results[i].Action = p.ProductID, BatchUpdated
+			continue
+		}
+
+		created = append(created, i)
+		names = append(names, p.ProductName)
+		categories = append(categories, int64(p.CategoryID))
+		prices = append(prices, p.Price)
+		costs = append(costs, p.Cost)
+		stock = append(stock, int64(p.StockQuantity))
+		currencies = append(currencies, p.Currency)
+	}
+	if len(created) == 0 {
+		return results, nil
+	}
+
+	ids, err := queryCreated(ctx, tx, insertProductsBulk, pq.Array(names), pq.Array(categories), pq.Array(prices),
+		pq.Array(costs), pq.Array(stock), pq.Array(currencies))
+	if err != nil {
+		return nil, err
+	}
+	return fillCreated(results, created, ids)
+}
+
+func saveCustomersBulk(ctx context.Context, tx *sql.Tx, op string, customers []Customer) ([]BatchResult, error) {
+	results := make([]BatchResult, len(customers))
+
+	var (
+		created                                                   []int
+		firstNames, lastNames, emails, phones, cities, registered []string
+	)
+	for i, c := range customers {
+		results[i].Index = i
+		if c.CustomerID != 0 {
+			if err := updateCustomer(ctx, tx, op, c); err != nil {
+				return nil, err
+			}
+			results[i].ID, results[i].Action = c.CustomerID, BatchUpdated
+			continue
+		}
+
+		created = append(created, i)
+		firstNames = append(firstNames, c.FirstName)
+		lastNames = append(lastNames, c.LastName)
+		emails = append(emails, c.Email)
+		phones = append(phones, c.Phone)
+		cities = append(cities, c.City)
+		registered = append(registered, dateParam(c.RegistrationDate))
+	}
+	if len(created) == 0 {
+		return results, nil
+	}
+
+	ids, err := queryCreated(ctx, tx, insertCustomersBulk, pq.Array(firstNames), pq.Array(lastNames), pq.Array(emails),
+		pq.Array(phones), pq.Array(cities), pq.Array(registered))
+	if err != nil {
+		return nil, err
+	}
+	return fillCreated(results, created, ids)
+}
+
+// ====================================================================
+// HELPERS
+// ====================================================================
+
+// saveAll — режим BatchAllOrNothing: быстрый путь bulk в одной транзакции;
+// если он не удался, строки проходят по одной в откатываемой транзакции,
+// чтобы указать клиенту, какие из них ошибочны
+func (s *Storage) saveAll(ctx context.Context, n int, bulk func(tx *sql.Tx) ([]BatchResult, error),
+	save func(db dbtx, i int) (int, string, error), journal batchJournal) ([]BatchResult, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := journal.load(ctx, tx, journal.updates)
+	if err != nil {
+		return nil, err
+	}
+
+	results, bulkErr := bulk(tx)
+	if bulkErr == nil {
+		if err := journal.write(ctx, tx, before, results); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return results, nil
+	}
+	tx.Rollback()
+
+	results, err = s.saveEach(ctx, n, save, false, journal)
+	if err != nil {
+		return nil, err
+	}
+	reproduced := failed(results)
+	for i := range results {
+		switch {
+		case !reproduced:
+			// построчно ошибка не воспроизвелась (например, гонка с другим запросом)
+			results[i].Error = "batch rolled back: " + rowError(bulkErr)
+		case results[i].Error != "":
+			continue
+		}
+		// пакет откачен, у корректных строк нет ни ID, ни действия
+		results[i].ID, results[i].Action = 0, ""
+	}
+	return results, nil
+}
+
+// saveEach — строки по одной, каждая в своей точке сохранения; commit
+// определяет, фиксируются ли успешные строки (и пишется ли их журнал)
+func (s *Storage) saveEach(ctx context.Context, n int, save func(db dbtx, i int) (int, string, error), commit bool,
+	journal batchJournal) ([]BatchResult, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var before map[int]any
+	if commit {
+		if before, err = journal.load(ctx, tx, journal.updates); err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]BatchResult, n)
+	for i := 0; i < n; i++ {
+		results[i].Index = i
+
+		if _, err := tx.ExecContext(ctx, `SAVEPOINT batch_row`); err != nil {
+			return nil, err
+		}
+		id, action, err := save(tx, i)
+		if err != nil {
+			if _, rbErr := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT batch_row`); rbErr != nil {
+				return nil, rbErr
+			}
+			results[i].Error = rowError(err)
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `RELEASE SAVEPOINT batch_row`); err != nil {
+			return nil, err
+		}
+		results[i].ID, results[i].Action = id, action
+	}
+
+	if commit {
+		if err := journal.write(ctx, tx, before, results); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// batchJournal — построчный журнал пакета: состояния обновляемых строк
+// (updates) читаются до записи, итоговые — после, перед фиксацией
+type batchJournal struct {
+	entityType string
+	updates    []int
+	load       func(ctx context.Context, tx *sql.Tx, ids []int) (map[int]any, error)
+}
+
+// write — журнал успешно записанных строк results
+func (j batchJournal) write(ctx context.Context, tx *sql.Tx, before map[int]any, results []BatchResult) error {
+	var ids []int
+	for _, r := range results {
+		if r.Error == "" && r.Action != "" {
+			ids = append(ids, r.ID)
+		}
+	}
+
+	after, err := j.load(ctx, tx, ids)
+	if err != nil {
+		return err
+	}
+
+	changes := make([]auditChange, 0, len(ids))
+	for _, r := range results {
+		if r.Error != "" || r.Action == "" {
+			continue
+		}
+		id := r.ID
+		change := auditChange{EntityType: j.entityType, EntityID: &id, Action: AuditCreate, After: after[id]}
+		if r.Action == BatchUpdated {
+			change.Action, change.Before = AuditUpdate, before[id]
+		}
+		changes = append(changes, change)
+	}
+	return addAuditChanges(ctx, tx, changes)
+}
+
+// queryCreated — ID вставленных строк по номеру строки ord (с 1)
+// в массивах запроса
+func queryCreated(ctx context.Context, tx *sql.Tx, query string, args ...any) (map[int]int, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[int]int)
+	for rows.Next() {
+		var ord, id int
+		if err := rows.Scan(&ord, &id); err != nil {
+			return nil, err
+		}
+		ids[ord] = id
+	}
+	return ids, rows.Err()
+}
+
+// fillCreated — результаты созданных строк: created[k] — позиция во входном
+// массиве строки с номером k+1 в запросе
+func fillCreated(results []BatchResult, created []int, ids map[int]int) ([]BatchResult, error) {
+	for k, i := range created {
+		id, ok := ids[k+1]
+		if !ok {
+			return nil, fmt.Errorf("bulk insert returned no id for row %d", i)
+		}
+		results[i].ID, results[i].Action = id, BatchCreated
+	}
+	return results, nil
+}
+
+func failed(results []BatchResult) bool {
+	for _, r := range results {
+		if r.Error != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// rowError — понятная клиенту причина ошибки строки без внутренних префиксов
+func rowError(err error) string {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		if pqErr.Detail != "" {
+			return pqErr.Message + ": " + pqErr.Detail
+		}
+		return pqErr.Message
+	}
+	for _, known := range []error{storage.ErrProductNotFound, storage.ErrCustomerNotFound, storage.ErrVersionConflict} {
+		if errors.Is(err, known) {
+			return known.Error()
+		}
+	}
+	return err.Error()
+}
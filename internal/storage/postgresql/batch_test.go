@@ -0,0 +1,43 @@
+package postgresql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFillCreated(t *testing.T) {
+	tests := []struct {
+		name    string
+		created []int
+		ids     map[int]int
+		want    []BatchResult
+		wantErr bool
+	}{
+		{
+			// строки 0 и 2 создаются, строка 1 обновлена раньше; ID выданы не по порядку
+			name:    "ids matched by ord",
+			created: []int{0, 2},
+			ids:     map[int]int{1: 57, 2: 41},
+			want: []BatchResult{
+				{Index: 0, ID: 57, Action: BatchCreated},
+				{Index: 1, ID: 5, Action: BatchUpdated},
+				{Index: 2, ID: 41, Action: BatchCreated},
+			},
+		},
+		{name: "row without id", created: []int{0, 2}, ids: map[int]int{1: 57}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := []BatchResult{{Index: 0}, {Index: 1, ID: 5, Action: BatchUpdated}, {Index: 2}}
+
+			got, err := fillCreated(results, tt.created, tt.ids)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fillCreated() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fillCreated() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
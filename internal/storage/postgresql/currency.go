@@ -0,0 +1,152 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"salesTracker/internal/storage"
+)
+
+// ====================================================================
+// CURRENCIES - Валюты и курсы
+// ====================================================================
+
+// BaseCurrency — учетная валюта: курсы хранятся относительно нее
+const BaseCurrency = "RUB"
+
+// ExchangeRate — курс валюты на дату: 1 единица Currency стоит Rate рублей
+type ExchangeRate struct {
+	RateDate time.Time `json:"rate_date"`
+	Currency string    `json:"currency"`
+	Rate     float64   `json:"rate"`
+}
+
+// UpsertExchangeRates — загрузить курсы одной транзакцией;
+// курс на уже известную дату перезаписывается, каждый курс пишется в журнал
+// вместе с прежним значением
+func (s *Storage) UpsertExchangeRates(ctx context.Context, rates []ExchangeRate) (int, error) {
+	const op = "storage.postgresql.UpsertExchangeRates"
+
+	ctx, done := s.analytics(ctx, op)
+	defer done()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	// CTE видит таблицу до вставки, поэтому old — прежний курс на дату
+	stmt, err := tx.PrepareContext(ctx, `WITH old AS (
+				SELECT rate FROM exchange_rates WHERE rate_date = $1 AND currency = $2
+			)
+			INSERT INTO exchange_rates (rate_date, currency, rate)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (currency, rate_date) DO UPDATE SET rate = EXCLUDED.rate
+			RETURNING rate_date, currency, rate, (SELECT rate FROM old)`)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	changes := make([]auditChange, 0, len(rates))
+	for _, rate := range rates {
+		var (
+			after ExchangeRate
+			old   sql.NullFloat64
+		)
+		err := stmt.QueryRowContext(ctx, dateParam(rate.RateDate), strings.ToUpper(rate.Currency), rate.Rate).
+			Scan(&after.RateDate, &after.Currency, &after.Rate, &old)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %s on %s: %w", op, rate.Currency, dateParam(rate.RateDate), err)
+		}
+
+		change := auditChange{EntityType: "exchange_rates", Action: AuditCreate, After: after}
+		if old.Valid {
+			change.Action = AuditUpdate
+			change.Before = ExchangeRate{RateDate: after.RateDate, Currency: after.Currency, Rate: old.Float64}
+		}
+		changes = append(changes, change)
+	}
+
+	if err := addAuditChanges(ctx, tx, changes); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return len(rates), nil
+}
+
+// ListExchangeRates — курсы за период; пустая валюта — все валюты
+func (s *Storage) ListExchangeRates(ctx context.Context, currency string, start, end time.Time) ([]ExchangeRate, error) {
+	const op = "storage.postgresql.ListExchangeRates"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	rows, err := s.DB.QueryContext(ctx, `SELECT rate_date, currency, rate
+			FROM exchange_rates
+			WHERE ($1 = '' OR currency = $1) AND rate_date BETWEEN $2 AND $3
+			ORDER BY currency, rate_date`, currency, dateParam(start), dateParam(end))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var rates []ExchangeRate
+	for rows.Next() {
+		var rate ExchangeRate
+		if err := rows.Scan(&rate.RateDate, &rate.Currency, &rate.Rate); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		rates = append(rates, rate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rates, nil
+}
+
+// checkExchangeRates — убедиться, что для каждого заказа периода [from, to)
+// есть курс пересчета в валюту отчета. Без этой проверки заказы без курса
+// молча выпали бы из сумм.
+func (s *Storage) checkExchangeRates(ctx context.Context, from, to time.Time, currency string) error {
+	const op = "storage.postgresql.checkExchangeRates"
+
+	if currency == "" {
+		return nil
+	}
+
+	query := `SELECT x.currency, MIN(x.day)
+			FROM (
+				SELECT DISTINCT currency, utc_day(order_date) AS day
+				FROM orders
+				WHERE order_date >= $1 AND order_date < $2 AND currency <> $3 AND deleted_at IS NULL
+			) x
+			WHERE convert_amount(1, x.currency, $3, x.day) IS NULL
+			GROUP BY x.currency
+			ORDER BY x.currency
+			LIMIT 1`
+
+	var (
+		missing string
+		day     time.Time
+	)
+	err := s.DB.QueryRowContext(ctx, query, from, to, currency).Scan(&missing, &day)
+	if err == nil {
+		return fmt.Errorf("%w: %s to %s on %s", storage.ErrMissingExchangeRate, missing, currency, dateParam(day))
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
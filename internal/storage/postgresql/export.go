@@ -0,0 +1,215 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"salesTracker/internal/storage"
+)
+
+// ====================================================================
+// EXPORT - Потоковая выгрузка таблиц для хранилища данных
+// ====================================================================
+
+// exportFetchSize — строк за один FETCH из курсора
+const exportFetchSize = 1000
+
+// ExportType — тип значений колонки выгрузки
+type ExportType int
+
+// Типы колонок выгрузки
+const (
+	// ExportText — строка (string или []byte)
+	ExportText ExportType = iota
+	// ExportInteger — целое (int64)
+	ExportInteger
+	// ExportNumeric — NUMERIC с Scale знаками после запятой; драйвер отдает
+	// его текстом ([]byte), в JSON оно пишется числом
+	ExportNumeric
+	// ExportTimestamp — момент времени (time.Time)
+	ExportTimestamp
+)
+
+// ExportColumn — колонка выгрузки; значения могут быть NULL
+type ExportColumn struct {
+	Name  string
+	Type  ExportType
+	Scale int
+
+	expr string
+}
+
+type exportTable struct {
+	table   string
+	key     string
+	columns []ExportColumn
+}
+
+func text(name string) ExportColumn {
+	return ExportColumn{Name: name, Type: ExportText, expr: name}
+}
+
+func integer(name string) ExportColumn {
+	return ExportColumn{Name: name, Type: ExportInteger, expr: name}
+}
+
+func timestamp(name string) ExportColumn {
+	return ExportColumn{Name: name, Type: ExportTimestamp, expr: name}
+}
+
+// numeric — денежная колонка NUMERIC(p, 2)
+func numeric(name string) ExportColumn {
+	return ExportColumn{Name: name, Type: ExportNumeric, Scale: 2, expr: name}
+}
+
+// exportTables — выгружаемые сущности. Удаленные записи выгружаются
+// с заполненным deleted_at, чтобы хранилище данных видело удаление.
+var exportTables = map[string]exportTable{
+	"categories": {table: "categories", key: "category_id", columns: []ExportColumn{
+		integer("category_id"), text("category_name"), text("description"), integer("version"), timestamp("updated_at"),
+	}},
+	"products": {table: "products", key: "product_id", columns: []ExportColumn{
+		integer("product_id"), text("product_name"), integer("category_id"), numeric("price"), numeric("cost"),
+		integer("stock_quantity"), text("currency"), integer("version"), timestamp("updated_at"), timestamp("deleted_at"),
+	}},
+	"customers": {table: "customers", key: "customer_id", columns: []ExportColumn{
+		integer("customer_id"), text("first_name"), text("last_name"), text("email"), text("phone"), text("city"),
+		{Name: "registration_date", Type: ExportText, expr: "registration_date::text"}, integer("version"),
+		timestamp("updated_at"), timestamp("deleted_at"),
+	}},
+	"orders": {table: "orders", key: "order_id", columns: []ExportColumn{
+		integer("order_id"), integer("customer_id"), timestamp("order_date"), text("status"), numeric("total_amount"),
+		text("payment_method"), text("currency"), text("external_ref"), integer("version"), timestamp("updated_at"),
+		timestamp("deleted_at"),
+	}},
+	"order_items": {table: "order_items", key: "order_item_id", columns: []ExportColumn{
+		integer("order_item_id"), integer("order_id"), integer("product_id"), integer("quantity"), numeric("price"),
+		numeric("discount"), numeric("discount_amount"), integer("promotion_id"), timestamp("updated_at"),
+	}},
+}
+
+// ExportEntities — имена выгружаемых сущностей
+func ExportEntities() []string {
+	names := make([]string, 0, len(exportTables))
+	for name := range exportTables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ExportCursor — открытая выгрузка: серверный курсор в транзакции
+// REPEATABLE READ, все строки читаются из одного снимка данных
+type ExportCursor struct {
+	// Columns — колонки строк в порядке значений Next
+	Columns []ExportColumn
+	// Watermark — значение updated_since для следующей инкрементальной
+	// выгрузки: изменения после него в эту выгрузку не попали
+	Watermark time.Time
+
+	ctx     context.Context
+	tx      *sql.Tx
+	rows    *sql.Rows
+	fetched int
+	done    bool
+}
+
+// OpenExport — начать выгрузку сущности entity в порядке первичного ключа;
+// since != nil — только записи с updated_at не раньше since. Курсор нужно закрыть.
+func (s *Storage) OpenExport(ctx context.Context, entity string, since *time.Time) (*ExportCursor, error) {
+	const op = "storage.postgresql.OpenExport"
+
+	spec, ok := exportTables[entity]
+	if !ok {
+		return nil, fmt.Errorf("%w %q, use one of: %s", storage.ErrUnknownExport, entity, strings.Join(ExportEntities(), ", "))
+	}
+
+	tx, err := s.DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Запись, которую еще не зафиксировала начатая раньше транзакция, получит
+	// updated_at не позже начала этой транзакции, а в снимок не попадет, поэтому
+	// отметка сдвигается к началу самой старой пишущей транзакции.
+	c := &ExportCursor{Columns: spec.columns, ctx: ctx, tx: tx}
+	err = tx.QueryRowContext(ctx, `SELECT LEAST(NOW(), (SELECT MIN(xact_start) FROM pg_stat_activity
+			WHERE backend_xid IS NOT NULL AND pid <> pg_backend_pid()))`).Scan(&c.Watermark)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	exprs := make([]string, len(spec.columns))
+	for i, column := range spec.columns {
+		exprs[i] = column.expr
+	}
+	// DECLARE не принимает параметры запроса; since форматируется здесь, а не клиентом
+	where := ""
+	if since != nil {
+		where = `WHERE updated_at >= '` + since.UTC().Format(time.RFC3339Nano) + `'::timestamptz`
+	}
+	_, err = tx.ExecContext(ctx, `DECLARE export_rows NO SCROLL CURSOR FOR
+			SELECT `+strings.Join(exprs, ", ")+` FROM `+spec.table+` `+where+`
+			ORDER BY `+spec.key)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return c, nil
+}
+
+// Next — следующая строка выгрузки; io.EOF — строки закончились. Значения:
+// int64, float64, bool, string, time.Time, []byte (NUMERIC и CHAR) или nil.
+func (c *ExportCursor) Next() ([]any, error) {
+	const op = "storage.postgresql.ExportCursor.Next"
+
+	for {
+		if c.rows == nil {
+			if c.done {
+				return nil, io.EOF
+			}
+			rows, err := c.tx.QueryContext(c.ctx, fmt.Sprintf(`FETCH %d FROM export_rows`, exportFetchSize))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			c.rows, c.fetched = rows, 0
+		}
+
+		if c.rows.Next() {
+			c.fetched++
+			values := make([]any, len(c.Columns))
+			dest := make([]any, len(values))
+			for i := range values {
+				dest[i] = &values[i]
+			}
+			if err := c.rows.Scan(dest...); err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			return values, nil
+		}
+
+		err := c.rows.Err()
+		c.rows.Close()
+		c.rows = nil
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		// неполный FETCH — курсор дочитан
+		c.done = c.fetched < exportFetchSize
+	}
+}
+
+// Close — закрыть курсор и завершить транзакцию выгрузки
+func (c *ExportCursor) Close() error {
+	if c.rows != nil {
+		c.rows.Close()
+	}
+	return c.tx.Rollback()
+}
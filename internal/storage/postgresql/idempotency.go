@@ -0,0 +1,134 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ====================================================================
+// IDEMPOTENCY KEYS - Сохраненные ответы на повторяемые POST
+// ====================================================================
+
+// IdempotencyKey — ключ Idempotency-Key клиента и ответ на первый запрос с ним.
+// StatusCode 0 — первый запрос еще выполняется.
+type IdempotencyKey struct {
+	Scope       string
+	Key         string
+	Method      string
+	Path        string
+	RequestHash string
+	StatusCode  int
+	Headers     map[string]string
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// ClaimIdempotencyKey — занять ключ под новый запрос. Если ключ уже занят и не
+// истек, возвращает сохраненную запись и false; истекший ключ занимается заново.
+func (s *Storage) ClaimIdempotencyKey(ctx context.Context, k IdempotencyKey) (*IdempotencyKey, bool, error) {
+	const op = "storage.postgresql.ClaimIdempotencyKey"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	var claimed bool
+	err := s.DB.QueryRowContext(ctx, `INSERT INTO idempotency_keys (scope, idem_key, method, path, request_hash, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (scope, idem_key) DO UPDATE
+				SET method = EXCLUDED.method, path = EXCLUDED.path, request_hash = EXCLUDED.request_hash,
+					status_code = NULL, response_headers = NULL, response_body = NULL,
+					created_at = NOW(), expires_at = EXCLUDED.expires_at
+				WHERE idempotency_keys.expires_at <= NOW()
+			RETURNING TRUE`, k.Scope, k.Key, k.Method, k.Path, k.RequestHash, k.ExpiresAt).Scan(&claimed)
+	if err == nil {
+		return nil, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var (
+		existing IdempotencyKey
+		status   sql.NullInt64
+		headers  []byte
+	)
+	err = s.DB.QueryRowContext(ctx, `SELECT scope, idem_key, method, path, request_hash, status_code, response_headers,
+				response_body, created_at, expires_at
+			FROM idempotency_keys
+			WHERE scope = $1 AND idem_key = $2`, k.Scope, k.Key).
+		Scan(&existing.Scope, &existing.Key, &existing.Method, &existing.Path, &existing.RequestHash, &status,
+			&headers, &existing.Body, &existing.CreatedAt, &existing.ExpiresAt)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	existing.StatusCode = int(status.Int64)
+	if len(headers) > 0 {
+		if err := json.Unmarshal(headers, &existing.Headers); err != nil {
+			return nil, false, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return &existing, false, nil
+}
+
+// CompleteIdempotencyKey — сохранить ответ на запрос, занявший ключ
+func (s *Storage) CompleteIdempotencyKey(ctx context.Context, scope, key string, status int, headers map[string]string, body []byte) error {
+	const op = "storage.postgresql.CompleteIdempotencyKey"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	data, err := json.Marshal(headers)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = s.DB.ExecContext(ctx, `UPDATE idempotency_keys
+			SET status_code = $3, response_headers = $4, response_body = $5
+			WHERE scope = $1 AND idem_key = $2`, scope, key, status, data, body)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ReleaseIdempotencyKey — освободить ключ, чтобы клиент мог повторить запрос
+func (s *Storage) ReleaseIdempotencyKey(ctx context.Context, scope, key string) error {
+	const op = "storage.postgresql.ReleaseIdempotencyKey"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	if _, err := s.DB.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE scope = $1 AND idem_key = $2`, scope, key); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// PurgeIdempotencyKeys — удалить истекшие ключи, возвращает число удаленных
+func (s *Storage) PurgeIdempotencyKeys(ctx context.Context, now time.Time) (int64, error) {
+	const op = "storage.postgresql.PurgeIdempotencyKeys"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	purged, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return purged, nil
+}
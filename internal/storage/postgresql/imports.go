@@ -0,0 +1,390 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"salesTracker/internal/storage"
+)
+
+// ====================================================================
+// IMPORTS - Загрузка исторических заказов
+// ====================================================================
+
+// Статусы загрузки
+const (
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+)
+
+// ImportJob — загрузка файла заказов; LastLine — последняя строка файла,
+// уже записанная в базу
+type ImportJob struct {
+	JobID          int        `json:"job_id"`
+	Source         string     `json:"source"`
+	Format         string     `json:"format"`
+	Status         string     `json:"status"`
+	LastLine       int        `json:"last_line"`
+	OrdersImported int        `json:"orders_imported"`
+	OrdersSkipped  int        `json:"orders_skipped"`
+	OrdersFailed   int        `json:"orders_failed"`
+	Error          string     `json:"error,omitempty"`
+	StartedAt      time.Time  `json:"started_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+}
+
+const importJobColumns = `job_id, source, format, status, last_line, orders_imported, orders_skipped, orders_failed,
+	COALESCE(error, ''), started_at, updated_at, finished_at`
+
+func scanImportJob(row interface{ Scan(...any) error }) (*ImportJob, error) {
+	var j ImportJob
+	err := row.Scan(&j.JobID, &j.Source, &j.Format, &j.Status, &j.LastLine, &j.OrdersImported, &j.OrdersSkipped,
+		&j.OrdersFailed, &j.Error, &j.StartedAt, &j.UpdatedAt, &j.FinishedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// ImportOrder — заказ из файла с уже найденными покупателем и товарами.
+// ExternalRef — номер заказа в старой системе: заказ с уже загруженным
+// номером пропускается, поэтому повторная загрузка файла не создает дублей.
+type ImportOrder struct {
+	Line          int
+	ExternalRef   string
+	CustomerID    int
+	OrderDate     time.Time
+	Status        string
+	PaymentMethod string
+	Currency      string
+	Items         []ImportOrderItem
+}
+
+// ImportOrderItem — позиция загружаемого заказа; Discount — скидка в процентах
+type ImportOrderItem struct {
+	ProductID int
+	Quantity  int
+	Price     float64
+	Discount  float64
+}
+
+// ImportBatch — пачка заказов одной транзакции. LastLine — последняя строка
+// файла, прочитанная к концу пачки, Rejected — заказы пачки, отброшенные
+// до записи (ошибки разбора, не найден покупатель или товар).
+type ImportBatch struct {
+	JobID    int
+	LastLine int
+	Rejected int
+	Orders   []ImportOrder
+}
+
+// ImportOutcome — итог записи одного заказа пачки
+type ImportOutcome struct {
+	Line    int
+	OrderID int
+	Skipped bool
+	Error   string
+}
+
+// CreateImportJob — начать загрузку файла source
+func (s *Storage) CreateImportJob(ctx context.Context, source, format string) (*ImportJob, error) {
+	const op = "storage.postgresql.CreateImportJob"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	job, err := scanImportJob(s.DB.QueryRowContext(ctx, `INSERT INTO import_jobs (source, format)
+			VALUES ($1, $2)
+			RETURNING `+importJobColumns, source, format))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return job, nil
+}
+
+// GetImportJob — загрузка по ID
+func (s *Storage) GetImportJob(ctx context.Context, id int) (*ImportJob, error) {
+	const op = "storage.postgresql.GetImportJob"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	job, err := scanImportJob(s.DB.QueryRowContext(ctx, `SELECT `+importJobColumns+` FROM import_jobs WHERE job_id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrImportJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return job, nil
+}
+
+// ResumeImportJob — вернуть незавершенную загрузку в работу
+func (s *Storage) ResumeImportJob(ctx context.Context, id int) (*ImportJob, error) {
+	const op = "storage.postgresql.ResumeImportJob"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	job, err := scanImportJob(s.DB.QueryRowContext(ctx, `UPDATE import_jobs
+			SET status = 'running', error = NULL, finished_at = NULL, updated_at = NOW()
+			WHERE job_id = $1 AND status <> 'completed'
+			RETURNING `+importJobColumns, id))
+	if err == nil {
+		return job, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := s.GetImportJob(ctx, id); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%w: import job %d is already completed", storage.ErrImportJobFinished, id)
+}
+
+// FinishImportJob — отметить загрузку завершенной (ImportCompleted) или
+// прерванной ошибкой (ImportFailed); прерванную можно продолжить
+func (s *Storage) FinishImportJob(ctx context.Context, id int, status, message string) error {
+	const op = "storage.postgresql.FinishImportJob"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	res, err := s.DB.ExecContext(ctx, `UPDATE import_jobs
+			SET status = $2, error = NULLIF($3, ''), finished_at = NOW(), updated_at = NOW()
+			WHERE job_id = $1`, id, status, message)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return checkAffected(op, res, storage.ErrImportJobNotFound)
+}
+
+// FindCustomerID — покупатель по email, а если email пуст — по имени
+// "Имя Фамилия" без учета регистра; удаленные покупатели не ищутся
+func (s *Storage) FindCustomerID(ctx context.Context, email, name string) (int, error) {
+	const op = "storage.postgresql.FindCustomerID"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	query := `SELECT customer_id FROM customers WHERE LOWER(email) = LOWER($1) AND deleted_at IS NULL`
+	key := email
+	if email == "" {
+		query = `SELECT customer_id FROM customers
+			WHERE LOWER(first_name || ' ' || last_name) = LOWER($1) AND deleted_at IS NULL`
+		key = name
+	}
+
+	id, err := s.findID(ctx, query+` ORDER BY customer_id LIMIT 2`, key, storage.ErrCustomerNotFound)
+	if err != nil && !errors.Is(err, storage.ErrCustomerNotFound) && !errors.Is(err, storage.ErrAmbiguousMatch) {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return id, err
+}
+
+// FindProductID — товар по названию без учета регистра
+func (s *Storage) FindProductID(ctx context.Context, name string) (int, error) {
+	const op = "storage.postgresql.FindProductID"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	id, err := s.findID(ctx, `SELECT product_id FROM products
+			WHERE LOWER(product_name) = LOWER($1) AND deleted_at IS NULL
+			ORDER BY product_id LIMIT 2`, name, storage.ErrProductNotFound)
+	if err != nil && !errors.Is(err, storage.ErrProductNotFound) && !errors.Is(err, storage.ErrAmbiguousMatch) {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return id, err
+}
+
+// ImportOrders — записать пачку заказов одной транзакцией. Каждый заказ пишется
+// в своей точке сохранения: ошибка заказа попадает в его ImportOutcome и не
+// отменяет остальные. Вместе с заказами в той же транзакции сохраняется
+// прогресс загрузки, поэтому после сбоя загрузка продолжается ровно с
+// первой незаписанной пачки. Записанные заказы и позиции журналируются там же.
+// dryRun — все проверки выполняются, но транзакция откатывается, а прогресс
+// не сохраняется.
+func (s *Storage) ImportOrders(ctx context.Context, b ImportBatch, dryRun bool) ([]ImportOutcome, error) {
+	const op = "storage.postgresql.ImportOrders"
+
+	ctx, done := s.analytics(ctx, op)
+	defer done()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var (
+		imported, skipped, failed int
+		importedIDs               []int
+		created                   []ImportOrder
+	)
+	outcomes := make([]ImportOutcome, len(b.Orders))
+	for i, o := range b.Orders {
+		outcomes[i].Line = o.Line
+
+		if _, err := tx.ExecContext(ctx, `SAVEPOINT import_order`); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		id, err := insertImportOrder(ctx, tx, o)
+		if err != nil {
+			if _, rbErr := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT import_order`); rbErr != nil {
+				return nil, fmt.Errorf("%s: %w", op, rbErr)
+			}
+			outcomes[i].Error = rowError(err)
+			failed++
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `RELEASE SAVEPOINT import_order`); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if id == 0 {
+			outcomes[i].Skipped = true
+			skipped++
+			continue
+		}
+		outcomes[i].OrderID = id
+		importedIDs = append(importedIDs, id)
+		created = append(created, o)
+		imported++
+	}
+
+	if dryRun {
+		return outcomes, nil
+	}
+
+	if err := auditImportedOrders(ctx, tx, importedIDs); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if b.JobID != 0 {
+		_, err := tx.ExecContext(ctx, `UPDATE import_jobs
+				SET last_line = $2, orders_imported = orders_imported + $3, orders_skipped = orders_skipped + $4,
+					orders_failed = orders_failed + $5, updated_at = NOW()
+				WHERE job_id = $1`, b.JobID, b.LastLine, imported, skipped, failed+b.Rejected)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, o := range created {
+		s.orderCreated(o.Status, o.Currency)
+	}
+	return outcomes, nil
+}
+
+// auditImportedOrders — журнал загруженных заказов и их позиций, по записи
+// на заказ и на позицию
+func auditImportedOrders(ctx context.Context, tx *sql.Tx, ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	orders, err := rowsByID(ctx, tx, `SELECT `+orderColumns+` FROM orders WHERE order_id = ANY($1)`, ids,
+		scanOrder, func(o *Order) int { return o.OrderID })
+	if err != nil {
+		return err
+	}
+	items, err := rowsByID(ctx, tx, `SELECT `+orderItemColumns+` FROM order_items WHERE order_id = ANY($1)`, ids,
+		scanOrderItem, func(item *OrderItem) int { return item.OrderItemID })
+	if err != nil {
+		return err
+	}
+
+	changes := make([]auditChange, 0, len(orders)+len(items))
+	for _, id := range ids {
+		changes = append(changes, auditChange{EntityType: "orders", EntityID: &id, Action: AuditCreate, After: orders[id]})
+	}
+	for _, id := range slices.Sorted(maps.Keys(items)) {
+		changes = append(changes, auditChange{EntityType: "order_items", EntityID: &id, Action: AuditCreate, After: items[id]})
+	}
+	return addAuditChanges(ctx, tx, changes)
+}
+
+// insertImportOrder — заказ с позициями; 0 — заказ с таким ExternalRef уже есть.
+// Сумма заказа считается по позициям за вычетом процентных скидок.
+func insertImportOrder(ctx context.Context, tx *sql.Tx, o ImportOrder) (int, error) {
+	if len(o.Items) == 0 {
+		return 0, fmt.Errorf("%w: no items", storage.ErrInvalidOrder)
+	}
+
+	var total float64
+	amounts := make([]float64, len(o.Items))
+	for i, item := range o.Items {
+		gross := item.Price * float64(item.Quantity)
+		amounts[i] = gross * item.Discount / 100
+		total += gross - amounts[i]
+	}
+
+	var id int
+	err := tx.QueryRowContext(ctx, `INSERT INTO orders (customer_id, order_date, status, total_amount, payment_method, currency, external_ref)
+			VALUES (NULLIF($1, 0), $2, COALESCE(NULLIF($3, ''), 'completed'), $4, $5, $6, NULLIF($7, ''))
+			ON CONFLICT (external_ref) WHERE external_ref IS NOT NULL DO NOTHING
+			RETURNING order_id`, o.CustomerID, o.OrderDate, o.Status, total, nullString(o.PaymentMethod), o.Currency,
+		o.ExternalRef).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	for i, item := range o.Items {
+		_, err := tx.ExecContext(ctx, `INSERT INTO order_items (order_id, product_id, quantity, price, discount, discount_amount)
+				VALUES ($1, $2, $3, $4, $5, $6)`, id, item.ProductID, item.Quantity, item.Price, item.Discount, amounts[i])
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return id, nil
+}
+
+// findID — единственный ID из запроса с LIMIT 2; две строки — неоднозначное совпадение
+func (s *Storage) findID(ctx context.Context, query, key string, notFound error) (int, error) {
+	rows, err := s.DB.QueryContext(ctx, query, key)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	switch len(ids) {
+	case 0:
+		return 0, notFound
+	case 1:
+		return ids[0], nil
+	default:
+		return 0, storage.ErrAmbiguousMatch
+	}
+}
@@ -0,0 +1,65 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+)
+
+// ====================================================================
+// METRICS - Показатели продаж для /api/v1/metrics/business
+// ====================================================================
+
+// defaultOrderStatus — статус заказа, если он не задан при создании
+const defaultOrderStatus = "completed"
+
+// orderCreated — сообщить OrderCreated о записанном заказе; вызывается
+// после фиксации транзакции, чтобы откаченные заказы не попадали в счетчик
+func (s *Storage) orderCreated(status, currency string) {
+	if s.OrderCreated == nil {
+		return
+	}
+	if status == "" {
+		status = defaultOrderStatus
+	}
+	s.OrderCreated(status, currency)
+}
+
+// CurrencyRevenue — сумма неудаленных заказов в одной валюте
+type CurrencyRevenue struct {
+	Currency string
+	Amount   float64
+}
+
+// RevenueTotals — выручка по валютам из дневной сводки: строк в ней на
+// порядки меньше, чем заказов, поэтому запрос дешев для каждого опроса.
+// Заказы последних секунд попадают в сводку с фоновым пересчетом.
+func (s *Storage) RevenueTotals(ctx context.Context) ([]CurrencyRevenue, error) {
+	const op = "storage.postgresql.RevenueTotals"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	rows, err := s.DB.QueryContext(ctx, `SELECT currency, COALESCE(SUM(total_amount), 0)
+			FROM daily_sales_rollup
+			WHERE category_id IS NULL
+			GROUP BY currency
+			ORDER BY currency`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var totals []CurrencyRevenue
+	for rows.Next() {
+		var t CurrencyRevenue
+		if err := rows.Scan(&t.Currency, &t.Amount); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		totals = append(totals, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return totals, nil
+}
@@ -1,14 +1,40 @@
 package postgresql
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"time"
+
+	"github.com/lib/pq"
+
+	"salesTracker/internal/storage"
 )
 
+// Storage — хранилище на PostgreSQL. Каждая операция получает контекст
+// вызывающего и ограничивается таймаутом своего класса: QueryTimeout — запросы
+// CRUD, AnalyticsTimeout — отчеты и массовая запись. Нулевой таймаут — без
+// ограничения, запрос прерывается только отменой контекста.
 type Storage struct {
-	DB *sql.DB
+	DB               *sql.DB
+	QueryTimeout     time.Duration
+	AnalyticsTimeout time.Duration
+	// Observe — необязательный приемник длительности операций для метрик:
+	// op — имя операции ("storage.postgresql.GetOrder"), class — ClassCRUD
+	// или ClassAnalytics
+	Observe func(op, class string, elapsed time.Duration)
+	// OrderCreated — необязательный приемник созданных заказов для счетчика
+	// в метриках: вызывается после фиксации с итоговым статусом и валютой
+	OrderCreated func(status, currency string)
 }
 
+// Классы операций хранилища
+const (
+	ClassCRUD      = "crud"
+	ClassAnalytics = "analytics"
+)
+
 // ====================================================================
 // CATEGORIES - Категории товаров
 // ====================================================================
@@ -17,33 +43,121 @@ type Category struct {
 	CategoryID   int    `json:"category_id"`
 	CategoryName string `json:"category_name"`
 	Description  string `json:"description"`
+	Version      int    `json:"version"`
 }
 
-func (s *Storage) AddCategory(name, description string) (int, error) {
-	// Mock implementation
-	return 1, nil
+const categoryExists = `SELECT EXISTS (SELECT 1 FROM categories WHERE category_id = $1)`
+
+const categoryColumns = `category_id, category_name, COALESCE(description, ''), version`
+
+func scanCategory(row interface{ Scan(...any) error }) (*Category, error) {
+	var c Category
+	if err := row.Scan(&c.CategoryID, &c.CategoryName, &c.Description, &c.Version); err != nil {
+		return nil, err
+	}
+	return &c, nil
 }
 
-func (s *Storage) GetCategory(id int) (*Category, error) {
-	// Mock implementation
-	return &Category{CategoryID: id, CategoryName: "Электроника", Description: "Mock category"}, nil
+func (s *Storage) AddCategory(ctx context.Context, name, description string) (int, error) {
+	const op = "storage.postgresql.AddCategory"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	return audited(ctx, s.DB, op, categoriesAudit, AuditCreate, 0, func(tx *sql.Tx) (int, error) {
+		var id int
+		err := tx.QueryRowContext(ctx, `INSERT INTO categories (category_name, description) VALUES ($1, $2)
+				RETURNING category_id`, name, nullString(description)).Scan(&id)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		return id, nil
+	})
 }
 
-func (s *Storage) ListCategories() ([]Category, error) {
-	// Mock implementation
-	return []Category{
-		{CategoryID: 1, CategoryName: "Электроника", Description: "Mock category"},
-	}, nil
+func (s *Storage) GetCategory(ctx context.Context, id int) (*Category, error) {
+	const op = "storage.postgresql.GetCategory"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	c, err := scanCategory(s.DB.QueryRowContext(ctx, `SELECT `+categoryColumns+` FROM categories WHERE category_id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrCategoryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return c, nil
 }
 
-func (s *Storage) UpdateCategory(id int, name, description string) error {
-	// Mock implementation
-	return nil
+func (s *Storage) ListCategories(ctx context.Context) ([]Category, error) {
+	const op = "storage.postgresql.ListCategories"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	rows, err := s.DB.QueryContext(ctx, `SELECT `+categoryColumns+` FROM categories ORDER BY category_id`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var categories []Category
+	for rows.Next() {
+		c, err := scanCategory(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		categories = append(categories, *c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return categories, nil
 }
 
-func (s *Storage) DeleteCategory(id int) error {
-	// Mock implementation
-	return nil
+// UpdateCategory — изменить категорию, если ее версия все еще version
+func (s *Storage) UpdateCategory(ctx context.Context, id, version int, name, description string) error {
+	const op = "storage.postgresql.UpdateCategory"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	_, err := audited(ctx, s.DB, op, categoriesAudit, AuditUpdate, id, func(tx *sql.Tx) (int, error) {
+		res, err := tx.ExecContext(ctx, `UPDATE categories
+				SET category_name = $3, description = $4, version = version + 1
+				WHERE category_id = $1 AND version = $2`, id, version, name, nullString(description))
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		return id, checkVersion(ctx, tx, op, res, categoryExists, id, storage.ErrCategoryNotFound)
+	})
+	return err
+}
+
+// DeleteCategory — удалить категорию, если ее версия все еще version.
+// Категорию с товарами удалить нельзя.
+func (s *Storage) DeleteCategory(ctx context.Context, id, version int) error {
+	const op = "storage.postgresql.DeleteCategory"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	_, err := audited(ctx, s.DB, op, categoriesAudit, AuditDelete, id, func(tx *sql.Tx) (int, error) {
+		res, err := tx.ExecContext(ctx, `DELETE FROM categories WHERE category_id = $1 AND version = $2`, id, version)
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return 0, storage.ErrCategoryInUse
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		return id, checkVersion(ctx, tx, op, res, categoryExists, id, storage.ErrCategoryNotFound)
+	})
+	return err
 }
 
 // ====================================================================
@@ -51,53 +165,194 @@ func (s *Storage) DeleteCategory(id int) error {
 // ====================================================================
 
 type Product struct {
-	ProductID     int     `json:"product_id"`
-	ProductName   string  `json:"product_name"`
-	CategoryID    int     `json:"category_id"`
-	Price         float64 `json:"price"`
-	Cost          float64 `json:"cost"`
-	StockQuantity int     `json:"stock_quantity"`
+	ProductID     int        `json:"product_id"`
+	ProductName   string     `json:"product_name"`
+	CategoryID    int        `json:"category_id"`
+	Price         float64    `json:"price"`
+	Cost          float64    `json:"cost"`
+	StockQuantity int        `json:"stock_quantity"`
+	Currency      string     `json:"currency"`
+	Version       int        `json:"version"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
 }
 
-func (s *Storage) AddProduct(name string, categoryID int, price, cost float64, stockQty int) (int, error) {
-	// Mock implementation
-	return 1, nil
+const productExists = `SELECT EXISTS (SELECT 1 FROM products WHERE product_id = $1 AND deleted_at IS NULL)`
+
+const productColumns = `product_id, product_name, COALESCE(category_id, 0), price, cost,
+	COALESCE(stock_quantity, 0), currency, version, deleted_at`
+
+func scanProduct(row interface{ Scan(...any) error }) (*Product, error) {
+	var (
+		p         Product
+		deletedAt sql.NullTime
+	)
+
+	err := row.Scan(&p.ProductID, &p.ProductName, &p.CategoryID, &p.Price, &p.Cost, &p.StockQuantity, &p.Currency, &p.Version,
+		&deletedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	p.DeletedAt = nullTime(deletedAt)
+	return &p, nil
 }
 
-func (s *Storage) GetProduct(id int) (*Product, error) {
-	// Mock implementation
-	return &Product{
-		ProductID:     id,
-		ProductName:   "Смартфон Samsung",
-		CategoryID:    1,
-		Price:         65000.00,
-		Cost:          50000.00,
-		StockQuantity: 45,
-	}, nil
+func (s *Storage) AddProduct(ctx context.Context, name string, categoryID int, price, cost float64, stockQty int, currency string) (int, error) {
+	const op = "storage.postgresql.AddProduct"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	return audited(ctx, s.DB, op, productsAudit, AuditCreate, 0, func(tx *sql.Tx) (int, error) {
+		id, err := insertProduct(ctx, tx, Product{ProductName: name, CategoryID: categoryID, Price: price, Cost: cost,
+			StockQuantity: stockQty, Currency: currency})
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		return id, nil
+	})
 }
 
-func (s *Storage) ListProducts() ([]Product, error) {
-	// Mock implementation
-	return []Product{
-		{ProductID: 1, ProductName: "Смартфон Samsung", CategoryID: 1, Price: 65000, Cost: 50000, StockQuantity: 45},
-	}, nil
+func insertProduct(ctx context.Context, db dbtx, p Product) (int, error) {
+	var id int
+	err := db.QueryRowContext(ctx, `INSERT INTO products (product_name, category_id, price, cost, stock_quantity, currency)
+			VALUES ($1, NULLIF($2, 0), $3, $4, $5, $6)
+			RETURNING product_id`, p.ProductName, p.CategoryID, p.Price, p.Cost, p.StockQuantity, p.Currency).Scan(&id)
+	return id, err
 }
 
-func (s *Storage) ListProductsByCategory(categoryID int) ([]Product, error) {
-	// Mock implementation
-	return []Product{
-		{ProductID: 1, ProductName: "Смартфон Samsung", CategoryID: categoryID, Price: 65000, Cost: 50000, StockQuantity: 45},
-	}, nil
+// GetProduct — товар по ID, в том числе удаленный (DeletedAt заполнен)
+func (s *Storage) GetProduct(ctx context.Context, id int) (*Product, error) {
+	const op = "storage.postgresql.GetProduct"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	p, err := scanProduct(s.DB.QueryRowContext(ctx, `SELECT `+productColumns+` FROM products WHERE product_id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrProductNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return p, nil
 }
 
-func (s *Storage) UpdateProduct(id int, name string, categoryID int, price, cost float64, stockQty int) error {
-	// Mock implementation
-	return nil
+func (s *Storage) ListProducts(ctx context.Context, includeDeleted bool) ([]Product, error) {
+	const op = "storage.postgresql.ListProducts"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	products, err := s.queryProducts(ctx, `SELECT `+productColumns+` FROM products
+			WHERE ($1 OR deleted_at IS NULL)
+			ORDER BY product_id`, includeDeleted)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return products, nil
 }
 
-func (s *Storage) DeleteProduct(id int) error {
-	// Mock implementation
-	return nil
+func (s *Storage) ListProductsByCategory(ctx context.Context, categoryID int, includeDeleted bool) ([]Product, error) {
+	const op = "storage.postgresql.ListProductsByCategory"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	products, err := s.queryProducts(ctx, `SELECT `+productColumns+` FROM products
+			WHERE category_id = $1 AND ($2 OR deleted_at IS NULL)
+			ORDER BY product_id`, categoryID, includeDeleted)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return products, nil
+}
+
+// UpdateProduct — изменить товар, если его версия все еще version;
+// удаленный товар не изменяется
+func (s *Storage) UpdateProduct(ctx context.Context, id, version int, name string, categoryID int, price, cost float64, stockQty int, currency string) error {
+	const op = "storage.postgresql.UpdateProduct"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	_, err := audited(ctx, s.DB, op, productsAudit, AuditUpdate, id, func(tx *sql.Tx) (int, error) {
+		return id, updateProduct(ctx, tx, op, Product{ProductID: id, Version: version, ProductName: name, CategoryID: categoryID,
+			Price: price, Cost: cost, StockQuantity: stockQty, Currency: currency})
+	})
+	return err
+}
+
+// updateProduct — записать поля товара p при совпадении p.Version
+func updateProduct(ctx context.Context, db dbtx, op string, p Product) error {
+	res, err := db.ExecContext(ctx, `UPDATE products
+			SET product_name = $3, category_id = NULLIF($4, 0), price = $5, cost = $6, stock_quantity = $7, currency = $8,
+				version = version + 1
+			WHERE product_id = $1 AND version = $2 AND deleted_at IS NULL`,
+		p.ProductID, p.Version, p.ProductName, p.CategoryID, p.Price, p.Cost, p.StockQuantity, p.Currency)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return checkVersion(ctx, db, op, res, productExists, p.ProductID, storage.ErrProductNotFound)
+}
+
+// DeleteProduct — мягкое удаление: товар скрывается из списков, но остается
+// в позициях заказов и аналитике
+func (s *Storage) DeleteProduct(ctx context.Context, id, version int) error {
+	const op = "storage.postgresql.DeleteProduct"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	_, err := audited(ctx, s.DB, op, productsAudit, AuditDelete, id, func(tx *sql.Tx) (int, error) {
+		res, err := tx.ExecContext(ctx, `UPDATE products SET deleted_at = NOW(), version = version + 1
+				WHERE product_id = $1 AND version = $2 AND deleted_at IS NULL`, id, version)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		return id, checkVersion(ctx, tx, op, res, productExists, id, storage.ErrProductNotFound)
+	})
+	return err
+}
+
+// RestoreProduct — вернуть удаленный товар; для неудаленного ничего не меняет
+func (s *Storage) RestoreProduct(ctx context.Context, id int) error {
+	const op = "storage.postgresql.RestoreProduct"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	_, err := audited(ctx, s.DB, op, productsAudit, AuditRestore, id, func(tx *sql.Tx) (int, error) {
+		res, err := tx.ExecContext(ctx, `UPDATE products SET deleted_at = NULL, version = version + 1 WHERE product_id = $1`, id)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		return id, checkAffected(op, res, storage.ErrProductNotFound)
+	})
+	return err
+}
+
+func (s *Storage) queryProducts(ctx context.Context, query string, args ...any) ([]Product, error) {
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []Product
+	for rows.Next() {
+		p, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, *p)
+	}
+
+	return products, rows.Err()
 }
 
 // ====================================================================
@@ -105,48 +360,172 @@ func (s *Storage) DeleteProduct(id int) error {
 // ====================================================================
 
 type Customer struct {
-	CustomerID        int       `json:"customer_id"`
-	FirstName         string    `json:"first_name"`
-	LastName          string    `json:"last_name"`
-	Email             string    `json:"email"`
-	Phone             string    `json:"phone"`
-	City              string    `json:"city"`
-	RegistrationDate  time.Time `json:"registration_date"`
-}
-
-func (s *Storage) AddCustomer(firstName, lastName, email, phone, city string, registrationDate time.Time) (int, error) {
-	// Mock implementation
-	return 1, nil
-}
-
-func (s *Storage) GetCustomer(id int) (*Customer, error) {
-	// Mock implementation
-	return &Customer{
-		CustomerID:       id,
-		FirstName:        "Иван",
-		LastName:         "Иванов",
-		Email:            "ivan@mail.ru",
-		Phone:            "+79161234567",
-		City:             "Москва",
-		RegistrationDate: time.Now(),
-	}, nil
-}
-
-func (s *Storage) ListCustomers() ([]Customer, error) {
-	// Mock implementation
-	return []Customer{
-		{CustomerID: 1, FirstName: "Иван", LastName: "Иванов", Email: "ivan@mail.ru", Phone: "+79161234567", City: "Москва"},
-	}, nil
-}
-
-func (s *Storage) UpdateCustomer(id int, firstName, lastName, email, phone, city string) error {
-	// Mock implementation
-	return nil
+	CustomerID       int        `json:"customer_id"`
+	FirstName        string     `json:"first_name"`
+	LastName         string     `json:"last_name"`
+	Email            string     `json:"email"`
+	Phone            string     `json:"phone"`
+	City             string     `json:"city"`
+	RegistrationDate time.Time  `json:"registration_date"`
+	Version          int        `json:"version"`
+	DeletedAt        *time.Time `json:"deleted_at,omitempty"`
 }
 
-func (s *Storage) DeleteCustomer(id int) error {
-	// Mock implementation
-	return nil
+const customerExists = `SELECT EXISTS (SELECT 1 FROM customers WHERE customer_id = $1 AND deleted_at IS NULL)`
+
+const customerColumns = `customer_id, first_name, last_name, COALESCE(email, ''), COALESCE(phone, ''),
+	COALESCE(city, ''), COALESCE(registration_date, CURRENT_DATE), version, deleted_at`
+
+func scanCustomer(row interface{ Scan(...any) error }) (*Customer, error) {
+	var (
+		c         Customer
+		deletedAt sql.NullTime
+	)
+
+	err := row.Scan(&c.CustomerID, &c.FirstName, &c.LastName, &c.Email, &c.Phone, &c.City, &c.RegistrationDate, &c.Version,
+		&deletedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	c.DeletedAt = nullTime(deletedAt)
+	return &c, nil
+}
+
+func (s *Storage) AddCustomer(ctx context.Context, firstName, lastName, email, phone, city string, registrationDate time.Time) (int, error) {
+	const op = "storage.postgresql.AddCustomer"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	return audited(ctx, s.DB, op, customersAudit, AuditCreate, 0, func(tx *sql.Tx) (int, error) {
+		id, err := insertCustomer(ctx, tx, Customer{FirstName: firstName, LastName: lastName, Email: email, Phone: phone,
+			City: city, RegistrationDate: registrationDate})
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		return id, nil
+	})
+}
+
+func insertCustomer(ctx context.Context, db dbtx, c Customer) (int, error) {
+	var id int
+	err := db.QueryRowContext(ctx, `INSERT INTO customers (first_name, last_name, email, phone, city, registration_date)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING customer_id`, c.FirstName, c.LastName, nullString(c.Email), nullString(c.Phone), nullString(c.City),
+		dateParam(c.RegistrationDate)).Scan(&id)
+	return id, err
+}
+
+// GetCustomer — покупатель по ID, в том числе удаленный (DeletedAt заполнен)
+func (s *Storage) GetCustomer(ctx context.Context, id int) (*Customer, error) {
+	const op = "storage.postgresql.GetCustomer"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	c, err := scanCustomer(s.DB.QueryRowContext(ctx, `SELECT `+customerColumns+` FROM customers WHERE customer_id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrCustomerNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return c, nil
+}
+
+func (s *Storage) ListCustomers(ctx context.Context, includeDeleted bool) ([]Customer, error) {
+	const op = "storage.postgresql.ListCustomers"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	rows, err := s.DB.QueryContext(ctx, `SELECT `+customerColumns+` FROM customers
+			WHERE ($1 OR deleted_at IS NULL)
+			ORDER BY customer_id`, includeDeleted)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var customers []Customer
+	for rows.Next() {
+		c, err := scanCustomer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		customers = append(customers, *c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return customers, nil
+}
+
+// UpdateCustomer — изменить покупателя, если его версия все еще version;
+// удаленный покупатель не изменяется
+func (s *Storage) UpdateCustomer(ctx context.Context, id, version int, firstName, lastName, email, phone, city string) error {
+	const op = "storage.postgresql.UpdateCustomer"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	_, err := audited(ctx, s.DB, op, customersAudit, AuditUpdate, id, func(tx *sql.Tx) (int, error) {
+		return id, updateCustomer(ctx, tx, op, Customer{CustomerID: id, Version: version, FirstName: firstName, LastName: lastName,
+			Email: email, Phone: phone, City: city})
+	})
+	return err
+}
+
+// updateCustomer — записать поля покупателя c при совпадении c.Version
+func updateCustomer(ctx context.Context, db dbtx, op string, c Customer) error {
+	res, err := db.ExecContext(ctx, `UPDATE customers
+			SET first_name = $3, last_name = $4, email = $5, phone = $6, city = $7, version = version + 1
+			WHERE customer_id = $1 AND version = $2 AND deleted_at IS NULL`,
+		c.CustomerID, c.Version, c.FirstName, c.LastName, nullString(c.Email), nullString(c.Phone), nullString(c.City))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return checkVersion(ctx, db, op, res, customerExists, c.CustomerID, storage.ErrCustomerNotFound)
+}
+
+// DeleteCustomer — мягкое удаление. Заказы покупателя не затрагиваются
+// и продолжают учитываться в аналитике.
+func (s *Storage) DeleteCustomer(ctx context.Context, id, version int) error {
+	const op = "storage.postgresql.DeleteCustomer"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	_, err := audited(ctx, s.DB, op, customersAudit, AuditDelete, id, func(tx *sql.Tx) (int, error) {
+		res, err := tx.ExecContext(ctx, `UPDATE customers SET deleted_at = NOW(), version = version + 1
+				WHERE customer_id = $1 AND version = $2 AND deleted_at IS NULL`, id, version)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		return id, checkVersion(ctx, tx, op, res, customerExists, id, storage.ErrCustomerNotFound)
+	})
+	return err
+}
+
+// RestoreCustomer — вернуть удаленного покупателя; для неудаленного ничего не меняет
+func (s *Storage) RestoreCustomer(ctx context.Context, id int) error {
+	const op = "storage.postgresql.RestoreCustomer"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	_, err := audited(ctx, s.DB, op, customersAudit, AuditRestore, id, func(tx *sql.Tx) (int, error) {
+		res, err := tx.ExecContext(ctx, `UPDATE customers SET deleted_at = NULL, version = version + 1 WHERE customer_id = $1`, id)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		return id, checkAffected(op, res, storage.ErrCustomerNotFound)
+	})
+	return err
 }
 
 // ====================================================================
@@ -154,53 +533,191 @@ func (s *Storage) DeleteCustomer(id int) error {
 // ====================================================================
 
 type Order struct {
-	OrderID        int       `json:"order_id"`
-	CustomerID     int       `json:"customer_id"`
-	OrderDate      time.Time `json:"order_date"`
-	Status         string    `json:"status"`
-	TotalAmount    float64   `json:"total_amount"`
-	PaymentMethod  string    `json:"payment_method"`
+	OrderID       int        `json:"order_id"`
+	CustomerID    int        `json:"customer_id"`
+	OrderDate     time.Time  `json:"order_date"`
+	Status        string     `json:"status"`
+	TotalAmount   float64    `json:"total_amount"`
+	PaymentMethod string     `json:"payment_method"`
+	Currency      string     `json:"currency"`
+	Version       int        `json:"version"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
 }
 
-func (s *Storage) AddOrder(customerID int, orderDate time.Time, status, paymentMethod string, totalAmount float64) (int, error) {
-	// Mock implementation
-	return 1, nil
+const orderExists = `SELECT EXISTS (SELECT 1 FROM orders WHERE order_id = $1 AND deleted_at IS NULL)`
+
+const orderColumns = `order_id, COALESCE(customer_id, 0), order_date, COALESCE(status, ''), COALESCE(total_amount, 0),
+	COALESCE(payment_method, ''), currency, version, deleted_at`
+
+func scanOrder(row interface{ Scan(...any) error }) (*Order, error) {
+	var (
+		o         Order
+		deletedAt sql.NullTime
+	)
+
+	err := row.Scan(&o.OrderID, &o.CustomerID, &o.OrderDate, &o.Status, &o.TotalAmount, &o.PaymentMethod, &o.Currency, &o.Version,
+		&deletedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	o.DeletedAt = nullTime(deletedAt)
+	return &o, nil
 }
 
-func (s *Storage) GetOrder(id int) (*Order, error) {
-	// Mock implementation
-	return &Order{
-		OrderID:       id,
-		CustomerID:    1,
-		OrderDate:     time.Now(),
-		Status:        "completed",
-		TotalAmount:   5000.00,
-		PaymentMethod: "Карта",
-	}, nil
+// AddOrder — заказ без позиций с нулевой суммой: сумму заказа считает
+// сервер по позициям, которые добавляются через AddOrderItem
+func (s *Storage) AddOrder(ctx context.Context, customerID int, orderDate time.Time, status, paymentMethod, currency string) (int, error) {
+	const op = "storage.postgresql.AddOrder"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	id, err := audited(ctx, s.DB, op, ordersAudit, AuditCreate, 0, func(tx *sql.Tx) (int, error) {
+		var id int
+		err := tx.QueryRowContext(ctx, `INSERT INTO orders (customer_id, order_date, status, total_amount, payment_method, currency)
+				VALUES (NULLIF($1, 0), $2, COALESCE(NULLIF($3, ''), 'completed'), 0, $4, $5)
+				RETURNING order_id`, customerID, orderDate, status, nullString(paymentMethod), currency).Scan(&id)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		return id, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	s.orderCreated(status, currency)
+	return id, nil
 }
 
-func (s *Storage) ListOrders() ([]Order, error) {
-	// Mock implementation
-	return []Order{
-		{OrderID: 1, CustomerID: 1, OrderDate: time.Now(), Status: "completed", TotalAmount: 5000, PaymentMethod: "Карта"},
-	}, nil
+// GetOrder — заказ по ID, в том числе удаленный (DeletedAt заполнен)
+func (s *Storage) GetOrder(ctx context.Context, id int) (*Order, error) {
+	const op = "storage.postgresql.GetOrder"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	o, err := scanOrder(s.DB.QueryRowContext(ctx, `SELECT `+orderColumns+` FROM orders WHERE order_id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return o, nil
 }
 
-func (s *Storage) ListOrdersByCustomer(customerID int) ([]Order, error) {
-	// Mock implementation
-	return []Order{
-		{OrderID: 1, CustomerID: customerID, OrderDate: time.Now(), Status: "completed", TotalAmount: 5000, PaymentMethod: "Карта"},
-	}, nil
+func (s *Storage) ListOrders(ctx context.Context, includeDeleted bool) ([]Order, error) {
+	const op = "storage.postgresql.ListOrders"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	orders, err := s.queryOrders(ctx, `SELECT `+orderColumns+` FROM orders
+			WHERE ($1 OR deleted_at IS NULL)
+			ORDER BY order_id`, includeDeleted)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return orders, nil
 }
 
-func (s *Storage) UpdateOrder(id int, status string, totalAmount float64) error {
-	// Mock implementation
-	return nil
+// ListOrdersByCustomer — заказы покупателя; работает и для удаленного покупателя
+func (s *Storage) ListOrdersByCustomer(ctx context.Context, customerID int, includeDeleted bool) ([]Order, error) {
+	const op = "storage.postgresql.ListOrdersByCustomer"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	orders, err := s.queryOrders(ctx, `SELECT `+orderColumns+` FROM orders
+			WHERE customer_id = $1 AND ($2 OR deleted_at IS NULL)
+			ORDER BY order_date, order_id`, customerID, includeDeleted)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return orders, nil
 }
 
-func (s *Storage) DeleteOrder(id int) error {
-	// Mock implementation
-	return nil
+// UpdateOrder — записать поля заказа o, если его версия все еще version;
+// удаленный заказ не изменяется. Сумма заказа не записывается: ее
+// пересчитывают изменения позиций.
+func (s *Storage) UpdateOrder(ctx context.Context, id, version int, o Order) error {
+	const op = "storage.postgresql.UpdateOrder"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	_, err := audited(ctx, s.DB, op, ordersAudit, AuditUpdate, id, func(tx *sql.Tx) (int, error) {
+		res, err := tx.ExecContext(ctx, `UPDATE orders
+				SET customer_id = NULLIF($3, 0), order_date = $4, status = $5, payment_method = $6,
+					currency = $7, version = version + 1
+				WHERE order_id = $1 AND version = $2 AND deleted_at IS NULL`,
+			id, version, o.CustomerID, o.OrderDate, o.Status, nullString(o.PaymentMethod), o.Currency)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		return id, checkVersion(ctx, tx, op, res, orderExists, id, storage.ErrOrderNotFound)
+	})
+	return err
+}
+
+// DeleteOrder — мягкое удаление: заказ исключается из списков и аналитики,
+// триггер пересчитывает сводку за день заказа
+func (s *Storage) DeleteOrder(ctx context.Context, id, version int) error {
+	const op = "storage.postgresql.DeleteOrder"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	_, err := audited(ctx, s.DB, op, ordersAudit, AuditDelete, id, func(tx *sql.Tx) (int, error) {
+		res, err := tx.ExecContext(ctx, `UPDATE orders SET deleted_at = NOW(), version = version + 1
+				WHERE order_id = $1 AND version = $2 AND deleted_at IS NULL`, id, version)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		return id, checkVersion(ctx, tx, op, res, orderExists, id, storage.ErrOrderNotFound)
+	})
+	return err
+}
+
+// RestoreOrder — вернуть удаленный заказ в списки и аналитику
+func (s *Storage) RestoreOrder(ctx context.Context, id int) error {
+	const op = "storage.postgresql.RestoreOrder"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	_, err := audited(ctx, s.DB, op, ordersAudit, AuditRestore, id, func(tx *sql.Tx) (int, error) {
+		res, err := tx.ExecContext(ctx, `UPDATE orders SET deleted_at = NULL, version = version + 1 WHERE order_id = $1`, id)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		return id, checkAffected(op, res, storage.ErrOrderNotFound)
+	})
+	return err
+}
+
+func (s *Storage) queryOrders(ctx context.Context, query string, args ...any) ([]Order, error) {
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []Order
+	for rows.Next() {
+		o, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, *o)
+	}
+
+	return orders, rows.Err()
 }
 
 // ====================================================================
@@ -208,44 +725,320 @@ func (s *Storage) DeleteOrder(id int) error {
 // ====================================================================
 
 type OrderItem struct {
-	OrderItemID int     `json:"order_item_id"`
-	OrderID     int     `json:"order_id"`
-	ProductID   int     `json:"product_id"`
-	Quantity    int     `json:"quantity"`
-	Price       float64 `json:"price"`
-	Discount    float64 `json:"discount"`
+	OrderItemID    int     `json:"order_item_id"`
+	OrderID        int     `json:"order_id"`
+	ProductID      int     `json:"product_id"`
+	Quantity       int     `json:"quantity"`
+	Price          float64 `json:"price"`
+	Discount       float64 `json:"discount"`
+	DiscountAmount float64 `json:"discount_amount"`
+	PromotionID    *int    `json:"promotion_id,omitempty"`
 }
 
-func (s *Storage) AddOrderItem(orderID, productID, quantity int, price, discount float64) (int, error) {
-	// Mock implementation
-	return 1, nil
+const orderItemColumns = `order_item_id, order_id, product_id, quantity, price, COALESCE(discount, 0), discount_amount,
+	promotion_id`
+
+func scanOrderItem(row interface{ Scan(...any) error }) (*OrderItem, error) {
+	var (
+		item        OrderItem
+		promotionID sql.NullInt64
+	)
+
+	err := row.Scan(&item.OrderItemID, &item.OrderID, &item.ProductID, &item.Quantity, &item.Price, &item.Discount,
+		&item.DiscountAmount, &promotionID)
+	if err != nil {
+		return nil, err
+	}
+
+	item.PromotionID = nullInt(promotionID)
+	return &item, nil
 }
 
-func (s *Storage) GetOrderItem(id int) (*OrderItem, error) {
-	// Mock implementation
-	return &OrderItem{
-		OrderItemID: id,
-		OrderID:     1,
-		ProductID:   1,
-		Quantity:    2,
-		Price:       65000,
-		Discount:    5,
-	}, nil
+// AddOrderItem — добавить позицию в неудаленный заказ по цене каталога
+// и пересчитать скидки и сумму заказа (repriceOrder)
+func (s *Storage) AddOrderItem(ctx context.Context, orderID, productID, quantity int) (int, error) {
+	const op = "storage.postgresql.AddOrderItem"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	if quantity <= 0 {
+		return 0, fmt.Errorf("%w: product %d: quantity must be positive", storage.ErrInvalidOrder, productID)
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var currency string
+	err = tx.QueryRowContext(ctx, `SELECT currency FROM orders WHERE order_id = $1 AND deleted_at IS NULL FOR UPDATE`, orderID).
+		Scan(&currency)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, storage.ErrOrderNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var (
+		price           float64
+		productCurrency string
+	)
+	err = tx.QueryRowContext(ctx, `SELECT price, currency FROM products WHERE product_id = $1 AND deleted_at IS NULL`, productID).
+		Scan(&price, &productCurrency)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("%w: product %d not found", storage.ErrInvalidOrder, productID)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if productCurrency != currency {
+		return 0, fmt.Errorf("%w: product %d is priced in %s, order is in %s",
+			storage.ErrInvalidOrder, productID, productCurrency, currency)
+	}
+
+	before, err := loadOrderState(ctx, tx, orderID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var id int
+	err = tx.QueryRowContext(ctx, `INSERT INTO order_items (order_id, product_id, quantity, price)
+			VALUES ($1, $2, $3, $4)
+			RETURNING order_item_id`, orderID, productID, quantity, price).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := repriceOrder(ctx, tx, orderID); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := auditOrderState(ctx, tx, orderID, before); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+func (s *Storage) GetOrderItem(ctx context.Context, id int) (*OrderItem, error) {
+	const op = "storage.postgresql.GetOrderItem"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	item, err := scanOrderItem(s.DB.QueryRowContext(ctx, `SELECT `+orderItemColumns+` FROM order_items WHERE order_item_id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrOrderItemNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return item, nil
 }
 
-func (s *Storage) ListOrderItems(orderID int) ([]OrderItem, error) {
-	// Mock implementation
-	return []OrderItem{
-		{OrderItemID: 1, OrderID: orderID, ProductID: 1, Quantity: 2, Price: 65000, Discount: 5},
-	}, nil
+func (s *Storage) ListOrderItems(ctx context.Context, orderID int) ([]OrderItem, error) {
+	const op = "storage.postgresql.ListOrderItems"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	rows, err := s.DB.QueryContext(ctx, `SELECT `+orderItemColumns+` FROM order_items
+			WHERE order_id = $1
+			ORDER BY order_item_id`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var items []OrderItem
+	for rows.Next() {
+		item, err := scanOrderItem(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		items = append(items, *item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return items, nil
 }
 
-func (s *Storage) UpdateOrderItem(id int, quantity int, price, discount float64) error {
-	// Mock implementation
+// UpdateOrderItem — изменить количество в позиции неудаленного заказа
+// и пересчитать скидки и сумму заказа. Цена позиции остается той, по которой
+// ее добавили.
+func (s *Storage) UpdateOrderItem(ctx context.Context, id, quantity int) error {
+	const op = "storage.postgresql.UpdateOrderItem"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	if quantity <= 0 {
+		return fmt.Errorf("%w: order item %d: quantity must be positive", storage.ErrInvalidOrder, id)
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	orderID, err := lockItemOrder(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	before, err := loadOrderState(ctx, tx, orderID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE order_items SET quantity = $2 WHERE order_item_id = $1`, id, quantity); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := repriceOrder(ctx, tx, orderID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := auditOrderState(ctx, tx, orderID, before); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	return nil
 }
 
-func (s *Storage) DeleteOrderItem(id int) error {
-	// Mock implementation
+// DeleteOrderItem — удалить позицию неудаленного заказа и пересчитать скидки
+// и сумму заказа. Позицию, по которой оформлен возврат, удалить нельзя.
+func (s *Storage) DeleteOrderItem(ctx context.Context, id int) error {
+	const op = "storage.postgresql.DeleteOrderItem"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	orderID, err := lockItemOrder(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	before, err := loadOrderState(ctx, tx, orderID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM order_items WHERE order_item_id = $1`, id)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return fmt.Errorf("%w: order item %d has returns", storage.ErrInvalidOrder, id)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := repriceOrder(ctx, tx, orderID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := auditOrderState(ctx, tx, orderID, before); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	return nil
 }
+
+// lockItemOrder — заказ позиции id, заблокированный до конца транзакции,
+// чтобы параллельные изменения позиций не испортили пересчет суммы
+func lockItemOrder(ctx context.Context, tx *sql.Tx, id int) (int, error) {
+	var orderID int
+	err := tx.QueryRowContext(ctx, `SELECT o.order_id
+			FROM order_items oi
+			JOIN orders o ON o.order_id = oi.order_id
+			WHERE oi.order_item_id = $1 AND o.deleted_at IS NULL
+			FOR UPDATE OF o`, id).Scan(&orderID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, storage.ErrOrderItemNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("storage.postgresql.lockItemOrder: %w", err)
+	}
+	return orderID, nil
+}
+
+// ====================================================================
+// HELPERS
+// ====================================================================
+
+// dbtx — общее у *sql.DB и *sql.Tx: запросы, которые выполняются
+// как отдельно, так и внутри транзакции
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// crud — контекст операции CRUD: отменяется вместе с ctx или по QueryTimeout.
+// done освобождает контекст и сообщает длительность операции в Observe.
+func (s *Storage) crud(ctx context.Context, op string) (_ context.Context, done func()) {
+	return s.start(ctx, op, ClassCRUD, s.QueryTimeout)
+}
+
+// analytics — контекст отчета или массовой записи: отменяется вместе с ctx
+// или по AnalyticsTimeout
+func (s *Storage) analytics(ctx context.Context, op string) (_ context.Context, done func()) {
+	return s.start(ctx, op, ClassAnalytics, s.AnalyticsTimeout)
+}
+
+func (s *Storage) start(ctx context.Context, op, class string, timeout time.Duration) (context.Context, func()) {
+	ctx, cancel := withTimeout(ctx, timeout)
+	started := time.Now()
+
+	return ctx, func() {
+		cancel()
+		if s.Observe != nil {
+			s.Observe(op, class, time.Since(started))
+		}
+	}
+}
+
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// checkVersion — для UPDATE/DELETE с условием на версию: если ни одна строка
+// не изменилась, запросом exists отличает отсутствующую запись от устаревшей версии
+func checkVersion(ctx context.Context, db dbtx, op string, res sql.Result, exists string, id int, notFound error) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	var found bool
+	if err := db.QueryRowContext(ctx, exists, id).Scan(&found); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !found {
+		return notFound
+	}
+
+	return storage.ErrVersionConflict
+}
@@ -0,0 +1,478 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"salesTracker/internal/pricing"
+	"salesTracker/internal/storage"
+)
+
+// ====================================================================
+// PROMOTIONS - Промоакции и купоны
+// ====================================================================
+
+// Promotion — промоакция. Пустые CategoryID и ProductID — акция на весь заказ,
+// непустой CouponCode — акция применяется только по купону.
+type Promotion struct {
+	PromotionID int        `json:"promotion_id"`
+	Name        string     `json:"name"`
+	Kind        string     `json:"kind"`
+	Value       float64    `json:"value"`
+	Currency    string     `json:"currency"`
+	BuyQuantity int        `json:"buy_quantity,omitempty"`
+	GetQuantity int        `json:"get_quantity,omitempty"`
+	CategoryID  *int       `json:"category_id,omitempty"`
+	ProductID   *int       `json:"product_id,omitempty"`
+	CouponCode  string     `json:"coupon_code,omitempty"`
+	StartsAt    *time.Time `json:"starts_at,omitempty"`
+	EndsAt      *time.Time `json:"ends_at,omitempty"`
+	UsageLimit  *int       `json:"usage_limit,omitempty"`
+	UsageCount  int        `json:"usage_count"`
+	Active      bool       `json:"active"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Rule — параметры акции для расчета скидок
+func (p Promotion) Rule() pricing.Rule {
+	return pricing.Rule{
+		ID:          p.PromotionID,
+		Kind:        p.Kind,
+		Value:       p.Value,
+		BuyQuantity: p.BuyQuantity,
+		GetQuantity: p.GetQuantity,
+		CategoryID:  p.CategoryID,
+		ProductID:   p.ProductID,
+	}
+}
+
+const promotionColumns = `promotion_id, name, kind, value, currency, buy_quantity, get_quantity, category_id, product_id,
+		coupon_code, starts_at, ends_at, usage_limit, usage_count, active, created_at`
+
+func scanPromotion(row interface{ Scan(...any) error }) (*Promotion, error) {
+	var (
+		p          Promotion
+		categoryID sql.NullInt64
+		productID  sql.NullInt64
+		couponCode sql.NullString
+		startsAt   sql.NullTime
+		endsAt     sql.NullTime
+		usageLimit sql.NullInt64
+	)
+
+	err := row.Scan(&p.PromotionID, &p.Name, &p.Kind, &p.Value, &p.Currency, &p.BuyQuantity, &p.GetQuantity, &categoryID, &productID,
+		&couponCode, &startsAt, &endsAt, &usageLimit, &p.UsageCount, &p.Active, &p.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	p.CategoryID = nullInt(categoryID)
+	p.ProductID = nullInt(productID)
+	p.CouponCode = couponCode.String
+	p.UsageLimit = nullInt(usageLimit)
+	p.StartsAt = nullTime(startsAt)
+	p.EndsAt = nullTime(endsAt)
+
+	return &p, nil
+}
+
+func (s *Storage) AddPromotion(ctx context.Context, p Promotion) (int, error) {
+	const op = "storage.postgresql.AddPromotion"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	query := `INSERT INTO promotions
+			(name, kind, value, currency, buy_quantity, get_quantity, category_id, product_id,
+				coupon_code, starts_at, ends_at, usage_limit, active)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			RETURNING promotion_id`
+
+	return audited(ctx, s.DB, op, promotionsAudit, AuditCreate, 0, func(tx *sql.Tx) (int, error) {
+		var id int
+		err := tx.QueryRowContext(ctx, query, p.Name, p.Kind, p.Value, p.Currency, p.BuyQuantity, p.GetQuantity, p.CategoryID, p.ProductID,
+			nullString(p.CouponCode), p.StartsAt, p.EndsAt, p.UsageLimit, p.Active).Scan(&id)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		return id, nil
+	})
+}
+
+func (s *Storage) GetPromotion(ctx context.Context, id int) (*Promotion, error) {
+	const op = "storage.postgresql.GetPromotion"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	query := `SELECT ` + promotionColumns + ` FROM promotions WHERE promotion_id = $1`
+
+	p, err := scanPromotion(s.DB.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrPromotionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return p, nil
+}
+
+func (s *Storage) ListPromotions(ctx context.Context) ([]Promotion, error) {
+	const op = "storage.postgresql.ListPromotions"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	rows, err := s.DB.QueryContext(ctx, `SELECT `+promotionColumns+` FROM promotions ORDER BY promotion_id`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var promotions []Promotion
+	for rows.Next() {
+		p, err := scanPromotion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		promotions = append(promotions, *p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return promotions, nil
+}
+
+// UpdatePromotion — изменить условия акции; счетчик использований не меняется
+func (s *Storage) UpdatePromotion(ctx context.Context, id int, p Promotion) error {
+	const op = "storage.postgresql.UpdatePromotion"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	query := `UPDATE promotions
+			SET name = $2, kind = $3, value = $4, currency = $5, buy_quantity = $6, get_quantity = $7,
+				category_id = $8, product_id = $9, coupon_code = $10, starts_at = $11, ends_at = $12,
+				usage_limit = $13, active = $14
+			WHERE promotion_id = $1`
+
+	_, err := audited(ctx, s.DB, op, promotionsAudit, AuditUpdate, id, func(tx *sql.Tx) (int, error) {
+		res, err := tx.ExecContext(ctx, query, id, p.Name, p.Kind, p.Value, p.Currency, p.BuyQuantity, p.GetQuantity,
+			p.CategoryID, p.ProductID, nullString(p.CouponCode), p.StartsAt, p.EndsAt, p.UsageLimit, p.Active)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		return id, checkAffected(op, res, storage.ErrPromotionNotFound)
+	})
+	return err
+}
+
+// DeactivatePromotion — отключить акцию. Акции не удаляются: на них ссылаются
+// позиции оформленных заказов и отчет по промоакциям.
+func (s *Storage) DeactivatePromotion(ctx context.Context, id int) error {
+	const op = "storage.postgresql.DeactivatePromotion"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	_, err := audited(ctx, s.DB, op, promotionsAudit, AuditDelete, id, func(tx *sql.Tx) (int, error) {
+		res, err := tx.ExecContext(ctx, `UPDATE promotions SET active = FALSE WHERE promotion_id = $1`, id)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		return id, checkAffected(op, res, storage.ErrPromotionNotFound)
+	})
+	return err
+}
+
+// ====================================================================
+// PLACE ORDER - Оформление заказа с расчетом скидок
+// ====================================================================
+
+// OrderLineInput — позиция оформляемого заказа; цена берется из каталога
+type OrderLineInput struct {
+	ProductID int
+	Quantity  int
+}
+
+// PlacedOrder — оформленный заказ с позициями и примененными акциями
+type PlacedOrder struct {
+	Order
+	Items      []OrderItem `json:"items"`
+	Promotions []int       `json:"promotions,omitempty"`
+}
+
+// PlaceOrder — оформить заказ одной транзакцией: взять цены из каталога,
+// применить действующие акции (и купон, если указан), сохранить заказ с позициями
+// и увеличить счетчики использования акций. Сумма заказа считается на сервере.
+func (s *Storage) PlaceOrder(ctx context.Context, order Order, lines []OrderLineInput, coupon string) (*PlacedOrder, error) {
+	const op = "storage.postgresql.PlaceOrder"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("%w: no items", storage.ErrInvalidOrder)
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	priced := make([]pricing.Line, 0, len(lines))
+	for _, line := range lines {
+		if line.Quantity <= 0 {
+			return nil, fmt.Errorf("%w: product %d: quantity must be positive", storage.ErrInvalidOrder, line.ProductID)
+		}
+
+		var (
+			l        = pricing.Line{ProductID: line.ProductID, Quantity: line.Quantity}
+			currency string
+		)
+		err := tx.QueryRowContext(ctx, `SELECT price, category_id, currency FROM products WHERE product_id = $1 AND deleted_at IS NULL`, line.ProductID).
+			Scan(&l.Price, &l.CategoryID, &currency)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: product %d not found", storage.ErrInvalidOrder, line.ProductID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if currency != order.Currency {
+			return nil, fmt.Errorf("%w: product %d is priced in %s, order is in %s",
+				storage.ErrInvalidOrder, line.ProductID, currency, order.Currency)
+		}
+
+		priced = append(priced, l)
+	}
+
+	// блокируем подходящие акции, чтобы параллельные заказы не превысили лимит использований
+	rows, err := tx.QueryContext(ctx, `SELECT `+promotionColumns+` FROM promotions
+			WHERE active
+				AND (starts_at IS NULL OR starts_at <= $1) AND (ends_at IS NULL OR ends_at > $1)
+				AND (usage_limit IS NULL OR usage_count < usage_limit)
+				AND (kind <> 'fixed_off' OR currency = $2)
+				AND (coupon_code IS NULL OR UPPER(coupon_code) = UPPER($3))
+			ORDER BY promotion_id
+			FOR UPDATE`, time.Now(), order.Currency, coupon)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var (
+		rules    []pricing.Rule
+		couponID int
+	)
+	for rows.Next() {
+		p, err := scanPromotion(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if p.CouponCode != "" {
+			couponID = p.PromotionID
+		}
+		rules = append(rules, p.Rule())
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if coupon != "" && couponID == 0 {
+		return nil, fmt.Errorf("%w: coupon %q is not valid", storage.ErrInvalidOrder, coupon)
+	}
+
+	discounts := pricing.Apply(rules, priced)
+	applied := pricing.Applied(discounts)
+
+	if couponID != 0 && !containsID(applied, couponID) {
+		return nil, fmt.Errorf("%w: coupon %q does not apply to this order", storage.ErrInvalidOrder, coupon)
+	}
+
+	var total float64
+	for i, l := range priced {
+		total += l.Total() - discounts[i].Amount
+	}
+
+	placed := &PlacedOrder{Order: order, Promotions: applied}
+	placed.TotalAmount = total
+
+	// статус по умолчанию тот же, что у AddOrder
+	err = tx.QueryRowContext(ctx, `INSERT INTO orders (customer_id, order_date, status, total_amount, payment_method, currency)
+			VALUES ($1, $2, COALESCE(NULLIF($3, ''), 'completed'), $4, $5, $6)
+			RETURNING order_id, version, status`, order.CustomerID, order.OrderDate, order.Status, total, order.PaymentMethod, order.Currency).
+		Scan(&placed.OrderID, &placed.Version, &placed.Status)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for i, l := range priced {
+		item := OrderItem{
+			OrderID:        placed.OrderID,
+			ProductID:      l.ProductID,
+			Quantity:       l.Quantity,
+			Price:          l.Price,
+			Discount:       discounts[i].Percent,
+			DiscountAmount: discounts[i].Amount,
+		}
+		if id := discounts[i].PromotionID; id != 0 {
+			item.PromotionID = &id
+		}
+
+		err := tx.QueryRowContext(ctx, `INSERT INTO order_items (order_id, product_id, quantity, price, discount, discount_amount, promotion_id)
+				VALUES ($1, $2, $3, $4, $5, $6, $7)
+				RETURNING order_item_id`, item.OrderID, item.ProductID, item.Quantity, item.Price,
+			item.Discount, item.DiscountAmount, item.PromotionID).Scan(&item.OrderItemID)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		placed.Items = append(placed.Items, item)
+	}
+
+	for _, id := range applied {
+		if _, err := tx.ExecContext(ctx, `UPDATE promotions SET usage_count = usage_count + 1 WHERE promotion_id = $1`, id); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	after, err := ordersAudit.load(ctx, tx, placed.OrderID, false)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	changes := []auditChange{{EntityType: ordersAudit.Type, EntityID: &placed.OrderID, Action: AuditCreate, After: after}}
+	for i := range placed.Items {
+		item := &placed.Items[i]
+		changes = append(changes, auditChange{EntityType: "order_items", EntityID: &item.OrderItemID, Action: AuditCreate, After: item})
+	}
+	if err := addAuditChanges(ctx, tx, changes); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.orderCreated(placed.Status, placed.Currency)
+	return placed, nil
+}
+
+// repriceOrder — пересчитать скидки всех позиций заказа и его сумму после
+// изменения позиций. Применяются акции, которые заказ получил при оформлении
+// (PlaceOrder), по правилам pricing.Apply; новых акций изменение позиций
+// не добавляет, счетчики использования не меняются.
+func repriceOrder(ctx context.Context, tx *sql.Tx, orderID int) error {
+	rows, err := tx.QueryContext(ctx, `SELECT oi.order_item_id, oi.product_id, COALESCE(p.category_id, 0), oi.quantity, oi.price
+			FROM order_items oi
+			JOIN products p ON p.product_id = oi.product_id
+			WHERE oi.order_id = $1
+			ORDER BY oi.order_item_id`, orderID)
+	if err != nil {
+		return err
+	}
+
+	var (
+		ids   []int
+		lines []pricing.Line
+	)
+	for rows.Next() {
+		var (
+			id int
+			l  pricing.Line
+		)
+		if err := rows.Scan(&id, &l.ProductID, &l.CategoryID, &l.Quantity, &l.Price); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+		lines = append(lines, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = tx.QueryContext(ctx, `SELECT `+promotionColumns+` FROM promotions
+			WHERE promotion_id IN (SELECT promotion_id FROM order_items WHERE order_id = $1)
+			ORDER BY promotion_id`, orderID)
+	if err != nil {
+		return err
+	}
+	var rules []pricing.Rule
+	for rows.Next() {
+		p, err := scanPromotion(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		rules = append(rules, p.Rule())
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	discounts := pricing.Apply(rules, lines)
+
+	var total float64
+	for i, l := range lines {
+		d := discounts[i]
+		var promotionID *int
+		if d.PromotionID != 0 {
+			promotionID = &d.PromotionID
+		}
+		_, err := tx.ExecContext(ctx, `UPDATE order_items SET discount = $2, discount_amount = $3, promotion_id = $4
+				WHERE order_item_id = $1
+					AND (discount, discount_amount, promotion_id) IS DISTINCT FROM ($2::numeric, $3::numeric, $4::int)`,
+			ids[i], d.Percent, d.Amount, promotionID)
+		if err != nil {
+			return err
+		}
+		total += l.Total() - d.Amount
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE orders SET total_amount = ROUND($2::numeric, 2), version = version + 1 WHERE order_id = $1`,
+		orderID, total)
+	return err
+}
+
+// ====================================================================
+// HELPERS
+// ====================================================================
+
+func nullInt(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	i := int(v.Int64)
+	return &i
+}
+
+func nullTime(v sql.NullTime) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Time
+}
+
+func nullString(s string) sql.NullString {
+	s = strings.TrimSpace(s)
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func containsID(ids []int, id int) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,291 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"salesTracker/internal/storage"
+)
+
+// ====================================================================
+// RETURNS - Возвраты и компенсации
+// ====================================================================
+
+// Return — возврат по заказу
+type Return struct {
+	ReturnID     int          `json:"return_id"`
+	OrderID      int          `json:"order_id"`
+	ReturnDate   time.Time    `json:"return_date"`
+	Reason       string       `json:"reason,omitempty"`
+	RefundAmount float64      `json:"refund_amount"`
+	Restocked    bool         `json:"restocked"`
+	Items        []ReturnItem `json:"items"`
+}
+
+// ReturnItem — возвращенная позиция заказа
+type ReturnItem struct {
+	ReturnItemID int     `json:"return_item_id"`
+	OrderItemID  int     `json:"order_item_id"`
+	Quantity     int     `json:"quantity"`
+	RefundAmount float64 `json:"refund_amount"`
+	Reason       string  `json:"reason,omitempty"`
+}
+
+// ReturnItemInput — позиция для оформления возврата; RefundAmount == nil —
+// вернуть стоимость позиции пропорционально количеству с учетом скидки
+type ReturnItemInput struct {
+	OrderItemID  int
+	Quantity     int
+	RefundAmount *float64
+	Reason       string
+}
+
+// AddReturn — оформить возврат одной транзакцией: проверить количества против
+// купленного и уже возвращенного, сохранить возврат, вернуть товар на склад
+// и записать в журнал возврат и изменение остатков
+func (s *Storage) AddReturn(ctx context.Context, orderID int, returnDate time.Time, reason string, restock bool, items []ReturnItemInput) (int, error) {
+	const op = "storage.postgresql.AddReturn"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	if err := checkReturnItems(items); err != nil {
+		return 0, err
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	// блокируем заказ, чтобы параллельные возвраты не превысили купленное количество
+	var exists int
+	err = tx.QueryRowContext(ctx, `SELECT order_id FROM orders WHERE order_id = $1 AND deleted_at IS NULL FOR UPDATE`, orderID).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, storage.ErrOrderNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	type line struct {
+		input  ReturnItemInput
+		refund float64
+		prodID int
+	}
+	lines := make([]line, 0, len(items))
+	var total float64
+
+	for _, item := range items {
+		var (
+			productID int
+			quantity  int
+			price     float64
+			discount  float64
+			returned  int
+		)
+		err := tx.QueryRowContext(ctx, `SELECT oi.product_id, oi.quantity, oi.price, oi.discount_amount,
+					COALESCE((SELECT SUM(ri.quantity) FROM return_items ri WHERE ri.order_item_id = oi.order_item_id), 0)
+				FROM order_items oi
+				WHERE oi.order_item_id = $1 AND oi.order_id = $2`, item.OrderItemID, orderID).
+			Scan(&productID, &quantity, &price, &discount, &returned)
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("%w: order item %d does not belong to order %d", storage.ErrInvalidReturn, item.OrderItemID, orderID)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+
+		refund, err := refundFor(item, quantity, returned, price, discount)
+		if err != nil {
+			return 0, err
+		}
+
+		lines = append(lines, line{input: item, refund: refund, prodID: productID})
+		total += refund
+	}
+
+	var returnID int
+	err = tx.QueryRowContext(ctx, `INSERT INTO returns (order_id, return_date, reason, refund_amount, restocked)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING return_id`, orderID, returnDate, reason, total, restock).Scan(&returnID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var restocked []int
+	if restock {
+		for _, l := range lines {
+			if !slices.Contains(restocked, l.prodID) {
+				restocked = append(restocked, l.prodID)
+			}
+		}
+	}
+	before, err := productsByID(ctx, tx, restocked)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	ret := Return{ReturnID: returnID, OrderID: orderID, ReturnDate: returnDate, Reason: reason, RefundAmount: total,
+		Restocked: restock}
+	for _, l := range lines {
+		item := ReturnItem{OrderItemID: l.input.OrderItemID, Quantity: l.input.Quantity, RefundAmount: l.refund,
+			Reason: l.input.Reason}
+		err := tx.QueryRowContext(ctx, `INSERT INTO return_items (return_id, order_item_id, quantity, refund_amount, reason)
+				VALUES ($1, $2, $3, $4, $5)
+				RETURNING return_item_id`, returnID, item.OrderItemID, item.Quantity, item.RefundAmount, item.Reason).
+			Scan(&item.ReturnItemID)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		ret.Items = append(ret.Items, item)
+
+		if restock {
+			_, err := tx.ExecContext(ctx, `UPDATE products SET stock_quantity = stock_quantity + $2, version = version + 1 WHERE product_id = $1`,
+				l.prodID, l.input.Quantity)
+			if err != nil {
+				return 0, fmt.Errorf("%s: %w", op, err)
+			}
+		}
+	}
+
+	// возврат и изменение остатков каждого товара журналируются вместе с ними
+	after, err := productsByID(ctx, tx, restocked)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	changes := []auditChange{{EntityType: "returns", EntityID: &returnID, Action: AuditCreate, After: ret}}
+	for _, id := range restocked {
+		changes = append(changes, auditChange{EntityType: "products", EntityID: &id, Action: AuditUpdate,
+			Before: before[id], After: after[id]})
+	}
+	if err := addAuditChanges(ctx, tx, changes); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return returnID, nil
+}
+
+// checkReturnItems — в возврате есть позиции, и каждая позиция заказа указана
+// один раз: количества проверяются против базы по строкам, поэтому повтор
+// позволил бы вернуть больше купленного и дважды вернуть деньги и товар
+func checkReturnItems(items []ReturnItemInput) error {
+	if len(items) == 0 {
+		return fmt.Errorf("%w: no items", storage.ErrInvalidReturn)
+	}
+
+	seen := make(map[int]bool, len(items))
+	for _, item := range items {
+		if seen[item.OrderItemID] {
+			return fmt.Errorf("%w: order item %d is listed more than once", storage.ErrInvalidReturn, item.OrderItemID)
+		}
+		seen[item.OrderItemID] = true
+	}
+	return nil
+}
+
+// refundFor — сумма возврата позиции item, из которой куплено quantity единиц
+// по цене price со скидкой discount (в деньгах, на всю позицию), а returned
+// уже возвращено. Вернуть можно только оставшиеся единицы; на них приходится
+// доля уплаченной суммы, запрошенная сумма не может ее превышать.
+func refundFor(item ReturnItemInput, quantity, returned int, price, discount float64) (float64, error) {
+	if item.Quantity <= 0 {
+		return 0, fmt.Errorf("%w: order item %d: quantity must be positive", storage.ErrInvalidReturn, item.OrderItemID)
+	}
+	if item.Quantity > quantity-returned {
+		return 0, fmt.Errorf("%w: order item %d: returning %d, only %d left to return",
+			storage.ErrInvalidReturn, item.OrderItemID, item.Quantity, quantity-returned)
+	}
+
+	paid := (price*float64(quantity) - discount) * float64(item.Quantity) / float64(quantity)
+	refund := math.Round(paid*100) / 100
+	if item.RefundAmount != nil {
+		if *item.RefundAmount < 0 || *item.RefundAmount > refund {
+			return 0, fmt.Errorf("%w: order item %d: refund must be between 0 and %.2f",
+				storage.ErrInvalidReturn, item.OrderItemID, refund)
+		}
+		refund = *item.RefundAmount
+	}
+
+	return refund, nil
+}
+
+// ListReturnsByOrder — возвраты заказа вместе с позициями
+func (s *Storage) ListReturnsByOrder(ctx context.Context, orderID int) ([]Return, error) {
+	const op = "storage.postgresql.ListReturnsByOrder"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	rows, err := s.DB.QueryContext(ctx, `SELECT r.return_id, r.order_id, r.return_date, COALESCE(r.reason, ''),
+				r.refund_amount, r.restocked,
+				ri.return_item_id, ri.order_item_id, ri.quantity, ri.refund_amount, COALESCE(ri.reason, '')
+			FROM returns r
+			JOIN return_items ri ON ri.return_id = r.return_id
+			WHERE r.order_id = $1
+			ORDER BY r.return_id, ri.return_item_id`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var returns []Return
+	for rows.Next() {
+		var (
+			ret  Return
+			item ReturnItem
+		)
+		err := rows.Scan(&ret.ReturnID, &ret.OrderID, &ret.ReturnDate, &ret.Reason, &ret.RefundAmount, &ret.Restocked,
+			&item.ReturnItemID, &item.OrderItemID, &item.Quantity, &item.RefundAmount, &item.Reason)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if n := len(returns); n == 0 || returns[n-1].ReturnID != ret.ReturnID {
+			returns = append(returns, ret)
+		}
+		last := &returns[len(returns)-1]
+		last.Items = append(last.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return returns, nil
+}
+
+// GetReturn — возврат по ID
+func (s *Storage) GetReturn(ctx context.Context, id int) (*Return, error) {
+	const op = "storage.postgresql.GetReturn"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	var orderID int
+	err := s.DB.QueryRowContext(ctx, `SELECT order_id FROM returns WHERE return_id = $1`, id).Scan(&orderID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	returns, err := s.ListReturnsByOrder(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	for _, ret := range returns {
+		if ret.ReturnID == id {
+			return &ret, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s: return %d has no items", op, id)
+}
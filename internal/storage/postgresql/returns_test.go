@@ -0,0 +1,89 @@
+package postgresql
+
+import (
+	"errors"
+	"testing"
+
+	"salesTracker/internal/storage"
+)
+
+func TestRefundFor(t *testing.T) {
+	amount := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name     string
+		item     ReturnItemInput
+		quantity int
+		returned int
+		price    float64
+		discount float64
+		want     float64
+		wantErr  bool
+	}{
+		{name: "whole item", item: ReturnItemInput{Quantity: 3}, quantity: 3, price: 100, want: 300},
+		{name: "partial quantity", item: ReturnItemInput{Quantity: 1}, quantity: 3, price: 100, want: 100},
+		{name: "partial with discount share", item: ReturnItemInput{Quantity: 1}, quantity: 3, price: 100, discount: 30, want: 90},
+		{name: "rounded to kopecks", item: ReturnItemInput{Quantity: 1}, quantity: 3, price: 10, discount: 1, want: 9.67},
+		{name: "rest after earlier return", item: ReturnItemInput{Quantity: 2}, quantity: 5, returned: 3, price: 20, want: 40},
+		{name: "requested amount", item: ReturnItemInput{Quantity: 2, RefundAmount: amount(50)}, quantity: 2, price: 40, want: 50},
+		{name: "zero amount", item: ReturnItemInput{Quantity: 1, RefundAmount: amount(0)}, quantity: 1, price: 40, want: 0},
+
+		{name: "over-return", item: ReturnItemInput{Quantity: 4}, quantity: 3, price: 100, wantErr: true},
+		{name: "over-return after earlier return", item: ReturnItemInput{Quantity: 2}, quantity: 3, returned: 2, price: 100, wantErr: true},
+		{name: "everything already returned", item: ReturnItemInput{Quantity: 1}, quantity: 2, returned: 2, price: 100, wantErr: true},
+		{name: "zero quantity", item: ReturnItemInput{Quantity: 0}, quantity: 2, price: 100, wantErr: true},
+		{name: "negative quantity", item: ReturnItemInput{Quantity: -1}, quantity: 2, price: 100, wantErr: true},
+		{name: "amount above paid", item: ReturnItemInput{Quantity: 1, RefundAmount: amount(91)}, quantity: 3, price: 100, discount: 30, wantErr: true},
+		{name: "negative amount", item: ReturnItemInput{Quantity: 1, RefundAmount: amount(-1)}, quantity: 1, price: 100, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := refundFor(tt.item, tt.quantity, tt.returned, tt.price, tt.discount)
+			if tt.wantErr {
+				if !errors.Is(err, storage.ErrInvalidReturn) {
+					t.Fatalf("refundFor() error = %v, want ErrInvalidReturn", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("refundFor() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("refundFor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckReturnItems(t *testing.T) {
+	tests := []struct {
+		name    string
+		items   []ReturnItemInput
+		wantErr bool
+	}{
+		{name: "single item", items: []ReturnItemInput{{OrderItemID: 1, Quantity: 1}}},
+		{name: "different items", items: []ReturnItemInput{{OrderItemID: 1, Quantity: 1}, {OrderItemID: 2, Quantity: 3}}},
+
+		{name: "no items", items: nil, wantErr: true},
+		{name: "same item twice", items: []ReturnItemInput{{OrderItemID: 1, Quantity: 1}, {OrderItemID: 1, Quantity: 1}}, wantErr: true},
+		{name: "same item not adjacent", items: []ReturnItemInput{
+			{OrderItemID: 1, Quantity: 1}, {OrderItemID: 2, Quantity: 1}, {OrderItemID: 1, Quantity: 1},
+		}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkReturnItems(tt.items)
+			if tt.wantErr {
+				if !errors.Is(err, storage.ErrInvalidReturn) {
+					t.Fatalf("checkReturnItems() error = %v, want ErrInvalidReturn", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("checkReturnItems() error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ====================================================================
+// DAILY ROLLUP - Дневная сводка продаж
+// ====================================================================
+
+// Сводка daily_sales_rollup поддерживается в два шага: триггеры на orders,
+// order_items, город покупателя и категорию товара отмечают измененные дни
+// в очереди daily_sales_rollup_dirty, а пересчет идет фоном (DrainDailyRollup)
+// и перед чтением сводки за период (syncRollup). Запись не ждет пересчета
+// дня, а отчет не читает устаревшую сводку.
+// Аналитика читает ее только для закрытых периодов (закончившихся до сегодняшнего
+// дня): текущий день еще меняется, и для него надежнее сканировать orders.
+// Дни в сводке считаются по UTC, поэтому периоды в других часовых поясах
+// всегда считаются по orders.
+
+// useRollup — период в UTC и целиком в прошлом, его можно читать из сводки
+func useRollup(end time.Time) bool {
+	if end.Location().String() != time.UTC.String() {
+		return false
+	}
+	return rangeClosed(end)
+}
+
+// rangeClosed — период целиком в прошлом
+func rangeClosed(end time.Time) bool {
+	now := time.Now().In(end.Location())
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, end.Location())
+	return end.Before(today)
+}
+
+// RefreshDailyRollup — пересчитать сводку за диапазон дней,
+// например после загрузки данных в обход триггеров
+func (s *Storage) RefreshDailyRollup(ctx context.Context, start, end time.Time) error {
+	const op = packageOp + "RefreshDailyRollup"
+
+	ctx, done := s.analytics(ctx, op)
+	defer done()
+
+	if _, err := s.DB.ExecContext(ctx, `SELECT refresh_daily_sales_rollup($1::date, $2::date)`, start, end); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DrainDailyRollup — пересчитать все дни из очереди; возвращает их число
+func (s *Storage) DrainDailyRollup(ctx context.Context) (int, error) {
+	const op = packageOp + "DrainDailyRollup"
+
+	ctx, done := s.analytics(ctx, op)
+	defer done()
+
+	var days int
+	if err := s.DB.QueryRowContext(ctx, `SELECT drain_daily_sales_rollup(NULL, NULL)`).Scan(&days); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return days, nil
+}
+
+// syncRollup — пересчитать дни периода, ожидающие в очереди, перед чтением
+// сводки. Вызывается внутри аналитической операции, таймаут уже задан.
+func (s *Storage) syncRollup(ctx context.Context, start, end time.Time) error {
+	_, err := s.DB.ExecContext(ctx, `SELECT drain_daily_sales_rollup($1::date, $2::date)`, dateParam(start), dateParam(end))
+	return err
+}
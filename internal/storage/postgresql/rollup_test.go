@@ -0,0 +1,80 @@
+package postgresql
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUseRollup(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		end  time.Time
+		want bool
+	}{
+		{name: "closed period in UTC", end: today.AddDate(0, 0, -1), want: true},
+		{name: "last year", end: today.AddDate(-1, 0, 0), want: true},
+
+		// текущий день еще меняется — только по orders
+		{name: "ends today", end: today, want: false},
+		{name: "ends in the future", end: today.AddDate(0, 0, 7), want: false},
+		// дни сводки — по UTC, в другом поясе границы суток не совпадают
+		{name: "closed period in another zone", end: today.AddDate(0, 0, -10).In(moscow), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := useRollup(tt.end); got != tt.want {
+				t.Errorf("useRollup(%v) = %v, want %v", tt.end, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPeriodBounds(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		start    time.Time
+		end      time.Time
+		wantFrom string
+		wantTo   string
+	}{
+		{name: "one day in UTC",
+			start: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), end: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			wantFrom: "2024-03-01T00:00:00Z", wantTo: "2024-03-02T00:00:00Z"},
+		// время внутри дня отбрасывается, конец периода включает весь последний день
+		{name: "time of day ignored",
+			start: time.Date(2024, 3, 1, 15, 30, 0, 0, time.UTC), end: time.Date(2024, 3, 31, 23, 59, 0, 0, time.UTC),
+			wantFrom: "2024-03-01T00:00:00Z", wantTo: "2024-04-01T00:00:00Z"},
+		{name: "end of year",
+			start: time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC), end: time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC),
+			wantFrom: "2024-12-31T00:00:00Z", wantTo: "2025-01-01T00:00:00Z"},
+		// день перевода часов короче суток: граница — местная полночь
+		{name: "dst day in new york",
+			start: time.Date(2024, 3, 10, 0, 0, 0, 0, newYork), end: time.Date(2024, 3, 10, 0, 0, 0, 0, newYork),
+			wantFrom: "2024-03-10T05:00:00Z", wantTo: "2024-03-11T04:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to := periodBounds(tt.start, tt.end)
+			if got := from.UTC().Format(time.RFC3339); got != tt.wantFrom {
+				t.Errorf("from = %s, want %s", got, tt.wantFrom)
+			}
+			if got := to.UTC().Format(time.RFC3339); got != tt.wantTo {
+				t.Errorf("to = %s, want %s", got, tt.wantTo)
+			}
+		})
+	}
+}
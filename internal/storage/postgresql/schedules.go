@@ -0,0 +1,244 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"salesTracker/internal/storage"
+)
+
+// ====================================================================
+// REPORT SCHEDULES - Расписания регулярных отчетов
+// ====================================================================
+
+// ReportSchedule — расписание регулярного отчета
+type ReportSchedule struct {
+	ScheduleID int        `json:"schedule_id"`
+	Name       string     `json:"name"`
+	CronExpr   string     `json:"cron_expr"`
+	ReportType string     `json:"report_type"`
+	Range      string     `json:"range"`
+	Percentile int        `json:"percentile,omitempty"`
+	TimeZone   string     `json:"time_zone"`
+	Currency   string     `json:"currency,omitempty"`
+	Delivery   string     `json:"delivery"`
+	Recipients []string   `json:"recipients,omitempty"`
+	Enabled    bool       `json:"enabled"`
+	LastRunAt  *time.Time `json:"last_run_at,omitempty"`
+	LastError  string     `json:"last_error,omitempty"`
+	NextRunAt  *time.Time `json:"next_run_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+const scheduleColumns = `schedule_id, name, cron_expr, report_type, range_spec, percentile, time_zone, currency,
+		delivery, recipients, enabled, last_run_at, last_error, next_run_at, created_at`
+
+func scanSchedule(row interface{ Scan(...any) error }) (*ReportSchedule, error) {
+	var (
+		sch        ReportSchedule
+		recipients pq.StringArray
+		lastError  sql.NullString
+		lastRunAt  sql.NullTime
+		nextRunAt  sql.NullTime
+	)
+
+	err := row.Scan(&sch.ScheduleID, &sch.Name, &sch.CronExpr, &sch.ReportType, &sch.Range, &sch.Percentile, &sch.TimeZone, &sch.Currency,
+		&sch.Delivery, &recipients, &sch.Enabled, &lastRunAt, &lastError, &nextRunAt, &sch.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	sch.Recipients = recipients
+	sch.LastError = lastError.String
+	if lastRunAt.Valid {
+		sch.LastRunAt = &lastRunAt.Time
+	}
+	if nextRunAt.Valid {
+		sch.NextRunAt = &nextRunAt.Time
+	}
+
+	return &sch, nil
+}
+
+func (s *Storage) AddReportSchedule(ctx context.Context, sch ReportSchedule) (int, error) {
+	const op = "storage.postgresql.AddReportSchedule"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	query := `INSERT INTO report_schedules
+			(name, cron_expr, report_type, range_spec, percentile, time_zone, currency,
+				delivery, recipients, enabled, next_run_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			RETURNING schedule_id`
+
+	return audited(ctx, s.DB, op, schedulesAudit, AuditCreate, 0, func(tx *sql.Tx) (int, error) {
+		var id int
+		err := tx.QueryRowContext(ctx, query, sch.Name, sch.CronExpr, sch.ReportType, sch.Range, sch.Percentile, sch.TimeZone, sch.Currency,
+			sch.Delivery, pq.Array(sch.Recipients), sch.Enabled, sch.NextRunAt).Scan(&id)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		return id, nil
+	})
+}
+
+func (s *Storage) GetReportSchedule(ctx context.Context, id int) (*ReportSchedule, error) {
+	const op = "storage.postgresql.GetReportSchedule"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	query := `SELECT ` + scheduleColumns + ` FROM report_schedules WHERE schedule_id = $1`
+
+	sch, err := scanSchedule(s.DB.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrScheduleNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sch, nil
+}
+
+func (s *Storage) ListReportSchedules(ctx context.Context) ([]ReportSchedule, error) {
+	const op = "storage.postgresql.ListReportSchedules"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	query := `SELECT ` + scheduleColumns + ` FROM report_schedules ORDER BY schedule_id`
+
+	return s.queryReportSchedules(ctx, op, query)
+}
+
+// ClaimDueReportSchedules — забрать включенные расписания, время запуска которых
+// наступило. Захват атомарный: next_run_at сдвигается на now+lease в том же
+// запросе, а строки, уже захваченные другим экземпляром, пропускаются
+// (SKIP LOCKED), поэтому одно расписание не запустится дважды. Если экземпляр
+// упадет, не дойдя до MarkReportScheduleRun, расписание снова станет
+// доступным по истечении lease.
+func (s *Storage) ClaimDueReportSchedules(ctx context.Context, now time.Time, lease time.Duration) ([]ReportSchedule, error) {
+	const op = "storage.postgresql.ClaimDueReportSchedules"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	query := `UPDATE report_schedules
+			SET next_run_at = $2
+			WHERE schedule_id IN (
+				SELECT schedule_id FROM report_schedules
+				WHERE enabled AND next_run_at IS NOT NULL AND next_run_at <= $1
+				ORDER BY next_run_at
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING ` + scheduleColumns
+
+	return s.queryReportSchedules(ctx, op, query, now, now.Add(lease))
+}
+
+func (s *Storage) queryReportSchedules(ctx context.Context, op, query string, args ...any) ([]ReportSchedule, error) {
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var schedules []ReportSchedule
+	for rows.Next() {
+		sch, err := scanSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		schedules = append(schedules, *sch)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return schedules, nil
+}
+
+func (s *Storage) UpdateReportSchedule(ctx context.Context, id int, sch ReportSchedule) error {
+	const op = "storage.postgresql.UpdateReportSchedule"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	query := `UPDATE report_schedules
+			SET name = $2, cron_expr = $3, report_type = $4, range_spec = $5, percentile = $6, time_zone = $7,
+				currency = $8, delivery = $9, recipients = $10, enabled = $11, next_run_at = $12
+			WHERE schedule_id = $1`
+
+	_, err := audited(ctx, s.DB, op, schedulesAudit, AuditUpdate, id, func(tx *sql.Tx) (int, error) {
+		res, err := tx.ExecContext(ctx, query, id, sch.Name, sch.CronExpr, sch.ReportType, sch.Range, sch.Percentile, sch.TimeZone,
+			sch.Currency, sch.Delivery, pq.Array(sch.Recipients), sch.Enabled, sch.NextRunAt)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		return id, checkAffected(op, res, storage.ErrScheduleNotFound)
+	})
+	return err
+}
+
+// MarkReportScheduleRun — сохранить результат запуска и время следующего запуска
+func (s *Storage) MarkReportScheduleRun(ctx context.Context, id int, runAt time.Time, runErr error, nextRunAt *time.Time) error {
+	const op = "storage.postgresql.MarkReportScheduleRun"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	var lastError sql.NullString
+	if runErr != nil {
+		lastError = sql.NullString{String: runErr.Error(), Valid: true}
+	}
+
+	query := `UPDATE report_schedules
+			SET last_run_at = $2, last_error = $3, next_run_at = $4
+			WHERE schedule_id = $1`
+
+	res, err := s.DB.ExecContext(ctx, query, id, runAt, lastError, nextRunAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return checkAffected(op, res, storage.ErrScheduleNotFound)
+}
+
+func (s *Storage) DeleteReportSchedule(ctx context.Context, id int) error {
+	const op = "storage.postgresql.DeleteReportSchedule"
+
+	ctx, done := s.crud(ctx, op)
+	defer done()
+
+	_, err := audited(ctx, s.DB, op, schedulesAudit, AuditDelete, id, func(tx *sql.Tx) (int, error) {
+		res, err := tx.ExecContext(ctx, `DELETE FROM report_schedules WHERE schedule_id = $1`, id)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		return id, checkAffected(op, res, storage.ErrScheduleNotFound)
+	})
+	return err
+}
+
+// ====================================================================
+// HELPERS
+// ====================================================================
+
+func checkAffected(op string, res sql.Result, notFound error) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return notFound
+	}
+
+	return nil
+}
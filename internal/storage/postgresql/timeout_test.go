@@ -0,0 +1,174 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stallDriver — база, которая не отвечает: каждый запрос ждет отмены контекста
+type stallDriver struct{}
+
+type stallConn struct{}
+
+func init() {
+	sql.Register("stall", stallDriver{})
+}
+
+func (stallDriver) Open(string) (driver.Conn, error) { return stallConn{}, nil }
+
+func (stallConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("stall: prepared statements are not supported")
+}
+func (stallConn) Close() error              { return nil }
+func (stallConn) Begin() (driver.Tx, error) { return stallConn{}, nil }
+func (stallConn) Commit() error             { return nil }
+func (stallConn) Rollback() error           { return nil }
+
+func (stallConn) QueryContext(ctx context.Context, _ string, _ []driver.NamedValue) (driver.Rows, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (stallConn) ExecContext(ctx context.Context, _ string, _ []driver.NamedValue) (driver.Result, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+type observed struct {
+	mu    sync.Mutex
+	calls map[string]string // операция → класс
+}
+
+func (o *observed) observe(op, class string, _ time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.calls[op] = class
+}
+
+func stallStorage(t *testing.T, queryTimeout, analyticsTimeout time.Duration) (*Storage, *observed) {
+	t.Helper()
+
+	db, err := sql.Open("stall", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	o := &observed{calls: map[string]string{}}
+	return &Storage{DB: db, QueryTimeout: queryTimeout, AnalyticsTimeout: analyticsTimeout, Observe: o.observe}, o
+}
+
+func TestStartDeadline(t *testing.T) {
+	s := &Storage{QueryTimeout: 2 * time.Second, AnalyticsTimeout: time.Minute}
+
+	tests := []struct {
+		name   string
+		parent time.Duration // 0 — без дедлайна у вызывающего
+		start  func(ctx context.Context) (context.Context, func())
+		want   time.Duration // 0 — без дедлайна
+	}{
+		{name: "crud", start: func(ctx context.Context) (context.Context, func()) { return s.crud(ctx, "op") },
+			want: 2 * time.Second},
+		{name: "analytics", start: func(ctx context.Context) (context.Context, func()) { return s.analytics(ctx, "op") },
+			want: time.Minute},
+		// дедлайн вызывающего раньше таймаута класса — он и действует
+		{name: "earlier parent deadline", parent: time.Second,
+			start: func(ctx context.Context) (context.Context, func()) { return s.analytics(ctx, "op") }, want: time.Second},
+		{name: "no timeout", start: func(ctx context.Context) (context.Context, func()) {
+			return (&Storage{}).crud(ctx, "op")
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent := context.Background()
+			if tt.parent > 0 {
+				var cancel context.CancelFunc
+				parent, cancel = context.WithTimeout(parent, tt.parent)
+				defer cancel()
+			}
+
+			ctx, done := tt.start(parent)
+			deadline, ok := ctx.Deadline()
+			if ok != (tt.want > 0) {
+				t.Fatalf("deadline set = %v, want %v", ok, tt.want > 0)
+			}
+			if left := time.Until(deadline); ok && (left > tt.want || left < tt.want-time.Second/2) {
+				t.Errorf("deadline in %v, want about %v", left, tt.want)
+			}
+
+			// done освобождает контекст операции
+			done()
+			if ctx.Err() == nil {
+				t.Error("context is still active after done()")
+			}
+		})
+	}
+}
+
+func TestQueryTimeouts(t *testing.T) {
+	const queryTimeout, analyticsTimeout = 50 * time.Millisecond, 300 * time.Millisecond
+	s, o := stallStorage(t, queryTimeout, analyticsTimeout)
+	start, end := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		op    string
+		class string
+		want  time.Duration
+		call  func(ctx context.Context) error
+	}{
+		{name: "get", op: "storage.postgresql.GetProduct", class: ClassCRUD, want: queryTimeout,
+			call: func(ctx context.Context) error { _, err := s.GetProduct(ctx, 1); return err }},
+		{name: "list", op: "storage.postgresql.ListOrders", class: ClassCRUD, want: queryTimeout,
+			call: func(ctx context.Context) error { _, err := s.ListOrders(ctx, false); return err }},
+		{name: "write", op: "storage.postgresql.AddOrder", class: ClassCRUD, want: queryTimeout,
+			call: func(ctx context.Context) error {
+				_, err := s.AddOrder(ctx, 1, start, "completed", "Карта", BaseCurrency)
+				return err
+			}},
+		{name: "report", op: "storage.postgresql.analytics.OrdersMedian", class: ClassAnalytics, want: analyticsTimeout,
+			call: func(ctx context.Context) error { _, err := s.OrdersMedian(ctx, start, end, ""); return err }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			began := time.Now()
+			err := tt.call(context.Background())
+			elapsed := time.Since(began)
+
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("error = %v, want the deadline of the %s class", err, tt.class)
+			}
+			if elapsed < tt.want || elapsed > tt.want+2*time.Second {
+				t.Errorf("returned after %v, want about %v", elapsed, tt.want)
+			}
+			o.mu.Lock()
+			defer o.mu.Unlock()
+			if got := o.calls[tt.op]; got != tt.class {
+				t.Errorf("observed %s as %q, want %q", tt.op, got, tt.class)
+			}
+		})
+	}
+}
+
+func TestQueryStopsWithCaller(t *testing.T) {
+	// таймаут отчета длинный, но клиент ушел раньше
+	s, _ := stallStorage(t, time.Minute, time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	began := time.Now()
+	_, err := s.GenerateSalesReport(ctx, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC), "")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("GenerateSalesReport() error = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(began); elapsed > 2*time.Second {
+		t.Errorf("returned after %v, want it to stop with the caller", elapsed)
+	}
+}
@@ -1 +1,23 @@
 package storage
+
+import "errors"
+
+var (
+	ErrScheduleNotFound    = errors.New("report schedule not found")
+	ErrMissingExchangeRate = errors.New("missing exchange rate")
+	ErrOrderNotFound       = errors.New("order not found")
+	ErrOrderItemNotFound   = errors.New("order item not found")
+	ErrProductNotFound     = errors.New("product not found")
+	ErrCustomerNotFound    = errors.New("customer not found")
+	ErrInvalidReturn       = errors.New("invalid return")
+	ErrPromotionNotFound   = errors.New("promotion not found")
+	ErrInvalidOrder        = errors.New("invalid order")
+	ErrAPIKeyNotFound      = errors.New("api key not found")
+	ErrCategoryNotFound    = errors.New("category not found")
+	ErrCategoryInUse       = errors.New("category has products")
+	ErrVersionConflict     = errors.New("version conflict")
+	ErrImportJobNotFound   = errors.New("import job not found")
+	ErrImportJobFinished   = errors.New("import job finished")
+	ErrAmbiguousMatch      = errors.New("ambiguous match")
+	ErrUnknownExport       = errors.New("unknown export entity")
+)
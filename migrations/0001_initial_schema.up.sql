@@ -0,0 +1,574 @@
+-- ====================================================================
+-- 0001: СХЕМА БАЗЫ ДАННЫХ ПРОДАЖ
+-- ====================================================================
+
+-- ====================================================================
+-- СОЗДАНИЕ ТАБЛИЦ
+-- ====================================================================
+
+-- Таблица категорий товаров
+CREATE TABLE categories (
+                            category_id SERIAL PRIMARY KEY,
+                            category_name VARCHAR(100) NOT NULL,
+                            description TEXT,
+                            version INTEGER NOT NULL DEFAULT 1,
+                            updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Таблица товаров
+CREATE TABLE products (
+                          product_id SERIAL PRIMARY KEY,
+                          product_name VARCHAR(200) NOT NULL,
+                          category_id INTEGER REFERENCES categories(category_id),
+                          price NUMERIC(10, 2) NOT NULL,
+                          cost NUMERIC(10, 2) NOT NULL,
+                          stock_quantity INTEGER DEFAULT 0,
+                          currency CHAR(3) NOT NULL DEFAULT 'RUB',
+                          version INTEGER NOT NULL DEFAULT 1,
+                          updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                          deleted_at TIMESTAMPTZ
+);
+
+-- Таблица покупателей
+CREATE TABLE customers (
+                           customer_id SERIAL PRIMARY KEY,
+                           first_name VARCHAR(100) NOT NULL,
+                           last_name VARCHAR(100) NOT NULL,
+                           email VARCHAR(150) UNIQUE,
+                           phone VARCHAR(20),
+                           city VARCHAR(100),
+                           registration_date DATE DEFAULT CURRENT_DATE,
+                           version INTEGER NOT NULL DEFAULT 1,
+                           updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                           deleted_at TIMESTAMPTZ
+);
+
+-- Таблица заказов
+CREATE TABLE orders (
+                        order_id SERIAL PRIMARY KEY,
+                        customer_id INTEGER REFERENCES customers(customer_id),
+                        order_date TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                        status VARCHAR(50) DEFAULT 'completed',
+                        total_amount NUMERIC(12, 2),
+                        payment_method VARCHAR(50),
+                        currency CHAR(3) NOT NULL DEFAULT 'RUB',
+                        version INTEGER NOT NULL DEFAULT 1,
+                        updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                        deleted_at TIMESTAMPTZ,
+                        external_ref VARCHAR(100)
+);
+
+-- Таблица промоакций: правила скидок, которые сервер применяет при создании заказа.
+-- kind: percent_off | fixed_off | buy_x_get_y; область действия — весь заказ,
+-- категория (category_id) или товар (product_id); coupon_code — только по купону.
+-- value: процент для percent_off, сумма в валюте currency для fixed_off.
+CREATE TABLE promotions (
+                            promotion_id SERIAL PRIMARY KEY,
+                            name VARCHAR(200) NOT NULL,
+                            kind VARCHAR(20) NOT NULL CHECK (kind IN ('percent_off', 'fixed_off', 'buy_x_get_y')),
+                            value NUMERIC(12, 2) NOT NULL DEFAULT 0,
+                            currency CHAR(3) NOT NULL DEFAULT 'RUB',
+                            buy_quantity INTEGER NOT NULL DEFAULT 0,
+                            get_quantity INTEGER NOT NULL DEFAULT 0,
+                            category_id INTEGER REFERENCES categories(category_id),
+                            product_id INTEGER REFERENCES products(product_id),
+                            coupon_code VARCHAR(50) UNIQUE,
+                            starts_at TIMESTAMPTZ,
+                            ends_at TIMESTAMPTZ,
+                            usage_limit INTEGER,
+                            usage_count INTEGER NOT NULL DEFAULT 0,
+                            active BOOLEAN NOT NULL DEFAULT TRUE,
+                            created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Таблица позиций в заказах
+CREATE TABLE order_items (
+                             order_item_id SERIAL PRIMARY KEY,
+                             order_id INTEGER REFERENCES orders(order_id),
+                             product_id INTEGER REFERENCES products(product_id),
+                             quantity INTEGER NOT NULL,
+                             price NUMERIC(10, 2) NOT NULL,
+                             discount NUMERIC(5, 2) DEFAULT 0,
+                             discount_amount NUMERIC(12, 2) NOT NULL DEFAULT 0,
+                             promotion_id INTEGER REFERENCES promotions(promotion_id),
+                             updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Таблица возвратов по заказам
+CREATE TABLE returns (
+                         return_id SERIAL PRIMARY KEY,
+                         order_id INTEGER NOT NULL REFERENCES orders(order_id),
+                         return_date TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                         reason TEXT,
+                         refund_amount NUMERIC(12, 2) NOT NULL DEFAULT 0,
+                         restocked BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+-- Таблица возвращенных позиций: частичный возврат — quantity меньше купленного
+CREATE TABLE return_items (
+                              return_item_id SERIAL PRIMARY KEY,
+                              return_id INTEGER NOT NULL REFERENCES returns(return_id),
+                              order_item_id INTEGER NOT NULL REFERENCES order_items(order_item_id),
+                              quantity INTEGER NOT NULL CHECK (quantity > 0),
+                              refund_amount NUMERIC(12, 2) NOT NULL CHECK (refund_amount >= 0),
+                              reason TEXT
+);
+
+-- Таблица курсов валют: 1 единица currency стоит rate рублей на дату rate_date.
+-- Рубль — учетная валюта, для него курс всегда 1 и в таблице не хранится.
+CREATE TABLE exchange_rates (
+                                rate_date DATE NOT NULL,
+                                currency CHAR(3) NOT NULL,
+                                rate NUMERIC(18, 8) NOT NULL CHECK (rate > 0),
+                                PRIMARY KEY (currency, rate_date)
+);
+
+-- Таблица расписаний регулярных отчетов
+CREATE TABLE report_schedules (
+                                  schedule_id SERIAL PRIMARY KEY,
+                                  name VARCHAR(200) NOT NULL,
+                                  cron_expr VARCHAR(100) NOT NULL,
+                                  report_type VARCHAR(50) NOT NULL,
+                                  range_spec VARCHAR(50) NOT NULL,
+                                  percentile INTEGER NOT NULL DEFAULT 0,
+                                  time_zone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+                                  currency CHAR(3) NOT NULL DEFAULT '',
+                                  delivery VARCHAR(20) NOT NULL,
+                                  recipients TEXT[],
+                                  enabled BOOLEAN NOT NULL DEFAULT TRUE,
+                                  last_run_at TIMESTAMPTZ,
+                                  last_error TEXT,
+                                  next_run_at TIMESTAMPTZ,
+                                  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Таблица API-ключей: хранится только SHA-256 ключа, prefix — открытая часть
+-- ключа для поиска записи. Отозванные ключи остаются для истории.
+CREATE TABLE api_keys (
+                          key_id SERIAL PRIMARY KEY,
+                          name VARCHAR(200) NOT NULL,
+                          prefix VARCHAR(32) NOT NULL UNIQUE,
+                          key_hash CHAR(64) NOT NULL,
+                          role VARCHAR(50) NOT NULL,
+                          created_by VARCHAR(200),
+                          created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                          expires_at TIMESTAMPTZ,
+                          last_used_at TIMESTAMPTZ,
+                          revoked_at TIMESTAMPTZ
+);
+
+-- Журнал изменений: кто, когда и как изменил сущность.
+-- before_data/after_data — состояние сущности до и после изменения.
+CREATE TABLE audit_log (
+                           audit_id BIGSERIAL PRIMARY KEY,
+                           entity_type VARCHAR(50) NOT NULL,
+                           entity_id INTEGER,
+                           action VARCHAR(20) NOT NULL,
+                           actor VARCHAR(200) NOT NULL,
+                           request_id VARCHAR(100),
+                           before_data JSONB,
+                           after_data JSONB,
+                           created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Ответы на POST с заголовком Idempotency-Key. Ключ уникален в пределах
+-- клиента (scope); status_code IS NULL, пока первый запрос выполняется.
+CREATE TABLE idempotency_keys (
+                                  scope VARCHAR(200) NOT NULL,
+                                  idem_key VARCHAR(255) NOT NULL,
+                                  method VARCHAR(10) NOT NULL,
+                                  path TEXT NOT NULL,
+                                  request_hash CHAR(64) NOT NULL,
+                                  status_code INTEGER,
+                                  response_headers JSONB,
+                                  response_body BYTEA,
+                                  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                                  expires_at TIMESTAMPTZ NOT NULL,
+                                  PRIMARY KEY (scope, idem_key)
+);
+
+-- Загрузки исторических заказов из CSV/JSONL. last_line — последняя строка
+-- файла, записанная в базу: прерванная загрузка продолжается с нее.
+CREATE TABLE import_jobs (
+                             job_id SERIAL PRIMARY KEY,
+                             source VARCHAR(500) NOT NULL,
+                             format VARCHAR(10) NOT NULL CHECK (format IN ('csv', 'jsonl')),
+                             status VARCHAR(20) NOT NULL DEFAULT 'running' CHECK (status IN ('running', 'completed', 'failed')),
+                             last_line INTEGER NOT NULL DEFAULT 0,
+                             orders_imported INTEGER NOT NULL DEFAULT 0,
+                             orders_skipped INTEGER NOT NULL DEFAULT 0,
+                             orders_failed INTEGER NOT NULL DEFAULT 0,
+                             error TEXT,
+                             started_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                             updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                             finished_at TIMESTAMPTZ
+);
+
+-- Дневная сводка продаж: дата × категория × способ оплаты × город.
+-- Дни считаются по UTC, поэтому аналитика в других часовых поясах читает orders.
+-- Строки с category_id IS NULL содержат показатели уровня заказа
+-- (количество, сумма, min/max чека), строки с категорией — показатели позиций.
+CREATE TABLE daily_sales_rollup (
+                                    sale_date DATE NOT NULL,
+                                    category_id INTEGER,
+                                    payment_method VARCHAR(50) NOT NULL DEFAULT '',
+                                    city VARCHAR(100) NOT NULL DEFAULT '',
+                                    currency CHAR(3) NOT NULL DEFAULT 'RUB',
+                                    order_count INTEGER NOT NULL DEFAULT 0,
+                                    total_amount NUMERIC(14, 2) NOT NULL DEFAULT 0,
+                                    min_check NUMERIC(12, 2),
+                                    max_check NUMERIC(12, 2),
+                                    items_quantity INTEGER NOT NULL DEFAULT 0,
+                                    items_revenue NUMERIC(14, 2) NOT NULL DEFAULT 0
+);
+
+-- Очередь дней, сводку которых нужно пересчитать. Триггеры только отмечают
+-- день, пересчет идет позже (drain_daily_sales_rollup). Уникального ключа нет
+-- намеренно: с ним параллельные транзакции ждали бы друг друга на одном дне.
+CREATE TABLE daily_sales_rollup_dirty (
+                                          sale_date DATE NOT NULL,
+                                          marked_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- ====================================================================
+-- ПОДДЕРЖКА ДНЕВНОЙ СВОДКИ
+-- ====================================================================
+
+-- День заказа по UTC — единая граница суток для сводки
+CREATE FUNCTION utc_day(ts TIMESTAMPTZ) RETURNS DATE AS $$
+SELECT (ts AT TIME ZONE 'UTC')::DATE;
+$$ LANGUAGE sql IMMUTABLE;
+
+-- Курс валюты в рублях на день: последний известный курс не позже p_day
+CREATE FUNCTION exchange_rate(p_currency CHAR(3), p_day DATE) RETURNS NUMERIC AS $$
+SELECT CASE
+           WHEN p_currency = 'RUB' THEN 1::NUMERIC
+           ELSE (SELECT rate
+                 FROM exchange_rates
+                 WHERE currency = p_currency AND rate_date <= p_day
+                 ORDER BY rate_date DESC
+                 LIMIT 1)
+           END;
+$$ LANGUAGE sql STABLE;
+
+-- Пересчитать сумму в валюту отчета по курсу на день заказа.
+-- Пустая валюта отчета означает «без пересчета». NULL — курса на эту дату нет.
+CREATE FUNCTION convert_amount(p_amount NUMERIC, p_from CHAR(3), p_to TEXT, p_day DATE) RETURNS NUMERIC AS $$
+SELECT CASE
+           WHEN p_to IS NULL OR p_to = '' OR p_from = p_to THEN p_amount
+           ELSE p_amount * exchange_rate(p_from, p_day) / exchange_rate(p_to::CHAR(3), p_day)
+           END;
+$$ LANGUAGE sql STABLE;
+
+-- Пересчитать сводку за диапазон дней. Дни блокируются advisory-локом,
+-- чтобы параллельные пересчеты не вставили дубликаты одного дня. Вызывается
+-- из drain_daily_sales_rollup и вручную, а не из триггеров записи.
+CREATE FUNCTION refresh_daily_sales_rollup(p_from DATE, p_to DATE) RETURNS void AS $$
+DECLARE
+    d DATE;
+BEGIN
+    IF p_from IS NULL OR p_to IS NULL THEN
+        RETURN;
+    END IF;
+
+    FOR d IN SELECT generate_series(p_from, p_to, INTERVAL '1 day')::DATE LOOP
+        PERFORM pg_advisory_xact_lock(872501, d - DATE '2000-01-01');
+    END LOOP;
+
+    DELETE FROM daily_sales_rollup WHERE sale_date BETWEEN p_from AND p_to;
+
+    INSERT INTO daily_sales_rollup (sale_date, category_id, payment_method, city, currency,
+                                    order_count, total_amount, min_check, max_check)
+    SELECT utc_day(o.order_date), NULL, COALESCE(o.payment_method, ''), COALESCE(c.city, ''), o.currency,
+           COUNT(*), COALESCE(SUM(o.total_amount), 0), MIN(o.total_amount), MAX(o.total_amount)
+    FROM orders o
+             LEFT JOIN customers c ON c.customer_id = o.customer_id
+    WHERE o.order_date >= (p_from::TIMESTAMP AT TIME ZONE 'UTC')
+      AND o.order_date < ((p_to + 1)::TIMESTAMP AT TIME ZONE 'UTC')
+      AND o.deleted_at IS NULL
+    GROUP BY 1, 3, 4, 5;
+
+    -- discount_amount — вся скидка позиции в деньгах: и процентная, и по
+    -- промоакции, поэтому выручка позиции совпадает с ее долей в total_amount
+    INSERT INTO daily_sales_rollup (sale_date, category_id, payment_method, city, currency,
+                                    order_count, items_quantity, items_revenue)
+    SELECT utc_day(o.order_date), p.category_id, COALESCE(o.payment_method, ''), COALESCE(c.city, ''), o.currency,
+           COUNT(DISTINCT o.order_id), SUM(oi.quantity),
+           SUM(oi.price * oi.quantity - oi.discount_amount)
+    FROM order_items oi
+             JOIN orders o ON o.order_id = oi.order_id
+             JOIN products p ON p.product_id = oi.product_id
+             LEFT JOIN customers c ON c.customer_id = o.customer_id
+    WHERE o.order_date >= (p_from::TIMESTAMP AT TIME ZONE 'UTC')
+      AND o.order_date < ((p_to + 1)::TIMESTAMP AT TIME ZONE 'UTC')
+      AND o.deleted_at IS NULL
+      AND p.category_id IS NOT NULL
+    GROUP BY 1, 2, 3, 4, 5;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Пересчитать дни из очереди в диапазоне [p_from, p_to] (NULL — без границы);
+-- возвращает число пересчитанных дней. Записи очереди удаляются в той же
+-- транзакции, что и пересчет: при ошибке день останется в очереди.
+CREATE FUNCTION drain_daily_sales_rollup(p_from DATE, p_to DATE) RETURNS INTEGER AS $$
+DECLARE
+    v_days DATE[];
+    d DATE;
+BEGIN
+    WITH taken AS (
+        DELETE FROM daily_sales_rollup_dirty
+        WHERE (p_from IS NULL OR sale_date >= p_from)
+          AND (p_to IS NULL OR sale_date <= p_to)
+        RETURNING sale_date
+    )
+    SELECT array_agg(DISTINCT sale_date ORDER BY sale_date) INTO v_days FROM taken;
+
+    IF v_days IS NULL THEN
+        RETURN 0;
+    END IF;
+
+    FOREACH d IN ARRAY v_days LOOP
+        PERFORM refresh_daily_sales_rollup(d, d);
+    END LOOP;
+
+    RETURN array_length(v_days, 1);
+END;
+$$ LANGUAGE plpgsql;
+
+-- Триггеры уровня оператора: один оператор (пакетная вставка, импорт,
+-- массовое обновление) отмечает каждый затронутый день один раз.
+-- UPDATE отмечает день, только если изменились поля, которые входят в сводку:
+-- касания updated_at и version ее не меняют.
+CREATE FUNCTION orders_rollup_mark() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO daily_sales_rollup_dirty (sale_date)
+        SELECT DISTINCT utc_day(order_date) FROM new_rows;
+    ELSIF TG_OP = 'DELETE' THEN
+        INSERT INTO daily_sales_rollup_dirty (sale_date)
+        SELECT DISTINCT utc_day(order_date) FROM old_rows;
+    ELSE
+        INSERT INTO daily_sales_rollup_dirty (sale_date)
+        SELECT DISTINCT v.sale_date
+        FROM old_rows o
+                 JOIN new_rows n ON n.order_id = o.order_id
+                 CROSS JOIN LATERAL (VALUES (utc_day(o.order_date)), (utc_day(n.order_date))) v(sale_date)
+        WHERE (o.order_date, o.customer_id, o.total_amount, o.payment_method, o.currency, o.deleted_at)
+                  IS DISTINCT FROM
+              (n.order_date, n.customer_id, n.total_amount, n.payment_method, n.currency, n.deleted_at);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- День позиции — день ее заказа. Позиции, удаленные вместе с заказом,
+-- отмечает триггер orders.
+CREATE FUNCTION order_items_rollup_mark() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO daily_sales_rollup_dirty (sale_date)
+        SELECT DISTINCT utc_day(o.order_date)
+        FROM orders o
+        WHERE o.order_id IN (SELECT order_id FROM new_rows);
+    ELSIF TG_OP = 'DELETE' THEN
+        INSERT INTO daily_sales_rollup_dirty (sale_date)
+        SELECT DISTINCT utc_day(o.order_date)
+        FROM orders o
+        WHERE o.order_id IN (SELECT order_id FROM old_rows);
+    ELSE
+        INSERT INTO daily_sales_rollup_dirty (sale_date)
+        SELECT DISTINCT utc_day(o.order_date)
+        FROM orders o
+        WHERE o.order_id IN (
+            SELECT v.order_id
+            FROM old_rows oi
+                     JOIN new_rows ni ON ni.order_item_id = oi.order_item_id
+                     CROSS JOIN LATERAL (VALUES (oi.order_id), (ni.order_id)) v(order_id)
+            WHERE (oi.order_id, oi.product_id, oi.quantity, oi.price, oi.discount, oi.discount_amount)
+                      IS DISTINCT FROM
+                  (ni.order_id, ni.product_id, ni.quantity, ni.price, ni.discount, ni.discount_amount)
+        );
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Город покупателя и категория товара хранятся в справочниках, а сводка
+-- группирует по ним: их изменение отмечает дни всех заказов покупателя
+-- или всех заказов с товаром.
+CREATE FUNCTION customers_rollup_mark() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO daily_sales_rollup_dirty (sale_date)
+    SELECT DISTINCT utc_day(ord.order_date)
+    FROM old_rows o
+             JOIN new_rows n ON n.customer_id = o.customer_id
+             JOIN orders ord ON ord.customer_id = n.customer_id
+    WHERE o.city IS DISTINCT FROM n.city
+      AND ord.deleted_at IS NULL;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION products_rollup_mark() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO daily_sales_rollup_dirty (sale_date)
+    SELECT DISTINCT utc_day(ord.order_date)
+    FROM old_rows o
+             JOIN new_rows n ON n.product_id = o.product_id
+             JOIN order_items oi ON oi.product_id = n.product_id
+             JOIN orders ord ON ord.order_id = oi.order_id
+    WHERE o.category_id IS DISTINCT FROM n.category_id
+      AND ord.deleted_at IS NULL;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_orders_rollup_insert
+    AFTER INSERT ON orders
+    REFERENCING NEW TABLE AS new_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION orders_rollup_mark();
+
+CREATE TRIGGER trg_orders_rollup_update
+    AFTER UPDATE ON orders
+    REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION orders_rollup_mark();
+
+CREATE TRIGGER trg_orders_rollup_delete
+    AFTER DELETE ON orders
+    REFERENCING OLD TABLE AS old_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION orders_rollup_mark();
+
+CREATE TRIGGER trg_order_items_rollup_insert
+    AFTER INSERT ON order_items
+    REFERENCING NEW TABLE AS new_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION order_items_rollup_mark();
+
+CREATE TRIGGER trg_order_items_rollup_update
+    AFTER UPDATE ON order_items
+    REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION order_items_rollup_mark();
+
+CREATE TRIGGER trg_order_items_rollup_delete
+    AFTER DELETE ON order_items
+    REFERENCING OLD TABLE AS old_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION order_items_rollup_mark();
+
+CREATE TRIGGER trg_customers_rollup_update
+    AFTER UPDATE ON customers
+    REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION customers_rollup_mark();
+
+CREATE TRIGGER trg_products_rollup_update
+    AFTER UPDATE ON products
+    REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION products_rollup_mark();
+
+-- ====================================================================
+-- ОТМЕТКА ВРЕМЕНИ ИЗМЕНЕНИЯ ДЛЯ ИНКРЕМЕНТАЛЬНОЙ ВЫГРУЗКИ
+-- ====================================================================
+
+-- updated_at меняется при любом UPDATE, в том числе при мягком удалении,
+-- поэтому выгрузка с updated_since видит и удаленные записи
+CREATE FUNCTION touch_updated_at() RETURNS trigger AS $$
+BEGIN
+    NEW.updated_at = NOW();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_categories_touch
+    BEFORE UPDATE ON categories
+    FOR EACH ROW EXECUTE FUNCTION touch_updated_at();
+
+CREATE TRIGGER trg_products_touch
+    BEFORE UPDATE ON products
+    FOR EACH ROW EXECUTE FUNCTION touch_updated_at();
+
+CREATE TRIGGER trg_customers_touch
+    BEFORE UPDATE ON customers
+    FOR EACH ROW EXECUTE FUNCTION touch_updated_at();
+
+CREATE TRIGGER trg_orders_touch
+    BEFORE UPDATE ON orders
+    FOR EACH ROW EXECUTE FUNCTION touch_updated_at();
+
+CREATE TRIGGER trg_order_items_touch
+    BEFORE UPDATE ON order_items
+    FOR EACH ROW EXECUTE FUNCTION touch_updated_at();
+
+-- ====================================================================
+-- СОЗДАНИЕ ИНДЕКСОВ для оптимизации запросов
+-- ====================================================================
+
+CREATE INDEX idx_orders_date ON orders(order_date);
+CREATE INDEX idx_orders_customer ON orders(customer_id);
+CREATE INDEX idx_order_items_order ON order_items(order_id);
+CREATE INDEX idx_order_items_product ON order_items(product_id);
+CREATE INDEX idx_products_category ON products(category_id);
+CREATE INDEX idx_products_deleted ON products(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_customers_deleted ON customers(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_orders_deleted ON orders(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE UNIQUE INDEX idx_orders_external_ref ON orders(external_ref) WHERE external_ref IS NOT NULL;
+CREATE INDEX idx_categories_updated ON categories(updated_at);
+CREATE INDEX idx_products_updated ON products(updated_at);
+CREATE INDEX idx_customers_updated ON customers(updated_at);
+CREATE INDEX idx_orders_updated ON orders(updated_at);
+CREATE INDEX idx_order_items_updated ON order_items(updated_at);
+CREATE INDEX idx_order_items_promotion ON order_items(promotion_id) WHERE promotion_id IS NOT NULL;
+CREATE INDEX idx_returns_order ON returns(order_id);
+CREATE INDEX idx_return_items_return ON return_items(return_id);
+CREATE INDEX idx_return_items_order_item ON return_items(order_item_id);
+CREATE INDEX idx_daily_sales_rollup_date ON daily_sales_rollup(sale_date);
+CREATE INDEX idx_daily_sales_rollup_dirty_date ON daily_sales_rollup_dirty(sale_date);
+CREATE INDEX idx_audit_log_entity ON audit_log(entity_type, entity_id, created_at);
+CREATE INDEX idx_audit_log_actor ON audit_log(actor, created_at);
+CREATE INDEX idx_idempotency_keys_expires ON idempotency_keys(expires_at);
+CREATE INDEX idx_report_schedules_next_run ON report_schedules(next_run_at) WHERE enabled;
+
+-- ====================================================================
+-- ПОЛЕЗНЫЕ ПРЕДСТАВЛЕНИЯ (VIEWS)
+-- ====================================================================
+
+-- Детальная информация о продажах
+CREATE VIEW sales_detailed AS
+SELECT
+    o.order_id,
+    o.order_date,
+    o.status,
+    o.payment_method,
+    c.customer_id,
+    c.first_name || ' ' || c.last_name AS customer_name,
+    c.city,
+    p.product_id,
+    p.product_name,
+    cat.category_name,
+    oi.quantity,
+    oi.price,
+    oi.discount,
+    (oi.price * oi.quantity - oi.discount_amount) AS item_total,
+    (p.price - p.cost) * oi.quantity AS profit
+FROM orders o
+         JOIN customers c ON o.customer_id = c.customer_id
+         JOIN order_items oi ON o.order_id = oi.order_id
+         JOIN products p ON oi.product_id = p.product_id
+         JOIN categories cat ON p.category_id = cat.category_id
+WHERE o.status = 'completed'
+  AND o.deleted_at IS NULL;
+
+COMMENT ON TABLE categories IS 'Категории товаров';
+COMMENT ON TABLE products IS 'Товары с ценами и остатками';
+COMMENT ON TABLE customers IS 'Покупатели';
+COMMENT ON TABLE orders IS 'Заказы покупателей';
+COMMENT ON TABLE order_items IS 'Позиции в заказах';
+COMMENT ON TABLE promotions IS 'Промоакции и купоны';
+COMMENT ON TABLE returns IS 'Возвраты по заказам';
+COMMENT ON TABLE return_items IS 'Возвращенные позиции заказов';
+COMMENT ON TABLE exchange_rates IS 'Курсы валют к рублю по датам';
+COMMENT ON TABLE daily_sales_rollup IS 'Дневная сводка продаж, поддерживается триггерами';
+COMMENT ON TABLE report_schedules IS 'Расписания регулярных отчетов';
+COMMENT ON TABLE api_keys IS 'API-ключи для доступа к сервису';
+COMMENT ON TABLE audit_log IS 'Журнал изменений сущностей';
+COMMENT ON TABLE idempotency_keys IS 'Сохраненные ответы на запросы с Idempotency-Key';
+COMMENT ON TABLE import_jobs IS 'Загрузки исторических заказов';
+COMMENT ON COLUMN orders.external_ref IS 'Номер заказа во внешней системе, из которой он загружен';
+COMMENT ON VIEW sales_detailed IS 'Детальная информация о продажах с расчетными полями';
@@ -0,0 +1,18 @@
+package migrations
+
+import "embed"
+
+// ====================================================================
+// MIGRATIONS - SQL-миграции схемы, встроенные в бинарник
+// ====================================================================
+
+// FS — файлы миграций NNNN_name.up.sql и NNNN_name.down.sql. Примененная
+// миграция не меняется: изменение схемы — следующая по номеру миграция.
+//
+//go:embed *.sql
+var FS embed.FS
+
+// Catalog — категории и товары тестовых данных, загружаются командой seed
+//
+//go:embed seed/catalog.sql
+var Catalog string
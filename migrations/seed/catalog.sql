@@ -0,0 +1,59 @@
+-- ====================================================================
+-- КАТАЛОГ ТЕСТОВЫХ ДАННЫХ: salesTracker seed
+-- Покупатели, заказы и позиции генерирует internal/seed. Номера заданы
+-- явно: товары ссылаются на категории по номеру, а сид не должен зависеть
+-- от состояния последовательностей
+-- ====================================================================
+
+-- Категории
+INSERT INTO categories (category_id, category_name, description) VALUES
+                                                        (1, 'Электроника', 'Электронные устройства и гаджеты'),
+                                                        (2, 'Одежда', 'Мужская и женская одежда'),
+                                                        (3, 'Продукты питания', 'Продукты и напитки'),
+                                                        (4, 'Книги', 'Художественная и техническая литература'),
+                                                        (5, 'Спорт и отдых', 'Спортивные товары и туристическое снаряжение'),
+                                                        (6, 'Дом и сад', 'Товары для дома и садоводства');
+
+-- Товары
+INSERT INTO products (product_id, product_name, category_id, price, cost, stock_quantity) VALUES
+-- Электроника
+(1, 'Смартфон Samsung Galaxy S23', 1, 65000.00, 50000.00, 45),
+(2, 'Ноутбук Lenovo ThinkPad', 1, 85000.00, 65000.00, 20),
+(3, 'Наушники Sony WH-1000XM5', 1, 28000.00, 20000.00, 60),
+(4, 'Планшет iPad Air', 1, 55000.00, 42000.00, 30),
+(5, 'Умные часы Apple Watch', 1, 35000.00, 27000.00, 40),
+
+-- Одежда
+(6, 'Джинсы Levis 501', 2, 6500.00, 3500.00, 100),
+(7, 'Куртка зимняя North Face', 2, 15000.00, 9000.00, 45),
+(8, 'Футболка Nike', 2, 2500.00, 1200.00, 150),
+(9, 'Кроссовки Adidas Ultraboost', 2, 12000.00, 7000.00, 80),
+(10, 'Платье вечернее', 2, 8500.00, 4500.00, 35),
+
+-- Продукты питания
+(11, 'Кофе Lavazza 1кг', 3, 1800.00, 1200.00, 200),
+(12, 'Шоколад Lindt 100г', 3, 450.00, 250.00, 300),
+(13, 'Оливковое масло 1л', 3, 850.00, 500.00, 120),
+(14, 'Чай зеленый 100г', 3, 350.00, 200.00, 250),
+(15, 'Мед натуральный 500г', 3, 650.00, 400.00, 100),
+
+-- Книги
+(16, 'Мастер и Маргарита', 4, 550.00, 300.00, 80),
+(17, 'SQL. Сборник рецептов', 4, 2500.00, 1500.00, 40),
+(18, 'Python для анализа данных', 4, 3200.00, 2000.00, 50),
+(19, 'Атлас мира', 4, 1800.00, 1000.00, 30),
+(20, '1984 Джордж Оруэлл', 4, 480.00, 250.00, 90),
+
+-- Спорт и отдых
+(21, 'Велосипед горный', 5, 35000.00, 25000.00, 15),
+(22, 'Палатка туристическая 4-местная', 5, 12000.00, 8000.00, 25),
+(23, 'Коврик для йоги', 5, 1500.00, 800.00, 70),
+(24, 'Гантели 10кг пара', 5, 3500.00, 2000.00, 40),
+(25, 'Рюкзак туристический 60л', 5, 8500.00, 5500.00, 35),
+
+-- Дом и сад
+(26, 'Пылесос Dyson V15', 6, 45000.00, 35000.00, 20),
+(27, 'Кофеварка Delonghi', 6, 18000.00, 12000.00, 30),
+(28, 'Набор посуды 12 предметов', 6, 5500.00, 3500.00, 50),
+(29, 'Лейка садовая 10л', 6, 650.00, 350.00, 80),
+(30, 'Секатор профессиональный', 6, 1800.00, 1000.00, 60);